@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
@@ -10,7 +13,16 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 	"github.com/yf-web/backend/internal/api"
+	"github.com/yf-web/backend/internal/canary"
 	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/events"
+	"github.com/yf-web/backend/internal/importer"
+	"github.com/yf-web/backend/internal/models"
+	"github.com/yf-web/backend/internal/notifier"
+	"github.com/yf-web/backend/internal/presence"
+	"github.com/yf-web/backend/internal/publisher"
+	"github.com/yf-web/backend/internal/reconcile"
+	"github.com/yf-web/backend/internal/scheduler"
 	"github.com/yf-web/backend/internal/zk"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -57,8 +69,88 @@ func main() {
 		logger.Warn("failed to ensure zk cluster path", zap.Error(err))
 	}
 
+	// 子命令：从 ZooKeeper 导入已有配置后退出
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(database, zkClient, logger, os.Args[2:]))
+	}
+
+	// 实时事件：广播配置变更、ZooKeeper 连接状态与 Agent 上下线，由 /api/v1/events 推送
+	hub := events.New(events.Config{
+		Backlog: viper.GetInt("events.backlog"),
+		Buffer:  viper.GetInt("events.buffer"),
+	}, logger)
+	zkClient.SetStateListener(func(state string, connected bool) {
+		hub.Publish(events.TypeZKState, events.ZKState{State: state, Connected: connected})
+	})
+	database.OnChange(hub.PublishChange)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 通知：将配置事件投递给订阅的 webhook 与邮箱，失败按退避间隔重试
+	notify := notifier.New(database, notifier.Config{
+		Interval:    viper.GetDuration("notify.interval"),
+		MaxAttempts: viper.GetInt("notify.max_attempts"),
+		MaxBackoff:  viper.GetDuration("notify.max_backoff"),
+		Timeout:     viper.GetDuration("notify.webhook_timeout"),
+		SMTP: notifier.SMTPConfig{
+			Host:     viper.GetString("notify.smtp.host"),
+			Port:     viper.GetInt("notify.smtp.port"),
+			Username: viper.GetString("notify.smtp.username"),
+			Password: viper.GetString("notify.smtp.password"),
+			From:     viper.GetString("notify.smtp.from"),
+		},
+	}, logger)
+	database.OnChange(notify.NotifyChange)
+	go notify.Run(ctx)
+
+	// 启动发布任务：重试尚未写入 ZooKeeper 的配置版本
+	pub := publisher.New(database, zkClient, notify, publisher.Config{
+		Interval:   viper.GetDuration("publisher.interval"),
+		MaxBackoff: viper.GetDuration("publisher.max_backoff"),
+	}, logger)
+	go pub.Run(ctx)
+
+	// 漂移检查：开启 auto_heal 时周期性以数据库为准修复 ZooKeeper 中的配置
+	rec := reconcile.New(database, zkClient, reconcile.Config{
+		AutoHeal: viper.GetBool("reconcile.auto_heal"),
+		Interval: viper.GetDuration("reconcile.interval"),
+	}, logger)
+	go rec.Run(ctx)
+
+	// 在线状态：监听 config-agent 的在线临时节点
+	tracker := presence.New(database, zkClient, hub, presence.Config{
+		Interval: viper.GetDuration("presence.interval"),
+	}, logger)
+	go tracker.Run(ctx)
+
+	// 灰度发布：根据灰度节点的应用结果自动全量或回滚
+	canaries := canary.New(database, zkClient, pub, canary.Config{
+		Interval: viper.GetDuration("canary.interval"),
+	}, logger)
+	go canaries.Run(ctx)
+
+	// 定时发布：到发布时间时保存为新版本并写入 ZooKeeper
+	sched := scheduler.New(database, pub, scheduler.Config{
+		Interval: viper.GetDuration("scheduler.interval"),
+	}, logger)
+	go sched.Run(ctx)
+
 	// 创建 API 处理器
-	handler := api.NewHandler(database, zkClient, logger)
+	apiConfig := api.Config{
+		TokenTTL: viper.GetDuration("auth.token_ttl"),
+		Lockout: models.LockoutPolicy{
+			MaxAttempts: viper.GetInt("auth.max_failed_attempts"),
+			Duration:    viper.GetDuration("auth.lockout_duration"),
+		},
+		Review: models.ReviewPolicy{
+			models.ScopeGlobal:  viper.GetInt("review.approvals.global"),
+			models.ScopeCluster: viper.GetInt("review.approvals.cluster"),
+			models.ScopeNode:    viper.GetInt("review.approvals.node"),
+		},
+		EventHeartbeat: viper.GetDuration("events.heartbeat"),
+	}
+	handler := api.NewHandler(database, zkClient, pub, rec, canaries, notify, hub, apiConfig, logger)
 
 	// 设置 Gin
 	if viper.GetString("server.mode") == "release" {
@@ -93,6 +185,28 @@ func main() {
 	logger.Info("shutting down server...")
 }
 
+// runImport 执行 import 子命令，将导入报告以 JSON 输出到标准输出，返回进程退出码。
+// 导入的记录由运行中的后端服务发布到 ZooKeeper
+func runImport(database *db.PostgresDB, zkClient *zk.Client, logger *zap.Logger, args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "只报告将导入的配置与校验失败的节点，不写入数据库")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	report, err := importer.New(database, zkClient, logger).Import(*dryRun)
+	if err != nil {
+		logger.Error("failed to import configs from zookeeper", zap.Error(err))
+		return 1
+	}
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+	if report.Invalid > 0 {
+		return 1
+	}
+	return 0
+}
+
 func initLogger() *zap.Logger {
 	config := zap.NewProductionConfig()
 	config.EncoderConfig.TimeKey = "timestamp"
@@ -117,6 +231,27 @@ func loadConfig() {
 	viper.SetDefault("database.dbname", "yaf_config")
 	viper.SetDefault("database.sslmode", "disable")
 	viper.SetDefault("zookeeper.servers", "localhost:2181")
+	viper.SetDefault("auth.token_ttl", "12h")
+	viper.SetDefault("auth.max_failed_attempts", 5)
+	viper.SetDefault("auth.lockout_duration", "15m")
+	viper.SetDefault("review.approvals.global", 0)
+	viper.SetDefault("review.approvals.cluster", 0)
+	viper.SetDefault("review.approvals.node", 0)
+	viper.SetDefault("publisher.interval", "10s")
+	viper.SetDefault("publisher.max_backoff", "5m")
+	viper.SetDefault("reconcile.auto_heal", false)
+	viper.SetDefault("reconcile.interval", "5m")
+	viper.SetDefault("presence.interval", "30s")
+	viper.SetDefault("canary.interval", "10s")
+	viper.SetDefault("scheduler.interval", "10s")
+	viper.SetDefault("notify.interval", "10s")
+	viper.SetDefault("notify.max_attempts", 8)
+	viper.SetDefault("notify.max_backoff", "30m")
+	viper.SetDefault("notify.webhook_timeout", "10s")
+	viper.SetDefault("notify.smtp.port", 25)
+	viper.SetDefault("events.backlog", 256)
+	viper.SetDefault("events.buffer", 64)
+	viper.SetDefault("events.heartbeat", "15s")
 
 	// 支持环境变量
 	viper.AutomaticEnv()
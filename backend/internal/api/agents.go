@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// ListAgents 列出观察到的 config-agent 及其在线状态，可按集群（cluster）与状态（status=online|offline）筛选。
+// 包含尚未登记到清单中的节点上运行的 Agent
+func (h *Handler) ListAgents(c *gin.Context) {
+	filter := models.AgentFilter{
+		ClusterName: c.Query("cluster"),
+		Status:      c.Query("status"),
+	}
+	if filter.ClusterName != "" {
+		if err := h.validator.ValidateClusterName(filter.ClusterName); err != nil {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+			return
+		}
+	}
+	switch filter.Status {
+	case "", models.AgentOnline, models.AgentOffline:
+	default:
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid status: must be online or offline"})
+		return
+	}
+
+	agents, err := h.db.ListAgents(filter)
+	if err != nil {
+		h.logger.Error("failed to list agents", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: agents})
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/diff"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// ctxAuditActor 未登录请求（如登录接口）的操作人
+	ctxAuditActor = "audit_actor"
+	// ctxAuditTarget 审计目标（scope / cluster / node）
+	ctxAuditTarget = "audit_target"
+	// ctxAuditBefore 变更前内容
+	ctxAuditBefore = "audit_before"
+	// ctxAuditAfter 变更后内容
+	ctxAuditAfter = "audit_after"
+
+	// auditBodyLimit 为提取失败原因最多缓存的响应字节数
+	auditBodyLimit = 4096
+)
+
+// auditTarget 审计目标
+type auditTarget struct {
+	Scope       string
+	ClusterName string
+	NodeID      string
+}
+
+// auditWriter 记录响应体前若干字节，用于提取失败原因
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	if room := auditBodyLimit - w.body.Len(); room > 0 {
+		if len(data) < room {
+			room = len(data)
+		}
+		w.body.Write(data[:room])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// auditMiddleware 记录所有修改类请求（含失败与未授权请求）
+func (h *Handler) auditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		entry := &models.AuditEntry{
+			Actor:     currentUser(c),
+			SourceIP:  c.ClientIP(),
+			Method:    c.Request.Method,
+			Endpoint:  c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+			Outcome:   models.AuditSuccess,
+			CreatedAt: time.Now(),
+		}
+		if entry.Actor == "" {
+			entry.Actor = c.GetString(ctxAuditActor)
+		}
+		if entry.Actor == "" {
+			entry.Actor = "anonymous"
+		}
+		if v, ok := c.Get(ctxAuditTarget); ok {
+			target := v.(auditTarget)
+			entry.Scope = target.Scope
+			entry.ClusterName = target.ClusterName
+			entry.NodeID = target.NodeID
+		}
+
+		// 业务码非 0 或 HTTP 状态码异常都视为失败
+		var resp Response
+		json.Unmarshal(writer.body.Bytes(), &resp)
+		if entry.Status >= http.StatusBadRequest || resp.Code != 0 {
+			entry.Outcome = models.AuditFailure
+			entry.Message = resp.Message
+		}
+
+		before, _ := c.Get(ctxAuditBefore)
+		after, _ := c.Get(ctxAuditAfter)
+		entry.Before = toRawJSON(before)
+		entry.After = toRawJSON(after)
+		if entry.Before != nil || entry.After != nil {
+			if changes, err := diff.JSON(entry.Before, entry.After); err == nil {
+				entry.Diff, _ = json.Marshal(changes)
+			}
+		}
+
+		if err := h.db.InsertAudit(entry); err != nil {
+			h.logger.Error("failed to write audit log", zap.Error(err),
+				zap.String("actor", entry.Actor),
+				zap.String("endpoint", entry.Endpoint),
+			)
+		}
+	}
+}
+
+// toRawJSON 将审计内容转换为 JSON
+func toRawJSON(v interface{}) json.RawMessage {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case json.RawMessage:
+		return val
+	case string:
+		return json.RawMessage(val)
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return nil
+		}
+		return data
+	}
+}
+
+// auditConfigTarget 记录审计目标，并以当前最新配置作为变更前内容
+func (h *Handler) auditConfigTarget(c *gin.Context, scope models.ConfigScope, clusterName, nodeID string) {
+	c.Set(ctxAuditTarget, auditTarget{Scope: string(scope), ClusterName: clusterName, NodeID: nodeID})
+
+	record, err := h.db.GetLatestConfig(scope, clusterName, nodeID)
+	if err != nil {
+		h.logger.Warn("failed to load config for audit", zap.Error(err))
+		return
+	}
+	if record != nil {
+		c.Set(ctxAuditBefore, record.ConfigJSON)
+	}
+}
+
+// auditInventoryTarget 记录集群、节点清单操作的审计目标，before 为修改前的描述信息
+func auditInventoryTarget(c *gin.Context, scope models.ConfigScope, clusterName, nodeID string, before interface{}) {
+	c.Set(ctxAuditTarget, auditTarget{Scope: string(scope), ClusterName: clusterName, NodeID: nodeID})
+	if before != nil {
+		c.Set(ctxAuditBefore, before)
+	}
+}
+
+// auditAfter 记录变更后内容
+func auditAfter(c *gin.Context, after interface{}) {
+	c.Set(ctxAuditAfter, after)
+}
+
+// ListAudit 查询审计日志
+// 支持参数：actor、scope、cluster、node、outcome、since、until（RFC3339）、limit、offset
+func (h *Handler) ListAudit(c *gin.Context) {
+	filter := models.AuditFilter{
+		Actor:       c.Query("actor"),
+		Scope:       c.Query("scope"),
+		ClusterName: c.Query("cluster"),
+		NodeID:      c.Query("node"),
+		Outcome:     c.Query("outcome"),
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	var err error
+	if since := c.Query("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid since: " + err.Error()})
+			return
+		}
+	}
+	if until := c.Query("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid until: " + err.Error()})
+			return
+		}
+	}
+
+	entries, err := h.db.ListAudit(filter)
+	if err != nil {
+		h.logger.Error("failed to list audit log", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: entries})
+}
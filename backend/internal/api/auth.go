@@ -0,0 +1,247 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// ctxUsername gin 上下文中保存当前用户名的键
+	ctxUsername = "username"
+	// ctxToken gin 上下文中保存当前 token 的键
+	ctxToken = "token"
+	// ctxUser gin 上下文中保存当前用户的键
+	ctxUser = "user"
+)
+
+// authMiddleware 校验 Authorization: Bearer <token>，未登录或会话过期时返回 401
+func (h *Handler) authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, Response{Code: 401, Message: "未登录"})
+			return
+		}
+
+		user, reason, err := h.sessionUser(token)
+		if err != nil {
+			h.logger.Error("failed to authenticate", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, Response{Code: 500, Message: "服务器错误"})
+			return
+		}
+		if user == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, Response{Code: 401, Message: reason})
+			return
+		}
+
+		// 须修改密码的用户只能访问改密、退出和当前用户接口
+		if user.MustChangePassword && !passwordChangeExempt[c.FullPath()] {
+			c.AbortWithStatusJSON(http.StatusForbidden, Response{Code: 403, Message: "请先修改初始密码"})
+			return
+		}
+
+		c.Set(ctxUsername, user.Username)
+		c.Set(ctxToken, token)
+		c.Set(ctxUser, user)
+		c.Next()
+	}
+}
+
+// sessionUser 校验会话 token 并读取所属用户。会话不存在（已退出登录）或已过期、账号不存在或已禁用时
+// 返回 nil 与提示信息
+func (h *Handler) sessionUser(token string) (*models.User, string, error) {
+	session, err := h.db.GetSession(token)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, "登录已过期，请重新登录", nil
+	}
+
+	user, err := h.db.GetUser(session.Username)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || user.Disabled {
+		return nil, "账号不存在或已禁用", nil
+	}
+	return user, "", nil
+}
+
+// passwordChangeExempt 强制改密状态下仍允许访问的路由
+var passwordChangeExempt = map[string]bool{
+	"/api/v1/auth/password": true,
+	"/api/v1/auth/logout":   true,
+	"/api/v1/auth/me":       true,
+}
+
+// requireAdmin 仅允许管理员访问的路由中间件
+func (h *Handler) requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.authorizeAdmin(c) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorize 检查当前用户能否修改指定范围的配置，无权限时写入 403 响应
+func (h *Handler) authorize(c *gin.Context, scope models.ConfigScope, clusterName string) bool {
+	user := authUser(c)
+	if user != nil && user.CanEdit(scope, clusterName) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, Response{Code: 403, Message: "没有修改该配置的权限"})
+	return false
+}
+
+// authorizeAdmin 检查当前用户是否为管理员，否则写入 403 响应
+func (h *Handler) authorizeAdmin(c *gin.Context) bool {
+	user := authUser(c)
+	if user != nil && user.Role == models.RoleAdmin {
+		return true
+	}
+	c.JSON(http.StatusForbidden, Response{Code: 403, Message: "需要管理员权限"})
+	return false
+}
+
+// authUser 获取当前登录用户
+func authUser(c *gin.Context) *models.User {
+	if v, ok := c.Get(ctxUser); ok {
+		return v.(*models.User)
+	}
+	return nil
+}
+
+// bearerToken 从请求头中提取 token
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// currentUser 获取当前登录用户名
+func currentUser(c *gin.Context) string {
+	return c.GetString(ctxUsername)
+}
+
+// Login 用户登录
+func (h *Handler) Login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "用户名和密码不能为空"})
+		return
+	}
+	c.Set(ctxAuditActor, req.Username)
+
+	user, err := h.db.Authenticate(req.Username, req.Password, h.config.Lockout)
+	switch err {
+	case nil:
+	case db.ErrInvalidCredentials:
+		c.JSON(http.StatusUnauthorized, Response{Code: 401, Message: "用户名或密码错误"})
+		return
+	case db.ErrUserDisabled:
+		c.JSON(http.StatusForbidden, Response{Code: 403, Message: "账号已禁用"})
+		return
+	case db.ErrUserLocked:
+		c.JSON(http.StatusForbidden, Response{
+			Code:    403,
+			Message: "登录失败次数过多，账号已锁定至 " + user.LockedUntil.Format("2006-01-02 15:04:05"),
+		})
+		return
+	default:
+		h.logger.Error("failed to authenticate user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: "服务器错误"})
+		return
+	}
+
+	session, err := h.db.CreateSession(user.Username, h.config.TokenTTL)
+	if err != nil {
+		h.logger.Error("failed to create session", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: "服务器错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "登录成功",
+		Data: map[string]interface{}{
+			"username":             session.Username,
+			"role":                 user.Role,
+			"clusters":             user.Clusters,
+			"must_change_password": user.MustChangePassword,
+			"token":                session.Token,
+			"expires_at":           session.ExpiresAt,
+		},
+	})
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword 修改自己的密码，成功后注销其他会话
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "原密码和新密码不能为空"})
+		return
+	}
+	if err := h.validator.ValidatePassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if req.NewPassword == req.OldPassword {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "新密码不能与原密码相同"})
+		return
+	}
+
+	username := currentUser(c)
+	ok, err := h.db.CheckPassword(username, req.OldPassword)
+	if err != nil {
+		h.logger.Error("failed to check password", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: "服务器错误"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "原密码错误"})
+		return
+	}
+
+	if err := h.db.SetPassword(username, req.NewPassword, false); err != nil {
+		h.logger.Error("failed to set password", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := h.db.DeleteUserSessions(username, c.GetString(ctxToken)); err != nil {
+		h.logger.Warn("failed to revoke other sessions", zap.Error(err))
+	}
+
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "密码已修改"})
+}
+
+// GetCurrentUser 获取当前登录用户信息
+func (h *Handler) GetCurrentUser(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: authUser(c)})
+}
+
+// Logout 退出登录，使当前 token 失效
+func (h *Handler) Logout(c *gin.Context) {
+	if err := h.db.DeleteSession(c.GetString(ctxToken)); err != nil {
+		h.logger.Error("failed to delete session", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "已退出登录"})
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/canary"
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultCanaryTimeout 未指定时等待灰度节点确认的时间（秒）
+	defaultCanaryTimeout = 600
+	// maxCanaryTimeout 等待灰度节点确认的最长时间（秒）
+	maxCanaryTimeout = 86400
+)
+
+// CanaryRequest 开始灰度发布请求，config 与 base_version 的含义同保存配置。
+// draft_id 指定审批通过的灰度草稿时，scope、cluster_name、config 与 base_version 取自草稿
+type CanaryRequest struct {
+	Scope          string                `json:"scope"` // global / cluster
+	ClusterName    string                `json:"cluster_name"`
+	Config         json.RawMessage       `json:"config"`
+	BaseVersion    *int                  `json:"base_version,omitempty"`
+	DraftID        int64                 `json:"draft_id,omitempty"`
+	Selector       models.CanarySelector `json:"selector"`
+	AutoPromote    bool                  `json:"auto_promote"`
+	TimeoutSeconds int                   `json:"timeout_seconds"` // 等待灰度节点确认的时间，默认 600
+}
+
+// CanaryRollbackRequest 手动回滚请求
+type CanaryRollbackRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ListCanaries 列出灰度发布，active=true 时只列出尚未结束的
+func (h *Handler) ListCanaries(c *gin.Context) {
+	activeOnly, _ := strconv.ParseBool(c.Query("active"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	canaries, err := h.db.ListCanaries(activeOnly, limit)
+	if err != nil {
+		h.logger.Error("failed to list canaries", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: canaries})
+}
+
+// GetCanary 获取灰度发布及各节点的应用状态
+func (h *Handler) GetCanary(c *gin.Context) {
+	record, ok := h.loadCanary(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: record})
+}
+
+// CreateCanary 开始灰度发布：保存全局或集群配置的新版本，只对选出的灰度节点生效。
+// 该级配置需要审批时只能从审批通过的灰度草稿开始（draft_id），版本作者记为草稿作者
+func (h *Handler) CreateCanary(c *gin.Context) {
+	var req CanaryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	author := currentUser(c)
+	if req.DraftID > 0 {
+		draft, err := h.db.GetDraft(req.DraftID)
+		if err != nil {
+			h.logger.Error("failed to get draft", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+			return
+		}
+		if draft == nil {
+			c.JSON(http.StatusNotFound, Response{Code: 404, Message: "draft not found"})
+			return
+		}
+		if !draft.Canary || draft.State != models.DraftApproved {
+			c.JSON(http.StatusConflict, Response{Code: 409, Message: "draft is not an approved canary draft"})
+			return
+		}
+		req.Scope = string(draft.Scope)
+		req.ClusterName = draft.ClusterName
+		req.Config = json.RawMessage(draft.ConfigJSON)
+		req.BaseVersion = &draft.BaseVersion
+		author = draft.CreatedBy
+	}
+	scope := models.ConfigScope(req.Scope)
+	switch scope {
+	case models.ScopeGlobal:
+		req.ClusterName = ""
+	case models.ScopeCluster:
+		if err := h.validator.ValidateClusterName(req.ClusterName); err != nil {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "scope must be global or cluster"})
+		return
+	}
+	h.auditConfigTarget(c, scope, req.ClusterName, "")
+	if !h.authorize(c, scope, req.ClusterName) {
+		return
+	}
+
+	if req.Selector.Percent < 0 || req.Selector.Percent > 100 {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "selector.percent must be between 0 and 100"})
+		return
+	}
+	if err := h.validator.ValidateInventoryMeta(&models.InventoryMeta{Labels: req.Selector.Labels}); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid selector: " + err.Error()})
+		return
+	}
+	if req.TimeoutSeconds == 0 {
+		req.TimeoutSeconds = defaultCanaryTimeout
+	}
+	if req.TimeoutSeconds < 0 || req.TimeoutSeconds > maxCanaryTimeout {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "timeout_seconds must be between 1 and 86400"})
+		return
+	}
+	baseVersion, err := expectedVersion(c, req.BaseVersion)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	overlay, err := models.ParseOverlay(req.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	auditAfter(c, overlay)
+	if err := h.validator.ValidateOverlay(overlay); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	configJSON, _ := json.Marshal(overlay)
+	record := &models.ConfigRecord{
+		Scope:       scope,
+		ClusterName: req.ClusterName,
+		ConfigJSON:  string(configJSON),
+		CreatedBy:   author,
+	}
+	rollout := &models.Canary{
+		Scope:       scope,
+		ClusterName: req.ClusterName,
+		Selector:    req.Selector,
+		AutoPromote: req.AutoPromote,
+		Timeout:     req.TimeoutSeconds,
+		DraftID:     req.DraftID,
+		CreatedBy:   currentUser(c),
+	}
+	err = h.canary.Start(rollout, record, baseVersion)
+	if err == canary.ErrNoCanaryNodes {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err == db.ErrDraftState {
+		h.respondDraftError(c, err)
+		return
+	}
+	if !h.checkSaved(c, record, err) {
+		return
+	}
+
+	// 固定节点已写入，新版本照常发布（失败时由后台任务重试）
+	syncState := h.publish(record)
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    map[string]interface{}{"canary": rollout, "version": record.Version, "sync_state": syncState},
+	})
+}
+
+// PromoteCanary 手动全量：不再等待（或已确认）灰度节点，解除其余节点的固定版本
+func (h *Handler) PromoteCanary(c *gin.Context) {
+	record, ok := h.loadCanary(c)
+	if !ok {
+		return
+	}
+	h.auditConfigTarget(c, record.Scope, record.ClusterName, "")
+	if !h.authorize(c, record.Scope, record.ClusterName) {
+		return
+	}
+
+	if err := h.canary.Promote(record.ID, currentUser(c)); err != nil {
+		h.respondCanaryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success"})
+}
+
+// RollbackCanary 手动回滚：保存内容为基准版本的新版本，发布后解除其余节点的固定版本
+func (h *Handler) RollbackCanary(c *gin.Context) {
+	record, ok := h.loadCanary(c)
+	if !ok {
+		return
+	}
+	h.auditConfigTarget(c, record.Scope, record.ClusterName, "")
+	if !h.authorize(c, record.Scope, record.ClusterName) {
+		return
+	}
+	var req CanaryRollbackRequest
+	c.ShouldBindJSON(&req)
+	if req.Reason == "" {
+		req.Reason = "rolled back by " + currentUser(c)
+	}
+
+	if err := h.canary.Rollback(record, currentUser(c), req.Reason); err != nil {
+		h.respondCanaryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    map[string]interface{}{"rollback_version": record.RollbackVersion},
+	})
+}
+
+// loadCanary 读取路径参数中的灰度发布，不存在时返回 404
+func (h *Handler) loadCanary(c *gin.Context) (*models.Canary, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid canary id"})
+		return nil, false
+	}
+	record, err := h.db.GetCanary(id)
+	if err != nil {
+		h.logger.Error("failed to get canary", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return nil, false
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "canary not found"})
+		return nil, false
+	}
+	return record, true
+}
+
+// respondCanaryError 灰度状态不允许该操作时返回 409，其他错误返回 500
+func (h *Handler) respondCanaryError(c *gin.Context, err error) {
+	if err == db.ErrCanaryState {
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: err.Error()})
+		return
+	}
+	h.logger.Error("failed to update canary", zap.Error(err))
+	c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+}
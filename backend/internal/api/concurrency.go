@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// expectedVersion 客户端修改所基于的配置版本：优先取 If-Match 请求头（ETag 形如 "3"），
+// 其次取请求体中的 base_version；都未提供或 If-Match 为 * 时返回 db.AnyVersion
+func expectedVersion(c *gin.Context, baseVersion *int) (int, error) {
+	if header := strings.TrimSpace(c.GetHeader("If-Match")); header != "" {
+		if header == "*" {
+			return db.AnyVersion, nil
+		}
+		tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+		version, err := strconv.Atoi(tag)
+		if err != nil || version < 0 {
+			return 0, fmt.Errorf("invalid If-Match header: %s", header)
+		}
+		return version, nil
+	}
+	if baseVersion != nil {
+		if *baseVersion < 0 {
+			return 0, fmt.Errorf("invalid base_version: %d", *baseVersion)
+		}
+		return *baseVersion, nil
+	}
+	return db.AnyVersion, nil
+}
+
+// setETag 以配置版本号作为 ETag，客户端保存时通过 If-Match 回传
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// respondConflict 返回 409 及当前版本
+func respondConflict(c *gin.Context, current int) {
+	setETag(c, current)
+	c.JSON(http.StatusConflict, Response{
+		Code:    409,
+		Message: fmt.Sprintf("配置已被其他人修改（当前版本 v%d），请刷新后重试", current),
+		Data:    map[string]int{"current_version": current},
+	})
+}
+
+// saveConfig 保存配置新版本，基准版本过期时返回 409，其他错误返回 500；保存成功返回 true
+func (h *Handler) saveConfig(c *gin.Context, record *models.ConfigRecord, baseVersion int) bool {
+	return h.checkSaved(c, record, h.db.SaveConfig(record, baseVersion))
+}
+
+// checkSaved 处理写入新版本（保存或删除）的结果：冲突或该级配置正在灰度发布时返回 409，其他错误返回 500；
+// 成功时设置 ETag 并返回 true
+func (h *Handler) checkSaved(c *gin.Context, record *models.ConfigRecord, err error) bool {
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		respondConflict(c, conflict.Current)
+		return false
+	}
+	if err == db.ErrCanaryActive {
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: "该配置正在灰度发布，请先全量或回滚"})
+		return false
+	}
+	if err != nil {
+		h.logger.Error("failed to save config", zap.Error(err),
+			zap.String("scope", string(record.Scope)),
+			zap.String("cluster", record.ClusterName),
+			zap.String("node", record.NodeID),
+		)
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return false
+	}
+	setETag(c, record.Version)
+	return true
+}
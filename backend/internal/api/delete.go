@@ -0,0 +1,181 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// DeleteNodeConfig 删除节点配置：写入删除标记版本并删除 ZooKeeper 中的节点，节点回退到集群配置。
+// 支持 If-Match 指定期望的当前版本
+func (h *Handler) DeleteNodeConfig(c *gin.Context) {
+	cluster := c.Param("cluster")
+	node := c.Param("node")
+
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidateNodeID(node); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	h.auditConfigTarget(c, models.ScopeNode, cluster, node)
+	if !h.authorize(c, models.ScopeNode, cluster) {
+		return
+	}
+	baseVersion, err := expectedVersion(c, nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	current, err := h.db.GetLatestConfig(models.ScopeNode, cluster, node)
+	if err != nil {
+		h.logger.Error("failed to get node config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if current == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "no config found"})
+		return
+	}
+
+	record := &models.ConfigRecord{
+		Scope:       models.ScopeNode,
+		ClusterName: cluster,
+		NodeID:      node,
+		CreatedBy:   currentUser(c),
+	}
+	if !h.checkSaved(c, record, h.db.DeleteConfig(record, baseVersion)) {
+		return
+	}
+
+	// 从 ZooKeeper 删除（失败时由后台任务重试）
+	syncState := h.publish(record)
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "deleted",
+		Data:    map[string]interface{}{"version": record.Version, "cluster": cluster, "node": node, "sync_state": syncState},
+	})
+}
+
+// DeleteClusterConfig 删除集群配置：集群及其下所有节点的配置写入删除标记版本，
+// 并删除 ZooKeeper 中集群的整个子树，集群与节点的清单记录保留。支持 If-Match 指定集群配置期望的当前版本
+func (h *Handler) DeleteClusterConfig(c *gin.Context) {
+	cluster := c.Param("cluster")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	h.auditConfigTarget(c, models.ScopeCluster, cluster, "")
+	if !h.authorize(c, models.ScopeCluster, cluster) {
+		return
+	}
+	baseVersion, err := expectedVersion(c, nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	existing, err := h.db.GetCluster(cluster)
+	if err != nil {
+		h.logger.Error("failed to get cluster", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if existing == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "cluster not found"})
+		return
+	}
+	if existing.Config == nil && existing.ConfiguredNodes == 0 {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "no config found"})
+		return
+	}
+
+	record := &models.ConfigRecord{Scope: models.ScopeCluster, ClusterName: cluster}
+	tombstone, err := h.db.DeleteClusterConfig(cluster, currentUser(c), baseVersion)
+	if tombstone != nil {
+		record = tombstone
+	}
+	if !h.checkSaved(c, record, err) {
+		return
+	}
+
+	// 集群子树由集群的删除标记一并删除，节点的删除标记交给后台任务确认
+	syncState := h.publish(record)
+	h.publisher.Kick()
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "deleted",
+		Data:    map[string]interface{}{"version": record.Version, "cluster": cluster, "sync_state": syncState},
+	})
+}
+
+// ListArchivedClusters 列出已归档的集群
+func (h *Handler) ListArchivedClusters(c *gin.Context) {
+	clusters, err := h.db.ListArchivedClusters()
+	if err != nil {
+		h.logger.Error("failed to list archived clusters", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: clusters})
+}
+
+// ArchiveCluster 归档集群：不再出现在集群列表中，配置、历史与 ZooKeeper 中的节点均保留
+func (h *Handler) ArchiveCluster(c *gin.Context) {
+	cluster, ok := h.archiveTarget(c)
+	if !ok {
+		return
+	}
+	existing, err := h.db.GetCluster(cluster)
+	if err != nil {
+		h.logger.Error("failed to get cluster", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if existing == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "cluster not found"})
+		return
+	}
+
+	if err := h.db.ArchiveCluster(cluster, currentUser(c)); err != nil {
+		h.logger.Error("failed to archive cluster", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "archived", Data: map[string]string{"cluster": cluster}})
+}
+
+// UnarchiveCluster 取消归档
+func (h *Handler) UnarchiveCluster(c *gin.Context) {
+	cluster, ok := h.archiveTarget(c)
+	if !ok {
+		return
+	}
+	if err := h.db.UnarchiveCluster(cluster); err != nil {
+		h.logger.Error("failed to unarchive cluster", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "unarchived", Data: map[string]string{"cluster": cluster}})
+}
+
+// archiveTarget 校验归档操作的集群名与权限
+func (h *Handler) archiveTarget(c *gin.Context) (string, bool) {
+	cluster := c.Param("cluster")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return "", false
+	}
+	h.auditConfigTarget(c, models.ScopeCluster, cluster, "")
+	if !h.authorize(c, models.ScopeCluster, cluster) {
+		return "", false
+	}
+	return cluster, true
+}
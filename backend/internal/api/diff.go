@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/diff"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// ConfigRef 指向某个作用范围下的某个配置版本
+type ConfigRef struct {
+	Scope       models.ConfigScope `json:"scope"`
+	ClusterName string             `json:"cluster_name,omitempty"`
+	NodeID      string             `json:"node_id,omitempty"`
+	Version     int                `json:"version"`
+	CreatedAt   time.Time          `json:"created_at"`
+	CreatedBy   string             `json:"created_by"`
+}
+
+// parseConfigRef 从查询参数解析配置引用，prefix 为 from 或 to；version 省略时取最新版本
+func (h *Handler) parseConfigRef(c *gin.Context, prefix string) (*ConfigRef, error) {
+	ref := &ConfigRef{
+		Scope:       models.ConfigScope(c.Query(prefix + "_scope")),
+		ClusterName: c.Query(prefix + "_cluster"),
+		NodeID:      c.Query(prefix + "_node"),
+	}
+
+	switch ref.Scope {
+	case models.ScopeGlobal:
+		ref.ClusterName, ref.NodeID = "", ""
+	case models.ScopeCluster:
+		if err := h.validator.ValidateClusterName(ref.ClusterName); err != nil {
+			return nil, fmt.Errorf("%s: %w", prefix, err)
+		}
+		ref.NodeID = ""
+	case models.ScopeNode:
+		if err := h.validator.ValidateClusterName(ref.ClusterName); err != nil {
+			return nil, fmt.Errorf("%s: %w", prefix, err)
+		}
+		if err := h.validator.ValidateNodeID(ref.NodeID); err != nil {
+			return nil, fmt.Errorf("%s: %w", prefix, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s_scope must be one of global, cluster, node", prefix)
+	}
+
+	if v := c.Query(prefix + "_version"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid %s_version: %s", prefix, v)
+		}
+		ref.Version = version
+	}
+	return ref, nil
+}
+
+// loadConfigRef 加载引用指向的配置记录
+func (h *Handler) loadConfigRef(ref *ConfigRef) (*models.YafConfig, error) {
+	var record *models.ConfigRecord
+	var err error
+	if ref.Version > 0 {
+		record, err = h.db.GetConfigByVersion(ref.Scope, ref.ClusterName, ref.NodeID, ref.Version)
+	} else {
+		record, err = h.db.GetLatestConfig(ref.Scope, ref.ClusterName, ref.NodeID)
+	}
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	var cfg models.YafConfig
+	if err := json.Unmarshal([]byte(record.ConfigJSON), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config json: %w", err)
+	}
+	ref.Version = record.Version
+	ref.CreatedAt = record.CreatedAt
+	ref.CreatedBy = record.CreatedBy
+	return &cfg, nil
+}
+
+// DiffConfig 比较任意两个配置版本（可跨作用范围）
+// 参数：from_scope、from_cluster、from_node、from_version，以及对应的 to_* 参数
+func (h *Handler) DiffConfig(c *gin.Context) {
+	from, err := h.parseConfigRef(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	to, err := h.parseConfigRef(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	fromCfg, err := h.loadConfigRef(from)
+	if err != nil {
+		h.logger.Error("failed to load config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	toCfg, err := h.loadConfigRef(to)
+	if err != nil {
+		h.logger.Error("failed to load config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if fromCfg == nil || toCfg == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "version not found"})
+		return
+	}
+
+	changes, err := diff.Values(fromCfg, toCfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"from":    from,
+			"to":      to,
+			"changes": changes,
+		},
+	})
+}
@@ -0,0 +1,411 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/diff"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// DraftRequest 创建或修改草稿请求，config 与 base_version 的含义同保存配置
+type DraftRequest struct {
+	Scope       string          `json:"scope"` // global / cluster / node，修改草稿时忽略
+	ClusterName string          `json:"cluster_name"`
+	NodeID      string          `json:"node_id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Config      json.RawMessage `json:"config"`
+	Deleted     bool            `json:"deleted"` // 删除该级配置（仅集群、节点）
+	Canary      bool            `json:"canary"`  // 灰度草稿：审批通过后以灰度发布上线（仅全局、集群）
+	BaseVersion *int            `json:"base_version,omitempty"`
+	Submit      bool            `json:"submit"` // 创建后立即提交评审
+}
+
+// DraftReviewRequest 审批、驳回或评论请求
+type DraftReviewRequest struct {
+	Comment string `json:"comment"`
+}
+
+// GetReviewPolicy 获取各级配置发布前需要的审批数
+func (h *Handler) GetReviewPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data: map[string]int{
+			string(models.ScopeGlobal):  h.config.Review.Required(models.ScopeGlobal),
+			string(models.ScopeCluster): h.config.Review.Required(models.ScopeCluster),
+			string(models.ScopeNode):    h.config.Review.Required(models.ScopeNode),
+		},
+	})
+}
+
+// ListDrafts 列出草稿，可按 state、scope、cluster、node、author 筛选
+func (h *Handler) ListDrafts(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	drafts, err := h.db.ListDrafts(models.DraftFilter{
+		State:       c.Query("state"),
+		Scope:       models.ConfigScope(c.Query("scope")),
+		ClusterName: c.Query("cluster"),
+		NodeID:      c.Query("node"),
+		CreatedBy:   c.Query("author"),
+		Limit:       limit,
+	})
+	if err != nil {
+		h.logger.Error("failed to list drafts", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: drafts})
+}
+
+// GetDraft 获取草稿、时间线，以及草稿相对该级配置当前版本的字段级差异
+func (h *Handler) GetDraft(c *gin.Context) {
+	draft, ok := h.loadDraft(c)
+	if !ok {
+		return
+	}
+
+	current, err := h.db.GetLatestConfig(draft.Scope, draft.ClusterName, draft.NodeID)
+	if err != nil {
+		h.logger.Error("failed to get config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	var before []byte
+	currentVersion := 0
+	if current != nil {
+		before = []byte(current.ConfigJSON)
+		currentVersion = current.Version
+	}
+	after := []byte(draft.ConfigJSON)
+	if draft.Deleted {
+		after = nil
+	}
+	changes, err := diff.JSON(before, after)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"draft":           draft,
+			"changes":         changes,
+			"current_version": currentVersion,
+			// 基准版本已不是当前版本时审批发布会失败，需要作者基于当前版本更新草稿
+			"outdated": draft.BaseVersion != db.AnyVersion && draft.BaseVersion != currentVersion,
+		},
+	})
+}
+
+// CreateDraft 保存草稿，submit 为 true 时同时提交评审
+func (h *Handler) CreateDraft(c *gin.Context) {
+	var req DraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	scope := models.ConfigScope(req.Scope)
+	if !h.validateTarget(c, scope, &req.ClusterName, &req.NodeID) {
+		return
+	}
+	h.auditConfigTarget(c, scope, req.ClusterName, req.NodeID)
+	if !h.authorize(c, scope, req.ClusterName) {
+		return
+	}
+
+	draft := &models.Draft{
+		Scope:       scope,
+		ClusterName: req.ClusterName,
+		NodeID:      req.NodeID,
+		CreatedBy:   currentUser(c),
+	}
+	if !h.fillDraft(c, draft, &req) {
+		return
+	}
+	if err := h.db.CreateDraft(draft); err != nil {
+		h.logger.Error("failed to create draft", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if req.Submit {
+		h.submitDraft(c, draft)
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: draft})
+}
+
+// UpdateDraft 作者修改草稿；评审中的草稿修改后需要重新审批
+func (h *Handler) UpdateDraft(c *gin.Context) {
+	draft, ok := h.loadDraftForAuthor(c)
+	if !ok {
+		return
+	}
+	var req DraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if !h.fillDraft(c, draft, &req) {
+		return
+	}
+	if err := h.db.UpdateDraft(draft, currentUser(c)); err != nil {
+		h.respondDraftError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success"})
+}
+
+// SubmitDraft 作者提交评审；该级配置不需要审批时直接发布
+func (h *Handler) SubmitDraft(c *gin.Context) {
+	draft, ok := h.loadDraftForAuthor(c)
+	if !ok {
+		return
+	}
+	h.submitDraft(c, draft)
+}
+
+// ApproveDraft 审批草稿：审批人不能是作者，且需要有修改该级配置的权限；审批数达到要求时发布
+func (h *Handler) ApproveDraft(c *gin.Context) {
+	draft, ok := h.loadDraftForReviewer(c)
+	if !ok {
+		return
+	}
+	var req DraftReviewRequest
+	c.ShouldBindJSON(&req)
+
+	record, err := h.db.ApproveDraft(draft.ID, currentUser(c), req.Comment)
+	if !h.checkDraftPublished(c, draft, record, err) {
+		return
+	}
+	if record == nil {
+		c.JSON(http.StatusOK, Response{Code: 0, Message: "approved"})
+		return
+	}
+	h.respondDraftPublished(c, record)
+}
+
+// RejectDraft 驳回草稿，作者修改后可重新提交
+func (h *Handler) RejectDraft(c *gin.Context) {
+	draft, ok := h.loadDraftForReviewer(c)
+	if !ok {
+		return
+	}
+	var req DraftReviewRequest
+	c.ShouldBindJSON(&req)
+	if strings.TrimSpace(req.Comment) == "" {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "comment is required when rejecting"})
+		return
+	}
+
+	if err := h.db.RejectDraft(draft.ID, currentUser(c), req.Comment); err != nil {
+		h.respondDraftError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "rejected"})
+}
+
+// CommentDraft 评论草稿，任何登录用户都可以评论
+func (h *Handler) CommentDraft(c *gin.Context) {
+	draft, ok := h.loadDraft(c)
+	if !ok {
+		return
+	}
+	var req DraftReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Comment) == "" {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "comment is required"})
+		return
+	}
+
+	event, err := h.db.AddDraftComment(draft.ID, currentUser(c), req.Comment)
+	if err != nil {
+		h.logger.Error("failed to add draft comment", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: event})
+}
+
+// CloseDraft 关闭尚未发布的草稿（作者或管理员）
+func (h *Handler) CloseDraft(c *gin.Context) {
+	draft, ok := h.loadDraft(c)
+	if !ok {
+		return
+	}
+	h.auditConfigTarget(c, draft.Scope, draft.ClusterName, draft.NodeID)
+	if user := authUser(c); draft.CreatedBy != currentUser(c) && (user == nil || user.Role != models.RoleAdmin) {
+		c.JSON(http.StatusForbidden, Response{Code: 403, Message: "只有作者或管理员可以关闭草稿"})
+		return
+	}
+
+	if err := h.db.CloseDraft(draft.ID, currentUser(c)); err != nil {
+		h.respondDraftError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "closed"})
+}
+
+// fillDraft 校验请求中的草稿内容并写入 draft，不合法时返回 400
+func (h *Handler) fillDraft(c *gin.Context, draft *models.Draft, req *DraftRequest) bool {
+	draft.Title = strings.TrimSpace(req.Title)
+	draft.Description = req.Description
+	if draft.Title == "" || len(draft.Title) > 256 {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "title is required and must be at most 256 characters"})
+		return false
+	}
+	baseVersion, err := expectedVersion(c, req.BaseVersion)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return false
+	}
+	draft.BaseVersion = baseVersion
+
+	draft.Canary = req.Canary
+	if draft.Canary && (draft.Scope == models.ScopeNode || req.Deleted) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "only global and cluster config changes can be canary drafts"})
+		return false
+	}
+	draft.Deleted = req.Deleted
+	if draft.Deleted {
+		if draft.Scope == models.ScopeGlobal {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "global config cannot be deleted"})
+			return false
+		}
+		draft.ConfigJSON = "{}"
+		auditAfter(c, map[string]interface{}{"title": draft.Title, "deleted": true})
+		return true
+	}
+
+	overlay, err := models.ParseOverlay(req.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return false
+	}
+	auditAfter(c, map[string]interface{}{"title": draft.Title, "config": overlay})
+	if err := h.validator.ValidateOverlay(overlay); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return false
+	}
+	configJSON, _ := json.Marshal(overlay)
+	draft.ConfigJSON = string(configJSON)
+	return true
+}
+
+// submitDraft 提交评审并按结果响应
+func (h *Handler) submitDraft(c *gin.Context, draft *models.Draft) {
+	record, err := h.db.SubmitDraft(draft.ID, currentUser(c), h.config.Review.Required(draft.Scope))
+	if !h.checkDraftPublished(c, draft, record, err) {
+		return
+	}
+	if record == nil {
+		c.JSON(http.StatusOK, Response{Code: 0, Message: "submitted", Data: map[string]int64{"id": draft.ID}})
+		return
+	}
+	h.respondDraftPublished(c, record)
+}
+
+// checkDraftPublished 处理提交或审批（可能同时发布）的结果，出错时写入响应并返回 false
+func (h *Handler) checkDraftPublished(c *gin.Context, draft *models.Draft, record *models.ConfigRecord, err error) bool {
+	if err == db.ErrDraftState {
+		h.respondDraftError(c, err)
+		return false
+	}
+	if err != nil {
+		if record == nil {
+			record = draft.Record()
+		}
+		return h.checkSaved(c, record, err)
+	}
+	if record != nil {
+		setETag(c, record.Version)
+	}
+	return true
+}
+
+// respondDraftPublished 草稿已发布：立即写入 ZooKeeper 并返回新版本与同步状态
+func (h *Handler) respondDraftPublished(c *gin.Context, record *models.ConfigRecord) {
+	syncState := h.publish(record)
+	if record.Deleted && record.Scope == models.ScopeCluster {
+		h.publisher.Kick()
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "published",
+		Data:    map[string]interface{}{"version": record.Version, "sync_state": syncState},
+	})
+}
+
+// loadDraft 读取路径参数中的草稿，不存在时返回 404
+func (h *Handler) loadDraft(c *gin.Context) (*models.Draft, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid draft id"})
+		return nil, false
+	}
+	draft, err := h.db.GetDraft(id)
+	if err != nil {
+		h.logger.Error("failed to get draft", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return nil, false
+	}
+	if draft == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "draft not found"})
+		return nil, false
+	}
+	return draft, true
+}
+
+// loadDraftForAuthor 读取草稿并检查当前用户是作者且仍有修改该级配置的权限
+func (h *Handler) loadDraftForAuthor(c *gin.Context) (*models.Draft, bool) {
+	draft, ok := h.loadDraft(c)
+	if !ok {
+		return nil, false
+	}
+	h.auditConfigTarget(c, draft.Scope, draft.ClusterName, draft.NodeID)
+	if draft.CreatedBy != currentUser(c) {
+		c.JSON(http.StatusForbidden, Response{Code: 403, Message: "只有作者可以修改或提交草稿"})
+		return nil, false
+	}
+	if !h.authorize(c, draft.Scope, draft.ClusterName) {
+		return nil, false
+	}
+	return draft, true
+}
+
+// loadDraftForReviewer 读取草稿并检查当前用户不是作者且有修改该级配置的权限
+func (h *Handler) loadDraftForReviewer(c *gin.Context) (*models.Draft, bool) {
+	draft, ok := h.loadDraft(c)
+	if !ok {
+		return nil, false
+	}
+	h.auditConfigTarget(c, draft.Scope, draft.ClusterName, draft.NodeID)
+	if draft.CreatedBy == currentUser(c) {
+		c.JSON(http.StatusForbidden, Response{Code: 403, Message: "不能审批自己的草稿"})
+		return nil, false
+	}
+	if !h.authorize(c, draft.Scope, draft.ClusterName) {
+		return nil, false
+	}
+	return draft, true
+}
+
+// respondDraftError 草稿状态不允许该操作时返回 409，其他错误返回 500
+func (h *Handler) respondDraftError(c *gin.Context, err error) {
+	if err == db.ErrDraftState {
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: err.Error()})
+		return
+	}
+	h.logger.Error("failed to update draft", zap.Error(err))
+	c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+}
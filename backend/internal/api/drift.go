@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetDrift 检查 ZooKeeper 中的配置节点是否与数据库最新版本一致
+func (h *Handler) GetDrift(c *gin.Context) {
+	report, err := h.reconciler.Check()
+	if err != nil {
+		h.logger.Error("failed to check config drift", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"report":    report,
+			"auto_heal": h.reconciler.AutoHeal(),
+		},
+	})
+}
+
+// RepairDrift 以数据库为准修复缺失与不一致的配置节点，多余的节点只报告不删除
+func (h *Handler) RepairDrift(c *gin.Context) {
+	report, err := h.reconciler.Repair()
+	if err != nil {
+		h.logger.Error("failed to repair config drift", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	auditAfter(c, report)
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: report})
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/models"
+	"github.com/yf-web/backend/internal/zk"
+	"go.uber.org/zap"
+)
+
+// LayerInfo 参与合并的某一级配置，配置档（scope 为 profile）为其挂载到的集群或节点，
+// 标签选择层（scope 为 selector）为所匹配的节点
+type LayerInfo struct {
+	Scope       models.ConfigScope `json:"scope"`
+	Profile     string             `json:"profile,omitempty"`
+	Selector    string             `json:"selector,omitempty"`
+	ClusterName string             `json:"cluster_name,omitempty"`
+	NodeID      string             `json:"node_id,omitempty"`
+	Version     int                `json:"version"`
+	Pinned      bool               `json:"pinned,omitempty"` // 灰度发布期间节点停留在该级的基准版本，而不是最新版本
+	CreatedAt   time.Time          `json:"created_at"`
+	CreatedBy   string             `json:"created_by"`
+}
+
+// FieldSource 生效配置中某个字段的来源
+type FieldSource struct {
+	Source      string     `json:"source"` // default / global / profile / cluster / selector / node
+	Profile     string     `json:"profile,omitempty"`
+	Selector    string     `json:"selector,omitempty"`
+	ClusterName string     `json:"cluster_name,omitempty"`
+	NodeID      string     `json:"node_id,omitempty"`
+	Version     int        `json:"version,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+}
+
+// layerRef 参与合并的某一级配置的定位信息
+type layerRef struct {
+	scope   models.ConfigScope
+	cluster string
+	node    string
+}
+
+// resolveConfig 按 global → profiles → cluster → selectors → node 加载最新配置，合并到 scope 指定的层级为止，
+// 返回生效配置、参与合并的层级以及每个字段的来源。配置档按集群挂载的在前、节点挂载的在后逐个参与合并，
+// 与 Agent 合并发布的配置档层结果一致。合并到节点时与 Agent 一致：灰度发布期间非灰度节点的全局、集群配置为基准版本，
+// 标签选择层按 labels（由调用方通过 nodeLabels 获取，合并到集群及以上时不使用）匹配
+func (h *Handler) resolveConfig(scope models.ConfigScope, cluster, node string, labels map[string]string) (*models.YafConfig, []LayerInfo, map[string]FieldSource, error) {
+	refs := []layerRef{{models.ScopeGlobal, "", ""}}
+	if scope == models.ScopeCluster || scope == models.ScopeNode {
+		refs = append(refs, layerRef{models.ScopeProfile, cluster, ""})
+		if scope == models.ScopeNode {
+			refs = append(refs, layerRef{models.ScopeProfile, cluster, node})
+		}
+		refs = append(refs, layerRef{models.ScopeCluster, cluster, ""})
+	}
+	if scope == models.ScopeNode {
+		refs = append(refs, layerRef{models.ScopeSelector, cluster, node}, layerRef{models.ScopeNode, cluster, node})
+	}
+
+	var configs []models.Overlay
+	layers := []LayerInfo{}
+	for _, ref := range refs {
+		if ref.scope == models.ScopeProfile {
+			profiles, err := h.db.LayerProfiles(ref.cluster, ref.node)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			for _, profile := range profiles {
+				overlay, err := models.ParseOverlay([]byte(profile.ConfigJSON))
+				if err != nil {
+					return nil, nil, nil, fmt.Errorf("invalid profile %s config json: %w", profile.Name, err)
+				}
+				configs = append(configs, overlay)
+				layers = append(layers, LayerInfo{
+					Scope:       models.ScopeProfile,
+					Profile:     profile.Name,
+					ClusterName: ref.cluster,
+					NodeID:      ref.node,
+					Version:     profile.Version,
+					CreatedAt:   profile.CreatedAt,
+					CreatedBy:   profile.CreatedBy,
+				})
+			}
+			continue
+		}
+		if ref.scope == models.ScopeSelector {
+			selectors, err := h.nodeSelectors(ref.cluster, labels)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			for _, selector := range selectors {
+				overlay, err := models.ParseOverlay([]byte(selector.ConfigJSON))
+				if err != nil {
+					return nil, nil, nil, fmt.Errorf("invalid selector %s config json: %w", selector.Name, err)
+				}
+				configs = append(configs, overlay)
+				layers = append(layers, LayerInfo{
+					Scope:       models.ScopeSelector,
+					Selector:    selector.Name,
+					ClusterName: ref.cluster,
+					NodeID:      ref.node,
+					Version:     selector.Version,
+					CreatedAt:   selector.UpdatedAt,
+					CreatedBy:   selector.UpdatedBy,
+				})
+			}
+			continue
+		}
+
+		pinnedNode := ""
+		if scope == models.ScopeNode {
+			pinnedNode = node
+		}
+		record, pinned, err := h.layerRecord(ref, cluster, pinnedNode)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if record == nil {
+			continue
+		}
+
+		overlay, err := models.ParseOverlay([]byte(record.ConfigJSON))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid %s config json: %w", ref.scope, err)
+		}
+		configs = append(configs, overlay)
+		layers = append(layers, LayerInfo{
+			Scope:       record.Scope,
+			ClusterName: record.ClusterName,
+			NodeID:      record.NodeID,
+			Version:     record.Version,
+			Pinned:      pinned,
+			CreatedAt:   record.CreatedAt,
+			CreatedBy:   record.CreatedBy,
+		})
+	}
+
+	cfg, prov := models.ResolveConfigWithProvenance(configs...)
+	sources := make(map[string]FieldSource, len(prov))
+	for path, index := range prov {
+		if index == models.DefaultLayer {
+			sources[path] = FieldSource{Source: "default"}
+			continue
+		}
+		layer := layers[index]
+		sources[path] = FieldSource{
+			Source:      string(layer.Scope),
+			Profile:     layer.Profile,
+			Selector:    layer.Selector,
+			ClusterName: layer.ClusterName,
+			NodeID:      layer.NodeID,
+			Version:     layer.Version,
+			CreatedAt:   &layer.CreatedAt,
+			CreatedBy:   layer.CreatedBy,
+		}
+	}
+
+	return cfg, layers, sources, nil
+}
+
+// layerRef 对应的配置在节点 node 上生效的版本：node 不为空、该级（全局、集群）正在灰度发布且节点不是灰度节点时
+// 为基准版本（Agent 读取固定节点或守护节点，基准版本为 0 时没有该级配置），pinned 为 true；否则为最新版本
+func (h *Handler) layerRecord(ref layerRef, cluster, node string) (*models.ConfigRecord, bool, error) {
+	if node != "" && (ref.scope == models.ScopeGlobal || ref.scope == models.ScopeCluster) {
+		base, pinned, err := h.db.CanaryBaseVersion(ref.scope, cluster, node)
+		if err != nil {
+			return nil, false, err
+		}
+		if pinned {
+			if base == 0 {
+				return nil, true, nil
+			}
+			record, err := h.db.GetConfigByVersion(ref.scope, ref.cluster, "", base)
+			return record, true, err
+		}
+	}
+	record, err := h.db.GetLatestConfig(ref.scope, ref.cluster, ref.node)
+	return record, false, err
+}
+
+// nodeSelectors 获取与节点标签 labels 匹配的标签选择层
+func (h *Handler) nodeSelectors(cluster string, labels map[string]string) ([]*models.Selector, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return h.db.MatchingSelectors(cluster, labels)
+}
+
+// nodeLabels 节点匹配标签选择层使用的标签：清单中的标签，再以 Agent 在线节点中上报的环境变量标签覆盖同名标签，
+// 与 Agent 的合并方式一致。Agent 不在线（或读取 ZooKeeper 失败）时无法得知其环境变量中的标签，envKnown 为 false，
+// 只按清单中的标签匹配
+func (h *Handler) nodeLabels(cluster, node string) (labels, envLabels map[string]string, envKnown bool, err error) {
+	labels = map[string]string{}
+	record, err := h.db.GetNode(cluster, node)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if record != nil {
+		for key, value := range record.Labels {
+			labels[key] = value
+		}
+	}
+
+	data, err := h.zkClient.GetConfig(zk.GetLivePath(cluster, node))
+	if err != nil {
+		h.logger.Warn("failed to read live agent labels", zap.String("cluster", cluster), zap.String("node", node), zap.Error(err))
+		return labels, nil, false, nil
+	}
+	if data == nil {
+		return labels, nil, false, nil
+	}
+	var agent models.AgentInfo
+	if err := json.Unmarshal(data, &agent); err != nil {
+		h.logger.Warn("invalid live agent data", zap.String("cluster", cluster), zap.String("node", node), zap.Error(err))
+		return labels, nil, false, nil
+	}
+	for key, value := range agent.Labels {
+		labels[key] = value
+	}
+	return labels, agent.Labels, true, nil
+}
+
+// GetEffectiveNodeConfig 预览节点实际生效的配置（与 config-agent 合并结果一致），
+// provenance 给出每个字段由哪一级配置的哪个版本、由谁提供。labels 为匹配标签选择层使用的标签，
+// env_labels_known 为 false 时 Agent 不在线，未包含其环境变量中的标签，预览可能与 Agent 上线后的结果不同
+func (h *Handler) GetEffectiveNodeConfig(c *gin.Context) {
+	cluster := c.Param("cluster")
+	node := c.Param("node")
+
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidateNodeID(node); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	// 标签只读取一次，返回的 labels 即为匹配标签选择层所用的标签
+	labels, envLabels, envKnown, err := h.nodeLabels(cluster, node)
+	if err != nil {
+		h.logger.Error("failed to get node labels", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	cfg, layers, provenance, err := h.resolveConfig(models.ScopeNode, cluster, node, labels)
+	if err != nil {
+		h.logger.Error("failed to resolve node config", zap.Error(err),
+			zap.String("cluster", cluster), zap.String("node", node))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"cluster":          cluster,
+			"node":             node,
+			"config":           cfg,
+			"layers":           layers,
+			"provenance":       provenance,
+			"labels":           labels,
+			"env_labels":       envLabels,
+			"env_labels_known": envKnown,
+		},
+	})
+}
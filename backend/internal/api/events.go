@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/events"
+	"go.uber.org/zap"
+)
+
+// eventRetry 建议客户端断线后重连的等待时间
+const eventRetry = 3 * time.Second
+
+// StreamEvents 以 Server-Sent Events 推送实时事件。连接建立时先推送当前的 ZooKeeper 连接状态，
+// 携带 Last-Event-ID 重连时补发缓存中错过的事件；types 可按逗号分隔的事件类型过滤。
+// 每次心跳重新校验会话，会话失效后关闭事件流，客户端重连时重新认证
+func (h *Handler) StreamEvents(c *gin.Context) {
+	lastID, _ := strconv.ParseUint(c.GetHeader("Last-Event-ID"), 10, 64)
+	var types map[string]bool
+	if raw := c.Query("types"); raw != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(raw, ",") {
+			types[strings.TrimSpace(t)] = true
+		}
+	}
+	wanted := func(event events.Event) bool {
+		return types == nil || types[event.Type]
+	}
+
+	sub, missed := h.events.Subscribe(lastID)
+	defer h.events.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry.Milliseconds())
+	current := events.Event{
+		Type: events.TypeZKState,
+		Time: time.Now(),
+		Data: events.ZKState{State: h.zkClient.GetState(), Connected: h.zkClient.IsConnected()},
+	}
+	for _, event := range append([]events.Event{current}, missed...) {
+		if wanted(event) {
+			writeEvent(w, event)
+		}
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(h.config.EventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			// 通道关闭：读取过慢被断开，客户端携带 Last-Event-ID 重连后补发
+			if !ok {
+				return
+			}
+			if !wanted(event) {
+				continue
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if !h.streamAuthorized(c) {
+				return
+			}
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
+
+// streamAuthorized 重新校验事件流的会话：已退出登录、会话过期、账号被禁用或须修改密码，
+// 或者角色、授权集群与建立连接时不同（例如被降级）时返回 false。校验出错时同样返回 false，由客户端重连
+func (h *Handler) streamAuthorized(c *gin.Context) bool {
+	user, _, err := h.sessionUser(c.GetString(ctxToken))
+	if err != nil {
+		h.logger.Warn("failed to recheck event stream session", zap.Error(err))
+		return false
+	}
+	if user == nil || user.MustChangePassword {
+		return false
+	}
+	opened := authUser(c)
+	return user.Role == opened.Role && strings.Join(user.Clusters, ",") == strings.Join(opened.Clusters, ",")
+}
+
+// writeEvent 写入一条 SSE 事件，data 为完整的事件 JSON；没有 ID 的事件（连接时的当前状态）不写 id 行
+func writeEvent(w io.Writer, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.ID > 0 {
+		fmt.Fprintf(w, "id: %d\n", event.ID)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
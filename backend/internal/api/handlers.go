@@ -5,30 +5,59 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/canary"
 	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/events"
 	"github.com/yf-web/backend/internal/models"
+	"github.com/yf-web/backend/internal/notifier"
+	"github.com/yf-web/backend/internal/publisher"
+	"github.com/yf-web/backend/internal/reconcile"
 	"github.com/yf-web/backend/internal/validator"
 	"github.com/yf-web/backend/internal/zk"
 	"go.uber.org/zap"
 )
 
+// Config API 配置
+type Config struct {
+	TokenTTL       time.Duration        // 登录 token 有效期
+	Lockout        models.LockoutPolicy // 登录失败锁定策略
+	Review         models.ReviewPolicy  // 各级配置发布前需要的审批数
+	EventHeartbeat time.Duration        // 事件流空闲时发送心跳的间隔，避免代理断开空闲连接
+}
+
 // Handler API 处理器
 type Handler struct {
-	db        *db.PostgresDB
-	zkClient  *zk.Client
-	validator *validator.ConfigValidator
-	logger    *zap.Logger
+	db         *db.PostgresDB
+	zkClient   *zk.Client
+	publisher  *publisher.Publisher
+	reconciler *reconcile.Reconciler
+	canary     *canary.Controller
+	notifier   *notifier.Notifier
+	events     *events.Hub
+	validator  *validator.ConfigValidator
+	config     Config
+	logger     *zap.Logger
 }
 
 // NewHandler 创建处理器
-func NewHandler(db *db.PostgresDB, zkClient *zk.Client, logger *zap.Logger) *Handler {
+func NewHandler(db *db.PostgresDB, zkClient *zk.Client, pub *publisher.Publisher, rec *reconcile.Reconciler, canaries *canary.Controller, notify *notifier.Notifier, hub *events.Hub, cfg Config, logger *zap.Logger) *Handler {
+	if cfg.EventHeartbeat <= 0 {
+		cfg.EventHeartbeat = 15 * time.Second
+	}
 	return &Handler{
-		db:        db,
-		zkClient:  zkClient,
-		validator: validator.NewConfigValidator(),
-		logger:    logger,
+		db:         db,
+		zkClient:   zkClient,
+		publisher:  pub,
+		reconciler: rec,
+		canary:     canaries,
+		notifier:   notify,
+		events:     hub,
+		validator:  validator.NewConfigValidator(),
+		config:     cfg,
+		logger:     logger,
 	}
 }
 
@@ -39,10 +68,11 @@ type Response struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
-// ConfigRequest 配置请求
+// ConfigRequest 配置请求，config 为该级的覆盖配置：
+// 缺省字段继承上级，给出的值（包括 false、0、""、[]）覆盖上级，null 清除继承的值
 type ConfigRequest struct {
-	Config    models.YafConfig `json:"config"`
-	CreatedBy string           `json:"created_by"`
+	Config      json.RawMessage `json:"config"`
+	BaseVersion *int            `json:"base_version,omitempty"` // 修改所基于的版本，也可通过 If-Match 请求头传递
 }
 
 // LoginRequest 登录请求
@@ -61,10 +91,41 @@ func (h *Handler) RegisterRoutes(r *gin.Engine) {
 	// CORS 中间件
 	r.Use(corsMiddleware())
 
-	api := r.Group("/api/v1")
+	// 登录接口（无需认证）
+	r.POST("/api/v1/auth/login", h.auditMiddleware(), h.Login)
+
+	// 审计中间件在认证之前，未授权的请求同样会被记录；审批策略在认证之后统一检查
+	api := r.Group("/api/v1", h.auditMiddleware(), h.authMiddleware(), h.reviewMiddleware())
 	{
-		// 登录接口
-		api.POST("/auth/login", h.Login)
+		// 退出登录 / 当前用户 / 修改密码
+		api.POST("/auth/logout", h.Logout)
+		api.GET("/auth/me", h.GetCurrentUser)
+		api.POST("/auth/password", h.ChangePassword)
+
+		// 用户管理（仅管理员）
+		users := api.Group("/users", h.requireAdmin())
+		users.GET("", h.ListUsers)
+		users.POST("", h.CreateUser)
+		users.GET("/:username", h.GetUser)
+		users.DELETE("/:username", h.DeleteUser)
+		users.PUT("/:username/role", h.SetUserRole)
+		users.PUT("/:username/grants", h.SetUserGrants)
+		users.POST("/:username/password", h.ResetUserPassword)
+		users.POST("/:username/disable", h.DisableUser)
+		users.POST("/:username/enable", h.EnableUser)
+
+		// 审计日志（仅管理员）
+		api.GET("/audit", h.requireAdmin(), h.ListAudit)
+
+		// 通知订阅与投递记录（仅管理员）
+		notifications := api.Group("/notifications", h.requireAdmin())
+		notifications.GET("/subscriptions", h.ListSubscriptions)
+		notifications.POST("/subscriptions", h.CreateSubscription)
+		notifications.PUT("/subscriptions/:id", h.UpdateSubscription)
+		notifications.DELETE("/subscriptions/:id", h.DeleteSubscription)
+		notifications.POST("/subscriptions/:id/test", h.TestSubscription)
+		notifications.GET("/deliveries", h.ListDeliveries)
+		notifications.POST("/deliveries/:id/retry", h.RetryDelivery)
 
 		// 系统设置
 		api.GET("/settings", h.GetSettings)
@@ -73,6 +134,63 @@ func (h *Handler) RegisterRoutes(r *gin.Engine) {
 		// 系统状态
 		api.GET("/status", h.GetSystemStatus)
 
+		// 实时事件流（Server-Sent Events）：配置保存与回滚、ZooKeeper 连接状态、Agent 上下线
+		api.GET("/events", h.StreamEvents)
+
+		// config-agent 在线状态
+		api.GET("/agents", h.ListAgents)
+
+		// 发布进度：各节点是否已应用最新的配置版本
+		api.GET("/rollout", h.ListRollouts)
+
+		// 全局、集群配置的灰度发布
+		api.GET("/canaries", h.ListCanaries)
+		api.POST("/canaries", h.CreateCanary)
+		api.GET("/canaries/:id", h.GetCanary)
+		api.POST("/canaries/:id/promote", h.PromoteCanary)
+		api.POST("/canaries/:id/rollback", h.RollbackCanary)
+
+		// 草稿与评审
+		api.GET("/review/policy", h.GetReviewPolicy)
+		api.GET("/drafts", h.ListDrafts)
+		api.POST("/drafts", h.CreateDraft)
+		api.GET("/drafts/:id", h.GetDraft)
+		api.PUT("/drafts/:id", h.UpdateDraft)
+		api.DELETE("/drafts/:id", h.CloseDraft)
+		api.POST("/drafts/:id/submit", h.SubmitDraft)
+		api.POST("/drafts/:id/approve", h.ApproveDraft)
+		api.POST("/drafts/:id/reject", h.RejectDraft)
+		api.POST("/drafts/:id/comments", h.CommentDraft)
+
+		// 定时发布
+		api.GET("/schedules", h.ListSchedules)
+		api.POST("/schedules", h.CreateSchedule)
+		api.GET("/schedules/:id", h.GetSchedule)
+		api.PUT("/schedules/:id", h.RescheduleSchedule)
+		api.DELETE("/schedules/:id", h.CancelSchedule)
+
+		// 配置档：可复用的命名覆盖配置，挂载到集群或节点（修改配置档仅管理员）
+		api.GET("/profiles", h.ListProfiles)
+		api.POST("/profiles", h.CreateProfile)
+		api.GET("/profiles/:name", h.GetProfile)
+		api.PUT("/profiles/:name", h.SaveProfile)
+		api.DELETE("/profiles/:name", h.DeleteProfile)
+		api.GET("/profiles/:name/history", h.GetProfileHistory)
+
+		// 标签选择层：按节点标签在集群配置与节点配置之间合并
+		api.GET("/selectors", h.ListSelectors)
+		api.POST("/selectors", h.CreateSelector)
+		api.GET("/selectors/:name", h.GetSelector)
+		api.PUT("/selectors/:name", h.SaveSelector)
+		api.DELETE("/selectors/:name", h.DeleteSelector)
+
+		// 数据库与 ZooKeeper 的配置漂移检查与修复（仅管理员）
+		api.GET("/sync/drift", h.requireAdmin(), h.GetDrift)
+		api.POST("/sync/drift/repair", h.requireAdmin(), h.RepairDrift)
+
+		// 从 ZooKeeper 导入已有配置（仅管理员）
+		api.POST("/sync/import", h.requireAdmin(), h.ImportFromZK)
+
 		// 获取支持的字段列表
 		api.GET("/fields", h.GetSupportedFields)
 
@@ -82,31 +200,61 @@ func (h *Handler) RegisterRoutes(r *gin.Engine) {
 		// 全局配置
 		api.GET("/config/global", h.GetGlobalConfig)
 		api.POST("/config/global", h.SaveGlobalConfig)
+		api.PATCH("/config/global", h.PatchGlobalConfig)
 		api.GET("/config/global/history", h.GetGlobalConfigHistory)
 
-		// 集群配置
+		// 集群与节点清单
 		api.GET("/clusters", h.ListClusters)
+		api.POST("/clusters", h.CreateCluster)
+		api.GET("/clusters/archived", h.ListArchivedClusters)
+		api.GET("/clusters/:cluster", h.GetCluster)
+		api.PUT("/clusters/:cluster", h.UpdateCluster)
+		api.DELETE("/clusters/:cluster", h.DeleteCluster)
+		api.POST("/clusters/:cluster/archive", h.ArchiveCluster)
+		api.DELETE("/clusters/:cluster/archive", h.UnarchiveCluster)
+		api.GET("/clusters/:cluster/rollout", h.GetClusterRollout)
+		api.GET("/clusters/:cluster/profiles", h.GetClusterProfiles)
+		api.PUT("/clusters/:cluster/profiles", h.SetClusterProfiles)
+		api.GET("/clusters/:cluster/nodes", h.ListNodes)
+		api.POST("/clusters/:cluster/nodes", h.CreateNode)
+		api.GET("/clusters/:cluster/nodes/:node", h.GetNode)
+		api.PUT("/clusters/:cluster/nodes/:node", h.UpdateNode)
+		api.DELETE("/clusters/:cluster/nodes/:node", h.DeleteNode)
+		api.GET("/clusters/:cluster/nodes/:node/profiles", h.GetNodeProfiles)
+		api.PUT("/clusters/:cluster/nodes/:node/profiles", h.SetNodeProfiles)
+
+		// 集群配置
 		api.GET("/config/cluster/:cluster", h.GetClusterConfig)
 		api.POST("/config/cluster/:cluster", h.SaveClusterConfig)
+		api.PATCH("/config/cluster/:cluster", h.PatchClusterConfig)
+		api.DELETE("/config/cluster/:cluster", h.DeleteClusterConfig)
 		api.GET("/config/cluster/:cluster/history", h.GetClusterConfigHistory)
 
 		// 节点配置
-		api.GET("/clusters/:cluster/nodes", h.ListNodes)
 		api.GET("/config/cluster/:cluster/node/:node", h.GetNodeConfig)
 		api.POST("/config/cluster/:cluster/node/:node", h.SaveNodeConfig)
+		api.PATCH("/config/cluster/:cluster/node/:node", h.PatchNodeConfig)
+		api.DELETE("/config/cluster/:cluster/node/:node", h.DeleteNodeConfig)
 		api.GET("/config/cluster/:cluster/node/:node/history", h.GetNodeConfigHistory)
+		api.GET("/config/cluster/:cluster/node/:node/effective", h.GetEffectiveNodeConfig)
 
 		// 配置回滚
 		api.POST("/config/rollback", h.RollbackConfig)
+
+		// 配置版本对比
+		api.GET("/config/diff", h.DiffConfig)
 	}
+
+	checkReviewRoutes(r.Routes())
 }
 
 // corsMiddleware CORS 中间件
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, If-Match, Last-Event-ID")
+		c.Header("Access-Control-Expose-Headers", "ETag")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
@@ -115,36 +263,6 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
-// Login 用户登录
-func (h *Handler) Login(c *gin.Context) {
-	var req LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "用户名和密码不能为空"})
-		return
-	}
-
-	valid, err := h.db.ValidateUser(req.Username, req.Password)
-	if err != nil {
-		h.logger.Error("failed to validate user", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: "服务器错误"})
-		return
-	}
-
-	if !valid {
-		c.JSON(http.StatusUnauthorized, Response{Code: 401, Message: "用户名或密码错误"})
-		return
-	}
-
-	c.JSON(http.StatusOK, Response{
-		Code:    0,
-		Message: "登录成功",
-		Data: map[string]string{
-			"username": req.Username,
-			"token":    "logged_in",
-		},
-	})
-}
-
 // GetSettings 获取系统设置
 func (h *Handler) GetSettings(c *gin.Context) {
 	settings, err := h.db.GetAllSettings()
@@ -171,11 +289,21 @@ func (h *Handler) GetSettings(c *gin.Context) {
 
 // SaveSettings 保存系统设置
 func (h *Handler) SaveSettings(c *gin.Context) {
+	c.Set(ctxAuditTarget, auditTarget{Scope: "settings"})
+	before, err := h.db.GetAllSettings()
+	if err == nil {
+		c.Set(ctxAuditBefore, before)
+	}
+	if !h.authorizeAdmin(c) {
+		return
+	}
+
 	var req SettingsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
 		return
 	}
+	auditAfter(c, map[string]string{"zookeeper_servers": req.ZookeeperServers})
 
 	// 验证 ZooKeeper 地址格式
 	if req.ZookeeperServers == "" {
@@ -189,6 +317,15 @@ func (h *Handler) SaveSettings(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
 		return
 	}
+	after := map[string]string{}
+	for key, value := range before {
+		after[key] = value
+	}
+	after["zookeeper_servers"] = req.ZookeeperServers
+	if before == nil {
+		before = map[string]string{}
+	}
+	h.notifySettings(before, after, currentUser(c))
 
 	// 尝试重新连接 ZooKeeper
 	servers := strings.Split(req.ZookeeperServers, ",")
@@ -269,7 +406,7 @@ func (h *Handler) GetDefaultConfig(c *gin.Context) {
 	})
 }
 
-// GetGlobalConfig 获取全局配置
+// GetGlobalConfig 获取全局配置：config 为合并默认配置后的结果，overlay 为保存的覆盖配置
 func (h *Handler) GetGlobalConfig(c *gin.Context) {
 	record, err := h.db.GetLatestConfig(models.ScopeGlobal, "", "")
 	if err != nil {
@@ -281,63 +418,83 @@ func (h *Handler) GetGlobalConfig(c *gin.Context) {
 		c.JSON(http.StatusOK, Response{Code: 0, Message: "no config found", Data: nil})
 		return
 	}
+	setETag(c, record.Version)
 
-	var cfg models.YafConfig
-	if err := json.Unmarshal([]byte(record.ConfigJSON), &cfg); err != nil {
+	overlay, err := models.ParseOverlay([]byte(record.ConfigJSON))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: "invalid config json"})
 		return
 	}
+	cfg, _, _, err := h.resolveConfig(models.ScopeGlobal, "", "", nil)
+	if err != nil {
+		h.logger.Error("failed to resolve global config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
 		Data: map[string]interface{}{
 			"config":     cfg,
+			"overlay":    overlay,
 			"version":    record.Version,
 			"created_at": record.CreatedAt,
 			"created_by": record.CreatedBy,
+			"sync_state": h.syncState(models.ScopeGlobal, "", ""),
 		},
 	})
 }
 
 // SaveGlobalConfig 保存全局配置
 func (h *Handler) SaveGlobalConfig(c *gin.Context) {
+	h.auditConfigTarget(c, models.ScopeGlobal, "", "")
+	if !h.authorize(c, models.ScopeGlobal, "") {
+		return
+	}
+
 	var req ConfigRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
 		return
 	}
+	baseVersion, err := expectedVersion(c, req.BaseVersion)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	overlay, err := models.ParseOverlay(req.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	auditAfter(c, overlay)
 
 	// 验证配置
-	if err := h.validator.Validate(&req.Config); err != nil {
+	if err := h.validator.ValidateOverlay(overlay); err != nil {
 		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
 		return
 	}
 
-	configJSON, _ := json.Marshal(req.Config)
+	configJSON, _ := json.Marshal(overlay)
 
 	// 保存到数据库
 	record := &models.ConfigRecord{
 		Scope:      models.ScopeGlobal,
 		ConfigJSON: string(configJSON),
-		CreatedBy:  req.CreatedBy,
+		CreatedBy:  currentUser(c),
 	}
-	if err := h.db.SaveConfig(record); err != nil {
-		h.logger.Error("failed to save global config", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+	if !h.saveConfig(c, record, baseVersion) {
 		return
 	}
 
-	// 同步到 ZooKeeper
-	if err := h.zkClient.SetConfig(zk.GetGlobalConfigPath(), req.Config); err != nil {
-		h.logger.Error("failed to sync global config to zk", zap.Error(err))
-		// 不返回错误，数据库已保存
-	}
+	// 同步到 ZooKeeper（失败时由后台任务重试）
+	syncState := h.publish(record)
 
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    map[string]int{"version": record.Version},
+		Data:    map[string]interface{}{"version": record.Version, "sync_state": syncState},
 	})
 }
 
@@ -353,18 +510,7 @@ func (h *Handler) GetGlobalConfigHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: records})
 }
 
-// ListClusters 列出所有集群
-func (h *Handler) ListClusters(c *gin.Context) {
-	clusters, err := h.db.ListClusters()
-	if err != nil {
-		h.logger.Error("failed to list clusters", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: clusters})
-}
-
-// GetClusterConfig 获取集群配置
+// GetClusterConfig 获取集群配置：config 为合并默认、全局配置后的结果，overlay 为保存的覆盖配置
 func (h *Handler) GetClusterConfig(c *gin.Context) {
 	cluster := c.Param("cluster")
 	if err := h.validator.ValidateClusterName(cluster); err != nil {
@@ -382,12 +528,19 @@ func (h *Handler) GetClusterConfig(c *gin.Context) {
 		c.JSON(http.StatusOK, Response{Code: 0, Message: "no config found", Data: nil})
 		return
 	}
+	setETag(c, record.Version)
 
-	var cfg models.YafConfig
-	if err := json.Unmarshal([]byte(record.ConfigJSON), &cfg); err != nil {
+	overlay, err := models.ParseOverlay([]byte(record.ConfigJSON))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: "invalid config json"})
 		return
 	}
+	cfg, _, _, err := h.resolveConfig(models.ScopeCluster, cluster, "", nil)
+	if err != nil {
+		h.logger.Error("failed to resolve cluster config", zap.Error(err), zap.String("cluster", cluster))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
@@ -395,9 +548,11 @@ func (h *Handler) GetClusterConfig(c *gin.Context) {
 		Data: map[string]interface{}{
 			"cluster":    cluster,
 			"config":     cfg,
+			"overlay":    overlay,
 			"version":    record.Version,
 			"created_at": record.CreatedAt,
 			"created_by": record.CreatedBy,
+			"sync_state": h.syncState(models.ScopeCluster, cluster, ""),
 		},
 	})
 }
@@ -409,40 +564,52 @@ func (h *Handler) SaveClusterConfig(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
 		return
 	}
+	h.auditConfigTarget(c, models.ScopeCluster, cluster, "")
+	if !h.authorize(c, models.ScopeCluster, cluster) {
+		return
+	}
 
 	var req ConfigRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
 		return
 	}
+	baseVersion, err := expectedVersion(c, req.BaseVersion)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	overlay, err := models.ParseOverlay(req.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	auditAfter(c, overlay)
 
-	if err := h.validator.Validate(&req.Config); err != nil {
+	if err := h.validator.ValidateOverlay(overlay); err != nil {
 		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
 		return
 	}
 
-	configJSON, _ := json.Marshal(req.Config)
+	configJSON, _ := json.Marshal(overlay)
 
 	record := &models.ConfigRecord{
 		Scope:       models.ScopeCluster,
 		ClusterName: cluster,
 		ConfigJSON:  string(configJSON),
-		CreatedBy:   req.CreatedBy,
+		CreatedBy:   currentUser(c),
 	}
-	if err := h.db.SaveConfig(record); err != nil {
-		h.logger.Error("failed to save cluster config", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+	if !h.saveConfig(c, record, baseVersion) {
 		return
 	}
 
-	if err := h.zkClient.SetConfig(zk.GetClusterConfigPath(cluster), req.Config); err != nil {
-		h.logger.Error("failed to sync cluster config to zk", zap.Error(err))
-	}
+	// 同步到 ZooKeeper（失败时由后台任务重试）
+	syncState := h.publish(record)
 
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    map[string]interface{}{"version": record.Version, "cluster": cluster},
+		Data:    map[string]interface{}{"version": record.Version, "cluster": cluster, "sync_state": syncState},
 	})
 }
 
@@ -460,24 +627,8 @@ func (h *Handler) GetClusterConfigHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: records})
 }
 
-// ListNodes 列出集群下的节点
-func (h *Handler) ListNodes(c *gin.Context) {
-	cluster := c.Param("cluster")
-	if err := h.validator.ValidateClusterName(cluster); err != nil {
-		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
-		return
-	}
-
-	nodes, err := h.db.ListNodes(cluster)
-	if err != nil {
-		h.logger.Error("failed to list nodes", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: nodes})
-}
-
-// GetNodeConfig 获取节点配置
+// GetNodeConfig 获取节点配置：config 为节点生效的配置，overlay 为保存的覆盖配置，
+// provenance 为 config 中每个字段的来源（与 GET .../effective 相同）
 func (h *Handler) GetNodeConfig(c *gin.Context) {
 	cluster := c.Param("cluster")
 	node := c.Param("node")
@@ -501,12 +652,25 @@ func (h *Handler) GetNodeConfig(c *gin.Context) {
 		c.JSON(http.StatusOK, Response{Code: 0, Message: "no config found", Data: nil})
 		return
 	}
+	setETag(c, record.Version)
 
-	var cfg models.YafConfig
-	if err := json.Unmarshal([]byte(record.ConfigJSON), &cfg); err != nil {
+	overlay, err := models.ParseOverlay([]byte(record.ConfigJSON))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: "invalid config json"})
 		return
 	}
+	labels, _, _, err := h.nodeLabels(cluster, node)
+	if err != nil {
+		h.logger.Error("failed to get node labels", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	cfg, _, provenance, err := h.resolveConfig(models.ScopeNode, cluster, node, labels)
+	if err != nil {
+		h.logger.Error("failed to resolve node config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
@@ -515,9 +679,12 @@ func (h *Handler) GetNodeConfig(c *gin.Context) {
 			"cluster":    cluster,
 			"node":       node,
 			"config":     cfg,
+			"overlay":    overlay,
+			"provenance": provenance,
 			"version":    record.Version,
 			"created_at": record.CreatedAt,
 			"created_by": record.CreatedBy,
+			"sync_state": h.syncState(models.ScopeNode, cluster, node),
 		},
 	})
 }
@@ -535,41 +702,53 @@ func (h *Handler) SaveNodeConfig(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
 		return
 	}
+	h.auditConfigTarget(c, models.ScopeNode, cluster, node)
+	if !h.authorize(c, models.ScopeNode, cluster) {
+		return
+	}
 
 	var req ConfigRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
 		return
 	}
+	baseVersion, err := expectedVersion(c, req.BaseVersion)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	overlay, err := models.ParseOverlay(req.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	auditAfter(c, overlay)
 
-	if err := h.validator.Validate(&req.Config); err != nil {
+	if err := h.validator.ValidateOverlay(overlay); err != nil {
 		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
 		return
 	}
 
-	configJSON, _ := json.Marshal(req.Config)
+	configJSON, _ := json.Marshal(overlay)
 
 	record := &models.ConfigRecord{
 		Scope:       models.ScopeNode,
 		ClusterName: cluster,
 		NodeID:      node,
 		ConfigJSON:  string(configJSON),
-		CreatedBy:   req.CreatedBy,
+		CreatedBy:   currentUser(c),
 	}
-	if err := h.db.SaveConfig(record); err != nil {
-		h.logger.Error("failed to save node config", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+	if !h.saveConfig(c, record, baseVersion) {
 		return
 	}
 
-	if err := h.zkClient.SetConfig(zk.GetNodeConfigPath(cluster, node), req.Config); err != nil {
-		h.logger.Error("failed to sync node config to zk", zap.Error(err))
-	}
+	// 同步到 ZooKeeper（失败时由后台任务重试）
+	syncState := h.publish(record)
 
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    map[string]interface{}{"version": record.Version, "cluster": cluster, "node": node},
+		Data:    map[string]interface{}{"version": record.Version, "cluster": cluster, "node": node, "sync_state": syncState},
 	})
 }
 
@@ -594,7 +773,7 @@ type RollbackRequest struct {
 	ClusterName string `json:"cluster_name,omitempty"`
 	NodeID      string `json:"node_id,omitempty"`
 	Version     int    `json:"version"`
-	CreatedBy   string `json:"created_by"`
+	BaseVersion *int   `json:"base_version,omitempty"` // 回滚时期望的当前版本，也可通过 If-Match 请求头传递
 }
 
 // RollbackConfig 回滚配置
@@ -606,6 +785,19 @@ func (h *Handler) RollbackConfig(c *gin.Context) {
 	}
 
 	scope := models.ConfigScope(req.Scope)
+	if !h.validateTarget(c, scope, &req.ClusterName, &req.NodeID) {
+		return
+	}
+	h.auditConfigTarget(c, scope, req.ClusterName, req.NodeID)
+	if !h.authorize(c, scope, req.ClusterName) {
+		return
+	}
+	baseVersion, err := expectedVersion(c, req.BaseVersion)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
 	record, err := h.db.GetConfigByVersion(scope, req.ClusterName, req.NodeID, req.Version)
 	if err != nil {
 		h.logger.Error("failed to get config version", zap.Error(err))
@@ -616,6 +808,16 @@ func (h *Handler) RollbackConfig(c *gin.Context) {
 		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "version not found"})
 		return
 	}
+	if record.Deleted {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "cannot roll back to a deleted version"})
+		return
+	}
+	overlay, err := models.ParseOverlay([]byte(record.ConfigJSON))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: "invalid config json"})
+		return
+	}
+	auditAfter(c, overlay)
 
 	// 创建新版本（回滚实际上是创建一个内容相同的新版本）
 	newRecord := &models.ConfigRecord{
@@ -623,36 +825,19 @@ func (h *Handler) RollbackConfig(c *gin.Context) {
 		ClusterName: req.ClusterName,
 		NodeID:      req.NodeID,
 		ConfigJSON:  record.ConfigJSON,
-		CreatedBy:   req.CreatedBy,
+		CreatedBy:   currentUser(c),
+		RollbackTo:  req.Version,
 	}
-	if err := h.db.SaveConfig(newRecord); err != nil {
-		h.logger.Error("failed to save rollback config", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+	if !h.saveConfig(c, newRecord, baseVersion) {
 		return
 	}
 
-	// 同步到 ZooKeeper
-	var cfg models.YafConfig
-	json.Unmarshal([]byte(record.ConfigJSON), &cfg)
-
-	var zkPath string
-	switch scope {
-	case models.ScopeGlobal:
-		zkPath = zk.GetGlobalConfigPath()
-	case models.ScopeCluster:
-		zkPath = zk.GetClusterConfigPath(req.ClusterName)
-	case models.ScopeNode:
-		zkPath = zk.GetNodeConfigPath(req.ClusterName, req.NodeID)
-	}
-
-	if err := h.zkClient.SetConfig(zkPath, cfg); err != nil {
-		h.logger.Error("failed to sync rollback config to zk", zap.Error(err))
-	}
+	// 同步到 ZooKeeper（失败时由后台任务重试）
+	syncState := h.publish(newRecord)
 
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "rollback success",
-		Data:    map[string]int{"new_version": newRecord.Version},
+		Data:    map[string]interface{}{"new_version": newRecord.Version, "sync_state": syncState},
 	})
 }
-
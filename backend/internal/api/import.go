@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/importer"
+	"go.uber.org/zap"
+)
+
+// ImportFromZK 将 ZooKeeper 中已有的配置导入数据库（仅管理员）；dry_run=true 时只返回导入预览
+func (h *Handler) ImportFromZK(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid dry_run"})
+		return
+	}
+
+	report, err := importer.New(h.db, h.zkClient, h.logger).Import(dryRun)
+	if err != nil {
+		h.logger.Error("failed to import configs from zk", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if !dryRun {
+		auditAfter(c, report)
+		h.publisher.Kick()
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: report})
+}
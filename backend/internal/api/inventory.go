@@ -0,0 +1,382 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// CreateClusterRequest 登记集群请求
+type CreateClusterRequest struct {
+	Name string `json:"name" binding:"required"`
+	models.InventoryMeta
+}
+
+// CreateNodeRequest 登记节点请求
+type CreateNodeRequest struct {
+	NodeID string `json:"node_id" binding:"required"`
+	models.InventoryMeta
+}
+
+// ListClusters 列出登记的集群及其配置概况，默认不含已归档的集群（include_archived=true 时包含）
+func (h *Handler) ListClusters(c *gin.Context) {
+	includeArchived, _ := strconv.ParseBool(c.Query("include_archived"))
+	clusters, err := h.db.ListClusters(includeArchived)
+	if err != nil {
+		h.logger.Error("failed to list clusters", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: clusters})
+}
+
+// GetCluster 获取集群的描述信息与配置概况
+func (h *Handler) GetCluster(c *gin.Context) {
+	cluster := c.Param("cluster")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	record, err := h.db.GetCluster(cluster)
+	if err != nil {
+		h.logger.Error("failed to get cluster", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "cluster not found"})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: record})
+}
+
+// CreateCluster 登记集群，登记后即可配置或添加节点，尚无配置时节点继承全局配置
+func (h *Handler) CreateCluster(c *gin.Context) {
+	var req CreateClusterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidateClusterName(req.Name); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidateInventoryMeta(&req.InventoryMeta); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	auditInventoryTarget(c, models.ScopeCluster, req.Name, "", nil)
+	if !h.authorize(c, models.ScopeCluster, req.Name) {
+		return
+	}
+
+	cluster := &models.Cluster{Name: req.Name, InventoryMeta: req.InventoryMeta, CreatedBy: currentUser(c)}
+	err := h.db.CreateCluster(cluster)
+	if err == db.ErrClusterExists {
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: "集群已存在"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to create cluster", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	auditAfter(c, req.InventoryMeta)
+	h.respondCluster(c, req.Name)
+}
+
+// UpdateCluster 修改集群的描述、负责人与标签（整体替换）
+func (h *Handler) UpdateCluster(c *gin.Context) {
+	cluster := c.Param("cluster")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	var meta models.InventoryMeta
+	if err := c.ShouldBindJSON(&meta); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidateInventoryMeta(&meta); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	h.auditCluster(c, cluster)
+	if !h.authorize(c, models.ScopeCluster, cluster) {
+		return
+	}
+
+	err := h.db.UpdateCluster(cluster, meta, currentUser(c))
+	if err == db.ErrClusterNotFound {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "cluster not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to update cluster", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	auditAfter(c, meta)
+	h.respondCluster(c, cluster)
+}
+
+// DeleteCluster 删除集群的清单记录及其节点记录。集群或节点仍有配置时返回 409，
+// 需先删除集群配置（DELETE /config/cluster/:cluster）
+func (h *Handler) DeleteCluster(c *gin.Context) {
+	cluster := c.Param("cluster")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	h.auditCluster(c, cluster)
+	if !h.authorize(c, models.ScopeCluster, cluster) {
+		return
+	}
+
+	err := h.db.DeleteCluster(cluster)
+	switch err {
+	case nil:
+	case db.ErrClusterNotFound:
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "cluster not found"})
+		return
+	case db.ErrStillConfigured:
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: "集群仍有配置，请先删除集群配置"})
+		return
+	case db.ErrProfilesAttached:
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: "集群或其节点仍挂载配置档，请先卸载"})
+		return
+	default:
+		h.logger.Error("failed to delete cluster", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	h.removeLabels(cluster, "")
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "deleted", Data: map[string]string{"cluster": cluster}})
+}
+
+// ListNodes 列出集群下登记的节点及其配置概况
+func (h *Handler) ListNodes(c *gin.Context) {
+	cluster := c.Param("cluster")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	nodes, err := h.db.ListNodes(cluster)
+	if err != nil {
+		h.logger.Error("failed to list nodes", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: nodes})
+}
+
+// GetNode 获取节点的描述信息与配置概况
+func (h *Handler) GetNode(c *gin.Context) {
+	cluster, node, ok := h.nodeParams(c)
+	if !ok {
+		return
+	}
+
+	record, err := h.db.GetNode(cluster, node)
+	if err != nil {
+		h.logger.Error("failed to get node", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "node not found"})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: record})
+}
+
+// CreateNode 在已登记的集群下登记节点，尚无节点配置时节点继承集群配置
+func (h *Handler) CreateNode(c *gin.Context) {
+	cluster := c.Param("cluster")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	var req CreateNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidateNodeID(req.NodeID); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidateInventoryMeta(&req.InventoryMeta); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	auditInventoryTarget(c, models.ScopeNode, cluster, req.NodeID, nil)
+	if !h.authorize(c, models.ScopeNode, cluster) {
+		return
+	}
+
+	node := &models.Node{ClusterName: cluster, NodeID: req.NodeID, InventoryMeta: req.InventoryMeta, CreatedBy: currentUser(c)}
+	err := h.db.CreateNode(node)
+	switch err {
+	case nil:
+	case db.ErrClusterNotFound:
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "cluster not found"})
+		return
+	case db.ErrNodeExists:
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: "节点已存在"})
+		return
+	default:
+		h.logger.Error("failed to create node", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	auditAfter(c, req.InventoryMeta)
+	h.respondNode(c, cluster, req.NodeID)
+}
+
+// UpdateNode 修改节点的描述、负责人与标签（整体替换）
+func (h *Handler) UpdateNode(c *gin.Context) {
+	cluster, node, ok := h.nodeParams(c)
+	if !ok {
+		return
+	}
+	var meta models.InventoryMeta
+	if err := c.ShouldBindJSON(&meta); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidateInventoryMeta(&meta); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	h.auditNode(c, cluster, node)
+	if !h.authorize(c, models.ScopeNode, cluster) {
+		return
+	}
+
+	err := h.db.UpdateNode(cluster, node, meta, currentUser(c))
+	if err == db.ErrNodeNotFound {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "node not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to update node", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	auditAfter(c, meta)
+	h.respondNode(c, cluster, node)
+}
+
+// DeleteNode 删除节点的清单记录。节点仍有配置时返回 409，
+// 需先删除节点配置（DELETE /config/cluster/:cluster/node/:node）
+func (h *Handler) DeleteNode(c *gin.Context) {
+	cluster, node, ok := h.nodeParams(c)
+	if !ok {
+		return
+	}
+	h.auditNode(c, cluster, node)
+	if !h.authorize(c, models.ScopeNode, cluster) {
+		return
+	}
+
+	err := h.db.DeleteNode(cluster, node)
+	switch err {
+	case nil:
+	case db.ErrNodeNotFound:
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "node not found"})
+		return
+	case db.ErrStillConfigured:
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: "节点仍有配置，请先删除节点配置"})
+		return
+	case db.ErrProfilesAttached:
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: "节点仍挂载配置档，请先卸载"})
+		return
+	default:
+		h.logger.Error("failed to delete node", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	h.removeLabels(cluster, node)
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "deleted", Data: map[string]string{"cluster": cluster, "node": node}})
+}
+
+// removeLabels 删除已从清单中删除的集群或节点在 ZooKeeper 中登记的标签；失败只记录日志，
+// 节点重新登记时会覆盖写入
+func (h *Handler) removeLabels(cluster, node string) {
+	if err := h.publisher.RemoveLabels(cluster, node); err != nil {
+		h.logger.Warn("failed to remove node labels from zk", zap.Error(err),
+			zap.String("cluster", cluster),
+			zap.String("node", node),
+		)
+	}
+}
+
+// nodeParams 校验路径中的集群名与节点 ID
+func (h *Handler) nodeParams(c *gin.Context) (string, string, bool) {
+	cluster := c.Param("cluster")
+	node := c.Param("node")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return "", "", false
+	}
+	if err := h.validator.ValidateNodeID(node); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return "", "", false
+	}
+	return cluster, node, true
+}
+
+// auditCluster 记录审计目标，并以集群当前的描述信息作为变更前内容
+func (h *Handler) auditCluster(c *gin.Context, cluster string) {
+	record, err := h.db.GetCluster(cluster)
+	if err != nil {
+		h.logger.Warn("failed to load cluster for audit", zap.Error(err))
+	}
+	var before interface{}
+	if record != nil {
+		before = record.InventoryMeta
+	}
+	auditInventoryTarget(c, models.ScopeCluster, cluster, "", before)
+}
+
+// auditNode 记录审计目标，并以节点当前的描述信息作为变更前内容
+func (h *Handler) auditNode(c *gin.Context, cluster, node string) {
+	record, err := h.db.GetNode(cluster, node)
+	if err != nil {
+		h.logger.Warn("failed to load node for audit", zap.Error(err))
+	}
+	var before interface{}
+	if record != nil {
+		before = record.InventoryMeta
+	}
+	auditInventoryTarget(c, models.ScopeNode, cluster, node, before)
+}
+
+// respondCluster 返回修改后的集群记录
+func (h *Handler) respondCluster(c *gin.Context, cluster string) {
+	record, err := h.db.GetCluster(cluster)
+	if err != nil {
+		h.logger.Error("failed to get cluster", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: record})
+}
+
+// respondNode 返回修改后的节点记录
+func (h *Handler) respondNode(c *gin.Context, cluster, node string) {
+	record, err := h.db.GetNode(cluster, node)
+	if err != nil {
+		h.logger.Error("failed to get node", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: record})
+}
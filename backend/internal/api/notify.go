@@ -0,0 +1,229 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/diff"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// SubscriptionRequest 创建或修改通知订阅请求。events、scope、cluster_name 为空时不按该项过滤
+type SubscriptionRequest struct {
+	Name        string   `json:"name"`
+	Events      []string `json:"events"`
+	Scope       string   `json:"scope"`
+	ClusterName string   `json:"cluster_name"`
+	Channel     string   `json:"channel"` // webhook / email
+	Target      string   `json:"target"`  // webhook URL，或以逗号分隔的收件人地址
+	Secret      *string  `json:"secret"`  // webhook 签名密钥；修改时缺省保留原密钥，空字符串表示不签名
+	Enabled     *bool    `json:"enabled"` // 缺省为启用
+}
+
+// ListSubscriptions 列出通知订阅（仅管理员）
+func (h *Handler) ListSubscriptions(c *gin.Context) {
+	subscriptions, err := h.db.ListSubscriptions()
+	if err != nil {
+		h.logger.Error("failed to list subscriptions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: subscriptions})
+}
+
+// CreateSubscription 创建通知订阅（仅管理员）
+func (h *Handler) CreateSubscription(c *gin.Context) {
+	c.Set(ctxAuditTarget, auditTarget{Scope: "subscription"})
+
+	var req SubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	subscription := &models.Subscription{Enabled: true, CreatedBy: currentUser(c)}
+	if !h.parseSubscription(c, &req, subscription) {
+		return
+	}
+
+	if err := h.db.CreateSubscription(subscription); err != nil {
+		h.logger.Error("failed to create subscription", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: subscription})
+}
+
+// UpdateSubscription 修改通知订阅（仅管理员）
+func (h *Handler) UpdateSubscription(c *gin.Context) {
+	subscription, ok := h.loadSubscription(c)
+	if !ok {
+		return
+	}
+	c.Set(ctxAuditTarget, auditTarget{Scope: "subscription"})
+	c.Set(ctxAuditBefore, *subscription)
+
+	var req SubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if !h.parseSubscription(c, &req, subscription) {
+		return
+	}
+	subscription.UpdatedBy = currentUser(c)
+
+	if err := h.db.UpdateSubscription(subscription); err != nil {
+		if err == db.ErrSubscriptionNotFound {
+			c.JSON(http.StatusNotFound, Response{Code: 404, Message: "subscription not found"})
+			return
+		}
+		h.logger.Error("failed to update subscription", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: subscription})
+}
+
+// DeleteSubscription 删除通知订阅及其投递记录（仅管理员）
+func (h *Handler) DeleteSubscription(c *gin.Context) {
+	subscription, ok := h.loadSubscription(c)
+	if !ok {
+		return
+	}
+	c.Set(ctxAuditTarget, auditTarget{Scope: "subscription"})
+	c.Set(ctxAuditBefore, subscription)
+
+	if err := h.db.DeleteSubscription(subscription.ID); err != nil {
+		if err == db.ErrSubscriptionNotFound {
+			c.JSON(http.StatusNotFound, Response{Code: 404, Message: "subscription not found"})
+			return
+		}
+		h.logger.Error("failed to delete subscription", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "deleted", Data: map[string]int64{"id": subscription.ID}})
+}
+
+// TestSubscription 向订阅发送一条测试通知（不论其是否启用），返回投递记录的 ID，结果见投递记录
+func (h *Handler) TestSubscription(c *gin.Context) {
+	subscription, ok := h.loadSubscription(c)
+	if !ok {
+		return
+	}
+	id, err := h.notifier.Test(subscription, currentUser(c))
+	if err != nil {
+		h.logger.Error("failed to send test notification", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: map[string]int64{"delivery_id": id}})
+}
+
+// ListDeliveries 查询投递记录（仅管理员），支持参数：subscription_id、status、limit
+func (h *Handler) ListDeliveries(c *gin.Context) {
+	filter := models.DeliveryFilter{Status: c.Query("status")}
+	if s := c.Query("subscription_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid subscription_id"})
+			return
+		}
+		filter.SubscriptionID = id
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	deliveries, err := h.db.ListDeliveries(filter)
+	if err != nil {
+		h.logger.Error("failed to list deliveries", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: deliveries})
+}
+
+// RetryDelivery 立即重新投递一条记录（仅管理员），重试次数重新计算
+func (h *Handler) RetryDelivery(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid delivery id"})
+		return
+	}
+	if err := h.db.RetryDelivery(id); err != nil {
+		if err == db.ErrDeliveryNotFound {
+			c.JSON(http.StatusNotFound, Response{Code: 404, Message: "delivery not found"})
+			return
+		}
+		h.logger.Error("failed to retry delivery", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	h.notifier.Kick()
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: map[string]int64{"id": id}})
+}
+
+// parseSubscription 将请求写入 subscription 并校验，不合法时返回 400
+func (h *Handler) parseSubscription(c *gin.Context, req *SubscriptionRequest, subscription *models.Subscription) bool {
+	subscription.Name = strings.TrimSpace(req.Name)
+	subscription.Events = req.Events
+	subscription.Scope = models.ConfigScope(req.Scope)
+	subscription.ClusterName = req.ClusterName
+	subscription.Channel = req.Channel
+	subscription.Target = strings.TrimSpace(req.Target)
+	if req.Secret != nil {
+		subscription.Secret = *req.Secret
+	}
+	if req.Enabled != nil {
+		subscription.Enabled = *req.Enabled
+	}
+	if subscription.Channel != models.ChannelWebhook {
+		subscription.Secret = ""
+	}
+	auditAfter(c, subscription)
+
+	if err := h.validator.ValidateSubscription(subscription); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return false
+	}
+	if subscription.Channel == models.ChannelEmail && !h.notifier.EmailEnabled() {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "未配置邮件服务器（notify.smtp），不能使用邮件通知"})
+		return false
+	}
+	return true
+}
+
+// loadSubscription 读取路径参数中的通知订阅，不存在时返回 404
+func (h *Handler) loadSubscription(c *gin.Context) (*models.Subscription, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid subscription id"})
+		return nil, false
+	}
+	subscription, err := h.db.GetSubscription(id)
+	if err != nil {
+		h.logger.Error("failed to get subscription", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return nil, false
+	}
+	if subscription == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "subscription not found"})
+		return nil, false
+	}
+	return subscription, true
+}
+
+// notifySettings 登记系统设置修改事件
+func (h *Handler) notifySettings(before, after map[string]string, author string) {
+	event := &models.NotifyEvent{Type: models.EventSettingsSaved, Author: author}
+	if changes, err := diff.Values(before, after); err == nil {
+		event.Diff = changes
+	}
+	h.notifier.Notify(event)
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"github.com/yf-web/backend/internal/patch"
+	"go.uber.org/zap"
+)
+
+const (
+	// contentTypeMergePatch JSON Merge Patch（RFC 7396），null 表示删除该字段的覆盖、恢复继承
+	contentTypeMergePatch = "application/merge-patch+json"
+	// contentTypeJSONPatch JSON Patch（RFC 6902），可以用 add/replace 写入 null 显式清除继承值
+	contentTypeJSONPatch = "application/json-patch+json"
+)
+
+// PatchGlobalConfig 局部修改全局配置
+func (h *Handler) PatchGlobalConfig(c *gin.Context) {
+	h.auditConfigTarget(c, models.ScopeGlobal, "", "")
+	if !h.authorize(c, models.ScopeGlobal, "") {
+		return
+	}
+	h.patchConfig(c, models.ScopeGlobal, "", "")
+}
+
+// PatchClusterConfig 局部修改集群配置
+func (h *Handler) PatchClusterConfig(c *gin.Context) {
+	cluster := c.Param("cluster")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	h.auditConfigTarget(c, models.ScopeCluster, cluster, "")
+	if !h.authorize(c, models.ScopeCluster, cluster) {
+		return
+	}
+	h.patchConfig(c, models.ScopeCluster, cluster, "")
+}
+
+// PatchNodeConfig 局部修改节点配置
+func (h *Handler) PatchNodeConfig(c *gin.Context) {
+	cluster := c.Param("cluster")
+	node := c.Param("node")
+
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidateNodeID(node); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	h.auditConfigTarget(c, models.ScopeNode, cluster, node)
+	if !h.authorize(c, models.ScopeNode, cluster) {
+		return
+	}
+	h.patchConfig(c, models.ScopeNode, cluster, node)
+}
+
+// patchConfig 将请求体中的补丁应用到该级当前的覆盖配置上，并保存为新版本。
+// 补丁格式由 Content-Type 决定，application/json 按 JSON Merge Patch 处理；
+// 提供 If-Match 时只在其与当前版本一致时应用
+func (h *Handler) patchConfig(c *gin.Context, scope models.ConfigScope, cluster, node string) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	expected, err := expectedVersion(c, nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	current, err := h.db.GetLatestConfig(scope, cluster, node)
+	if err != nil {
+		h.logger.Error("failed to get config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	base := models.Overlay{}
+	currentVersion := 0
+	if current != nil {
+		if base, err = models.ParseOverlay([]byte(current.ConfigJSON)); err != nil {
+			c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: "invalid config json"})
+			return
+		}
+		currentVersion = current.Version
+	}
+	if expected != db.AnyVersion && expected != currentVersion {
+		respondConflict(c, currentVersion)
+		return
+	}
+
+	var patched []byte
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	switch mediaType {
+	case contentTypeMergePatch, "application/json", "":
+		doc, _ := json.Marshal(base)
+		patched, err = patch.MergePatch(doc, body)
+	case contentTypeJSONPatch:
+		// 补全空分组，使 /capture/enable_dpi 这类路径在分组尚未覆盖时也能直接 add
+		doc, _ := json.Marshal(base.WithSections())
+		patched, err = patch.JSONPatch(doc, body)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, Response{
+			Code:    415,
+			Message: "unsupported content type, use " + contentTypeMergePatch + " or " + contentTypeJSONPatch,
+		})
+		return
+	}
+	if errors.Is(err, patch.ErrTestFailed) {
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	overlay, err := models.ParseOverlay(patched)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	auditAfter(c, overlay)
+
+	if err := h.validator.ValidateOverlay(overlay); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	configJSON, _ := json.Marshal(overlay)
+
+	// 补丁没有带来变化时不产生新版本
+	if current != nil {
+		unchanged, _ := json.Marshal(base)
+		if bytes.Equal(unchanged, configJSON) {
+			setETag(c, current.Version)
+			c.JSON(http.StatusOK, Response{
+				Code:    0,
+				Message: "no changes",
+				Data:    map[string]interface{}{"version": current.Version, "overlay": overlay},
+			})
+			return
+		}
+	}
+
+	record := &models.ConfigRecord{
+		Scope:       scope,
+		ClusterName: cluster,
+		NodeID:      node,
+		ConfigJSON:  string(configJSON),
+		CreatedBy:   currentUser(c),
+	}
+	// 以读取补丁基准时的版本保存，期间有其他人修改则返回 409
+	if !h.saveConfig(c, record, currentVersion) {
+		return
+	}
+
+	// 同步到 ZooKeeper（失败时由后台任务重试）
+	syncState := h.publish(record)
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    map[string]interface{}{"version": record.Version, "overlay": overlay, "sync_state": syncState},
+	})
+}
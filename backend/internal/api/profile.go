@@ -0,0 +1,365 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// ProfileRequest 创建或保存配置档请求，config 为覆盖配置（部分 YafConfig），base_version 的含义同保存配置
+type ProfileRequest struct {
+	Name        string          `json:"name"` // 仅创建时使用
+	Description string          `json:"description"`
+	Config      json.RawMessage `json:"config"`
+	BaseVersion *int            `json:"base_version,omitempty"`
+}
+
+// AttachRequest 设置集群或节点挂载的配置档，按列表顺序合并，空列表表示全部卸载
+type AttachRequest struct {
+	Profiles []string `json:"profiles"`
+}
+
+// ProfileDetail 配置档详情：挂载该配置档的集群与节点，以及节点 Agent 上报继承的版本
+type ProfileDetail struct {
+	*models.Profile
+	Usage []*models.ProfileAttachment  `json:"usage"`
+	Nodes []*models.ProfileInheritance `json:"nodes"`
+}
+
+// AttachedProfiles 集群或节点挂载的配置档及其配置档层的发布状态（从未挂载过时 layer 为空）
+type AttachedProfiles struct {
+	Profiles []string             `json:"profiles"`
+	Layer    *models.ProfileLayer `json:"layer"`
+}
+
+// ListProfiles 列出配置档
+func (h *Handler) ListProfiles(c *gin.Context) {
+	profiles, err := h.db.ListProfiles()
+	if err != nil {
+		h.logger.Error("failed to list profiles", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: profiles})
+}
+
+// GetProfile 获取配置档最新版本、挂载情况与各节点继承的版本
+func (h *Handler) GetProfile(c *gin.Context) {
+	profile, ok := h.loadProfile(c)
+	if !ok {
+		return
+	}
+	usage, err := h.db.ListProfileAttachments(profile.Name)
+	if err != nil {
+		h.logger.Error("failed to list profile attachments", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	nodes, err := h.db.ListProfileInheritance(profile.Name)
+	if err != nil {
+		h.logger.Error("failed to list profile inheritance", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	setETag(c, profile.Version)
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: ProfileDetail{Profile: profile, Usage: usage, Nodes: nodes}})
+}
+
+// GetProfileHistory 获取配置档的版本历史
+func (h *Handler) GetProfileHistory(c *gin.Context) {
+	profile, ok := h.loadProfile(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 500 {
+		limit = 20
+	}
+	versions, err := h.db.GetProfileHistory(profile.Name, limit)
+	if err != nil {
+		h.logger.Error("failed to get profile history", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: versions})
+}
+
+// CreateProfile 创建配置档（仅管理员：配置档可能被任意集群挂载）
+func (h *Handler) CreateProfile(c *gin.Context) {
+	var req ProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidateProfileName(req.Name); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	auditInventoryTarget(c, models.ScopeProfile, "", "", nil)
+	if !h.authorizeAdmin(c) {
+		return
+	}
+	configJSON, ok := h.parseProfileConfig(c, &req)
+	if !ok {
+		return
+	}
+
+	profile := &models.Profile{
+		Name:        req.Name,
+		Description: req.Description,
+		ConfigJSON:  configJSON,
+		CreatedBy:   currentUser(c),
+	}
+	if err := h.db.CreateProfile(profile); err != nil {
+		if err == db.ErrProfileExists {
+			c.JSON(http.StatusConflict, Response{Code: 409, Message: "配置档已存在"})
+			return
+		}
+		h.logger.Error("failed to create profile", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	setETag(c, profile.Version)
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: profile})
+}
+
+// SaveProfile 保存配置档新版本，并重新发布挂载了该配置档的集群与节点
+func (h *Handler) SaveProfile(c *gin.Context) {
+	profile, ok := h.loadProfile(c)
+	if !ok {
+		return
+	}
+	auditInventoryTarget(c, models.ScopeProfile, "", "", profile.ConfigJSON)
+	if !h.authorizeAdmin(c) {
+		return
+	}
+
+	var req ProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	baseVersion, err := expectedVersion(c, req.BaseVersion)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	configJSON, ok := h.parseProfileConfig(c, &req)
+	if !ok {
+		return
+	}
+
+	version, layers, err := h.db.SaveProfile(profile.Name, req.Description, configJSON, baseVersion, currentUser(c))
+	var conflict *db.VersionConflictError
+	switch {
+	case errors.As(err, &conflict):
+		respondConflict(c, conflict.Current)
+		return
+	case err == db.ErrProfileNotFound:
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "profile not found"})
+		return
+	case err != nil:
+		h.logger.Error("failed to save profile", zap.Error(err), zap.String("profile", profile.Name))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	// 受影响的配置档层可能很多，交给后台任务写入 ZooKeeper
+	if len(layers) > 0 {
+		h.publisher.Kick()
+	}
+
+	setETag(c, version)
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: map[string]interface{}{
+		"name":    profile.Name,
+		"version": version,
+		"layers":  layers,
+	}})
+}
+
+// DeleteProfile 删除配置档，仍有集群或节点挂载时返回 409
+func (h *Handler) DeleteProfile(c *gin.Context) {
+	profile, ok := h.loadProfile(c)
+	if !ok {
+		return
+	}
+	auditInventoryTarget(c, models.ScopeProfile, "", "", profile.ConfigJSON)
+	if !h.authorizeAdmin(c) {
+		return
+	}
+
+	switch err := h.db.DeleteProfile(profile.Name); err {
+	case nil:
+	case db.ErrProfileNotFound:
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "profile not found"})
+		return
+	case db.ErrProfileInUse:
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: "配置档仍被集群或节点挂载，请先卸载"})
+		return
+	default:
+		h.logger.Error("failed to delete profile", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "deleted", Data: map[string]string{"name": profile.Name}})
+}
+
+// GetClusterProfiles 获取集群挂载的配置档
+func (h *Handler) GetClusterProfiles(c *gin.Context) {
+	cluster := c.Param("cluster")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	h.respondAttached(c, cluster, "")
+}
+
+// SetClusterProfiles 设置集群挂载的配置档并立即发布
+func (h *Handler) SetClusterProfiles(c *gin.Context) {
+	cluster := c.Param("cluster")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	h.attachProfiles(c, cluster, "")
+}
+
+// GetNodeProfiles 获取节点挂载的配置档（不含所在集群挂载的配置档）
+func (h *Handler) GetNodeProfiles(c *gin.Context) {
+	cluster, node, ok := h.nodeParams(c)
+	if !ok {
+		return
+	}
+	h.respondAttached(c, cluster, node)
+}
+
+// SetNodeProfiles 设置节点挂载的配置档并立即发布
+func (h *Handler) SetNodeProfiles(c *gin.Context) {
+	cluster, node, ok := h.nodeParams(c)
+	if !ok {
+		return
+	}
+	h.attachProfiles(c, cluster, node)
+}
+
+// attachProfiles 设置集群（nodeID 为空）或节点挂载的配置档，重新合并配置档层并写入 ZooKeeper
+func (h *Handler) attachProfiles(c *gin.Context, clusterName, nodeID string) {
+	scope := models.ScopeCluster
+	if nodeID != "" {
+		scope = models.ScopeNode
+	}
+	before, err := h.db.GetAttachedProfiles(clusterName, nodeID)
+	if err != nil {
+		h.logger.Warn("failed to load attached profiles for audit", zap.Error(err))
+	}
+	auditInventoryTarget(c, scope, clusterName, nodeID, before)
+	if !h.authorize(c, scope, clusterName) {
+		return
+	}
+
+	var req AttachRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if req.Profiles == nil {
+		req.Profiles = []string{}
+	}
+	seen := make(map[string]bool, len(req.Profiles))
+	for _, name := range req.Profiles {
+		if err := h.validator.ValidateProfileName(name); err != nil {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+			return
+		}
+		if seen[name] {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "duplicate profile: " + name})
+			return
+		}
+		seen[name] = true
+	}
+	auditAfter(c, req.Profiles)
+
+	layer, err := h.db.AttachProfiles(clusterName, nodeID, req.Profiles, currentUser(c))
+	if err == db.ErrProfileNotFound {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "profile not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to attach profiles", zap.Error(err),
+			zap.String("cluster", clusterName),
+			zap.String("node", nodeID),
+		)
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if layer != nil {
+		if published, err := h.publisher.PublishLayer(layer); err != nil {
+			h.logger.Error("failed to get profile layer", zap.Error(err))
+		} else if published != nil {
+			layer = published
+		}
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: AttachedProfiles{Profiles: req.Profiles, Layer: layer}})
+}
+
+// respondAttached 返回集群（nodeID 为空）或节点挂载的配置档
+func (h *Handler) respondAttached(c *gin.Context, clusterName, nodeID string) {
+	names, err := h.db.GetAttachedProfiles(clusterName, nodeID)
+	if err != nil {
+		h.logger.Error("failed to get attached profiles", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	layer, err := h.db.GetProfileLayer(clusterName, nodeID)
+	if err != nil {
+		h.logger.Error("failed to get profile layer", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: AttachedProfiles{Profiles: names, Layer: layer}})
+}
+
+// parseProfileConfig 解析并校验配置档的覆盖配置与描述，返回规范化的 JSON；不合法时返回 400
+func (h *Handler) parseProfileConfig(c *gin.Context, req *ProfileRequest) (string, bool) {
+	if len(req.Description) > 1024 {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "description too long (max 1024 characters)"})
+		return "", false
+	}
+	overlay, err := models.ParseOverlay(req.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return "", false
+	}
+	auditAfter(c, overlay)
+	if err := h.validator.ValidateOverlay(overlay); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return "", false
+	}
+	configJSON, _ := json.Marshal(overlay)
+	return string(configJSON), true
+}
+
+// loadProfile 读取路径参数中的配置档，不存在时返回 404
+func (h *Handler) loadProfile(c *gin.Context) (*models.Profile, bool) {
+	name := c.Param("name")
+	if err := h.validator.ValidateProfileName(name); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return nil, false
+	}
+	profile, err := h.db.GetProfile(name)
+	if err != nil {
+		h.logger.Error("failed to get profile", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return nil, false
+	}
+	if profile == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "profile not found"})
+		return nil, false
+	}
+	return profile, true
+}
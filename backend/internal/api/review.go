@@ -0,0 +1,305 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// reviewRule 修改类路由的审批规则。scopes 返回该请求会改变哪几级配置的生效结果，按其中最严格的审批数处理；
+// 返回空表示该请求不影响任何节点的生效配置。draft 表示该修改可以改为通过草稿提交评审
+type reviewRule struct {
+	scopes func(h *Handler, c *gin.Context) ([]models.ConfigScope, error)
+	draft  bool
+}
+
+// reviewRules 所有会改变节点生效配置的路由（方法 + 路由模板）。审批策略只在 reviewMiddleware 中检查，
+// 新增修改类路由时须登记在这里或 reviewExempt 中，否则启动时 checkReviewRoutes 报错
+var reviewRules = map[string]reviewRule{
+	"POST /api/v1/config/global":                        {scopes: fixedScope(models.ScopeGlobal), draft: true},
+	"PATCH /api/v1/config/global":                       {scopes: fixedScope(models.ScopeGlobal), draft: true},
+	"POST /api/v1/config/cluster/:cluster":              {scopes: fixedScope(models.ScopeCluster), draft: true},
+	"PATCH /api/v1/config/cluster/:cluster":             {scopes: fixedScope(models.ScopeCluster), draft: true},
+	"DELETE /api/v1/config/cluster/:cluster":            {scopes: fixedScope(models.ScopeCluster), draft: true},
+	"POST /api/v1/config/cluster/:cluster/node/:node":   {scopes: fixedScope(models.ScopeNode), draft: true},
+	"PATCH /api/v1/config/cluster/:cluster/node/:node":  {scopes: fixedScope(models.ScopeNode), draft: true},
+	"DELETE /api/v1/config/cluster/:cluster/node/:node": {scopes: fixedScope(models.ScopeNode), draft: true},
+	"POST /api/v1/config/rollback":                      {scopes: bodyScope, draft: true},
+	"POST /api/v1/schedules":                            {scopes: bodyScope, draft: true},
+	"POST /api/v1/canaries":                             {scopes: canaryScopes, draft: true},
+
+	// 节点标签决定匹配哪些标签选择层
+	"POST /api/v1/clusters/:cluster/nodes":         {scopes: (*Handler).createNodeScopes},
+	"PUT /api/v1/clusters/:cluster/nodes/:node":    {scopes: (*Handler).updateNodeScopes},
+	"DELETE /api/v1/clusters/:cluster/nodes/:node": {scopes: (*Handler).deleteNodeScopes},
+	"DELETE /api/v1/clusters/:cluster":             {scopes: (*Handler).deleteClusterScopes},
+
+	// 配置档：挂载与卸载影响该集群或节点，修改配置档影响挂载了它的所有集群与节点
+	"PUT /api/v1/clusters/:cluster/profiles":             {scopes: fixedScope(models.ScopeCluster)},
+	"PUT /api/v1/clusters/:cluster/nodes/:node/profiles": {scopes: fixedScope(models.ScopeNode)},
+	"PUT /api/v1/profiles/:name":                         {scopes: (*Handler).profileScopes},
+	"DELETE /api/v1/profiles/:name":                      {scopes: (*Handler).profileScopes},
+
+	// 标签选择层：限定集群的按集群配置处理，对所有集群生效的按全局配置处理
+	"POST /api/v1/selectors":         {scopes: (*Handler).selectorScopes},
+	"PUT /api/v1/selectors/:name":    {scopes: (*Handler).selectorScopes},
+	"DELETE /api/v1/selectors/:name": {scopes: (*Handler).selectorScopes},
+}
+
+// reviewExempt 不改变节点生效配置的修改类路由
+var reviewExempt = map[string]bool{
+	"POST /api/v1/auth/logout":   true,
+	"POST /api/v1/auth/password": true,
+
+	"POST /api/v1/users":                       true,
+	"DELETE /api/v1/users/:username":           true,
+	"PUT /api/v1/users/:username/role":         true,
+	"PUT /api/v1/users/:username/grants":       true,
+	"POST /api/v1/users/:username/password":    true,
+	"POST /api/v1/users/:username/disable":     true,
+	"POST /api/v1/users/:username/enable":      true,
+	"POST /api/v1/settings":                    true,
+	"POST /api/v1/notifications/subscriptions": true,
+
+	"PUT /api/v1/notifications/subscriptions/:id":       true,
+	"DELETE /api/v1/notifications/subscriptions/:id":    true,
+	"POST /api/v1/notifications/subscriptions/:id/test": true,
+	"POST /api/v1/notifications/deliveries/:id/retry":   true,
+
+	// 草稿的提交与审批是需要审批时唯一的修改途径
+	"POST /api/v1/drafts":                true,
+	"PUT /api/v1/drafts/:id":             true,
+	"DELETE /api/v1/drafts/:id":          true,
+	"POST /api/v1/drafts/:id/submit":     true,
+	"POST /api/v1/drafts/:id/approve":    true,
+	"POST /api/v1/drafts/:id/reject":     true,
+	"POST /api/v1/drafts/:id/comments":   true,
+	"PUT /api/v1/schedules/:id":          true, // 只修改已审批内容的发布时间
+	"DELETE /api/v1/schedules/:id":       true,
+	"POST /api/v1/canaries/:id/promote":  true, // 全量已开始灰度的版本
+	"POST /api/v1/canaries/:id/rollback": true, // 恢复灰度前的版本
+
+	// 以数据库为准修复或从 ZooKeeper 导入，不产生新的配置内容
+	"POST /api/v1/sync/drift/repair": true,
+	"POST /api/v1/sync/import":       true,
+
+	"POST /api/v1/clusters":                    true,
+	"PUT /api/v1/clusters/:cluster":            true, // 集群标签不参与标签选择层匹配
+	"POST /api/v1/clusters/:cluster/archive":   true,
+	"DELETE /api/v1/clusters/:cluster/archive": true,
+	"POST /api/v1/profiles":                    true, // 新建的配置档尚未挂载
+}
+
+// reviewMiddleware 按审批策略拦截会改变节点生效配置的请求：受影响的任意一级配置需要审批时拒绝直接修改（403）
+func (h *Handler) reviewMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, ok := reviewRules[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+		scopes, err := rule.scopes(h, c)
+		if err != nil {
+			h.logger.Error("failed to resolve review scopes", zap.Error(err), zap.String("path", c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+			return
+		}
+
+		var strictest models.ConfigScope
+		required := 0
+		for _, scope := range scopes {
+			if n := h.config.Review.Required(scope); n > required {
+				strictest, required = scope, n
+			}
+		}
+		if required == 0 {
+			c.Next()
+			return
+		}
+		message := fmt.Sprintf("%s 配置需要 %d 人审批，请提交草稿评审", strictest, required)
+		if !rule.draft {
+			message = fmt.Sprintf("该修改影响 %s 配置，该级配置需要 %d 人审批，不能直接修改", strictest, required)
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, Response{Code: 403, Message: message})
+	}
+}
+
+// checkReviewRoutes 检查每个修改类路由都登记了审批规则或明确豁免，登记项也都对应实际的路由
+func checkReviewRoutes(routes gin.RoutesInfo) {
+	registered := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		switch route.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			continue
+		}
+		if !strings.HasPrefix(route.Path, "/api/v1/") || route.Path == "/api/v1/auth/login" {
+			continue
+		}
+		if _, ok := reviewRules[key]; !ok && !reviewExempt[key] {
+			panic("route is not classified for review: " + key)
+		}
+	}
+	for key := range reviewRules {
+		if !registered[key] {
+			panic("review rule for unknown route: " + key)
+		}
+	}
+	for key := range reviewExempt {
+		if !registered[key] {
+			panic("review exemption for unknown route: " + key)
+		}
+	}
+}
+
+// fixedScope 修改路由对应的一级配置
+func fixedScope(scope models.ConfigScope) func(*Handler, *gin.Context) ([]models.ConfigScope, error) {
+	return func(*Handler, *gin.Context) ([]models.ConfigScope, error) {
+		return []models.ConfigScope{scope}, nil
+	}
+}
+
+// bodyScope 请求体 scope 字段指定的一级配置；请求体不合法时不拦截，由处理器返回 400
+func bodyScope(_ *Handler, c *gin.Context) ([]models.ConfigScope, error) {
+	var req struct {
+		Scope models.ConfigScope `json:"scope"`
+	}
+	if err := peekJSON(c, &req); err != nil {
+		return nil, nil
+	}
+	return []models.ConfigScope{req.Scope}, nil
+}
+
+// canaryScopes 从审批通过的灰度草稿开始灰度时内容已经评审，不再拦截；否则同 bodyScope
+func canaryScopes(h *Handler, c *gin.Context) ([]models.ConfigScope, error) {
+	var req CanaryRequest
+	if err := peekJSON(c, &req); err != nil {
+		return nil, nil
+	}
+	if req.DraftID > 0 {
+		return nil, nil
+	}
+	return []models.ConfigScope{models.ConfigScope(req.Scope)}, nil
+}
+
+// createNodeScopes 登记带有标签的节点会改变其匹配的标签选择层
+func (h *Handler) createNodeScopes(c *gin.Context) ([]models.ConfigScope, error) {
+	var req CreateNodeRequest
+	if err := peekJSON(c, &req); err != nil || len(req.Labels) == 0 {
+		return nil, nil
+	}
+	return []models.ConfigScope{models.ScopeNode}, nil
+}
+
+// updateNodeScopes 修改节点标签会改变其匹配的标签选择层，只修改描述与负责人时不需要审批
+func (h *Handler) updateNodeScopes(c *gin.Context) ([]models.ConfigScope, error) {
+	var meta models.InventoryMeta
+	if err := peekJSON(c, &meta); err != nil {
+		return nil, nil
+	}
+	node, err := h.db.GetNode(c.Param("cluster"), c.Param("node"))
+	if err != nil || node == nil || labelsEqual(node.Labels, meta.Labels) {
+		return nil, err
+	}
+	return []models.ConfigScope{models.ScopeNode}, nil
+}
+
+// deleteNodeScopes 删除带有标签的节点会删除 ZooKeeper 中登记的标签
+func (h *Handler) deleteNodeScopes(c *gin.Context) ([]models.ConfigScope, error) {
+	node, err := h.db.GetNode(c.Param("cluster"), c.Param("node"))
+	if err != nil || node == nil || len(node.Labels) == 0 {
+		return nil, err
+	}
+	return []models.ConfigScope{models.ScopeNode}, nil
+}
+
+// deleteClusterScopes 删除集群会删除其下所有节点登记的标签
+func (h *Handler) deleteClusterScopes(c *gin.Context) ([]models.ConfigScope, error) {
+	nodes, err := h.db.ListNodes(c.Param("cluster"))
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		if len(node.Labels) > 0 {
+			return []models.ConfigScope{models.ScopeNode}, nil
+		}
+	}
+	return nil, nil
+}
+
+// profileScopes 挂载了该配置档的集群与节点对应的配置级别
+func (h *Handler) profileScopes(c *gin.Context) ([]models.ConfigScope, error) {
+	attachments, err := h.db.ListProfileAttachments(c.Param("name"))
+	if err != nil {
+		return nil, err
+	}
+	scopes := make([]models.ConfigScope, 0, len(attachments))
+	for _, attachment := range attachments {
+		if attachment.NodeID == "" {
+			scopes = append(scopes, models.ScopeCluster)
+		} else {
+			scopes = append(scopes, models.ScopeNode)
+		}
+	}
+	return scopes, nil
+}
+
+// selectorScopes 标签选择层修改前（已存在时）与修改后（请求体中给出时）限定的集群对应的配置级别
+func (h *Handler) selectorScopes(c *gin.Context) ([]models.ConfigScope, error) {
+	var scopes []models.ConfigScope
+	if name := c.Param("name"); name != "" {
+		current, err := h.db.GetSelector(name)
+		if err != nil {
+			return nil, err
+		}
+		if current != nil {
+			scopes = append(scopes, selectorScope(current.ClusterName))
+		}
+	}
+	if c.Request.Method != http.MethodDelete {
+		var req SelectorRequest
+		if err := peekJSON(c, &req); err == nil {
+			scopes = append(scopes, selectorScope(req.ClusterName))
+		}
+	}
+	return scopes, nil
+}
+
+// selectorScope 限定集群的标签选择层只影响该集群，否则影响所有集群
+func selectorScope(clusterName string) models.ConfigScope {
+	if clusterName == "" {
+		return models.ScopeGlobal
+	}
+	return models.ScopeCluster
+}
+
+// peekJSON 解析请求体，并恢复请求体供处理器再次读取
+func peekJSON(c *gin.Context, v interface{}) error {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+	return json.Unmarshal(data, v)
+}
+
+// labelsEqual 比较两组标签，nil 与空集合视为相同
+func labelsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
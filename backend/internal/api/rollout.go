@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// ListRollouts 列出各未归档集群的发布进度：已应用最新版本、待应用、应用失败与尚未上报的节点数
+func (h *Handler) ListRollouts(c *gin.Context) {
+	rollouts, err := h.db.ListRollouts("")
+	if err != nil {
+		h.logger.Error("failed to list rollouts", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: rollouts})
+}
+
+// GetClusterRollout 获取集群的发布进度及每个节点的期望版本、已应用版本与失败原因
+func (h *Handler) GetClusterRollout(c *gin.Context) {
+	cluster := c.Param("cluster")
+	if err := h.validator.ValidateClusterName(cluster); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	rollouts, err := h.db.ListRollouts(cluster)
+	if err != nil {
+		h.logger.Error("failed to get cluster rollout", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if len(rollouts) > 0 {
+		c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: rollouts[0]})
+		return
+	}
+
+	// 没有节点时，已登记的集群返回空的进度
+	record, err := h.db.GetCluster(cluster)
+	if err != nil {
+		h.logger.Error("failed to get cluster", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "cluster not found"})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: &models.Rollout{ClusterName: cluster}})
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// ScheduleRequest 定时发布请求，config 与 base_version 的含义同保存配置
+type ScheduleRequest struct {
+	Scope       string          `json:"scope" binding:"required"` // global / cluster / node
+	ClusterName string          `json:"cluster_name"`
+	NodeID      string          `json:"node_id"`
+	Config      json.RawMessage `json:"config"`
+	BaseVersion *int            `json:"base_version,omitempty"`
+	PublishAt   time.Time       `json:"publish_at" binding:"required"` // RFC 3339，如 2026-10-17T02:00:00+08:00
+}
+
+// RescheduleRequest 改期请求
+type RescheduleRequest struct {
+	PublishAt time.Time `json:"publish_at" binding:"required"`
+}
+
+// ListSchedules 列出定时发布，可按 state、scope、cluster、node 筛选
+func (h *Handler) ListSchedules(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	schedules, err := h.db.ListSchedules(models.ScheduleFilter{
+		State:       c.Query("state"),
+		Scope:       models.ConfigScope(c.Query("scope")),
+		ClusterName: c.Query("cluster"),
+		NodeID:      c.Query("node"),
+		Limit:       limit,
+	})
+	if err != nil {
+		h.logger.Error("failed to list schedules", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: schedules})
+}
+
+// GetSchedule 获取定时发布
+func (h *Handler) GetSchedule(c *gin.Context) {
+	schedule, ok := h.loadSchedule(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: schedule})
+}
+
+// CreateSchedule 登记定时发布：配置先经过校验，到 publish_at 时由后台任务保存为新版本并发布
+func (h *Handler) CreateSchedule(c *gin.Context) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	scope := models.ConfigScope(req.Scope)
+	if !h.validateTarget(c, scope, &req.ClusterName, &req.NodeID) {
+		return
+	}
+	h.auditConfigTarget(c, scope, req.ClusterName, req.NodeID)
+	if !h.authorize(c, scope, req.ClusterName) {
+		return
+	}
+
+	if !req.PublishAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "publish_at must be in the future"})
+		return
+	}
+	baseVersion, err := expectedVersion(c, req.BaseVersion)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	overlay, err := models.ParseOverlay(req.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	auditAfter(c, overlay)
+	if err := h.validator.ValidateOverlay(overlay); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	configJSON, _ := json.Marshal(overlay)
+	schedule := &models.Schedule{
+		Scope:       scope,
+		ClusterName: req.ClusterName,
+		NodeID:      req.NodeID,
+		ConfigJSON:  string(configJSON),
+		BaseVersion: baseVersion,
+		PublishAt:   req.PublishAt,
+		CreatedBy:   currentUser(c),
+	}
+	if err := h.db.CreateSchedule(schedule); err != nil {
+		h.logger.Error("failed to create schedule", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: schedule})
+}
+
+// RescheduleSchedule 修改等待发布的定时发布的发布时间
+func (h *Handler) RescheduleSchedule(c *gin.Context) {
+	schedule, ok := h.loadSchedule(c)
+	if !ok {
+		return
+	}
+	h.auditConfigTarget(c, schedule.Scope, schedule.ClusterName, schedule.NodeID)
+	if !h.authorize(c, schedule.Scope, schedule.ClusterName) {
+		return
+	}
+
+	var req RescheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if !req.PublishAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "publish_at must be in the future"})
+		return
+	}
+	auditAfter(c, req)
+
+	if err := h.db.RescheduleSchedule(schedule.ID, req.PublishAt, currentUser(c)); err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success"})
+}
+
+// CancelSchedule 取消等待发布的定时发布
+func (h *Handler) CancelSchedule(c *gin.Context) {
+	schedule, ok := h.loadSchedule(c)
+	if !ok {
+		return
+	}
+	h.auditConfigTarget(c, schedule.Scope, schedule.ClusterName, schedule.NodeID)
+	if !h.authorize(c, schedule.Scope, schedule.ClusterName) {
+		return
+	}
+
+	if err := h.db.CancelSchedule(schedule.ID, currentUser(c)); err != nil {
+		h.respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success"})
+}
+
+// validateTarget 校验配置级别与集群名、节点 ID，并清空该级别用不到的字段；不合法时返回 400
+func (h *Handler) validateTarget(c *gin.Context, scope models.ConfigScope, clusterName, nodeID *string) bool {
+	var err error
+	switch scope {
+	case models.ScopeGlobal:
+		*clusterName, *nodeID = "", ""
+	case models.ScopeCluster:
+		*nodeID = ""
+		err = h.validator.ValidateClusterName(*clusterName)
+	case models.ScopeNode:
+		if err = h.validator.ValidateClusterName(*clusterName); err == nil {
+			err = h.validator.ValidateNodeID(*nodeID)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "scope must be global, cluster or node"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return false
+	}
+	return true
+}
+
+// loadSchedule 读取路径参数中的定时发布，不存在时返回 404
+func (h *Handler) loadSchedule(c *gin.Context) (*models.Schedule, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid schedule id"})
+		return nil, false
+	}
+	schedule, err := h.db.GetSchedule(id)
+	if err != nil {
+		h.logger.Error("failed to get schedule", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return nil, false
+	}
+	if schedule == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "schedule not found"})
+		return nil, false
+	}
+	return schedule, true
+}
+
+// respondScheduleError 定时发布已不在等待状态时返回 409，其他错误返回 500
+func (h *Handler) respondScheduleError(c *gin.Context, err error) {
+	if err == db.ErrScheduleState {
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: err.Error()})
+		return
+	}
+	h.logger.Error("failed to update schedule", zap.Error(err))
+	c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// SelectorRequest 创建或修改标签选择层请求，config 为覆盖配置，base_version 的含义同保存配置
+type SelectorRequest struct {
+	Name        string            `json:"name"` // 仅创建时使用
+	Description string            `json:"description"`
+	ClusterName string            `json:"cluster_name"` // 为空时对所有集群生效
+	Labels      map[string]string `json:"labels"`
+	Priority    int               `json:"priority"`
+	Config      json.RawMessage   `json:"config"`
+	BaseVersion *int              `json:"base_version,omitempty"`
+}
+
+// SelectorDetail 标签选择层详情及清单中当前匹配的节点
+type SelectorDetail struct {
+	*models.Selector
+	Nodes []*models.Node `json:"nodes"`
+}
+
+// ListSelectors 列出标签选择层，按合并顺序排序
+func (h *Handler) ListSelectors(c *gin.Context) {
+	selectors, err := h.db.ListSelectors()
+	if err != nil {
+		h.logger.Error("failed to list selectors", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: selectors})
+}
+
+// GetSelector 获取标签选择层及清单中匹配的节点
+func (h *Handler) GetSelector(c *gin.Context) {
+	selector, ok := h.loadSelector(c)
+	if !ok {
+		return
+	}
+	nodes, err := h.db.SelectorNodes(selector)
+	if err != nil {
+		h.logger.Error("failed to list selector nodes", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	setETag(c, selector.Version)
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: SelectorDetail{Selector: selector, Nodes: nodes}})
+}
+
+// CreateSelector 创建标签选择层并立即发布。限定集群时需要该集群的写权限，否则需要全局配置的写权限
+func (h *Handler) CreateSelector(c *gin.Context) {
+	var req SelectorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidateSelectorName(req.Name); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	auditInventoryTarget(c, models.ScopeSelector, req.ClusterName, "", nil)
+	if !h.authorizeSelector(c, req.ClusterName) {
+		return
+	}
+	selector, ok := h.parseSelector(c, &req)
+	if !ok {
+		return
+	}
+	selector.CreatedBy = currentUser(c)
+
+	if err := h.db.CreateSelector(selector); err != nil {
+		if err == db.ErrSelectorExists {
+			c.JSON(http.StatusConflict, Response{Code: 409, Message: "标签选择层已存在"})
+			return
+		}
+		h.logger.Error("failed to create selector", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	setETag(c, selector.Version)
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: h.publishSelector(selector)})
+}
+
+// SaveSelector 修改标签选择层并立即发布。修改前后限定的集群都需要有写权限
+func (h *Handler) SaveSelector(c *gin.Context) {
+	current, ok := h.loadSelector(c)
+	if !ok {
+		return
+	}
+	auditInventoryTarget(c, models.ScopeSelector, current.ClusterName, "", current)
+	if !h.authorizeSelector(c, current.ClusterName) {
+		return
+	}
+
+	var req SelectorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if req.ClusterName != current.ClusterName && !h.authorizeSelector(c, req.ClusterName) {
+		return
+	}
+	baseVersion, err := expectedVersion(c, req.BaseVersion)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	req.Name = current.Name
+	selector, ok := h.parseSelector(c, &req)
+	if !ok {
+		return
+	}
+	selector.UpdatedBy = currentUser(c)
+
+	if !h.checkSelectorSaved(c, h.db.SaveSelector(selector, baseVersion)) {
+		return
+	}
+	setETag(c, selector.Version)
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: h.publishSelector(selector)})
+}
+
+// DeleteSelector 删除标签选择层，匹配的节点随之不再合并该层
+func (h *Handler) DeleteSelector(c *gin.Context) {
+	current, ok := h.loadSelector(c)
+	if !ok {
+		return
+	}
+	auditInventoryTarget(c, models.ScopeSelector, current.ClusterName, "", current)
+	if !h.authorizeSelector(c, current.ClusterName) {
+		return
+	}
+	baseVersion, err := expectedVersion(c, nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	deleted, err := h.db.DeleteSelector(current.Name, baseVersion, currentUser(c))
+	if !h.checkSelectorSaved(c, err) {
+		return
+	}
+	h.publishSelector(deleted)
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "deleted", Data: map[string]string{"name": current.Name}})
+}
+
+// authorizeSelector 限定集群的选择层按集群配置鉴权，对所有集群生效的选择层按全局配置鉴权
+func (h *Handler) authorizeSelector(c *gin.Context, clusterName string) bool {
+	if clusterName == "" {
+		return h.authorize(c, models.ScopeGlobal, "")
+	}
+	return h.authorize(c, models.ScopeCluster, clusterName)
+}
+
+// parseSelector 校验请求中的选择条件与覆盖配置，不合法时返回 400
+func (h *Handler) parseSelector(c *gin.Context, req *SelectorRequest) (*models.Selector, bool) {
+	if req.ClusterName != "" {
+		if err := h.validator.ValidateClusterName(req.ClusterName); err != nil {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+			return nil, false
+		}
+	}
+	if err := h.validator.ValidateSelectorLabels(req.Labels); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return nil, false
+	}
+	if len(req.Description) > 1024 {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "description too long (max 1024 characters)"})
+		return nil, false
+	}
+	overlay, err := models.ParseOverlay(req.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return nil, false
+	}
+	auditAfter(c, overlay)
+	if err := h.validator.ValidateOverlay(overlay); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return nil, false
+	}
+
+	configJSON, _ := json.Marshal(overlay)
+	return &models.Selector{
+		Name:        req.Name,
+		Description: req.Description,
+		ClusterName: req.ClusterName,
+		Labels:      req.Labels,
+		Priority:    req.Priority,
+		ConfigJSON:  string(configJSON),
+	}, true
+}
+
+// checkSelectorSaved 处理修改或删除标签选择层的结果：冲突返回 409，不存在返回 404，其他错误返回 500
+func (h *Handler) checkSelectorSaved(c *gin.Context, err error) bool {
+	var conflict *db.VersionConflictError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &conflict):
+		respondConflict(c, conflict.Current)
+	case err == db.ErrSelectorNotFound:
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "selector not found"})
+	default:
+		h.logger.Error("failed to save selector", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+	}
+	return false
+}
+
+// publishSelector 立即写入 ZooKeeper，返回带有最新发布状态的选择层；写入失败时由后台任务重试
+func (h *Handler) publishSelector(selector *models.Selector) *models.Selector {
+	published, err := h.publisher.PublishSelector(selector)
+	if err != nil {
+		h.logger.Error("failed to get selector", zap.Error(err))
+		return selector
+	}
+	if published == nil {
+		return selector
+	}
+	return published
+}
+
+// loadSelector 读取路径参数中的标签选择层，不存在时返回 404
+func (h *Handler) loadSelector(c *gin.Context) (*models.Selector, bool) {
+	name := c.Param("name")
+	if err := h.validator.ValidateSelectorName(name); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return nil, false
+	}
+	selector, err := h.db.GetSelector(name)
+	if err != nil {
+		h.logger.Error("failed to get selector", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return nil, false
+	}
+	if selector == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "selector not found"})
+		return nil, false
+	}
+	return selector, true
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// publish 立即将刚保存的版本写入 ZooKeeper，失败的条目由后台任务按退避间隔重试；
+// 返回该级配置的同步状态，供前端提示变更是否已下发
+func (h *Handler) publish(record *models.ConfigRecord) *models.SyncState {
+	state, err := h.publisher.Publish(record.Scope, record.ClusterName, record.NodeID)
+	if err != nil {
+		h.logger.Error("failed to publish config", zap.Error(err),
+			zap.String("scope", string(record.Scope)),
+			zap.String("cluster", record.ClusterName),
+			zap.String("node", record.NodeID),
+		)
+		h.publisher.Kick()
+		state = h.syncState(record.Scope, record.ClusterName, record.NodeID)
+	}
+	return state
+}
+
+// syncState 获取某一级配置的同步状态，没有发布记录或查询失败时返回 nil
+func (h *Handler) syncState(scope models.ConfigScope, clusterName, nodeID string) *models.SyncState {
+	state, err := h.db.GetSyncState(scope, clusterName, nodeID)
+	if err != nil {
+		h.logger.Error("failed to get sync state", zap.Error(err))
+		return nil
+	}
+	return state
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// CreateUserRequest 创建用户请求
+type CreateUserRequest struct {
+	Username string      `json:"username" binding:"required"`
+	Password string      `json:"password" binding:"required"`
+	Role     models.Role `json:"role"`
+	Clusters []string    `json:"clusters"`
+}
+
+// UserRoleRequest 设置用户角色请求
+type UserRoleRequest struct {
+	Role models.Role `json:"role" binding:"required"`
+}
+
+// UserGrantsRequest 设置用户集群授权请求
+type UserGrantsRequest struct {
+	Clusters []string `json:"clusters"`
+}
+
+// ResetPasswordRequest 管理员重置密码请求
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// ListUsers 列出所有用户
+func (h *Handler) ListUsers(c *gin.Context) {
+	users, err := h.db.ListUsers()
+	if err != nil {
+		h.logger.Error("failed to list users", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: users})
+}
+
+// CreateUser 创建用户，新用户首次登录须修改密码
+func (h *Handler) CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleViewer
+	}
+	if !req.Role.Valid() {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid role: " + string(req.Role)})
+		return
+	}
+	if err := h.validator.ValidateUsername(req.Username); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidatePassword(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	for _, cluster := range req.Clusters {
+		if err := h.validator.ValidateClusterName(cluster); err != nil {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+			return
+		}
+	}
+
+	err := h.db.CreateUser(req.Username, req.Password, req.Role, true)
+	if err == db.ErrUserExists {
+		c.JSON(http.StatusConflict, Response{Code: 409, Message: "用户名已存在"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to create user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if len(req.Clusters) > 0 {
+		if err := h.db.SetUserGrants(req.Username, req.Clusters); err != nil {
+			h.logger.Error("failed to set user grants", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+			return
+		}
+	}
+
+	user, err := h.db.GetUser(req.Username)
+	if err != nil {
+		h.logger.Error("failed to get user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: user})
+}
+
+// GetUser 获取用户详情（角色、授权、锁定与最近登录信息）
+func (h *Handler) GetUser(c *gin.Context) {
+	user, err := h.db.GetUser(c.Param("username"))
+	if err != nil {
+		h.logger.Error("failed to get user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "user not found"})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success", Data: user})
+}
+
+// DeleteUser 删除用户
+func (h *Handler) DeleteUser(c *gin.Context) {
+	username := c.Param("username")
+	if username == currentUser(c) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "不能删除自己"})
+		return
+	}
+
+	err := h.db.DeleteUser(username)
+	if err == db.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "user not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to delete user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success"})
+}
+
+// ResetUserPassword 管理员重置用户密码，用户下次登录须修改密码
+func (h *Handler) ResetUserPassword(c *gin.Context) {
+	username := c.Param("username")
+
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := h.validator.ValidatePassword(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+
+	err := h.db.SetPassword(username, req.Password, true)
+	if err == db.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "user not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to reset password", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := h.db.DeleteUserSessions(username, ""); err != nil {
+		h.logger.Warn("failed to revoke user sessions", zap.Error(err))
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success"})
+}
+
+// DisableUser 禁用用户并撤销其会话
+func (h *Handler) DisableUser(c *gin.Context) {
+	if c.Param("username") == currentUser(c) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "不能禁用自己"})
+		return
+	}
+	h.setUserDisabled(c, true)
+}
+
+// EnableUser 启用用户并解除锁定
+func (h *Handler) EnableUser(c *gin.Context) {
+	h.setUserDisabled(c, false)
+}
+
+// setUserDisabled 更新用户禁用状态
+func (h *Handler) setUserDisabled(c *gin.Context, disabled bool) {
+	err := h.db.SetUserDisabled(c.Param("username"), disabled)
+	if err == db.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "user not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to update user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success"})
+}
+
+// SetUserRole 设置用户角色
+func (h *Handler) SetUserRole(c *gin.Context) {
+	username := c.Param("username")
+
+	var req UserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	if !req.Role.Valid() {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "invalid role: " + string(req.Role)})
+		return
+	}
+	if username == currentUser(c) && req.Role != models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: "不能降低自己的角色"})
+		return
+	}
+
+	err := h.db.SetUserRole(username, req.Role)
+	if err == db.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "user not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to set user role", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success"})
+}
+
+// SetUserGrants 设置用户可管理的集群
+func (h *Handler) SetUserGrants(c *gin.Context) {
+	username := c.Param("username")
+
+	var req UserGrantsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+		return
+	}
+	for _, cluster := range req.Clusters {
+		if err := h.validator.ValidateClusterName(cluster); err != nil {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Message: err.Error()})
+			return
+		}
+	}
+
+	user, err := h.db.GetUser(username)
+	if err != nil {
+		h.logger.Error("failed to get user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Message: "user not found"})
+		return
+	}
+
+	if err := h.db.SetUserGrants(username, req.Clusters); err != nil {
+		h.logger.Error("failed to set user grants", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 0, Message: "success"})
+}
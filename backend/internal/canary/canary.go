@@ -0,0 +1,368 @@
+// Package canary 灰度发布全局或集群配置：新版本先只对选出的灰度节点生效，确认成功后全量，失败或超时则回滚
+package canary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"github.com/yf-web/backend/internal/publisher"
+	"github.com/yf-web/backend/internal/zk"
+	"go.uber.org/zap"
+)
+
+// systemUser 后台任务自动全量或回滚时记录的操作人
+const systemUser = "system"
+
+// ErrNoCanaryNodes 选择条件没有选出任何灰度节点
+var ErrNoCanaryNodes = errors.New("selector matches no nodes")
+
+// Config 灰度发布任务配置
+type Config struct {
+	Interval time.Duration // 检查灰度节点确认结果的周期
+}
+
+// Controller 管理灰度发布。开始灰度时，未被选中的节点在 ZooKeeper 的 cluster/<cluster>/pins/<node>/<scope>
+// 中固定为基准版本的配置文档，并在该级配置旁写入列出灰度节点的守护节点（之后才出现的节点据此同样停留在
+// 基准版本），随后照常保存并发布新版本，因此只有灰度节点应用新版本。
+// 后台任务根据 Agent 上报的应用结果推进：灰度节点全部成功后全量（删除固定节点）或等待手动全量；
+// 有节点失败或超时未确认时保存内容为基准版本的回滚版本，发布后再删除固定节点
+type Controller struct {
+	db        *db.PostgresDB
+	zk        *zk.Client
+	publisher *publisher.Publisher
+	config    Config
+	logger    *zap.Logger
+	kick      chan struct{}
+	mu        sync.Mutex // 串行化状态推进
+}
+
+// New 创建灰度发布任务
+func New(database *db.PostgresDB, zkClient *zk.Client, pub *publisher.Publisher, cfg Config, logger *zap.Logger) *Controller {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	return &Controller{
+		db:        database,
+		zk:        zkClient,
+		publisher: pub,
+		config:    cfg,
+		logger:    logger,
+		kick:      make(chan struct{}, 1),
+	}
+}
+
+// Run 周期性推进尚未结束的灰度发布，直到 ctx 结束
+func (c *Controller) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.advanceAll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.kick:
+		}
+	}
+}
+
+// Kick 唤醒后台任务立即检查一轮
+func (c *Controller) Kick() {
+	select {
+	case c.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Start 开始灰度发布：选出灰度节点，固定其余节点后保存新版本 record（由调用方随后发布）。
+// baseVersion 的含义同 db.SaveConfig，canary 中填写作用范围、选择条件、是否自动全量、超时与操作人
+func (c *Controller) Start(canary *models.Canary, record *models.ConfigRecord, baseVersion int) error {
+	latest, err := c.db.GetLatestConfig(canary.Scope, canary.ClusterName, "")
+	if err != nil {
+		return err
+	}
+	current := 0
+	if latest != nil {
+		current = latest.Version
+	}
+	if baseVersion != db.AnyVersion && baseVersion != current {
+		return &db.VersionConflictError{Expected: baseVersion, Current: current}
+	}
+	canary.BaseVersion = current
+
+	candidates, err := c.db.ListCanaryCandidates(canary.ClusterName)
+	if err != nil {
+		return err
+	}
+	nodes := selectNodes(candidates, canary.Selector)
+	if countCanary(nodes) == 0 {
+		return ErrNoCanaryNodes
+	}
+
+	doc, err := pinDocument(latest)
+	if err != nil {
+		return err
+	}
+	// 固定节点在 StartCanary 的事务中写入：已有灰度或基准版本过期时不会写入，也不会删除其他灰度的固定节点
+	pin := func() error { return c.pin(canary, nodes, doc) }
+	unpin := func() error { return c.unpin(canary, nodes) }
+	if err := c.db.StartCanary(canary, record, nodes, pin, unpin); err != nil {
+		return err
+	}
+	c.Kick()
+	return nil
+}
+
+// Promote 手动全量：灰度中或已确认的灰度切换到 promoting，由后台任务删除固定节点
+func (c *Controller) Promote(id int64, user string) error {
+	from := []string{models.CanaryRunning, models.CanaryVerified}
+	if err := c.db.SetCanaryState(id, from, models.CanaryPromoting, user); err != nil {
+		return err
+	}
+	c.Kick()
+	return nil
+}
+
+// Rollback 回滚灰度：保存内容为基准版本的新版本并立即发布，固定节点由后台任务在发布成功后删除
+func (c *Controller) Rollback(canary *models.Canary, user, reason string) error {
+	record := &models.ConfigRecord{
+		Scope:       canary.Scope,
+		ClusterName: canary.ClusterName,
+		ConfigJSON:  "{}", // 此前没有配置时回滚为空的覆盖配置（完全继承上级）
+		CreatedBy:   user,
+	}
+	if canary.BaseVersion > 0 {
+		base, err := c.db.GetConfigByVersion(canary.Scope, canary.ClusterName, "", canary.BaseVersion)
+		if err != nil {
+			return err
+		}
+		if base == nil {
+			return fmt.Errorf("base version %d not found", canary.BaseVersion)
+		}
+		record.ConfigJSON = base.ConfigJSON
+		record.RollbackTo = canary.BaseVersion
+	}
+	if err := c.db.RollbackCanary(canary, record, reason); err != nil {
+		return err
+	}
+
+	if _, err := c.publisher.Publish(record.Scope, record.ClusterName, ""); err != nil {
+		c.logger.Warn("failed to publish canary rollback", zap.Int64("canary", canary.ID), zap.Error(err))
+		c.publisher.Kick()
+	}
+	c.Kick()
+	return nil
+}
+
+// advanceAll 推进所有尚未结束的灰度发布
+func (c *Controller) advanceAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	canaries, err := c.db.ListCanaries(true, 100)
+	if err != nil {
+		c.logger.Error("failed to list canaries", zap.Error(err))
+		return
+	}
+	for _, canary := range canaries {
+		if err := c.advance(canary.ID); err != nil {
+			c.logger.Error("failed to advance canary", zap.Int64("canary", canary.ID), zap.Error(err))
+		}
+	}
+}
+
+// advance 根据灰度节点的应用结果推进一个灰度发布
+func (c *Controller) advance(id int64) error {
+	canary, err := c.db.GetCanary(id)
+	if err != nil || canary == nil {
+		return err
+	}
+
+	switch canary.State {
+	case models.CanaryRunning, models.CanaryVerified:
+		total, applied := 0, 0
+		for _, node := range canary.Nodes {
+			if !node.Canary {
+				continue
+			}
+			total++
+			switch node.State {
+			case models.RolloutFailed:
+				reason := fmt.Sprintf("node %s/%s failed to apply v%d: %s", node.ClusterName, node.NodeID, canary.Version, node.Error)
+				return c.autoRollback(canary, reason)
+			case models.RolloutLatest:
+				applied++
+			}
+		}
+		if canary.State == models.CanaryVerified {
+			return nil
+		}
+		if applied == total {
+			next := models.CanaryVerified
+			if canary.AutoPromote {
+				next = models.CanaryPromoting
+			}
+			c.logger.Info("canary nodes applied new version", zap.Int64("canary", id), zap.String("next", next))
+			if err := c.db.SetCanaryState(id, []string{models.CanaryRunning}, next, systemUser); err != nil {
+				return err
+			}
+			if next == models.CanaryPromoting {
+				c.Kick()
+			}
+			return nil
+		}
+		if time.Now().After(canary.Deadline) {
+			reason := fmt.Sprintf("timed out: %d of %d canary nodes applied v%d", applied, total, canary.Version)
+			return c.autoRollback(canary, reason)
+		}
+
+	case models.CanaryPromoting:
+		if err := c.unpin(canary, canary.Nodes); err != nil {
+			return err
+		}
+		c.logger.Info("canary promoted", zap.Int64("canary", id), zap.Int("version", canary.Version))
+		return c.db.SetCanaryState(id, []string{models.CanaryPromoting}, models.CanaryPromoted, canary.UpdatedBy)
+
+	case models.CanaryRollingBack:
+		// 回滚版本写入 ZooKeeper 后再解除固定，避免固定的节点短暂应用有问题的版本
+		state, err := c.db.GetSyncState(canary.Scope, canary.ClusterName, "")
+		if err != nil {
+			return err
+		}
+		if state == nil || state.Status != models.SyncSynced || state.Version < canary.RollbackVersion {
+			c.publisher.Kick()
+			return nil
+		}
+		if err := c.unpin(canary, canary.Nodes); err != nil {
+			return err
+		}
+		c.logger.Info("canary rolled back", zap.Int64("canary", id), zap.Int("rollback_version", canary.RollbackVersion))
+		return c.db.SetCanaryState(id, []string{models.CanaryRollingBack}, models.CanaryRolledBack, canary.UpdatedBy)
+	}
+	return nil
+}
+
+// autoRollback 灰度失败或超时时自动回滚
+func (c *Controller) autoRollback(canary *models.Canary, reason string) error {
+	c.logger.Warn("rolling back canary", zap.Int64("canary", canary.ID), zap.String("reason", reason))
+	err := c.Rollback(canary, systemUser, reason)
+	if err == db.ErrCanaryState {
+		// 同时被手动全量或回滚
+		return nil
+	}
+	return err
+}
+
+// pin 将非灰度节点的该级配置固定为 doc，并写入列出灰度节点的守护节点
+func (c *Controller) pin(canary *models.Canary, nodes []*models.CanaryNode, doc []byte) error {
+	scope := string(canary.Scope)
+	guard := &models.CanaryGuard{Nodes: []string{}, Stable: doc}
+	for _, node := range nodes {
+		if node.Canary {
+			guard.Nodes = append(guard.Nodes, models.CanaryNodeKey(node.ClusterName, node.NodeID))
+			continue
+		}
+		if err := c.zk.SetConfig(zk.GetPinPath(node.ClusterName, node.NodeID, scope), json.RawMessage(doc)); err != nil {
+			return fmt.Errorf("failed to pin %s/%s: %w", node.ClusterName, node.NodeID, err)
+		}
+	}
+	if err := c.zk.SetConfig(zk.GetCanaryGuardPath(scope, canary.ClusterName), guard); err != nil {
+		return fmt.Errorf("failed to write canary guard: %w", err)
+	}
+	return nil
+}
+
+// unpin 删除守护节点与非灰度节点的固定节点，返回遇到的第一个错误（其余节点仍会尝试删除）
+func (c *Controller) unpin(canary *models.Canary, nodes []*models.CanaryNode) error {
+	scope := string(canary.Scope)
+	var firstErr error
+	if err := c.zk.DeleteConfig(zk.GetCanaryGuardPath(scope, canary.ClusterName)); err != nil {
+		firstErr = fmt.Errorf("failed to delete canary guard: %w", err)
+	}
+	for _, node := range nodes {
+		if node.Canary {
+			continue
+		}
+		if err := c.zk.DeleteConfig(zk.GetPinPath(node.ClusterName, node.NodeID, scope)); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to unpin %s/%s: %w", node.ClusterName, node.NodeID, err)
+		}
+	}
+	return firstErr
+}
+
+// pinDocument 生成固定节点的内容：基准版本的配置文档，此前没有配置时为版本 0 的空覆盖配置
+func pinDocument(base *models.ConfigRecord) ([]byte, error) {
+	if base == nil {
+		return models.EncodeDocument(models.Overlay{}, models.DocumentMeta{})
+	}
+	overlay, err := models.ParseOverlay([]byte(base.ConfigJSON))
+	if err != nil {
+		return nil, err
+	}
+	return models.EncodeDocument(overlay, models.DocumentMeta{Version: base.Version})
+}
+
+// selectNodes 划分灰度节点与固定节点：先按标签筛选，再按节点名的哈希值排序后取前 percent%（至少一个）。
+// 同一组节点与选择条件总是选出相同的灰度节点
+func selectNodes(candidates []*models.Node, selector models.CanarySelector) []*models.CanaryNode {
+	matched := []*models.Node{}
+	for _, node := range candidates {
+		if matchLabels(node.Labels, selector.Labels) {
+			matched = append(matched, node)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return nodeHash(matched[i]) < nodeHash(matched[j])
+	})
+	count := len(matched)
+	if selector.Percent > 0 && selector.Percent < 100 {
+		count = (len(matched)*selector.Percent + 99) / 100
+	}
+
+	chosen := make(map[*models.Node]bool, count)
+	for _, node := range matched[:count] {
+		chosen[node] = true
+	}
+	nodes := make([]*models.CanaryNode, 0, len(candidates))
+	for _, node := range candidates {
+		nodes = append(nodes, &models.CanaryNode{ClusterName: node.ClusterName, NodeID: node.NodeID, Canary: chosen[node]})
+	}
+	return nodes
+}
+
+// matchLabels 节点标签是否包含 selector 中的全部标签
+func matchLabels(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeHash 节点的排序键，使按比例抽取的节点分散在各集群、各机架
+func nodeHash(node *models.Node) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(node.ClusterName + "/" + node.NodeID))
+	return h.Sum32()
+}
+
+// countCanary 灰度节点数
+func countCanary(nodes []*models.CanaryNode) int {
+	count := 0
+	for _, node := range nodes {
+		if node.Canary {
+			count++
+		}
+	}
+	return count
+}
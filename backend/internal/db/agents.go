@@ -0,0 +1,137 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/yf-web/backend/internal/models"
+)
+
+const agentColumns = `cluster_name, node_id, hostname, agent_version, interface, started_at, online, first_seen, last_seen`
+
+const applyColumns = `applied_global, applied_cluster, applied_node, config_hash, apply_status, apply_error, applied_at,
+	applied_profiles`
+
+// UpdateAgentPresence 记录一次观察结果：agents 为当前在线的 Agent，last_seen 更新为 seenAt；
+// 此前在线、本次未出现的 Agent 标记为离线，last_seen 保留为最后一次在线的时间。
+// Agent 尚未上报应用结果（刚启动）时保留上一次记录的结果
+func (p *PostgresDB) UpdateAgentPresence(agents []*models.AgentInfo, seenAt time.Time) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, agent := range agents {
+		_, err := tx.Exec(`
+			INSERT INTO yaf_agents (`+agentColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, TRUE, $7, $7)
+			ON CONFLICT (cluster_name, node_id) DO UPDATE SET
+				hostname = EXCLUDED.hostname, agent_version = EXCLUDED.agent_version, interface = EXCLUDED.interface,
+				started_at = EXCLUDED.started_at, online = TRUE, last_seen = EXCLUDED.last_seen
+		`, agent.ClusterName, agent.NodeID, agent.Hostname, agent.AgentVersion, agent.Interface, agent.StartedAt, seenAt)
+		if err != nil {
+			return fmt.Errorf("failed to save agent: %w", err)
+		}
+		if apply := agent.Apply; apply != nil {
+			_, err := tx.Exec(`
+				UPDATE yaf_agents SET (`+applyColumns+`) = ($3, $4, $5, $6, $7, $8, $9, $10)
+				WHERE cluster_name = $1 AND node_id = $2
+			`, agent.ClusterName, agent.NodeID, apply.GlobalVersion, apply.ClusterVersion, apply.NodeVersion,
+				apply.ConfigHash, apply.Status, apply.Error, apply.AppliedAt, encodeProfileRefs(apply.Profiles))
+			if err != nil {
+				return fmt.Errorf("failed to save agent apply state: %w", err)
+			}
+		}
+	}
+	if _, err := tx.Exec("UPDATE yaf_agents SET online = FALSE WHERE online AND last_seen < $1", seenAt); err != nil {
+		return fmt.Errorf("failed to mark agents offline: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit agent presence: %w", err)
+	}
+	return nil
+}
+
+// ListAgents 查询 Agent，按集群、节点排序
+func (p *PostgresDB) ListAgents(filter models.AgentFilter) ([]*models.Agent, error) {
+	rows, err := p.db.Query(`
+		SELECT `+agentColumns+`, `+applyColumns+` FROM yaf_agents
+		WHERE ($1 = '' OR cluster_name = $1)
+			AND ($2 = '' OR online = ($2 = $3))
+		ORDER BY cluster_name, node_id
+	`, filter.ClusterName, filter.Status, models.AgentOnline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	defer rows.Close()
+
+	agents := []*models.Agent{}
+	for rows.Next() {
+		agent := &models.Agent{}
+		var apply nullApply
+		if err := rows.Scan(
+			&agent.ClusterName, &agent.NodeID, &agent.Hostname, &agent.AgentVersion, &agent.Interface,
+			&agent.StartedAt, &agent.Online, &agent.FirstSeen, &agent.LastSeen,
+			&apply.GlobalVersion, &apply.ClusterVersion, &apply.NodeVersion,
+			&apply.ConfigHash, &apply.Status, &apply.Error, &apply.AppliedAt, &apply.Profiles,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan agent: %w", err)
+		}
+		agent.Apply = apply.state()
+		agents = append(agents, agent)
+	}
+	return agents, nil
+}
+
+// nullAgent 左连接得到的 Agent 状态，从未观察到时各列为 NULL
+type nullAgent struct {
+	Online       sql.NullBool
+	Hostname     sql.NullString
+	AgentVersion sql.NullString
+	LastSeen     sql.NullTime
+}
+
+// status 转换为节点上附带的 Agent 状态，从未观察到时返回 nil
+func (a *nullAgent) status() *models.AgentStatus {
+	if !a.Online.Valid {
+		return nil
+	}
+	return &models.AgentStatus{
+		Online:       a.Online.Bool,
+		Hostname:     a.Hostname.String,
+		AgentVersion: a.AgentVersion.String,
+		LastSeen:     a.LastSeen.Time,
+	}
+}
+
+// nullApply 记录的应用结果，Agent 从未上报（或左连接没有 Agent）时 applied_at 为 NULL
+type nullApply struct {
+	GlobalVersion  sql.NullInt64
+	ClusterVersion sql.NullInt64
+	NodeVersion    sql.NullInt64
+	ConfigHash     sql.NullString
+	Status         sql.NullString
+	Error          sql.NullString
+	AppliedAt      sql.NullTime
+	Profiles       []byte
+}
+
+// state 转换为应用结果，尚未上报时返回 nil
+func (a *nullApply) state() *models.ApplyState {
+	if !a.AppliedAt.Valid {
+		return nil
+	}
+	return &models.ApplyState{
+		GlobalVersion:  int(a.GlobalVersion.Int64),
+		ClusterVersion: int(a.ClusterVersion.Int64),
+		NodeVersion:    int(a.NodeVersion.Int64),
+		ConfigHash:     a.ConfigHash.String,
+		Status:         a.Status.String,
+		Error:          a.Error.String,
+		AppliedAt:      a.AppliedAt.Time,
+		Profiles:       decodeProfileRefs(a.Profiles),
+	}
+}
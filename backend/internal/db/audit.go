@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/yf-web/backend/internal/models"
+)
+
+// InsertAudit 写入一条审计日志
+func (p *PostgresDB) InsertAudit(entry *models.AuditEntry) error {
+	err := p.db.QueryRow(`
+		INSERT INTO yaf_audit (actor, source_ip, method, endpoint, scope, cluster_name, node_id,
+			status, outcome, message, before_json, after_json, diff_json, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+	`, entry.Actor, entry.SourceIP, entry.Method, entry.Endpoint,
+		nullString(entry.Scope), nullString(entry.ClusterName), nullString(entry.NodeID),
+		entry.Status, entry.Outcome, nullString(entry.Message),
+		nullJSON(entry.Before), nullJSON(entry.After), nullJSON(entry.Diff), entry.CreatedAt,
+	).Scan(&entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAudit 按条件查询审计日志，按时间倒序
+func (p *PostgresDB) ListAudit(filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if filter.Scope != "" {
+		add("scope = $%d", filter.Scope)
+	}
+	if filter.ClusterName != "" {
+		add("cluster_name = $%d", filter.ClusterName)
+	}
+	if filter.NodeID != "" {
+		add("node_id = $%d", filter.NodeID)
+	}
+	if filter.Outcome != "" {
+		add("outcome = $%d", filter.Outcome)
+	}
+	if !filter.Since.IsZero() {
+		add("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("created_at <= $%d", filter.Until)
+	}
+
+	query := `
+		SELECT id, actor, source_ip, method, endpoint, COALESCE(scope, ''), COALESCE(cluster_name, ''),
+			COALESCE(node_id, ''), status, outcome, COALESCE(message, ''),
+			before_json, after_json, diff_json, created_at
+		FROM yaf_audit`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		entry := &models.AuditEntry{}
+		var before, after, diff []byte
+		if err := rows.Scan(
+			&entry.ID, &entry.Actor, &entry.SourceIP, &entry.Method, &entry.Endpoint,
+			&entry.Scope, &entry.ClusterName, &entry.NodeID, &entry.Status, &entry.Outcome,
+			&entry.Message, &before, &after, &diff, &entry.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entry.Before, entry.After, entry.Diff = before, after, diff
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// nullString 空字符串写入 NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullJSON 空 JSON 写入 NULL
+func nullJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,353 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrCanaryActive 同一级配置有尚未结束的灰度发布，不能直接保存或开始新的灰度
+	ErrCanaryActive = errors.New("a canary rollout is in progress for this config")
+	// ErrCanaryState 灰度发布的当前状态不允许该操作
+	ErrCanaryState = errors.New("canary is not in a state that allows this operation")
+)
+
+// activeCanaryStates 尚未结束的灰度状态（作为 SQL 列表使用）
+const activeCanaryStates = `'running', 'verified', 'promoting', 'rolling_back'`
+
+const canaryColumns = `c.id, c.scope, c.cluster_name, c.version, c.base_version, c.rollback_version, c.selector,
+	c.auto_promote, c.timeout_seconds, c.state, c.reason, c.draft_id, c.created_at, c.created_by, c.updated_at, c.updated_by,
+	c.deadline,
+	(SELECT COUNT(*) FROM yaf_canary_nodes n WHERE n.canary_id = c.id AND n.canary),
+	(SELECT COUNT(*) FROM yaf_canary_nodes n WHERE n.canary_id = c.id AND NOT n.canary)`
+
+// scanCanary 扫描一行灰度发布记录
+func scanCanary(row interface{ Scan(...interface{}) error }) (*models.Canary, error) {
+	canary := &models.Canary{}
+	var selector []byte
+	if err := row.Scan(
+		&canary.ID, &canary.Scope, &canary.ClusterName, &canary.Version, &canary.BaseVersion, &canary.RollbackVersion,
+		&selector, &canary.AutoPromote, &canary.Timeout, &canary.State, &canary.Reason, &canary.DraftID,
+		&canary.CreatedAt, &canary.CreatedBy, &canary.UpdatedAt, &canary.UpdatedBy, &canary.Deadline,
+		&canary.CanaryCount, &canary.HoldoutCount,
+	); err != nil {
+		return nil, err
+	}
+	json.Unmarshal(selector, &canary.Selector)
+	return canary, nil
+}
+
+// checkNoCanary 在保存某一级配置的事务中检查是否有尚未结束的灰度发布。
+// 应在 insertVersion 之后调用，此时已持有该级配置的 advisory lock，与开始灰度互斥
+func checkNoCanary(tx *sql.Tx, record *models.ConfigRecord) error {
+	if record.Scope == models.ScopeNode {
+		return nil
+	}
+	var id int64
+	err := tx.QueryRow(`
+		SELECT id FROM yaf_canaries
+		WHERE scope = $1 AND cluster_name = $2 AND state IN (`+activeCanaryStates+`)
+		LIMIT 1
+	`, record.Scope, record.ClusterName).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check canary: %w", err)
+	}
+	return ErrCanaryActive
+}
+
+// CanaryBaseVersion 节点的某一级（global / cluster）配置是否因尚未结束的灰度发布停留在基准版本：
+// 该级正在灰度且节点不是灰度节点时返回基准版本与 true，与 Agent 读取固定节点或守护节点的结果一致
+func (p *PostgresDB) CanaryBaseVersion(scope models.ConfigScope, clusterName, nodeID string) (int, bool, error) {
+	var base int
+	err := p.db.QueryRow(`
+		SELECT c.base_version FROM yaf_canaries c
+		WHERE c.scope = $1 AND (c.scope = 'global' OR c.cluster_name = $2) AND c.state IN (`+activeCanaryStates+`)
+			AND NOT EXISTS (
+				SELECT 1 FROM yaf_canary_nodes cn
+				WHERE cn.canary_id = c.id AND cn.canary AND cn.cluster_name = $2 AND cn.node_id = $3
+			)
+		LIMIT 1
+	`, scope, clusterName, nodeID).Scan(&base)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to check canary pin: %w", err)
+	}
+	return base, true, nil
+}
+
+// ListCanaryCandidates 列出可参与灰度的节点：已登记的节点与观察到 Agent 的节点的并集，附带节点标签。
+// clusterName 为空时列出所有未归档集群的节点（全局配置灰度）
+func (p *PostgresDB) ListCanaryCandidates(clusterName string) ([]*models.Node, error) {
+	rows, err := p.db.Query(`
+		WITH members AS (
+			SELECT cluster_name, node_id FROM yaf_nodes
+			UNION
+			SELECT cluster_name, node_id FROM yaf_agents
+		)
+		SELECT m.cluster_name, m.node_id, COALESCE(n.labels, '{}')
+		FROM members m
+		LEFT JOIN yaf_nodes n ON n.cluster_name = m.cluster_name AND n.node_id = m.node_id
+		WHERE ($1 = '' AND m.cluster_name NOT IN (SELECT cluster_name FROM yaf_cluster_archive))
+			OR m.cluster_name = $1
+		ORDER BY m.cluster_name, m.node_id
+	`, clusterName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list canary candidates: %w", err)
+	}
+	defer rows.Close()
+
+	nodes := []*models.Node{}
+	for rows.Next() {
+		node := &models.Node{}
+		var labels []byte
+		var err error
+		if err := rows.Scan(&node.ClusterName, &node.NodeID, &labels); err != nil {
+			return nil, fmt.Errorf("failed to scan canary candidate: %w", err)
+		}
+		if node.Labels, err = decodeLabels(labels); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// StartCanary 在同一事务中保存灰度的新版本（record，基于 canary.BaseVersion）并登记灰度发布与参与的节点；
+// canary.DraftID 不为 0 时同时将该灰度草稿标记为已发布，草稿不是审批通过的灰度草稿时返回 ErrDraftState。
+// 同一级配置已有尚未结束的灰度时返回 ErrCanaryActive，基准版本已过期时返回 *VersionConflictError。
+// pin 在持有该级配置的 advisory lock 并确认没有其他灰度之后调用，用于写入固定节点与守护节点，
+// 因此并发开始的灰度不会互相覆盖或删除对方的固定节点；此后任一步骤失败时在释放锁之前调用 unpin 撤销
+func (p *PostgresDB) StartCanary(canary *models.Canary, record *models.ConfigRecord, nodes []*models.CanaryNode,
+	pin, unpin func() error) error {
+	tx, err := p.beginChanges()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertVersion(tx, record, canary.BaseVersion); err != nil {
+		return err
+	}
+	if err := checkNoCanary(tx.Tx, record); err != nil {
+		return err
+	}
+
+	pinned := true
+	defer func() {
+		if !pinned {
+			return
+		}
+		if err := unpin(); err != nil {
+			p.logger.Warn("failed to undo canary pins", zap.Error(err))
+		}
+	}()
+	if err := pin(); err != nil {
+		return err
+	}
+
+	selector, _ := json.Marshal(canary.Selector)
+	canary.Version = record.Version
+	canary.State = models.CanaryRunning
+	canary.CreatedAt = record.CreatedAt
+	canary.UpdatedAt = canary.CreatedAt
+	canary.UpdatedBy = canary.CreatedBy
+	canary.Deadline = canary.CreatedAt.Add(time.Duration(canary.Timeout) * time.Second)
+	err = tx.QueryRow(`
+		INSERT INTO yaf_canaries (scope, cluster_name, version, base_version, selector, auto_promote, timeout_seconds,
+			state, draft_id, created_at, created_by, updated_at, updated_by, deadline)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $10, $11, $12)
+		RETURNING id
+	`, canary.Scope, canary.ClusterName, canary.Version, canary.BaseVersion, selector, canary.AutoPromote, canary.Timeout,
+		canary.State, canary.DraftID, canary.CreatedAt, canary.CreatedBy, canary.Deadline).Scan(&canary.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save canary: %w", err)
+	}
+	if canary.DraftID > 0 {
+		if err := publishCanaryDraft(tx.Tx, canary, canary.CreatedBy); err != nil {
+			return err
+		}
+	}
+
+	canary.CanaryCount, canary.HoldoutCount = 0, 0
+	for _, node := range nodes {
+		if _, err := tx.Exec(`
+			INSERT INTO yaf_canary_nodes (canary_id, cluster_name, node_id, canary) VALUES ($1, $2, $3, $4)
+		`, canary.ID, node.ClusterName, node.NodeID, node.Canary); err != nil {
+			return fmt.Errorf("failed to save canary node: %w", err)
+		}
+		if node.Canary {
+			canary.CanaryCount++
+		} else {
+			canary.HoldoutCount++
+		}
+	}
+
+	if err := p.commit(tx); err != nil {
+		return fmt.Errorf("failed to commit canary: %w", err)
+	}
+	pinned = false
+
+	p.logger.Info("canary started",
+		zap.Int64("id", canary.ID),
+		zap.String("scope", string(canary.Scope)),
+		zap.String("cluster", canary.ClusterName),
+		zap.Int("version", canary.Version),
+		zap.Int("base_version", canary.BaseVersion),
+		zap.Int("canary_nodes", canary.CanaryCount),
+		zap.Int("holdout_nodes", canary.HoldoutCount),
+	)
+	return nil
+}
+
+// ListCanaries 列出灰度发布（不含节点明细），按创建时间倒序；activeOnly 时只列出尚未结束的
+func (p *PostgresDB) ListCanaries(activeOnly bool, limit int) ([]*models.Canary, error) {
+	rows, err := p.db.Query(`
+		SELECT `+canaryColumns+` FROM yaf_canaries c
+		WHERE NOT $1 OR c.state IN (`+activeCanaryStates+`)
+		ORDER BY c.id DESC
+		LIMIT $2
+	`, activeOnly, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list canaries: %w", err)
+	}
+	defer rows.Close()
+
+	canaries := []*models.Canary{}
+	for rows.Next() {
+		canary, err := scanCanary(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan canary: %w", err)
+		}
+		canaries = append(canaries, canary)
+	}
+	return canaries, nil
+}
+
+// GetCanary 获取灰度发布及其节点的应用状态，不存在时返回 nil
+func (p *PostgresDB) GetCanary(id int64) (*models.Canary, error) {
+	canary, err := scanCanary(p.db.QueryRow(`SELECT `+canaryColumns+` FROM yaf_canaries c WHERE c.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get canary: %w", err)
+	}
+
+	// 灰度节点以新版本为期望版本，固定的节点以基准版本为期望版本
+	appliedColumn := "applied_global"
+	if canary.Scope == models.ScopeCluster {
+		appliedColumn = "applied_cluster"
+	}
+	rows, err := p.db.Query(`
+		SELECT n.cluster_name, n.node_id, n.canary, COALESCE(ag.online, FALSE),
+			ag.`+appliedColumn+`, ag.apply_status, ag.apply_error, ag.applied_at
+		FROM yaf_canary_nodes n
+		LEFT JOIN yaf_agents ag ON ag.cluster_name = n.cluster_name AND ag.node_id = n.node_id
+		WHERE n.canary_id = $1
+		ORDER BY n.canary DESC, n.cluster_name, n.node_id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list canary nodes: %w", err)
+	}
+	defer rows.Close()
+
+	canary.Nodes = []*models.CanaryNode{}
+	for rows.Next() {
+		node := &models.CanaryNode{}
+		var applied sql.NullInt64
+		var status, applyError sql.NullString
+		var appliedAt sql.NullTime
+		if err := rows.Scan(
+			&node.ClusterName, &node.NodeID, &node.Canary, &node.Online,
+			&applied, &status, &applyError, &appliedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan canary node: %w", err)
+		}
+		expected := canary.BaseVersion
+		if node.Canary {
+			expected = canary.Version
+		}
+		switch {
+		case !appliedAt.Valid:
+			node.State = models.RolloutUnknown
+		case int(applied.Int64) != expected:
+			node.State = models.RolloutPending
+		case status.String == models.ApplyFailed:
+			node.State = models.RolloutFailed
+			node.Error = applyError.String
+		default:
+			node.State = models.RolloutLatest
+		}
+		canary.Nodes = append(canary.Nodes, node)
+	}
+	return canary, nil
+}
+
+// SetCanaryState 将灰度发布从 from 中的某个状态切换到 state，当前状态不在 from 中时返回 ErrCanaryState
+func (p *PostgresDB) SetCanaryState(id int64, from []string, state, updatedBy string) error {
+	return setCanaryState(p.db, id, from, state, "", updatedBy)
+}
+
+// RollbackCanary 回滚灰度发布：同一事务中保存回滚版本（record，内容为基准版本）并切换到 rolling_back，
+// 固定的节点在回滚版本发布后解除固定。灰度已全量或已回滚时返回 ErrCanaryState
+func (p *PostgresDB) RollbackCanary(canary *models.Canary, record *models.ConfigRecord, reason string) error {
+	tx, err := p.beginChanges()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	from := []string{models.CanaryRunning, models.CanaryVerified}
+	if err := setCanaryState(tx, canary.ID, from, models.CanaryRollingBack, reason, record.CreatedBy); err != nil {
+		return err
+	}
+	if err := insertVersion(tx, record, AnyVersion); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE yaf_canaries SET rollback_version = $1 WHERE id = $2", record.Version, canary.ID); err != nil {
+		return fmt.Errorf("failed to update canary: %w", err)
+	}
+
+	if err := p.commit(tx); err != nil {
+		return fmt.Errorf("failed to commit canary rollback: %w", err)
+	}
+
+	canary.State = models.CanaryRollingBack
+	canary.Reason = reason
+	canary.RollbackVersion = record.Version
+	p.logger.Info("canary rolling back",
+		zap.Int64("id", canary.ID),
+		zap.Int("rollback_version", record.Version),
+		zap.String("reason", reason),
+	)
+	return nil
+}
+
+// setCanaryState 条件更新灰度状态，reason 为空时保留原因
+func setCanaryState(exec interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, id int64, from []string, state, reason, updatedBy string) error {
+	result, err := exec.Exec(`
+		UPDATE yaf_canaries SET state = $1, reason = COALESCE(NULLIF($2, ''), reason), updated_at = NOW(), updated_by = $3
+		WHERE id = $4 AND state = ANY($5)
+	`, state, reason, updatedBy, id, pq.Array(from))
+	if err != nil {
+		return fmt.Errorf("failed to update canary: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCanaryState
+	}
+	return nil
+}
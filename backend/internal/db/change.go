@@ -0,0 +1,51 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/yf-web/backend/internal/models"
+)
+
+// ChangeListener 配置变更监听器，在事务提交后由提交的 goroutine 同步调用，不应阻塞
+type ChangeListener func(change *models.ConfigChange)
+
+// OnChange 注册配置变更监听器。写入配置版本、配置档层与标签选择层的事务提交后，
+// 每个变更（含删除标记）都会交给所有监听器；应在启动时、开始处理请求前注册
+func (p *PostgresDB) OnChange(listener ChangeListener) {
+	p.listeners = append(p.listeners, listener)
+}
+
+// changeTx 写入配置变更的事务：insertVersion、composeLayer 等在其中登记变更，
+// commit 成功后才交给监听器，回滚的事务不会产生通知或事件
+type changeTx struct {
+	*sql.Tx
+	changes []*models.ConfigChange
+}
+
+// beginChanges 开始一个写入配置变更的事务
+func (p *PostgresDB) beginChanges() (*changeTx, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &changeTx{Tx: tx}, nil
+}
+
+// record 登记事务中的一个变更
+func (tx *changeTx) record(change *models.ConfigChange) {
+	tx.changes = append(tx.changes, change)
+}
+
+// commit 提交事务并将登记的变更交给监听器
+func (p *PostgresDB) commit(tx *changeTx) error {
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, change := range tx.changes {
+		for _, listener := range p.listeners {
+			listener(change)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// ErrDraftState 草稿的当前状态不允许该操作
+var ErrDraftState = errors.New("draft is not in a state that allows this operation")
+
+const draftColumns = `d.id, d.scope, d.cluster_name, d.node_id, d.title, d.description, d.config_json, d.deleted,
+	d.canary, d.base_version, d.state, d.required_approvals, d.version, d.created_at, d.created_by, d.updated_at, d.updated_by,
+	ARRAY(SELECT a.approver FROM yaf_draft_approvals a WHERE a.draft_id = d.id ORDER BY a.created_at)`
+
+// scanDraft 扫描一行草稿记录
+func scanDraft(row interface{ Scan(...interface{}) error }) (*models.Draft, error) {
+	draft := &models.Draft{}
+	var approvals pq.StringArray
+	if err := row.Scan(
+		&draft.ID, &draft.Scope, &draft.ClusterName, &draft.NodeID, &draft.Title, &draft.Description,
+		&draft.ConfigJSON, &draft.Deleted, &draft.Canary, &draft.BaseVersion, &draft.State, &draft.RequiredApprovals, &draft.Version,
+		&draft.CreatedAt, &draft.CreatedBy, &draft.UpdatedAt, &draft.UpdatedBy, &approvals,
+	); err != nil {
+		return nil, err
+	}
+	draft.Approvals = []string(approvals)
+	return draft, nil
+}
+
+// CreateDraft 保存草稿，填充 ID、状态与时间
+func (p *PostgresDB) CreateDraft(draft *models.Draft) error {
+	now := time.Now()
+	draft.State = models.DraftOpen
+	draft.Approvals = []string{}
+	draft.CreatedAt = now
+	draft.UpdatedAt = now
+	draft.UpdatedBy = draft.CreatedBy
+	err := p.db.QueryRow(`
+		INSERT INTO yaf_drafts (scope, cluster_name, node_id, title, description, config_json, deleted, canary,
+			base_version, state, created_at, created_by, updated_at, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $11, $12)
+		RETURNING id
+	`, draft.Scope, draft.ClusterName, draft.NodeID, draft.Title, draft.Description, draft.ConfigJSON, draft.Deleted,
+		draft.Canary, draft.BaseVersion, draft.State, now, draft.CreatedBy).Scan(&draft.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create draft: %w", err)
+	}
+	return nil
+}
+
+// GetDraft 获取草稿及其时间线，不存在时返回 nil
+func (p *PostgresDB) GetDraft(id int64) (*models.Draft, error) {
+	draft, err := scanDraft(p.db.QueryRow(`SELECT `+draftColumns+` FROM yaf_drafts d WHERE d.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+
+	rows, err := p.db.Query(`
+		SELECT id, draft_id, author, action, body, created_at
+		FROM yaf_draft_events
+		WHERE draft_id = $1
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query draft events: %w", err)
+	}
+	defer rows.Close()
+
+	draft.Comments = []*models.DraftEvent{}
+	for rows.Next() {
+		event := &models.DraftEvent{}
+		if err := rows.Scan(&event.ID, &event.DraftID, &event.Author, &event.Action, &event.Body, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan draft event: %w", err)
+		}
+		draft.Comments = append(draft.Comments, event)
+	}
+	return draft, nil
+}
+
+// ListDrafts 列出草稿（不含时间线），按最后更新时间倒序
+func (p *PostgresDB) ListDrafts(filter models.DraftFilter) ([]*models.Draft, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := p.db.Query(`
+		SELECT `+draftColumns+` FROM yaf_drafts d
+		WHERE ($1 = '' OR d.state = $1)
+			AND ($2 = '' OR d.scope = $2)
+			AND ($3 = '' OR d.cluster_name = $3)
+			AND ($4 = '' OR d.node_id = $4)
+			AND ($5 = '' OR d.created_by = $5)
+		ORDER BY d.updated_at DESC, d.id DESC
+		LIMIT $6
+	`, filter.State, filter.Scope, filter.ClusterName, filter.NodeID, filter.CreatedBy, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drafts: %w", err)
+	}
+	defer rows.Close()
+
+	drafts := []*models.Draft{}
+	for rows.Next() {
+		draft, err := scanDraft(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan draft: %w", err)
+		}
+		drafts = append(drafts, draft)
+	}
+	return drafts, nil
+}
+
+// UpdateDraft 修改草稿内容（标题、说明、配置、是否删除、是否灰度、基准版本）。评审中的草稿清空已有的审批，
+// 被驳回的草稿回到编辑状态；已发布或已关闭时返回 ErrDraftState
+func (p *PostgresDB) UpdateDraft(draft *models.Draft, updatedBy string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockDraft(tx, draft.ID)
+	if err != nil {
+		return err
+	}
+	if !current.Editable() {
+		return ErrDraftState
+	}
+	state := current.State
+	if state == models.DraftRejected {
+		state = models.DraftOpen
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE yaf_drafts SET title = $2, description = $3, config_json = $4, deleted = $5, canary = $6, base_version = $7,
+			state = $8, updated_at = NOW(), updated_by = $9
+		WHERE id = $1
+	`, draft.ID, draft.Title, draft.Description, draft.ConfigJSON, draft.Deleted, draft.Canary, draft.BaseVersion, state,
+		updatedBy); err != nil {
+		return fmt.Errorf("failed to update draft: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM yaf_draft_approvals WHERE draft_id = $1`, draft.ID); err != nil {
+		return fmt.Errorf("failed to reset approvals: %w", err)
+	}
+	if err := insertDraftEvent(tx, draft.ID, updatedBy, models.DraftActionUpdate, ""); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit draft: %w", err)
+	}
+	return nil
+}
+
+// SubmitDraft 提交评审，required 为该级配置需要的审批数；为 0 时直接发布并返回保存的配置记录（灰度草稿改为
+// 审批通过，返回 nil）。
+// 只有编辑中或被驳回的草稿可以提交，否则返回 ErrDraftState；发布时的错误同 SaveConfig
+func (p *PostgresDB) SubmitDraft(id int64, submittedBy string, required int) (*models.ConfigRecord, error) {
+	tx, err := p.beginChanges()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	draft, err := lockDraft(tx.Tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if draft.State != models.DraftOpen && draft.State != models.DraftRejected {
+		return nil, ErrDraftState
+	}
+	if _, err := tx.Exec(`
+		UPDATE yaf_drafts SET state = 'in_review', required_approvals = $2, updated_at = NOW(), updated_by = $3
+		WHERE id = $1
+	`, id, required, submittedBy); err != nil {
+		return nil, fmt.Errorf("failed to submit draft: %w", err)
+	}
+	if err := insertDraftEvent(tx.Tx, id, submittedBy, models.DraftActionSubmit, ""); err != nil {
+		return nil, err
+	}
+
+	var record *models.ConfigRecord
+	if required == 0 {
+		if record, err = p.completeDraft(tx, draft, submittedBy); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.commit(tx); err != nil {
+		return nil, fmt.Errorf("failed to commit draft: %w", err)
+	}
+	return record, nil
+}
+
+// ApproveDraft 记录审批（同一用户重复审批只计一次），审批数达到要求时在同一事务中发布，返回保存的配置记录；
+// 灰度草稿审批数达到要求时改为审批通过，等待开始灰度发布，返回 nil。
+// 草稿不在评审中时返回 ErrDraftState；发布失败（如版本冲突）时整个审批不生效，错误同 SaveConfig
+func (p *PostgresDB) ApproveDraft(id int64, approver, comment string) (*models.ConfigRecord, error) {
+	tx, err := p.beginChanges()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	draft, err := lockDraft(tx.Tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if draft.State != models.DraftInReview {
+		return nil, ErrDraftState
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO yaf_draft_approvals (draft_id, approver) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, id, approver); err != nil {
+		return nil, fmt.Errorf("failed to approve draft: %w", err)
+	}
+	if err := insertDraftEvent(tx.Tx, id, approver, models.DraftActionApprove, comment); err != nil {
+		return nil, err
+	}
+
+	var approvals int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM yaf_draft_approvals WHERE draft_id = $1`, id).Scan(&approvals); err != nil {
+		return nil, fmt.Errorf("failed to count approvals: %w", err)
+	}
+	var record *models.ConfigRecord
+	if approvals >= draft.RequiredApprovals {
+		if record, err = p.completeDraft(tx, draft, approver); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.commit(tx); err != nil {
+		return nil, fmt.Errorf("failed to commit approval: %w", err)
+	}
+	return record, nil
+}
+
+// RejectDraft 驳回评审中的草稿并清空已有的审批
+func (p *PostgresDB) RejectDraft(id int64, reviewer, comment string) error {
+	return p.transitionDraft(id, []string{models.DraftInReview}, models.DraftRejected, reviewer, models.DraftActionReject, comment)
+}
+
+// CloseDraft 关闭尚未发布的草稿（包括审批通过但尚未开始灰度的灰度草稿）
+func (p *PostgresDB) CloseDraft(id int64, closedBy string) error {
+	return p.transitionDraft(id, []string{models.DraftOpen, models.DraftInReview, models.DraftRejected, models.DraftApproved},
+		models.DraftClosed, closedBy, models.DraftActionClose, "")
+}
+
+// AddDraftComment 为草稿添加评论
+func (p *PostgresDB) AddDraftComment(id int64, author, body string) (*models.DraftEvent, error) {
+	event := &models.DraftEvent{DraftID: id, Author: author, Action: models.DraftActionComment, Body: body}
+	err := p.db.QueryRow(`
+		INSERT INTO yaf_draft_events (draft_id, author, action, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, id, author, event.Action, body).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add draft comment: %w", err)
+	}
+	return event, nil
+}
+
+// transitionDraft 将处于 from 状态之一的草稿改为 state、清空审批并记录动作，否则返回 ErrDraftState
+func (p *PostgresDB) transitionDraft(id int64, from []string, state, user, action, body string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE yaf_drafts SET state = $2, updated_at = NOW(), updated_by = $3
+		WHERE id = $1 AND state = ANY($4)
+	`, id, state, user, pq.Array(from))
+	if err != nil {
+		return fmt.Errorf("failed to update draft state: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDraftState
+	}
+	if _, err := tx.Exec(`DELETE FROM yaf_draft_approvals WHERE draft_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to reset approvals: %w", err)
+	}
+	if err := insertDraftEvent(tx, id, user, action, body); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit draft: %w", err)
+	}
+	return nil
+}
+
+// lockDraft 在事务中锁定并读取草稿，不存在时返回 ErrDraftState
+func lockDraft(tx *sql.Tx, id int64) (*models.Draft, error) {
+	draft, err := scanDraft(tx.QueryRow(`SELECT `+draftColumns+` FROM yaf_drafts d WHERE d.id = $1 FOR UPDATE OF d`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrDraftState
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock draft: %w", err)
+	}
+	return draft, nil
+}
+
+// insertDraftEvent 在事务中记录草稿时间线
+func insertDraftEvent(tx *sql.Tx, id int64, author, action, body string) error {
+	if _, err := tx.Exec(`
+		INSERT INTO yaf_draft_events (draft_id, author, action, body) VALUES ($1, $2, $3, $4)
+	`, id, author, action, body); err != nil {
+		return fmt.Errorf("failed to record draft event: %w", err)
+	}
+	return nil
+}
+
+// completeDraft 审批数达到要求：灰度草稿改为审批通过并返回 nil，其他草稿发布并返回保存的配置记录
+func (p *PostgresDB) completeDraft(tx *changeTx, draft *models.Draft, user string) (*models.ConfigRecord, error) {
+	if !draft.Canary {
+		return p.publishDraft(tx, draft, user)
+	}
+	if _, err := tx.Exec(`
+		UPDATE yaf_drafts SET state = 'approved', updated_at = NOW(), updated_by = $2
+		WHERE id = $1
+	`, draft.ID, user); err != nil {
+		return nil, fmt.Errorf("failed to mark draft approved: %w", err)
+	}
+	p.logger.Info("canary draft approved", zap.Int64("draft", draft.ID))
+	return nil, nil
+}
+
+// publishCanaryDraft 在开始灰度的事务中将灰度草稿标记为已发布（版本为灰度的新版本），
+// 草稿不是审批通过的灰度草稿时返回 ErrDraftState
+func publishCanaryDraft(tx *sql.Tx, canary *models.Canary, publishedBy string) error {
+	result, err := tx.Exec(`
+		UPDATE yaf_drafts SET state = 'published', version = $2, updated_at = NOW(), updated_by = $3
+		WHERE id = $1 AND state = 'approved' AND canary
+	`, canary.DraftID, canary.Version, publishedBy)
+	if err != nil {
+		return fmt.Errorf("failed to mark draft published: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDraftState
+	}
+	return insertDraftEvent(tx, canary.DraftID, publishedBy, models.DraftActionPublish,
+		fmt.Sprintf("v%d（灰度发布 #%d）", canary.Version, canary.ID))
+}
+
+// publishDraft 在事务中将草稿保存为新版本（删除草稿写入删除标记，集群删除同 DeleteClusterConfig）
+// 并标记为已发布，publishedBy 为完成审批的用户
+func (p *PostgresDB) publishDraft(tx *changeTx, draft *models.Draft, publishedBy string) (*models.ConfigRecord, error) {
+	record := draft.Record()
+	if record.Deleted {
+		record.ConfigJSON = "{}"
+	}
+	if record.Deleted && record.Scope == models.ScopeCluster {
+		nodes, err := p.configuredNodes(record.ClusterName)
+		if err != nil {
+			return nil, err
+		}
+		if err := insertClusterTombstones(tx, record, nodes, draft.BaseVersion); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := insertVersion(tx, record, draft.BaseVersion); err != nil {
+			return nil, err
+		}
+		if err := checkNoCanary(tx.Tx, record); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE yaf_drafts SET state = 'published', version = $2, updated_at = NOW(), updated_by = $3
+		WHERE id = $1
+	`, draft.ID, record.Version, publishedBy); err != nil {
+		return nil, fmt.Errorf("failed to mark draft published: %w", err)
+	}
+	if err := insertDraftEvent(tx.Tx, draft.ID, publishedBy, models.DraftActionPublish, fmt.Sprintf("v%d", record.Version)); err != nil {
+		return nil, err
+	}
+
+	p.logger.Info("draft published",
+		zap.Int64("draft", draft.ID),
+		zap.String("scope", string(record.Scope)),
+		zap.String("cluster", record.ClusterName),
+		zap.String("node", record.NodeID),
+		zap.Int("version", record.Version),
+		zap.Bool("deleted", record.Deleted),
+	)
+	return record, nil
+}
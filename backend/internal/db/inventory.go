@@ -0,0 +1,412 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrClusterExists 集群已登记
+	ErrClusterExists = errors.New("cluster already exists")
+	// ErrClusterNotFound 集群未登记
+	ErrClusterNotFound = errors.New("cluster not found")
+	// ErrNodeExists 节点已登记
+	ErrNodeExists = errors.New("node already exists")
+	// ErrNodeNotFound 节点未登记
+	ErrNodeNotFound = errors.New("node not found")
+	// ErrStillConfigured 集群或节点仍有配置，需先删除配置才能删除清单记录
+	ErrStillConfigured = errors.New("config still exists, delete the config first")
+)
+
+// clusterQuery 查询集群清单及其配置概况，WHERE 子句由调用方拼接
+const clusterQuery = `
+	WITH live AS (` + liveConfigs + `)
+	SELECT cl.name, cl.description, cl.owner, cl.labels, cl.created_at, cl.created_by, cl.updated_at, cl.updated_by,
+		a.archived_at, COALESCE(a.archived_by, ''), cfg.version, cfg.created_at, cfg.created_by,
+		(SELECT COUNT(*) FROM yaf_nodes n WHERE n.cluster_name = cl.name),
+		(SELECT COUNT(*) FROM live WHERE live.cluster_name = cl.name AND live.node_id <> '')
+	FROM yaf_clusters cl
+	LEFT JOIN yaf_cluster_archive a ON a.cluster_name = cl.name
+	LEFT JOIN live cfg ON cfg.scope = 'cluster' AND cfg.cluster_name = cl.name
+`
+
+// nodeQuery 查询节点清单及其配置概况，WHERE 子句由调用方拼接
+const nodeQuery = `
+	WITH live AS (` + liveConfigs + `)
+	SELECT n.cluster_name, n.node_id, n.description, n.owner, n.labels,
+		n.created_at, n.created_by, n.updated_at, n.updated_by, cfg.version, cfg.created_at, cfg.created_by,
+		ag.online, ag.hostname, ag.agent_version, ag.last_seen
+	FROM yaf_nodes n
+	LEFT JOIN live cfg ON cfg.scope = 'node' AND cfg.cluster_name = n.cluster_name AND cfg.node_id = n.node_id
+	LEFT JOIN yaf_agents ag ON ag.cluster_name = n.cluster_name AND ag.node_id = n.node_id
+`
+
+// registerInventory 为仍有配置、尚未登记的集群与节点补登清单，创建信息取自其第一个配置版本
+func (p *PostgresDB) registerInventory() error {
+	clusters, err := p.db.Exec(`
+		INSERT INTO yaf_clusters (name, created_at, created_by, updated_at, updated_by)
+		SELECT DISTINCT ON (c.cluster_name) c.cluster_name, c.created_at, c.created_by, c.created_at, c.created_by
+		FROM yaf_config c
+		WHERE c.cluster_name IN (SELECT cluster_name FROM (` + liveConfigs + `) live WHERE cluster_name <> '')
+		ORDER BY c.cluster_name, c.version
+		ON CONFLICT (name) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to register clusters: %w", err)
+	}
+	nodes, err := p.db.Exec(`
+		INSERT INTO yaf_nodes (cluster_name, node_id, created_at, created_by, updated_at, updated_by)
+		SELECT DISTINCT ON (c.cluster_name, c.node_id) c.cluster_name, c.node_id, c.created_at, c.created_by, c.created_at, c.created_by
+		FROM yaf_config c
+		WHERE (c.cluster_name, c.node_id) IN (SELECT cluster_name, node_id FROM (` + liveConfigs + `) live WHERE node_id <> '')
+		ORDER BY c.cluster_name, c.node_id, c.version
+		ON CONFLICT (cluster_name, node_id) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to register nodes: %w", err)
+	}
+
+	clusterCount, _ := clusters.RowsAffected()
+	nodeCount, _ := nodes.RowsAffected()
+	if clusterCount > 0 || nodeCount > 0 {
+		p.logger.Info("registered configured clusters and nodes",
+			zap.Int64("clusters", clusterCount),
+			zap.Int64("nodes", nodeCount),
+		)
+	}
+	return nil
+}
+
+// ensureInventory 在保存配置的事务中登记配置所属的集群与节点（已登记时不做修改）
+func ensureInventory(tx *sql.Tx, record *models.ConfigRecord) error {
+	if record.Scope == models.ScopeGlobal {
+		return nil
+	}
+	_, err := tx.Exec(`
+		INSERT INTO yaf_clusters (name, created_at, created_by, updated_at, updated_by) VALUES ($1, $2, $3, $2, $3)
+		ON CONFLICT (name) DO NOTHING
+	`, record.ClusterName, record.CreatedAt, record.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to register cluster: %w", err)
+	}
+	if record.Scope != models.ScopeNode {
+		return nil
+	}
+	_, err = tx.Exec(`
+		INSERT INTO yaf_nodes (cluster_name, node_id, created_at, created_by, updated_at, updated_by) VALUES ($1, $2, $3, $4, $3, $4)
+		ON CONFLICT (cluster_name, node_id) DO NOTHING
+	`, record.ClusterName, record.NodeID, record.CreatedAt, record.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to register node: %w", err)
+	}
+	return nil
+}
+
+// ListClusters 列出登记的集群；includeArchived 为 false 时不含已归档的集群
+func (p *PostgresDB) ListClusters(includeArchived bool) ([]*models.Cluster, error) {
+	return p.queryClusters("WHERE $1 OR a.cluster_name IS NULL ORDER BY cl.name", includeArchived)
+}
+
+// ListArchivedClusters 列出已归档的集群
+func (p *PostgresDB) ListArchivedClusters() ([]*models.Cluster, error) {
+	return p.queryClusters("WHERE a.cluster_name IS NOT NULL ORDER BY cl.name")
+}
+
+// GetCluster 获取集群，未登记时返回 nil
+func (p *PostgresDB) GetCluster(clusterName string) (*models.Cluster, error) {
+	clusters, err := p.queryClusters("WHERE cl.name = $1", clusterName)
+	if err != nil || len(clusters) == 0 {
+		return nil, err
+	}
+	return clusters[0], nil
+}
+
+// queryClusters 查询集群清单
+func (p *PostgresDB) queryClusters(where string, args ...interface{}) ([]*models.Cluster, error) {
+	rows, err := p.db.Query(clusterQuery+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+	defer rows.Close()
+
+	clusters := []*models.Cluster{}
+	for rows.Next() {
+		cluster := &models.Cluster{}
+		var labels []byte
+		var archivedAt sql.NullTime
+		var config nullConfigSummary
+		if err := rows.Scan(
+			&cluster.Name, &cluster.Description, &cluster.Owner, &labels,
+			&cluster.CreatedAt, &cluster.CreatedBy, &cluster.UpdatedAt, &cluster.UpdatedBy,
+			&archivedAt, &cluster.ArchivedBy, &config.Version, &config.UpdatedAt, &config.UpdatedBy,
+			&cluster.NodeCount, &cluster.ConfiguredNodes,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster: %w", err)
+		}
+		if cluster.Labels, err = decodeLabels(labels); err != nil {
+			return nil, err
+		}
+		if archivedAt.Valid {
+			cluster.Archived = true
+			cluster.ArchivedAt = &archivedAt.Time
+		}
+		cluster.Config = config.summary()
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// CreateCluster 登记集群，集群已存在时返回 ErrClusterExists
+func (p *PostgresDB) CreateCluster(cluster *models.Cluster) error {
+	cluster.CreatedAt = time.Now()
+	cluster.UpdatedAt = cluster.CreatedAt
+	cluster.UpdatedBy = cluster.CreatedBy
+	_, err := p.db.Exec(`
+		INSERT INTO yaf_clusters (name, description, owner, labels, created_at, created_by, updated_at, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $5, $6)
+	`, cluster.Name, cluster.Description, cluster.Owner, encodeLabels(cluster.Labels), cluster.CreatedAt, cluster.CreatedBy)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrClusterExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create cluster: %w", err)
+	}
+	return nil
+}
+
+// UpdateCluster 修改集群的描述、负责人与标签
+func (p *PostgresDB) UpdateCluster(clusterName string, meta models.InventoryMeta, updatedBy string) error {
+	result, err := p.db.Exec(`
+		UPDATE yaf_clusters SET description = $1, owner = $2, labels = $3, updated_at = NOW(), updated_by = $4
+		WHERE name = $5
+	`, meta.Description, meta.Owner, encodeLabels(meta.Labels), updatedBy, clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to update cluster: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrClusterNotFound
+	}
+	return nil
+}
+
+// DeleteCluster 删除集群的清单记录（节点记录与归档状态一并删除）。
+// 集群或其节点仍有配置时返回 ErrStillConfigured，需先通过 DeleteClusterConfig 删除配置；
+// 仍挂载配置档时返回 ErrProfilesAttached
+func (p *PostgresDB) DeleteCluster(clusterName string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var configured bool
+	if err := tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM (`+liveConfigs+`) live WHERE cluster_name = $1)
+	`, clusterName).Scan(&configured); err != nil {
+		return fmt.Errorf("failed to check cluster configs: %w", err)
+	}
+	if configured {
+		return ErrStillConfigured
+	}
+	attached, err := hasAttachedProfiles(tx, clusterName, "")
+	if err != nil {
+		return err
+	}
+	if attached {
+		return ErrProfilesAttached
+	}
+
+	result, err := tx.Exec("DELETE FROM yaf_clusters WHERE name = $1", clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to delete cluster: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrClusterNotFound
+	}
+	if _, err := tx.Exec("DELETE FROM yaf_cluster_archive WHERE cluster_name = $1", clusterName); err != nil {
+		return fmt.Errorf("failed to delete cluster: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit cluster deletion: %w", err)
+	}
+	return nil
+}
+
+// ArchiveCluster 归档集群，已归档时不做修改
+func (p *PostgresDB) ArchiveCluster(clusterName, archivedBy string) error {
+	_, err := p.db.Exec(`
+		INSERT INTO yaf_cluster_archive (cluster_name, archived_by) VALUES ($1, $2)
+		ON CONFLICT (cluster_name) DO NOTHING
+	`, clusterName, archivedBy)
+	if err != nil {
+		return fmt.Errorf("failed to archive cluster: %w", err)
+	}
+	return nil
+}
+
+// UnarchiveCluster 取消归档
+func (p *PostgresDB) UnarchiveCluster(clusterName string) error {
+	_, err := p.db.Exec("DELETE FROM yaf_cluster_archive WHERE cluster_name = $1", clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to unarchive cluster: %w", err)
+	}
+	return nil
+}
+
+// ListNodes 列出集群下登记的节点
+func (p *PostgresDB) ListNodes(clusterName string) ([]*models.Node, error) {
+	return p.queryNodes("WHERE n.cluster_name = $1 ORDER BY n.node_id", clusterName)
+}
+
+// GetNode 获取节点，未登记时返回 nil
+func (p *PostgresDB) GetNode(clusterName, nodeID string) (*models.Node, error) {
+	nodes, err := p.queryNodes("WHERE n.cluster_name = $1 AND n.node_id = $2", clusterName, nodeID)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// queryNodes 查询节点清单
+func (p *PostgresDB) queryNodes(where string, args ...interface{}) ([]*models.Node, error) {
+	rows, err := p.db.Query(nodeQuery+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	defer rows.Close()
+
+	nodes := []*models.Node{}
+	for rows.Next() {
+		node := &models.Node{}
+		var labels []byte
+		var config nullConfigSummary
+		var agent nullAgent
+		if err := rows.Scan(
+			&node.ClusterName, &node.NodeID, &node.Description, &node.Owner, &labels,
+			&node.CreatedAt, &node.CreatedBy, &node.UpdatedAt, &node.UpdatedBy,
+			&config.Version, &config.UpdatedAt, &config.UpdatedBy,
+			&agent.Online, &agent.Hostname, &agent.AgentVersion, &agent.LastSeen,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan node: %w", err)
+		}
+		if node.Labels, err = decodeLabels(labels); err != nil {
+			return nil, err
+		}
+		node.Config = config.summary()
+		node.Agent = agent.status()
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// CreateNode 登记节点。集群未登记时返回 ErrClusterNotFound，节点已存在时返回 ErrNodeExists
+func (p *PostgresDB) CreateNode(node *models.Node) error {
+	node.CreatedAt = time.Now()
+	node.UpdatedAt = node.CreatedAt
+	node.UpdatedBy = node.CreatedBy
+	_, err := p.db.Exec(`
+		INSERT INTO yaf_nodes (cluster_name, node_id, description, owner, labels, created_at, created_by, updated_at, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $6, $7)
+	`, node.ClusterName, node.NodeID, node.Description, node.Owner, encodeLabels(node.Labels), node.CreatedAt, node.CreatedBy)
+	if pqErr, ok := err.(*pq.Error); ok {
+		switch pqErr.Code {
+		case "23505":
+			return ErrNodeExists
+		case "23503":
+			return ErrClusterNotFound
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create node: %w", err)
+	}
+	return nil
+}
+
+// UpdateNode 修改节点的描述、负责人与标签
+func (p *PostgresDB) UpdateNode(clusterName, nodeID string, meta models.InventoryMeta, updatedBy string) error {
+	result, err := p.db.Exec(`
+		UPDATE yaf_nodes SET description = $1, owner = $2, labels = $3, updated_at = NOW(), updated_by = $4,
+			labels_version = labels_version + CASE WHEN labels = $3::jsonb THEN 0 ELSE 1 END
+		WHERE cluster_name = $5 AND node_id = $6
+	`, meta.Description, meta.Owner, encodeLabels(meta.Labels), updatedBy, clusterName, nodeID)
+	if err != nil {
+		return fmt.Errorf("failed to update node: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNodeNotFound
+	}
+	return nil
+}
+
+// DeleteNode 删除节点的清单记录。节点仍有配置时返回 ErrStillConfigured，需先通过 DeleteConfig 删除配置；
+// 仍挂载配置档时返回 ErrProfilesAttached
+func (p *PostgresDB) DeleteNode(clusterName, nodeID string) error {
+	result, err := p.db.Exec(`
+		DELETE FROM yaf_nodes n
+		WHERE n.cluster_name = $1 AND n.node_id = $2
+			AND NOT EXISTS (SELECT 1 FROM (`+liveConfigs+`) live WHERE live.cluster_name = $1 AND live.node_id = $2)
+			AND NOT EXISTS (SELECT 1 FROM yaf_profile_attachments a WHERE a.cluster_name = $1 AND a.node_id = $2)
+	`, clusterName, nodeID)
+	if err != nil {
+		return fmt.Errorf("failed to delete node: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	node, err := p.GetNode(clusterName, nodeID)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return ErrNodeNotFound
+	}
+	if node.Config == nil {
+		return ErrProfilesAttached
+	}
+	return ErrStillConfigured
+}
+
+// nullConfigSummary 左连接得到的配置概况，没有配置时各列为 NULL
+type nullConfigSummary struct {
+	Version   sql.NullInt64
+	UpdatedAt sql.NullTime
+	UpdatedBy sql.NullString
+}
+
+// summary 转换为配置概况，没有配置时返回 nil
+func (s *nullConfigSummary) summary() *models.ConfigSummary {
+	if !s.Version.Valid {
+		return nil
+	}
+	return &models.ConfigSummary{
+		Version:   int(s.Version.Int64),
+		UpdatedAt: s.UpdatedAt.Time,
+		UpdatedBy: s.UpdatedBy.String,
+	}
+}
+
+// encodeLabels 序列化标签，nil 保存为空对象
+func encodeLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "{}"
+	}
+	data, _ := json.Marshal(labels)
+	return string(data)
+}
+
+// decodeLabels 解析标签列
+func decodeLabels(data []byte) (map[string]string, error) {
+	labels := map[string]string{}
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("failed to parse labels: %w", err)
+	}
+	return labels, nil
+}
@@ -0,0 +1,325 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrSubscriptionNotFound 通知订阅不存在
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrDeliveryNotFound 投递记录不存在
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+const subscriptionColumns = `id, name, events, scope, cluster_name, channel, target, secret, enabled,
+	created_at, created_by, updated_at, updated_by`
+
+const deliveryColumns = `d.id, d.subscription_id, s.name, s.channel, d.event_type, d.payload, d.status, d.attempts,
+	d.last_error, d.next_attempt_at, d.created_at, d.delivered_at`
+
+// scanSubscription 扫描一行通知订阅
+func scanSubscription(row interface{ Scan(...interface{}) error }) (*models.Subscription, error) {
+	subscription := &models.Subscription{}
+	var events []byte
+	err := row.Scan(
+		&subscription.ID, &subscription.Name, &events, &subscription.Scope, &subscription.ClusterName,
+		&subscription.Channel, &subscription.Target, &subscription.Secret, &subscription.Enabled,
+		&subscription.CreatedAt, &subscription.CreatedBy, &subscription.UpdatedAt, &subscription.UpdatedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(events, &subscription.Events); err != nil {
+		return nil, fmt.Errorf("failed to parse subscription events: %w", err)
+	}
+	subscription.HasSecret = subscription.Secret != ""
+	return subscription, nil
+}
+
+// encodeEvents 编码订阅的事件类型列表
+func encodeEvents(events []string) string {
+	if len(events) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(events)
+	return string(data)
+}
+
+// ListSubscriptions 列出通知订阅
+func (p *PostgresDB) ListSubscriptions() ([]*models.Subscription, error) {
+	rows, err := p.db.Query(`SELECT ` + subscriptionColumns + ` FROM yaf_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []*models.Subscription{}
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+// GetSubscription 获取通知订阅，不存在时返回 nil
+func (p *PostgresDB) GetSubscription(id int64) (*models.Subscription, error) {
+	subscription, err := scanSubscription(p.db.QueryRow(`SELECT `+subscriptionColumns+` FROM yaf_subscriptions WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+// CreateSubscription 创建通知订阅，填充 ID 与时间
+func (p *PostgresDB) CreateSubscription(subscription *models.Subscription) error {
+	now := time.Now()
+	subscription.CreatedAt = now
+	subscription.UpdatedAt = now
+	subscription.UpdatedBy = subscription.CreatedBy
+	err := p.db.QueryRow(`
+		INSERT INTO yaf_subscriptions (name, events, scope, cluster_name, channel, target, secret, enabled,
+			created_at, created_by, updated_at, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $9, $10)
+		RETURNING id
+	`, subscription.Name, encodeEvents(subscription.Events), subscription.Scope, subscription.ClusterName,
+		subscription.Channel, subscription.Target, subscription.Secret, subscription.Enabled,
+		now, subscription.CreatedBy).Scan(&subscription.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create subscription: %w", err)
+	}
+	subscription.HasSecret = subscription.Secret != ""
+
+	p.logger.Info("subscription created",
+		zap.Int64("id", subscription.ID),
+		zap.String("name", subscription.Name),
+		zap.String("channel", subscription.Channel),
+	)
+	return nil
+}
+
+// UpdateSubscription 修改通知订阅，不存在时返回 ErrSubscriptionNotFound
+func (p *PostgresDB) UpdateSubscription(subscription *models.Subscription) error {
+	err := p.db.QueryRow(`
+		UPDATE yaf_subscriptions SET name = $2, events = $3, scope = $4, cluster_name = $5, channel = $6,
+			target = $7, secret = $8, enabled = $9, updated_at = NOW(), updated_by = $10
+		WHERE id = $1
+		RETURNING updated_at
+	`, subscription.ID, subscription.Name, encodeEvents(subscription.Events), subscription.Scope,
+		subscription.ClusterName, subscription.Channel, subscription.Target, subscription.Secret,
+		subscription.Enabled, subscription.UpdatedBy).Scan(&subscription.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	subscription.HasSecret = subscription.Secret != ""
+	return nil
+}
+
+// DeleteSubscription 删除通知订阅及其投递记录，不存在时返回 ErrSubscriptionNotFound
+func (p *PostgresDB) DeleteSubscription(id int64) error {
+	result, err := p.db.Exec(`DELETE FROM yaf_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// EnqueueNotification 为接收该事件的每个启用的订阅登记一条投递记录，返回登记的条数
+func (p *PostgresDB) EnqueueNotification(event *models.NotifyEvent) (int, error) {
+	subscriptions, err := p.ListSubscriptions()
+	if err != nil {
+		return 0, err
+	}
+	var matched []*models.Subscription
+	for _, subscription := range subscriptions {
+		if subscription.Matches(event) {
+			matched = append(matched, subscription)
+		}
+	}
+	if len(matched) == 0 {
+		return 0, nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode event: %w", err)
+	}
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, subscription := range matched {
+		if err := insertDelivery(tx, subscription.ID, event.Type, payload); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit deliveries: %w", err)
+	}
+	return len(matched), nil
+}
+
+// EnqueueDelivery 只为指定的订阅登记一条投递记录（不论其是否启用、是否接收该事件），用于测试通知
+func (p *PostgresDB) EnqueueDelivery(subscriptionID int64, event *models.NotifyEvent) (int64, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode event: %w", err)
+	}
+	var id int64
+	err = p.db.QueryRow(`
+		INSERT INTO yaf_deliveries (subscription_id, event_type, payload, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, subscriptionID, event.Type, string(payload), models.DeliveryPending).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert delivery: %w", err)
+	}
+	return id, nil
+}
+
+// insertDelivery 在事务中登记一条投递记录
+func insertDelivery(tx *sql.Tx, subscriptionID int64, eventType string, payload []byte) error {
+	_, err := tx.Exec(`
+		INSERT INTO yaf_deliveries (subscription_id, event_type, payload, status)
+		VALUES ($1, $2, $3, $4)
+	`, subscriptionID, eventType, string(payload), models.DeliveryPending)
+	if err != nil {
+		return fmt.Errorf("failed to insert delivery: %w", err)
+	}
+	return nil
+}
+
+// scanDelivery 扫描一行投递记录
+func scanDelivery(row interface{ Scan(...interface{}) error }) (*models.Delivery, error) {
+	delivery := &models.Delivery{}
+	var deliveredAt sql.NullTime
+	err := row.Scan(
+		&delivery.ID, &delivery.SubscriptionID, &delivery.SubscriptionName, &delivery.Channel, &delivery.EventType,
+		&delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.LastError, &delivery.NextAttemptAt,
+		&delivery.CreatedAt, &deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return delivery, nil
+}
+
+// queryDeliveries 查询投递记录，WITH 与 WHERE 子句由调用方拼接
+func (p *PostgresDB) queryDeliveries(with, where string, args ...interface{}) ([]*models.Delivery, error) {
+	rows, err := p.db.Query(with+`
+		SELECT `+deliveryColumns+`
+		FROM yaf_deliveries d JOIN yaf_subscriptions s ON s.id = d.subscription_id
+	`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*models.Delivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// ListDeliveries 按 ID 倒序列出投递记录
+func (p *PostgresDB) ListDeliveries(filter models.DeliveryFilter) ([]*models.Delivery, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	return p.queryDeliveries("", `
+		WHERE ($1 = 0 OR d.subscription_id = $1) AND ($2 = '' OR d.status = $2)
+		ORDER BY d.id DESC
+		LIMIT $3
+	`, filter.SubscriptionID, filter.Status, limit)
+}
+
+// ClaimDueDeliveries 领取到达重试时间、等待投递的记录，并将其下次尝试时间推迟 lease，
+// 多个后端实例同时运行时同一条记录在租期内只会被一个实例投递
+func (p *PostgresDB) ClaimDueDeliveries(limit int, lease time.Duration) ([]*models.Delivery, error) {
+	return p.queryDeliveries(`
+		WITH claimed AS (
+			UPDATE yaf_deliveries SET next_attempt_at = NOW() + $3 * INTERVAL '1 second'
+			WHERE id IN (
+				SELECT id FROM yaf_deliveries
+				WHERE status = $1 AND next_attempt_at <= NOW()
+				ORDER BY id
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id
+		)`, `
+		WHERE d.id IN (SELECT id FROM claimed)
+		ORDER BY d.id
+	`, models.DeliveryPending, limit, lease.Seconds())
+}
+
+// MarkDeliveryDelivered 标记投递成功
+func (p *PostgresDB) MarkDeliveryDelivered(id int64) error {
+	_, err := p.db.Exec(`
+		UPDATE yaf_deliveries SET status = $2, attempts = attempts + 1, last_error = '', delivered_at = NOW()
+		WHERE id = $1
+	`, id, models.DeliveryDelivered)
+	if err != nil {
+		return fmt.Errorf("failed to mark delivery delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkDeliveryFailed 记录投递失败的原因；giveUp 时标记为失败不再重试，否则在 nextAttempt 重试
+func (p *PostgresDB) MarkDeliveryFailed(id int64, reason string, nextAttempt time.Time, giveUp bool) error {
+	status := models.DeliveryPending
+	if giveUp {
+		status = models.DeliveryFailed
+	}
+	_, err := p.db.Exec(`
+		UPDATE yaf_deliveries SET status = $2, attempts = attempts + 1, last_error = $3, next_attempt_at = $4
+		WHERE id = $1
+	`, id, status, reason, nextAttempt)
+	if err != nil {
+		return fmt.Errorf("failed to mark delivery failed: %w", err)
+	}
+	return nil
+}
+
+// RetryDelivery 将投递记录重新置为等待投递并清零尝试次数，不存在时返回 ErrDeliveryNotFound
+func (p *PostgresDB) RetryDelivery(id int64) error {
+	result, err := p.db.Exec(`
+		UPDATE yaf_deliveries SET status = $2, attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+		WHERE id = $1
+	`, id, models.DeliveryPending)
+	if err != nil {
+		return fmt.Errorf("failed to retry delivery: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDeliveryNotFound
+	}
+	return nil
+}
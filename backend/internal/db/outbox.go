@@ -0,0 +1,143 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/yf-web/backend/internal/models"
+)
+
+const outboxColumns = `
+	o.id, o.config_id, c.scope, COALESCE(c.cluster_name, ''), COALESCE(c.node_id, ''), c.version,
+	c.config_json, c.deleted, o.status, o.attempts, COALESCE(o.last_error, ''), o.next_attempt_at, o.created_at, o.synced_at`
+
+// insertOutbox 在保存配置的事务中登记待发布的版本
+func insertOutbox(tx *sql.Tx, record *models.ConfigRecord) error {
+	_, err := tx.Exec(`
+		INSERT INTO yaf_outbox (config_id, scope, cluster_name, node_id, version, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`, record.ID, record.Scope, record.ClusterName, record.NodeID, record.Version, models.SyncPending, record.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert outbox entry: %w", err)
+	}
+	return nil
+}
+
+// GetDueOutbox 获取到达重试时间、尚未写入 ZooKeeper 的条目，按保存顺序返回
+func (p *PostgresDB) GetDueOutbox(limit int) ([]*models.OutboxEntry, error) {
+	return p.queryOutbox(`
+		WHERE o.status <> $1 AND o.next_attempt_at <= NOW()
+		ORDER BY o.id
+		LIMIT $2
+	`, models.SyncSynced, limit)
+}
+
+// GetPendingOutbox 获取某一级配置尚未写入 ZooKeeper 的全部条目（不考虑重试时间）
+func (p *PostgresDB) GetPendingOutbox(scope models.ConfigScope, clusterName, nodeID string) ([]*models.OutboxEntry, error) {
+	return p.queryOutbox(`
+		WHERE o.status <> $1 AND o.scope = $2 AND o.cluster_name = $3 AND o.node_id = $4
+		ORDER BY o.id
+	`, models.SyncSynced, scope, clusterName, nodeID)
+}
+
+// LatestConfigVersion 获取某一级配置的最新版本号（包括删除标记），尚无任何版本时返回 0
+func (p *PostgresDB) LatestConfigVersion(scope models.ConfigScope, clusterName, nodeID string) (int, error) {
+	var version int
+	err := p.db.QueryRow(`
+		SELECT COALESCE(MAX(version), 0) FROM yaf_config
+		WHERE scope = $1 AND COALESCE(cluster_name, '') = $2 AND COALESCE(node_id, '') = $3
+	`, scope, clusterName, nodeID).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest config version: %w", err)
+	}
+	return version, nil
+}
+
+// queryOutbox 查询发布条目及其对应的配置内容
+func (p *PostgresDB) queryOutbox(where string, args ...interface{}) ([]*models.OutboxEntry, error) {
+	rows, err := p.db.Query(`
+		SELECT `+outboxColumns+`
+		FROM yaf_outbox o JOIN yaf_config c ON c.id = o.config_id
+	`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.OutboxEntry{}
+	for rows.Next() {
+		entry := &models.OutboxEntry{}
+		var syncedAt sql.NullTime
+		if err := rows.Scan(
+			&entry.ID, &entry.ConfigID, &entry.Scope, &entry.ClusterName, &entry.NodeID, &entry.Version,
+			&entry.ConfigJSON, &entry.Deleted, &entry.Status, &entry.Attempts, &entry.LastError, &entry.NextAttemptAt,
+			&entry.CreatedAt, &syncedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+		if syncedAt.Valid {
+			entry.SyncedAt = &syncedAt.Time
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// MarkOutboxSynced 标记条目已写入 ZooKeeper
+func (p *PostgresDB) MarkOutboxSynced(id int64) error {
+	_, err := p.db.Exec(`
+		UPDATE yaf_outbox SET status = $1, attempts = attempts + 1, last_error = NULL, synced_at = NOW()
+		WHERE id = $2
+	`, models.SyncSynced, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox entry synced: %w", err)
+	}
+	return nil
+}
+
+// MarkOutboxFailed 记录写入失败的原因与下次重试时间
+func (p *PostgresDB) MarkOutboxFailed(id int64, reason string, nextAttempt time.Time) error {
+	_, err := p.db.Exec(`
+		UPDATE yaf_outbox SET status = $1, attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE id = $4
+	`, models.SyncFailed, reason, nextAttempt, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox entry failed: %w", err)
+	}
+	return nil
+}
+
+// GetSyncState 获取某一级配置最新版本的同步状态，没有发布记录时返回 nil
+func (p *PostgresDB) GetSyncState(scope models.ConfigScope, clusterName, nodeID string) (*models.SyncState, error) {
+	state := &models.SyncState{}
+	var lastError string
+	var nextAttempt time.Time
+	var syncedAt sql.NullTime
+	err := p.db.QueryRow(`
+		SELECT status, version, attempts, COALESCE(last_error, ''), next_attempt_at, synced_at
+		FROM yaf_outbox
+		WHERE scope = $1 AND cluster_name = $2 AND node_id = $3
+		ORDER BY id DESC
+		LIMIT 1
+	`, scope, clusterName, nodeID).Scan(
+		&state.Status, &state.Version, &state.Attempts, &lastError, &nextAttempt, &syncedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync state: %w", err)
+	}
+	switch state.Status {
+	case models.SyncFailed:
+		state.Error = lastError
+		state.NextRetry = &nextAttempt
+	case models.SyncSynced:
+		if syncedAt.Valid {
+			state.SyncedAt = &syncedAt.Time
+		}
+	}
+	return state, nil
+}
@@ -2,18 +2,22 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/yf-web/backend/internal/models"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // PostgresDB PostgreSQL 数据库封装
 type PostgresDB struct {
-	db     *sql.DB
-	logger *zap.Logger
+	db        *sql.DB
+	logger    *zap.Logger
+	listeners []ChangeListener
 }
 
 // Config 数据库配置
@@ -78,6 +82,285 @@ func (p *PostgresDB) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_yaf_config_node ON yaf_config(node_id);
 	CREATE INDEX IF NOT EXISTS idx_yaf_config_created_at ON yaf_config(created_at);
 
+	-- 配置格式：1 为旧格式完整配置（零值表示未设置），2 为覆盖配置（JSON Merge Patch 语义）
+	ALTER TABLE yaf_config ADD COLUMN IF NOT EXISTS format SMALLINT NOT NULL DEFAULT 1;
+
+	-- 删除标记（tombstone）：删除时写入一个空配置的新版本，历史得以保留
+	ALTER TABLE yaf_config ADD COLUMN IF NOT EXISTS deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+	-- 已归档的集群：不再出现在集群列表中，配置与历史保留
+	CREATE TABLE IF NOT EXISTS yaf_cluster_archive (
+		cluster_name VARCHAR(128) PRIMARY KEY,
+		archived_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		archived_by VARCHAR(128) NOT NULL
+	);
+
+	-- 集群清单：可以先登记再配置，保存配置时自动登记
+	CREATE TABLE IF NOT EXISTS yaf_clusters (
+		name VARCHAR(128) PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		owner VARCHAR(128) NOT NULL DEFAULT '',
+		labels JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_by VARCHAR(128) NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_by VARCHAR(128) NOT NULL
+	);
+
+	-- 节点清单
+	CREATE TABLE IF NOT EXISTS yaf_nodes (
+		cluster_name VARCHAR(128) NOT NULL REFERENCES yaf_clusters(name) ON DELETE CASCADE,
+		node_id VARCHAR(128) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		owner VARCHAR(128) NOT NULL DEFAULT '',
+		labels JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_by VARCHAR(128) NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_by VARCHAR(128) NOT NULL,
+		PRIMARY KEY (cluster_name, node_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_yaf_nodes_labels ON yaf_nodes USING GIN (labels);
+	-- 节点标签写入 ZooKeeper 的进度：labels_version 随标签修改递增，publisher 写入后更新 labels_published
+	ALTER TABLE yaf_nodes ADD COLUMN IF NOT EXISTS labels_version INT NOT NULL DEFAULT 1;
+	ALTER TABLE yaf_nodes ADD COLUMN IF NOT EXISTS labels_published INT NOT NULL DEFAULT 0;
+
+	-- config-agent 在线状态：由后端根据 ZooKeeper 中的在线临时节点维护，离线后保留记录
+	CREATE TABLE IF NOT EXISTS yaf_agents (
+		cluster_name VARCHAR(128) NOT NULL,
+		node_id VARCHAR(128) NOT NULL,
+		hostname VARCHAR(255) NOT NULL DEFAULT '',
+		agent_version VARCHAR(64) NOT NULL DEFAULT '',
+		interface VARCHAR(64) NOT NULL DEFAULT '',
+		started_at TIMESTAMP NOT NULL,
+		online BOOLEAN NOT NULL DEFAULT FALSE,
+		first_seen TIMESTAMP NOT NULL DEFAULT NOW(),
+		last_seen TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (cluster_name, node_id)
+	);
+	-- Agent 上报的最近一次配置应用结果，applied_at 为空表示尚未上报
+	ALTER TABLE yaf_agents ADD COLUMN IF NOT EXISTS applied_global INT NOT NULL DEFAULT 0;
+	ALTER TABLE yaf_agents ADD COLUMN IF NOT EXISTS applied_cluster INT NOT NULL DEFAULT 0;
+	ALTER TABLE yaf_agents ADD COLUMN IF NOT EXISTS applied_node INT NOT NULL DEFAULT 0;
+	ALTER TABLE yaf_agents ADD COLUMN IF NOT EXISTS config_hash VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE yaf_agents ADD COLUMN IF NOT EXISTS apply_status VARCHAR(16) NOT NULL DEFAULT '';
+	ALTER TABLE yaf_agents ADD COLUMN IF NOT EXISTS apply_error TEXT NOT NULL DEFAULT '';
+	ALTER TABLE yaf_agents ADD COLUMN IF NOT EXISTS applied_at TIMESTAMP;
+	ALTER TABLE yaf_agents ADD COLUMN IF NOT EXISTS applied_profiles JSONB NOT NULL DEFAULT '[]';
+
+	-- 配置档：可挂载到多个集群或节点的部分配置，有独立的版本历史
+	CREATE TABLE IF NOT EXISTS yaf_profiles (
+		name VARCHAR(128) PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_by VARCHAR(128) NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_by VARCHAR(128) NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS yaf_profile_versions (
+		name VARCHAR(128) NOT NULL REFERENCES yaf_profiles(name) ON DELETE CASCADE,
+		version INT NOT NULL,
+		config_json TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_by VARCHAR(128) NOT NULL,
+		PRIMARY KEY (name, version)
+	);
+
+	-- 集群（node_id 为空）与节点挂载的配置档，按 position 顺序合并
+	CREATE TABLE IF NOT EXISTS yaf_profile_attachments (
+		cluster_name VARCHAR(128) NOT NULL,
+		node_id VARCHAR(128) NOT NULL DEFAULT '',
+		profile_name VARCHAR(128) NOT NULL REFERENCES yaf_profiles(name),
+		position INT NOT NULL,
+		PRIMARY KEY (cluster_name, node_id, profile_name)
+	);
+	CREATE INDEX IF NOT EXISTS idx_yaf_profile_attachments_profile ON yaf_profile_attachments(profile_name);
+
+	-- 合并后的配置档层及其发布状态（只保留最新内容），由 publisher 写入 ZooKeeper
+	CREATE TABLE IF NOT EXISTS yaf_profile_layers (
+		cluster_name VARCHAR(128) NOT NULL,
+		node_id VARCHAR(128) NOT NULL DEFAULT '',
+		version INT NOT NULL,
+		config_json TEXT NOT NULL,
+		profiles JSONB NOT NULL DEFAULT '[]',
+		status VARCHAR(16) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		synced_at TIMESTAMP,
+		PRIMARY KEY (cluster_name, node_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_yaf_profile_layers_due ON yaf_profile_layers(next_attempt_at) WHERE status <> 'synced';
+
+	-- 标签选择层：匹配标签的节点在集群配置之后、节点配置之前按优先级合并。
+	-- 删除时只做标记（版本号继续递增），由 publisher 删除 ZooKeeper 中的节点
+	CREATE TABLE IF NOT EXISTS yaf_selectors (
+		name VARCHAR(128) PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		cluster_name VARCHAR(128) NOT NULL DEFAULT '',
+		labels JSONB NOT NULL DEFAULT '{}',
+		priority INT NOT NULL DEFAULT 0,
+		version INT NOT NULL,
+		config_json TEXT NOT NULL,
+		deleted BOOLEAN NOT NULL DEFAULT FALSE,
+		status VARCHAR(16) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		synced_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_by VARCHAR(128) NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_by VARCHAR(128) NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_yaf_selectors_due ON yaf_selectors(next_attempt_at) WHERE status <> 'synced';
+
+	-- 灰度发布：新版本先对灰度节点生效，其余节点在 ZooKeeper 中固定在基准版本
+	CREATE TABLE IF NOT EXISTS yaf_canaries (
+		id BIGSERIAL PRIMARY KEY,
+		scope VARCHAR(16) NOT NULL,
+		cluster_name VARCHAR(128) NOT NULL DEFAULT '',
+		version INT NOT NULL,
+		base_version INT NOT NULL,
+		rollback_version INT NOT NULL DEFAULT 0,
+		selector JSONB NOT NULL DEFAULT '{}',
+		auto_promote BOOLEAN NOT NULL DEFAULT FALSE,
+		timeout_seconds INT NOT NULL,
+		state VARCHAR(16) NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_by VARCHAR(128) NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_by VARCHAR(128) NOT NULL,
+		deadline TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_yaf_canaries_state ON yaf_canaries(state);
+	ALTER TABLE yaf_canaries ADD COLUMN IF NOT EXISTS draft_id BIGINT NOT NULL DEFAULT 0;
+
+	CREATE TABLE IF NOT EXISTS yaf_canary_nodes (
+		canary_id BIGINT NOT NULL REFERENCES yaf_canaries(id) ON DELETE CASCADE,
+		cluster_name VARCHAR(128) NOT NULL,
+		node_id VARCHAR(128) NOT NULL,
+		canary BOOLEAN NOT NULL,
+		PRIMARY KEY (canary_id, cluster_name, node_id)
+	);
+
+	-- 定时发布：到 publish_at 时由后台任务保存为新版本
+	CREATE TABLE IF NOT EXISTS yaf_schedules (
+		id BIGSERIAL PRIMARY KEY,
+		scope VARCHAR(16) NOT NULL,
+		cluster_name VARCHAR(128) NOT NULL DEFAULT '',
+		node_id VARCHAR(128) NOT NULL DEFAULT '',
+		config_json TEXT NOT NULL,
+		base_version INT NOT NULL,
+		publish_at TIMESTAMP NOT NULL,
+		state VARCHAR(16) NOT NULL,
+		version INT NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_by VARCHAR(128) NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_by VARCHAR(128) NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_yaf_schedules_due ON yaf_schedules(publish_at) WHERE state = 'scheduled';
+	CREATE INDEX IF NOT EXISTS idx_yaf_schedules_target ON yaf_schedules(scope, cluster_name, node_id);
+
+	-- 草稿与评审：审批通过后才保存为配置版本
+	CREATE TABLE IF NOT EXISTS yaf_drafts (
+		id BIGSERIAL PRIMARY KEY,
+		scope VARCHAR(16) NOT NULL,
+		cluster_name VARCHAR(128) NOT NULL DEFAULT '',
+		node_id VARCHAR(128) NOT NULL DEFAULT '',
+		title VARCHAR(256) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		config_json TEXT NOT NULL,
+		deleted BOOLEAN NOT NULL DEFAULT FALSE,
+		base_version INT NOT NULL,
+		state VARCHAR(16) NOT NULL,
+		required_approvals INT NOT NULL DEFAULT 0,
+		version INT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_by VARCHAR(128) NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_by VARCHAR(128) NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_yaf_drafts_state ON yaf_drafts(state);
+	CREATE INDEX IF NOT EXISTS idx_yaf_drafts_target ON yaf_drafts(scope, cluster_name, node_id);
+	-- 灰度草稿：审批通过后不直接发布，由 POST /api/v1/canaries 以灰度发布上线
+	ALTER TABLE yaf_drafts ADD COLUMN IF NOT EXISTS canary BOOLEAN NOT NULL DEFAULT FALSE;
+
+	CREATE TABLE IF NOT EXISTS yaf_draft_approvals (
+		draft_id BIGINT NOT NULL REFERENCES yaf_drafts(id) ON DELETE CASCADE,
+		approver VARCHAR(128) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (draft_id, approver)
+	);
+
+	CREATE TABLE IF NOT EXISTS yaf_draft_events (
+		id BIGSERIAL PRIMARY KEY,
+		draft_id BIGINT NOT NULL REFERENCES yaf_drafts(id) ON DELETE CASCADE,
+		author VARCHAR(128) NOT NULL,
+		action VARCHAR(16) NOT NULL,
+		body TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_yaf_draft_events_draft ON yaf_draft_events(draft_id);
+
+	-- 待发布到 ZooKeeper 的配置版本（与配置记录在同一事务中写入，由后台任务投递）
+	CREATE TABLE IF NOT EXISTS yaf_outbox (
+		id BIGSERIAL PRIMARY KEY,
+		config_id BIGINT NOT NULL REFERENCES yaf_config(id) ON DELETE CASCADE,
+		scope VARCHAR(16) NOT NULL,
+		cluster_name VARCHAR(128) NOT NULL DEFAULT '',
+		node_id VARCHAR(128) NOT NULL DEFAULT '',
+		version INT NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT,
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		synced_at TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_yaf_outbox_due ON yaf_outbox(next_attempt_at) WHERE status <> 'synced';
+	CREATE INDEX IF NOT EXISTS idx_yaf_outbox_target ON yaf_outbox(scope, cluster_name, node_id);
+
+	-- 通知订阅：按事件类型与配置范围过滤，通过 webhook 或邮件投递
+	CREATE TABLE IF NOT EXISTS yaf_subscriptions (
+		id BIGSERIAL PRIMARY KEY,
+		name VARCHAR(128) NOT NULL,
+		events JSONB NOT NULL DEFAULT '[]',
+		scope VARCHAR(16) NOT NULL DEFAULT '',
+		cluster_name VARCHAR(128) NOT NULL DEFAULT '',
+		channel VARCHAR(16) NOT NULL,
+		target TEXT NOT NULL,
+		secret TEXT NOT NULL DEFAULT '',
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_by VARCHAR(128) NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_by VARCHAR(128) NOT NULL
+	);
+
+	-- 通知投递记录：每个事件对每个匹配的订阅一条，由后台任务投递并按退避间隔重试
+	CREATE TABLE IF NOT EXISTS yaf_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		subscription_id BIGINT NOT NULL REFERENCES yaf_subscriptions(id) ON DELETE CASCADE,
+		event_type VARCHAR(32) NOT NULL,
+		payload TEXT NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		delivered_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_yaf_deliveries_due ON yaf_deliveries(next_attempt_at) WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS idx_yaf_deliveries_subscription ON yaf_deliveries(subscription_id);
+
 	-- 用户表
 	CREATE TABLE IF NOT EXISTS yaf_users (
 		id BIGSERIAL PRIMARY KEY,
@@ -86,6 +369,53 @@ func (p *PostgresDB) initSchema() error {
 		created_at TIMESTAMP NOT NULL DEFAULT NOW()
 	);
 
+	ALTER TABLE yaf_users ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'viewer';
+	ALTER TABLE yaf_users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE yaf_users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE yaf_users ADD COLUMN IF NOT EXISTS failed_attempts INT NOT NULL DEFAULT 0;
+	ALTER TABLE yaf_users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+	ALTER TABLE yaf_users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP;
+
+	-- 用户集群授权表
+	CREATE TABLE IF NOT EXISTS yaf_user_grants (
+		username VARCHAR(64) NOT NULL REFERENCES yaf_users(username) ON DELETE CASCADE,
+		cluster_name VARCHAR(128) NOT NULL,
+		PRIMARY KEY (username, cluster_name)
+	);
+
+	-- 登录会话表（只保存 token 的哈希）
+	CREATE TABLE IF NOT EXISTS yaf_sessions (
+		token_hash CHAR(64) PRIMARY KEY,
+		username VARCHAR(64) NOT NULL REFERENCES yaf_users(username) ON DELETE CASCADE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		expires_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_yaf_sessions_expires_at ON yaf_sessions(expires_at);
+
+	-- 审计日志表
+	CREATE TABLE IF NOT EXISTS yaf_audit (
+		id BIGSERIAL PRIMARY KEY,
+		actor VARCHAR(64) NOT NULL,
+		source_ip VARCHAR(64) NOT NULL,
+		method VARCHAR(8) NOT NULL,
+		endpoint VARCHAR(256) NOT NULL,
+		scope VARCHAR(16),
+		cluster_name VARCHAR(128),
+		node_id VARCHAR(128),
+		status INT NOT NULL,
+		outcome VARCHAR(16) NOT NULL,
+		message TEXT,
+		before_json JSONB,
+		after_json JSONB,
+		diff_json JSONB,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_yaf_audit_created_at ON yaf_audit(created_at);
+	CREATE INDEX IF NOT EXISTS idx_yaf_audit_actor ON yaf_audit(actor);
+	CREATE INDEX IF NOT EXISTS idx_yaf_audit_target ON yaf_audit(scope, cluster_name, node_id);
+
 	-- 系统设置表
 	CREATE TABLE IF NOT EXISTS yaf_settings (
 		key VARCHAR(64) PRIMARY KEY,
@@ -99,8 +429,69 @@ func (p *PostgresDB) initSchema() error {
 		return err
 	}
 
+	// 将旧格式配置转换为覆盖配置
+	if err := p.migrateLegacyConfigs(); err != nil {
+		return err
+	}
+
+	// 为已有配置的集群与节点补登清单
+	if err := p.registerInventory(); err != nil {
+		return err
+	}
+
 	// 初始化默认管理员账号
-	return p.initDefaultUser()
+	if err := p.initDefaultUser(); err != nil {
+		return err
+	}
+
+	// 将历史明文密码迁移为 bcrypt 哈希
+	if err := p.migratePlaintextPasswords(); err != nil {
+		return err
+	}
+
+	// 仍在使用默认密码的管理员必须在登录后修改密码
+	return p.flagDefaultAdminPassword()
+}
+
+// migrateLegacyConfigs 将旧格式的完整配置转换为等价的覆盖配置（去掉零值字段，布尔值只保留 true），
+// 合并结果与旧的合并逻辑保持一致
+func (p *PostgresDB) migrateLegacyConfigs() error {
+	rows, err := p.db.Query("SELECT id, config_json FROM yaf_config WHERE format < $1", models.OverlayFormat)
+	if err != nil {
+		return fmt.Errorf("failed to query legacy configs: %w", err)
+	}
+	legacy := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var configJSON string
+		if err := rows.Scan(&id, &configJSON); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan config: %w", err)
+		}
+		legacy[id] = configJSON
+	}
+	rows.Close()
+
+	for id, configJSON := range legacy {
+		var cfg models.YafConfig
+		if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+			return fmt.Errorf("failed to parse legacy config %d: %w", id, err)
+		}
+		overlay, err := json.Marshal(models.LegacyOverlay(&cfg))
+		if err != nil {
+			return err
+		}
+		if _, err := p.db.Exec(
+			"UPDATE yaf_config SET config_json = $1, format = $2 WHERE id = $3",
+			string(overlay), models.OverlayFormat, id,
+		); err != nil {
+			return fmt.Errorf("failed to migrate config %d: %w", id, err)
+		}
+	}
+	if len(legacy) > 0 {
+		p.logger.Info("migrated legacy configs to overlay format", zap.Int("count", len(legacy)))
+	}
+	return nil
 }
 
 // initDefaultUser 初始化默认用户
@@ -113,45 +504,141 @@ func (p *PostgresDB) initDefaultUser() error {
 
 	// 如果没有用户，创建默认管理员
 	if count == 0 {
+		hash, err := hashPassword("admin")
+		if err != nil {
+			return err
+		}
 		_, err = p.db.Exec(
-			"INSERT INTO yaf_users (username, password) VALUES ($1, $2)",
-			"admin", "admin",
+			"INSERT INTO yaf_users (username, password, role, must_change_password) VALUES ($1, $2, $3, TRUE)",
+			"admin", hash, models.RoleAdmin,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to create default user: %w", err)
 		}
 		p.logger.Info("created default admin user (admin/admin)")
 	}
+
+	// 升级前的库没有角色字段，保证至少有一个管理员
+	_, err = p.db.Exec(`
+		UPDATE yaf_users SET role = $1
+		WHERE username = 'admin' AND NOT EXISTS (SELECT 1 FROM yaf_users WHERE role = $1)
+	`, models.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("failed to ensure admin role: %w", err)
+	}
+	return nil
+}
+
+// migratePlaintextPasswords 将明文存储的密码替换为 bcrypt 哈希
+func (p *PostgresDB) migratePlaintextPasswords() error {
+	rows, err := p.db.Query("SELECT username, password FROM yaf_users")
+	if err != nil {
+		return fmt.Errorf("failed to query users: %w", err)
+	}
+	plaintext := make(map[string]string)
+	for rows.Next() {
+		var username, password string
+		if err := rows.Scan(&username, &password); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan user: %w", err)
+		}
+		if !isPasswordHash(password) {
+			plaintext[username] = password
+		}
+	}
+	rows.Close()
+
+	for username, password := range plaintext {
+		hash, err := hashPassword(password)
+		if err != nil {
+			return err
+		}
+		if _, err := p.db.Exec(
+			"UPDATE yaf_users SET password = $1 WHERE username = $2",
+			hash, username,
+		); err != nil {
+			return fmt.Errorf("failed to migrate password for %s: %w", username, err)
+		}
+		p.logger.Info("migrated plaintext password to bcrypt", zap.String("username", username))
+	}
+	return nil
+}
+
+// flagDefaultAdminPassword 为仍使用 admin/admin 的账号设置强制改密标记
+func (p *PostgresDB) flagDefaultAdminPassword() error {
+	var stored string
+	var mustChange bool
+	err := p.db.QueryRow(
+		"SELECT password, must_change_password FROM yaf_users WHERE username = 'admin'",
+	).Scan(&stored, &mustChange)
+	if err == sql.ErrNoRows || mustChange {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query admin user: %w", err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte("admin")) != nil {
+		return nil
+	}
+	if _, err := p.db.Exec("UPDATE yaf_users SET must_change_password = TRUE WHERE username = 'admin'"); err != nil {
+		return fmt.Errorf("failed to flag admin password: %w", err)
+	}
+	p.logger.Warn("admin user still uses the default password, it must be changed on next login")
 	return nil
 }
 
+// hashPassword 生成 bcrypt 密码哈希
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// isPasswordHash 判断存储值是否已经是 bcrypt 哈希
+func isPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
 // Close 关闭数据库连接
 func (p *PostgresDB) Close() error {
 	return p.db.Close()
 }
 
-// SaveConfig 保存配置（新版本）
-func (p *PostgresDB) SaveConfig(record *models.ConfigRecord) error {
-	// 获取最新版本号
-	var maxVersion int
-	err := p.db.QueryRow(`
-		SELECT COALESCE(MAX(version), 0) FROM yaf_config 
-		WHERE scope = $1 AND COALESCE(cluster_name, '') = $2 AND COALESCE(node_id, '') = $3
-	`, record.Scope, record.ClusterName, record.NodeID).Scan(&maxVersion)
-	if err != nil {
-		return fmt.Errorf("failed to get max version: %w", err)
-	}
+// AnyVersion 保存配置时不检查基准版本
+const AnyVersion = -1
 
-	record.Version = maxVersion + 1
-	record.CreatedAt = time.Now()
+// VersionConflictError 保存配置时基准版本已不是最新版本
+type VersionConflictError struct {
+	Expected int // 客户端期望的当前版本
+	Current  int // 实际的当前版本，0 表示尚无配置
+}
 
-	_, err = p.db.Exec(`
-		INSERT INTO yaf_config (scope, cluster_name, node_id, version, config_json, created_at, created_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, record.Scope, record.ClusterName, record.NodeID, record.Version, record.ConfigJSON, record.CreatedAt, record.CreatedBy)
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("config version conflict: expected %d, current %d", e.Expected, e.Current)
+}
 
+// SaveConfig 保存配置（新版本）。baseVersion 为客户端修改时所基于的版本（0 表示尚无配置或已删除），
+// 与当前版本不一致时返回 *VersionConflictError；传 AnyVersion 则不检查。该级配置正在灰度发布时返回 ErrCanaryActive。
+// 版本号在事务内分配，同一配置的并发保存通过 advisory lock 串行化；
+// 同一事务中登记发布条目，由 publisher 负责写入 ZooKeeper
+func (p *PostgresDB) SaveConfig(record *models.ConfigRecord, baseVersion int) error {
+	tx, err := p.beginChanges()
 	if err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertVersion(tx, record, baseVersion); err != nil {
+		return err
+	}
+	if err := checkNoCanary(tx.Tx, record); err != nil {
+		return err
+	}
+	if err := p.commit(tx); err != nil {
+		return fmt.Errorf("failed to commit config: %w", err)
 	}
 
 	p.logger.Info("config saved to database",
@@ -159,25 +646,158 @@ func (p *PostgresDB) SaveConfig(record *models.ConfigRecord) error {
 		zap.String("cluster", record.ClusterName),
 		zap.String("node", record.NodeID),
 		zap.Int("version", record.Version),
+		zap.Bool("deleted", record.Deleted),
+	)
+	return nil
+}
+
+// DeleteConfig 删除节点配置：写入删除标记版本，ZooKeeper 中的节点随后由 publisher 删除，
+// Agent 因此回退到集群配置。baseVersion 的含义同 SaveConfig
+func (p *PostgresDB) DeleteConfig(record *models.ConfigRecord, baseVersion int) error {
+	record.ConfigJSON = "{}"
+	record.Deleted = true
+	return p.SaveConfig(record, baseVersion)
+}
+
+// DeleteClusterConfig 删除集群配置：为集群下仍有配置的节点与集群本身写入删除标记版本（同一事务），
+// 集群在 ZooKeeper 中的整个子树随后由 publisher 删除，集群与节点的清单记录保留。
+// baseVersion 针对集群配置，含义同 SaveConfig。返回集群的删除标记记录
+func (p *PostgresDB) DeleteClusterConfig(clusterName, createdBy string, baseVersion int) (*models.ConfigRecord, error) {
+	nodes, err := p.configuredNodes(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := p.beginChanges()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tombstone := &models.ConfigRecord{
+		Scope:       models.ScopeCluster,
+		ClusterName: clusterName,
+		ConfigJSON:  "{}",
+		CreatedBy:   createdBy,
+		Deleted:     true,
+	}
+	if err := insertClusterTombstones(tx, tombstone, nodes, baseVersion); err != nil {
+		return nil, err
+	}
+
+	if err := p.commit(tx); err != nil {
+		return nil, fmt.Errorf("failed to commit cluster deletion: %w", err)
+	}
+
+	p.logger.Info("cluster deleted",
+		zap.String("cluster", clusterName),
+		zap.Int("nodes", len(nodes)),
+		zap.Int("version", tombstone.Version),
 	)
+	return tombstone, nil
+}
+
+// insertClusterTombstones 在事务中为集群下仍有配置的节点（nodes）与集群本身写入删除标记版本，
+// tombstone 为集群的删除标记记录，baseVersion 针对集群配置
+func insertClusterTombstones(tx *changeTx, tombstone *models.ConfigRecord, nodes []string, baseVersion int) error {
+	for _, node := range nodes {
+		nodeTombstone := &models.ConfigRecord{
+			Scope:       models.ScopeNode,
+			ClusterName: tombstone.ClusterName,
+			NodeID:      node,
+			ConfigJSON:  "{}",
+			CreatedBy:   tombstone.CreatedBy,
+			Deleted:     true,
+		}
+		if err := insertVersion(tx, nodeTombstone, AnyVersion); err != nil {
+			return err
+		}
+	}
+	if err := insertVersion(tx, tombstone, baseVersion); err != nil {
+		return err
+	}
+	return checkNoCanary(tx.Tx, tombstone)
+}
+
+// insertVersion 在事务中为 record 分配版本号并写入，同时登记发布条目与配置变更
+func insertVersion(tx *changeTx, record *models.ConfigRecord, baseVersion int) error {
+	lockKey := fmt.Sprintf("yaf_config/%s/%s/%s", record.Scope, record.ClusterName, record.NodeID)
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", lockKey); err != nil {
+		return fmt.Errorf("failed to lock config: %w", err)
+	}
+
+	// 获取最新版本号；最新版本为删除标记时，当前版本视为 0（尚无配置）
+	var maxVersion int
+	var deleted bool
+	var previous string
+	err := tx.QueryRow(`
+		SELECT version, deleted, config_json FROM yaf_config 
+		WHERE scope = $1 AND COALESCE(cluster_name, '') = $2 AND COALESCE(node_id, '') = $3
+		ORDER BY version DESC
+		LIMIT 1
+	`, record.Scope, record.ClusterName, record.NodeID).Scan(&maxVersion, &deleted, &previous)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get max version: %w", err)
+	}
+	current := maxVersion
+	if deleted {
+		current = 0
+		previous = ""
+	}
+	if baseVersion != AnyVersion && baseVersion != current {
+		return &VersionConflictError{Expected: baseVersion, Current: current}
+	}
+
+	record.Version = maxVersion + 1
+	record.CreatedAt = time.Now()
+
+	err = tx.QueryRow(`
+		INSERT INTO yaf_config (scope, cluster_name, node_id, version, config_json, created_at, created_by, format, deleted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, record.Scope, record.ClusterName, record.NodeID, record.Version, record.ConfigJSON, record.CreatedAt, record.CreatedBy,
+		models.OverlayFormat, record.Deleted).Scan(&record.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	change := &models.ConfigChange{
+		Scope:       record.Scope,
+		ClusterName: record.ClusterName,
+		NodeID:      record.NodeID,
+		Version:     record.Version,
+		RollbackTo:  record.RollbackTo,
+		Deleted:     record.Deleted,
+		Author:      record.CreatedBy,
+		Before:      previous,
+	}
+	if !record.Deleted {
+		change.After = record.ConfigJSON
+		if err := ensureInventory(tx.Tx, record); err != nil {
+			return err
+		}
+	}
+	if err := insertOutbox(tx.Tx, record); err != nil {
+		return err
+	}
+	tx.record(change)
 	return nil
 }
 
-// GetLatestConfig 获取最新配置
+// GetLatestConfig 获取最新配置，不存在或最新版本为删除标记时返回 nil
 func (p *PostgresDB) GetLatestConfig(scope models.ConfigScope, clusterName, nodeID string) (*models.ConfigRecord, error) {
 	record := &models.ConfigRecord{}
 	err := p.db.QueryRow(`
-		SELECT id, scope, cluster_name, node_id, version, config_json, created_at, created_by
+		SELECT id, scope, cluster_name, node_id, version, config_json, created_at, created_by, deleted
 		FROM yaf_config
 		WHERE scope = $1 AND COALESCE(cluster_name, '') = $2 AND COALESCE(node_id, '') = $3
 		ORDER BY version DESC
 		LIMIT 1
 	`, scope, clusterName, nodeID).Scan(
 		&record.ID, &record.Scope, &record.ClusterName, &record.NodeID,
-		&record.Version, &record.ConfigJSON, &record.CreatedAt, &record.CreatedBy,
+		&record.Version, &record.ConfigJSON, &record.CreatedAt, &record.CreatedBy, &record.Deleted,
 	)
 
-	if err == sql.ErrNoRows {
+	if err == sql.ErrNoRows || record.Deleted {
 		return nil, nil
 	}
 	if err != nil {
@@ -186,10 +806,46 @@ func (p *PostgresDB) GetLatestConfig(scope models.ConfigScope, clusterName, node
 	return record, nil
 }
 
-// GetConfigHistory 获取配置历史
+// ListLatestConfigs 获取每一级（全局、各集群、各节点）配置的最新版本，已删除的不返回
+func (p *PostgresDB) ListLatestConfigs() ([]*models.ConfigRecord, error) {
+	rows, err := p.db.Query(`
+		SELECT id, scope, cluster_name, node_id, version, config_json, created_at, created_by FROM (
+			SELECT DISTINCT ON (scope, COALESCE(cluster_name, ''), COALESCE(node_id, ''))
+				id, scope, COALESCE(cluster_name, '') AS cluster_name, COALESCE(node_id, '') AS node_id,
+				version, config_json, created_at, created_by, deleted
+			FROM yaf_config
+			ORDER BY scope, COALESCE(cluster_name, ''), COALESCE(node_id, ''), version DESC
+		) latest
+		WHERE NOT deleted
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list latest configs: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*models.ConfigRecord
+	for rows.Next() {
+		record := &models.ConfigRecord{}
+		if err := rows.Scan(
+			&record.ID, &record.Scope, &record.ClusterName, &record.NodeID,
+			&record.Version, &record.ConfigJSON, &record.CreatedAt, &record.CreatedBy,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan config: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// GetConfigHistory 获取配置历史，尚未发布的定时变更（Scheduled 为 true）按发布时间排在最前
 func (p *PostgresDB) GetConfigHistory(scope models.ConfigScope, clusterName, nodeID string, limit int) ([]*models.ConfigRecord, error) {
+	records, err := p.scheduledHistory(scope, clusterName, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
 	rows, err := p.db.Query(`
-		SELECT id, scope, cluster_name, node_id, version, config_json, created_at, created_by
+		SELECT id, scope, cluster_name, node_id, version, config_json, created_at, created_by, deleted
 		FROM yaf_config
 		WHERE scope = $1 AND COALESCE(cluster_name, '') = $2 AND COALESCE(node_id, '') = $3
 		ORDER BY version DESC
@@ -200,12 +856,11 @@ func (p *PostgresDB) GetConfigHistory(scope models.ConfigScope, clusterName, nod
 	}
 	defer rows.Close()
 
-	var records []*models.ConfigRecord
 	for rows.Next() {
 		record := &models.ConfigRecord{}
 		if err := rows.Scan(
 			&record.ID, &record.Scope, &record.ClusterName, &record.NodeID,
-			&record.Version, &record.ConfigJSON, &record.CreatedAt, &record.CreatedBy,
+			&record.Version, &record.ConfigJSON, &record.CreatedAt, &record.CreatedBy, &record.Deleted,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
@@ -218,12 +873,12 @@ func (p *PostgresDB) GetConfigHistory(scope models.ConfigScope, clusterName, nod
 func (p *PostgresDB) GetConfigByVersion(scope models.ConfigScope, clusterName, nodeID string, version int) (*models.ConfigRecord, error) {
 	record := &models.ConfigRecord{}
 	err := p.db.QueryRow(`
-		SELECT id, scope, cluster_name, node_id, version, config_json, created_at, created_by
+		SELECT id, scope, cluster_name, node_id, version, config_json, created_at, created_by, deleted
 		FROM yaf_config
 		WHERE scope = $1 AND COALESCE(cluster_name, '') = $2 AND COALESCE(node_id, '') = $3 AND version = $4
 	`, scope, clusterName, nodeID, version).Scan(
 		&record.ID, &record.Scope, &record.ClusterName, &record.NodeID,
-		&record.Version, &record.ConfigJSON, &record.CreatedAt, &record.CreatedBy,
+		&record.Version, &record.ConfigJSON, &record.CreatedAt, &record.CreatedBy, &record.Deleted,
 	)
 
 	if err == sql.ErrNoRows {
@@ -235,34 +890,22 @@ func (p *PostgresDB) GetConfigByVersion(scope models.ConfigScope, clusterName, n
 	return record, nil
 }
 
-// ListClusters 列出数据库中的所有集群
-func (p *PostgresDB) ListClusters() ([]string, error) {
-	rows, err := p.db.Query(`
-		SELECT DISTINCT cluster_name FROM yaf_config 
-		WHERE cluster_name IS NOT NULL AND cluster_name != ''
-		ORDER BY cluster_name
-	`)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list clusters: %w", err)
-	}
-	defer rows.Close()
-
-	var clusters []string
-	for rows.Next() {
-		var cluster string
-		if err := rows.Scan(&cluster); err != nil {
-			return nil, fmt.Errorf("failed to scan cluster: %w", err)
-		}
-		clusters = append(clusters, cluster)
-	}
-	return clusters, nil
-}
+// liveConfigs 每一级配置中最新版本不是删除标记的记录（作为子查询使用）
+const liveConfigs = `
+	SELECT scope, cluster_name, node_id, version, created_at, created_by FROM (
+		SELECT DISTINCT ON (scope, COALESCE(cluster_name, ''), COALESCE(node_id, ''))
+			scope, COALESCE(cluster_name, '') AS cluster_name, COALESCE(node_id, '') AS node_id,
+			version, created_at, created_by, deleted
+		FROM yaf_config
+		ORDER BY scope, COALESCE(cluster_name, ''), COALESCE(node_id, ''), version DESC
+	) latest
+	WHERE NOT deleted`
 
-// ListNodes 列出集群下的所有节点
-func (p *PostgresDB) ListNodes(clusterName string) ([]string, error) {
+// configuredNodes 列出集群下仍有配置的节点
+func (p *PostgresDB) configuredNodes(clusterName string) ([]string, error) {
 	rows, err := p.db.Query(`
-		SELECT DISTINCT node_id FROM yaf_config 
-		WHERE cluster_name = $1 AND node_id IS NOT NULL AND node_id != ''
+		SELECT node_id FROM (`+liveConfigs+`) live
+		WHERE cluster_name = $1 AND node_id != ''
 		ORDER BY node_id
 	`, clusterName)
 	if err != nil {
@@ -281,24 +924,6 @@ func (p *PostgresDB) ListNodes(clusterName string) ([]string, error) {
 	return nodes, nil
 }
 
-// ValidateUser 验证用户登录
-func (p *PostgresDB) ValidateUser(username, password string) (bool, error) {
-	var storedPassword string
-	err := p.db.QueryRow(
-		"SELECT password FROM yaf_users WHERE username = $1",
-		username,
-	).Scan(&storedPassword)
-
-	if err == sql.ErrNoRows {
-		return false, nil
-	}
-	if err != nil {
-		return false, fmt.Errorf("failed to query user: %w", err)
-	}
-
-	return storedPassword == password, nil
-}
-
 // GetSetting 获取系统设置
 func (p *PostgresDB) GetSetting(key string) (string, error) {
 	var value string
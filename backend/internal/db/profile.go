@@ -0,0 +1,586 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrProfileExists 配置档已存在
+	ErrProfileExists = errors.New("profile already exists")
+	// ErrProfileNotFound 配置档不存在
+	ErrProfileNotFound = errors.New("profile not found")
+	// ErrProfileInUse 配置档仍挂载在集群或节点上，不能删除
+	ErrProfileInUse = errors.New("profile is still attached")
+	// ErrProfilesAttached 集群或节点仍挂载配置档，需先卸载才能删除清单记录
+	ErrProfilesAttached = errors.New("profiles still attached, detach them first")
+)
+
+// profileQuery 查询配置档及其最新版本，WHERE 子句由调用方拼接
+const profileQuery = `
+	SELECT p.name, p.description, v.version, v.config_json,
+		(SELECT COUNT(*) FROM yaf_profile_attachments a WHERE a.profile_name = p.name),
+		p.created_at, p.created_by, p.updated_at, p.updated_by
+	FROM yaf_profiles p
+	JOIN LATERAL (
+		SELECT version, config_json FROM yaf_profile_versions WHERE name = p.name ORDER BY version DESC LIMIT 1
+	) v ON TRUE
+`
+
+const layerColumns = `cluster_name, node_id, version, config_json, profiles, status, attempts, last_error,
+	next_attempt_at, updated_at, synced_at`
+
+// scanProfile 扫描一行配置档
+func scanProfile(row interface{ Scan(...interface{}) error }) (*models.Profile, error) {
+	profile := &models.Profile{}
+	err := row.Scan(
+		&profile.Name, &profile.Description, &profile.Version, &profile.ConfigJSON, &profile.Attachments,
+		&profile.CreatedAt, &profile.CreatedBy, &profile.UpdatedAt, &profile.UpdatedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// scanLayer 扫描一行配置档层
+func scanLayer(row interface{ Scan(...interface{}) error }) (*models.ProfileLayer, error) {
+	layer := &models.ProfileLayer{}
+	var profiles []byte
+	var syncedAt sql.NullTime
+	err := row.Scan(
+		&layer.ClusterName, &layer.NodeID, &layer.Version, &layer.ConfigJSON, &profiles, &layer.Status,
+		&layer.Attempts, &layer.LastError, &layer.NextAttemptAt, &layer.UpdatedAt, &syncedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	layer.Profiles = decodeProfileRefs(profiles)
+	if syncedAt.Valid {
+		layer.SyncedAt = &syncedAt.Time
+	}
+	return layer, nil
+}
+
+// CreateProfile 创建配置档及其第一个版本，同名配置档已存在时返回 ErrProfileExists
+func (p *PostgresDB) CreateProfile(profile *models.Profile) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO yaf_profiles (name, description, created_at, created_by, updated_at, updated_by)
+		VALUES ($1, $2, $3, $4, $3, $4)
+	`, profile.Name, profile.Description, now, profile.CreatedBy)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrProfileExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create profile: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO yaf_profile_versions (name, version, config_json, created_at, created_by)
+		VALUES ($1, 1, $2, $3, $4)
+	`, profile.Name, profile.ConfigJSON, now, profile.CreatedBy); err != nil {
+		return fmt.Errorf("failed to save profile version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit profile: %w", err)
+	}
+
+	profile.Version = 1
+	profile.CreatedAt = now
+	profile.UpdatedAt = now
+	profile.UpdatedBy = profile.CreatedBy
+	p.logger.Info("profile created", zap.String("profile", profile.Name))
+	return nil
+}
+
+// ListProfiles 列出配置档及其最新版本，按名称排序
+func (p *PostgresDB) ListProfiles() ([]*models.Profile, error) {
+	rows, err := p.db.Query(profileQuery + ` ORDER BY p.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+	defer rows.Close()
+
+	profiles := []*models.Profile{}
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan profile: %w", err)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// GetProfile 获取配置档及其最新版本，不存在时返回 nil
+func (p *PostgresDB) GetProfile(name string) (*models.Profile, error) {
+	profile, err := scanProfile(p.db.QueryRow(profileQuery+` WHERE p.name = $1`, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+	return profile, nil
+}
+
+// GetProfileHistory 获取配置档的版本历史，按版本倒序
+func (p *PostgresDB) GetProfileHistory(name string, limit int) ([]*models.ProfileVersion, error) {
+	rows, err := p.db.Query(`
+		SELECT name, version, config_json, created_at, created_by FROM yaf_profile_versions
+		WHERE name = $1
+		ORDER BY version DESC
+		LIMIT $2
+	`, name, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query profile history: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []*models.ProfileVersion{}
+	for rows.Next() {
+		version := &models.ProfileVersion{}
+		if err := rows.Scan(&version.Name, &version.Version, &version.ConfigJSON, &version.CreatedAt, &version.CreatedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan profile version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// SaveProfile 保存配置档的新版本（同时更新描述）。baseVersion 的含义同 SaveConfig，不一致时返回 *VersionConflictError；
+// 配置档不存在时返回 ErrProfileNotFound。同一事务中重新合并挂载了该配置档的每个配置档层，
+// 返回新版本号与需要重新发布的配置档层
+func (p *PostgresDB) SaveProfile(name, description, configJSON string, baseVersion int, updatedBy string) (int, []*models.ProfileLayer, error) {
+	tx, err := p.beginChanges()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	var current int
+	err = tx.QueryRow(`
+		SELECT (SELECT MAX(version) FROM yaf_profile_versions WHERE name = p.name)
+		FROM yaf_profiles p WHERE p.name = $1
+		FOR UPDATE
+	`, name).Scan(&current)
+	if err == sql.ErrNoRows {
+		return 0, nil, ErrProfileNotFound
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to lock profile: %w", err)
+	}
+	if baseVersion != AnyVersion && baseVersion != current {
+		return 0, nil, &VersionConflictError{Expected: baseVersion, Current: current}
+	}
+
+	version := current + 1
+	if _, err := tx.Exec(`
+		INSERT INTO yaf_profile_versions (name, version, config_json, created_at, created_by)
+		VALUES ($1, $2, $3, NOW(), $4)
+	`, name, version, configJSON, updatedBy); err != nil {
+		return 0, nil, fmt.Errorf("failed to save profile version: %w", err)
+	}
+	if _, err := tx.Exec(`
+		UPDATE yaf_profiles SET description = $2, updated_at = NOW(), updated_by = $3 WHERE name = $1
+	`, name, description, updatedBy); err != nil {
+		return 0, nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	targets, err := attachedTargets(tx.Tx, name)
+	if err != nil {
+		return 0, nil, err
+	}
+	layers := []*models.ProfileLayer{}
+	for _, target := range targets {
+		layer, err := composeLayer(tx, target[0], target[1], updatedBy)
+		if err != nil {
+			return 0, nil, err
+		}
+		layers = append(layers, layer)
+	}
+	if err := p.commit(tx); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit profile: %w", err)
+	}
+
+	p.logger.Info("profile saved",
+		zap.String("profile", name),
+		zap.Int("version", version),
+		zap.Int("layers", len(layers)),
+	)
+	return version, layers, nil
+}
+
+// DeleteProfile 删除配置档及其版本历史。仍有集群或节点挂载时返回 ErrProfileInUse，不存在时返回 ErrProfileNotFound
+func (p *PostgresDB) DeleteProfile(name string) error {
+	result, err := p.db.Exec(`
+		DELETE FROM yaf_profiles p
+		WHERE p.name = $1 AND NOT EXISTS (SELECT 1 FROM yaf_profile_attachments a WHERE a.profile_name = p.name)
+	`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete profile: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	profile, err := p.GetProfile(name)
+	if err != nil {
+		return err
+	}
+	if profile == nil {
+		return ErrProfileNotFound
+	}
+	return ErrProfileInUse
+}
+
+// GetAttachedProfiles 获取集群（nodeID 为空）或节点按顺序挂载的配置档名称
+func (p *PostgresDB) GetAttachedProfiles(clusterName, nodeID string) ([]string, error) {
+	rows, err := p.db.Query(`
+		SELECT profile_name FROM yaf_profile_attachments
+		WHERE cluster_name = $1 AND node_id = $2
+		ORDER BY position
+	`, clusterName, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attached profiles: %w", err)
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan attached profile: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// AttachProfiles 设置集群（nodeID 为空）或节点按顺序挂载的配置档（空列表表示全部卸载），
+// 同一事务中重新合并其配置档层。有配置档不存在时返回 ErrProfileNotFound。
+// 从未挂载过配置档且 names 为空时返回 nil，否则返回需要发布的配置档层
+func (p *PostgresDB) AttachProfiles(clusterName, nodeID string, names []string, attachedBy string) (*models.ProfileLayer, error) {
+	tx, err := p.beginChanges()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var found int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*) FROM (SELECT name FROM yaf_profiles WHERE name = ANY($1) FOR SHARE) p
+	`, pq.StringArray(names)).Scan(&found); err != nil {
+		return nil, fmt.Errorf("failed to check profiles: %w", err)
+	}
+	if found != len(names) {
+		return nil, ErrProfileNotFound
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM yaf_profile_attachments WHERE cluster_name = $1 AND node_id = $2
+	`, clusterName, nodeID); err != nil {
+		return nil, fmt.Errorf("failed to detach profiles: %w", err)
+	}
+	for position, name := range names {
+		if _, err := tx.Exec(`
+			INSERT INTO yaf_profile_attachments (cluster_name, node_id, profile_name, position)
+			VALUES ($1, $2, $3, $4)
+		`, clusterName, nodeID, name, position); err != nil {
+			return nil, fmt.Errorf("failed to attach profile: %w", err)
+		}
+	}
+
+	layer, err := composeLayer(tx, clusterName, nodeID, attachedBy)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.commit(tx); err != nil {
+		return nil, fmt.Errorf("failed to commit profile attachments: %w", err)
+	}
+
+	p.logger.Info("profiles attached",
+		zap.String("cluster", clusterName),
+		zap.String("node", nodeID),
+		zap.Strings("profiles", names),
+	)
+	return layer, nil
+}
+
+// LayerProfiles 获取集群（nodeID 为空）或节点按顺序挂载的配置档的最新版本
+func (p *PostgresDB) LayerProfiles(clusterName, nodeID string) ([]*models.ProfileVersion, error) {
+	return layerProfiles(p.db, clusterName, nodeID)
+}
+
+// layerProfiles 查询按顺序挂载的配置档的最新版本，可在事务内使用
+func layerProfiles(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, clusterName, nodeID string) ([]*models.ProfileVersion, error) {
+	rows, err := q.Query(`
+		SELECT a.profile_name, v.version, v.config_json, v.created_at, v.created_by
+		FROM yaf_profile_attachments a
+		JOIN LATERAL (
+			SELECT version, config_json, created_at, created_by FROM yaf_profile_versions
+			WHERE name = a.profile_name ORDER BY version DESC LIMIT 1
+		) v ON TRUE
+		WHERE a.cluster_name = $1 AND a.node_id = $2
+		ORDER BY a.position
+	`, clusterName, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query layer profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*models.ProfileVersion
+	for rows.Next() {
+		version := &models.ProfileVersion{}
+		if err := rows.Scan(&version.Name, &version.Version, &version.ConfigJSON, &version.CreatedAt, &version.CreatedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan layer profile: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// attachedTargets 挂载了某个配置档的集群与节点（[集群, 节点]，集群挂载时节点为空）
+func attachedTargets(tx *sql.Tx, name string) ([][2]string, error) {
+	rows, err := tx.Query(`
+		SELECT cluster_name, node_id FROM yaf_profile_attachments
+		WHERE profile_name = $1
+		ORDER BY cluster_name, node_id
+	`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query profile attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var targets [][2]string
+	for rows.Next() {
+		var target [2]string
+		if err := rows.Scan(&target[0], &target[1]); err != nil {
+			return nil, fmt.Errorf("failed to scan profile attachment: %w", err)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// composeLayer 在事务中按顺序合并集群或节点挂载的配置档的最新版本，写入新版本的配置档层并标记为待发布。
+// 从未挂载过配置档时返回 nil；全部卸载后写入空的配置档层。author 为触发重新合并的用户，登记在配置变更中
+func composeLayer(tx *changeTx, clusterName, nodeID, author string) (*models.ProfileLayer, error) {
+	lockKey := fmt.Sprintf("yaf_profile_layer/%s/%s", clusterName, nodeID)
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", lockKey); err != nil {
+		return nil, fmt.Errorf("failed to lock profile layer: %w", err)
+	}
+
+	versions, err := layerProfiles(tx, clusterName, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	var previous string
+	err = tx.QueryRow(`
+		SELECT config_json FROM yaf_profile_layers WHERE cluster_name = $1 AND node_id = $2
+	`, clusterName, nodeID).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to check profile layer: %w", err)
+	}
+	if err == sql.ErrNoRows && len(versions) == 0 {
+		return nil, nil
+	}
+
+	overlays := make([]models.Overlay, 0, len(versions))
+	refs := make([]models.ProfileRef, 0, len(versions))
+	for _, version := range versions {
+		overlay, err := models.ParseOverlay([]byte(version.ConfigJSON))
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile %s config json: %w", version.Name, err)
+		}
+		overlays = append(overlays, overlay)
+		refs = append(refs, models.ProfileRef{Name: version.Name, Version: version.Version})
+	}
+	configJSON, _ := json.Marshal(models.ComposeOverlays(overlays...))
+
+	layer, err := scanLayer(tx.QueryRow(`
+		INSERT INTO yaf_profile_layers (cluster_name, node_id, version, config_json, profiles, status)
+		VALUES ($1, $2, 1, $3, $4, $5)
+		ON CONFLICT (cluster_name, node_id) DO UPDATE SET
+			version = yaf_profile_layers.version + 1, config_json = EXCLUDED.config_json, profiles = EXCLUDED.profiles,
+			status = EXCLUDED.status, attempts = 0, last_error = '', next_attempt_at = NOW(), updated_at = NOW(),
+			synced_at = NULL
+		RETURNING `+layerColumns,
+		clusterName, nodeID, string(configJSON), encodeProfileRefs(refs), models.SyncPending))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save profile layer: %w", err)
+	}
+	tx.record(&models.ConfigChange{
+		Scope:       models.ScopeProfile,
+		ClusterName: clusterName,
+		NodeID:      nodeID,
+		Version:     layer.Version,
+		Author:      author,
+		Before:      previous,
+		After:       layer.ConfigJSON,
+	})
+	return layer, nil
+}
+
+// GetProfileLayer 获取集群（nodeID 为空）或节点的配置档层，从未挂载过配置档时返回 nil
+func (p *PostgresDB) GetProfileLayer(clusterName, nodeID string) (*models.ProfileLayer, error) {
+	layer, err := scanLayer(p.db.QueryRow(`
+		SELECT `+layerColumns+` FROM yaf_profile_layers WHERE cluster_name = $1 AND node_id = $2
+	`, clusterName, nodeID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile layer: %w", err)
+	}
+	return layer, nil
+}
+
+// GetDueProfileLayers 获取到达重试时间、尚未写入 ZooKeeper 的配置档层
+func (p *PostgresDB) GetDueProfileLayers(limit int) ([]*models.ProfileLayer, error) {
+	rows, err := p.db.Query(`
+		SELECT `+layerColumns+` FROM yaf_profile_layers
+		WHERE status <> $1 AND next_attempt_at <= NOW()
+		ORDER BY updated_at
+		LIMIT $2
+	`, models.SyncSynced, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query profile layers: %w", err)
+	}
+	defer rows.Close()
+
+	layers := []*models.ProfileLayer{}
+	for rows.Next() {
+		layer, err := scanLayer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan profile layer: %w", err)
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// MarkProfileLayerSynced 标记配置档层的某个版本已写入 ZooKeeper，已被重新合并时不做修改
+func (p *PostgresDB) MarkProfileLayerSynced(layer *models.ProfileLayer) error {
+	_, err := p.db.Exec(`
+		UPDATE yaf_profile_layers SET status = $4, attempts = attempts + 1, last_error = '', synced_at = NOW()
+		WHERE cluster_name = $1 AND node_id = $2 AND version = $3
+	`, layer.ClusterName, layer.NodeID, layer.Version, models.SyncSynced)
+	if err != nil {
+		return fmt.Errorf("failed to mark profile layer synced: %w", err)
+	}
+	return nil
+}
+
+// MarkProfileLayerFailed 记录配置档层写入失败的原因与下次重试时间，已被重新合并时不做修改
+func (p *PostgresDB) MarkProfileLayerFailed(layer *models.ProfileLayer, reason string, nextAttempt time.Time) error {
+	_, err := p.db.Exec(`
+		UPDATE yaf_profile_layers SET status = $4, attempts = attempts + 1, last_error = $5, next_attempt_at = $6
+		WHERE cluster_name = $1 AND node_id = $2 AND version = $3
+	`, layer.ClusterName, layer.NodeID, layer.Version, models.SyncFailed, reason, nextAttempt)
+	if err != nil {
+		return fmt.Errorf("failed to mark profile layer failed: %w", err)
+	}
+	return nil
+}
+
+// ListProfileAttachments 列出挂载了某个配置档的集群与节点，以及其配置档层中合并的该配置档版本
+func (p *PostgresDB) ListProfileAttachments(name string) ([]*models.ProfileAttachment, error) {
+	rows, err := p.db.Query(`
+		SELECT a.cluster_name, a.node_id, a.position,
+			COALESCE((SELECT (ref->>'version')::INT FROM jsonb_array_elements(l.profiles) ref WHERE ref->>'name' = a.profile_name LIMIT 1), 0),
+			COALESCE(l.status, '')
+		FROM yaf_profile_attachments a
+		LEFT JOIN yaf_profile_layers l ON l.cluster_name = a.cluster_name AND l.node_id = a.node_id
+		WHERE a.profile_name = $1
+		ORDER BY a.cluster_name, a.node_id
+	`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profile attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []*models.ProfileAttachment{}
+	for rows.Next() {
+		attachment := &models.ProfileAttachment{}
+		if err := rows.Scan(
+			&attachment.ClusterName, &attachment.NodeID, &attachment.Position, &attachment.Published, &attachment.SyncStatus,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan profile attachment: %w", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// ListProfileInheritance 列出 Agent 上报的、生效配置中继承了某个配置档的节点及其版本
+func (p *PostgresDB) ListProfileInheritance(name string) ([]*models.ProfileInheritance, error) {
+	rows, err := p.db.Query(`
+		SELECT ag.cluster_name, ag.node_id, (ref->>'version')::INT, ag.online, ag.applied_at
+		FROM yaf_agents ag, jsonb_array_elements(ag.applied_profiles) ref
+		WHERE ref->>'name' = $1 AND ag.applied_at IS NOT NULL
+		ORDER BY ag.cluster_name, ag.node_id
+	`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profile inheritance: %w", err)
+	}
+	defer rows.Close()
+
+	nodes := []*models.ProfileInheritance{}
+	for rows.Next() {
+		node := &models.ProfileInheritance{}
+		if err := rows.Scan(&node.ClusterName, &node.NodeID, &node.Version, &node.Online, &node.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan profile inheritance: %w", err)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// hasAttachedProfiles 检查集群（nodeID 为空时包括其节点）或节点是否仍挂载配置档
+func hasAttachedProfiles(tx *sql.Tx, clusterName, nodeID string) (bool, error) {
+	var attached bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM yaf_profile_attachments WHERE cluster_name = $1 AND ($2 = '' OR node_id = $2)
+		)
+	`, clusterName, nodeID).Scan(&attached)
+	if err != nil {
+		return false, fmt.Errorf("failed to check attached profiles: %w", err)
+	}
+	return attached, nil
+}
+
+// encodeProfileRefs 序列化配置档版本列表，nil 保存为空数组
+func encodeProfileRefs(refs []models.ProfileRef) string {
+	if len(refs) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(refs)
+	return string(data)
+}
+
+// decodeProfileRefs 解析配置档版本列表，列为空或无法解析时返回 nil
+func decodeProfileRefs(data []byte) []models.ProfileRef {
+	var refs []models.ProfileRef
+	json.Unmarshal(data, &refs)
+	if len(refs) == 0 {
+		return nil
+	}
+	return refs
+}
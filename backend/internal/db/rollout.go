@@ -0,0 +1,76 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/yf-web/backend/internal/models"
+)
+
+// ListRollouts 统计各集群的发布进度。节点取已登记的节点与观察到 Agent 的节点的并集，
+// 期望版本为数据库中各级配置的最新版本（已删除或未配置为 0），灰度发布期间灰度节点以外的节点（包括开始灰度后
+// 才出现、由守护节点固定的节点）为基准版本。
+// clusterName 为空时统计所有未归档的集群且不附带节点明细，否则只统计该集群并附带节点明细
+func (p *PostgresDB) ListRollouts(clusterName string) ([]*models.Rollout, error) {
+	rows, err := p.db.Query(`
+		WITH live AS (`+liveConfigs+`),
+		members AS (
+			SELECT cluster_name, node_id FROM yaf_nodes
+			UNION
+			SELECT cluster_name, node_id FROM yaf_agents
+		),
+		pins AS (
+			SELECT m.cluster_name, m.node_id, c.scope, c.base_version
+			FROM yaf_canaries c JOIN members m ON c.scope = 'global' OR m.cluster_name = c.cluster_name
+			WHERE c.state IN (`+activeCanaryStates+`) AND NOT EXISTS (
+				SELECT 1 FROM yaf_canary_nodes cn
+				WHERE cn.canary_id = c.id AND cn.canary AND cn.cluster_name = m.cluster_name AND cn.node_id = m.node_id
+			)
+		)
+		SELECT m.cluster_name, m.node_id, n.node_id IS NOT NULL, COALESCE(ag.online, FALSE),
+			COALESCE(pg.base_version, (SELECT version FROM live WHERE scope = 'global'), 0),
+			COALESCE(pc.base_version, cc.version, 0), COALESCE(nc.version, 0),
+			ag.applied_global, ag.applied_cluster, ag.applied_node,
+			ag.config_hash, ag.apply_status, ag.apply_error, ag.applied_at, ag.applied_profiles
+		FROM members m
+		LEFT JOIN yaf_nodes n ON n.cluster_name = m.cluster_name AND n.node_id = m.node_id
+		LEFT JOIN yaf_agents ag ON ag.cluster_name = m.cluster_name AND ag.node_id = m.node_id
+		LEFT JOIN live cc ON cc.scope = 'cluster' AND cc.cluster_name = m.cluster_name
+		LEFT JOIN live nc ON nc.scope = 'node' AND nc.cluster_name = m.cluster_name AND nc.node_id = m.node_id
+		LEFT JOIN pins pg ON pg.scope = 'global' AND pg.cluster_name = m.cluster_name AND pg.node_id = m.node_id
+		LEFT JOIN pins pc ON pc.scope = 'cluster' AND pc.cluster_name = m.cluster_name AND pc.node_id = m.node_id
+		WHERE ($1 = '' AND m.cluster_name NOT IN (SELECT cluster_name FROM yaf_cluster_archive))
+			OR m.cluster_name = $1
+		ORDER BY m.cluster_name, m.node_id
+	`, clusterName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollout: %w", err)
+	}
+	defer rows.Close()
+
+	rollouts := []*models.Rollout{}
+	var current *models.Rollout
+	for rows.Next() {
+		var cluster string
+		var apply nullApply
+		node := &models.NodeRollout{}
+		if err := rows.Scan(
+			&cluster, &node.NodeID, &node.Registered, &node.Online,
+			&node.Expected.Global, &node.Expected.Cluster, &node.Expected.Node,
+			&apply.GlobalVersion, &apply.ClusterVersion, &apply.NodeVersion,
+			&apply.ConfigHash, &apply.Status, &apply.Error, &apply.AppliedAt, &apply.Profiles,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan rollout: %w", err)
+		}
+		node.SetApplied(apply.state())
+
+		if current == nil || current.ClusterName != cluster {
+			current = &models.Rollout{ClusterName: cluster}
+			rollouts = append(rollouts, current)
+		}
+		current.Add(node)
+		if clusterName != "" {
+			current.Nodes = append(current.Nodes, node)
+		}
+	}
+	return rollouts, nil
+}
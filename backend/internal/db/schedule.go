@@ -0,0 +1,241 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// ErrScheduleState 定时发布已发布、失败或取消，不能再改期或取消
+var ErrScheduleState = errors.New("schedule is no longer pending")
+
+const scheduleColumns = `id, scope, cluster_name, node_id, config_json, base_version, publish_at, state, version, error,
+	created_at, created_by, updated_at, updated_by`
+
+// scanSchedule 扫描一行定时发布记录
+func scanSchedule(row interface{ Scan(...interface{}) error }) (*models.Schedule, error) {
+	schedule := &models.Schedule{}
+	err := row.Scan(
+		&schedule.ID, &schedule.Scope, &schedule.ClusterName, &schedule.NodeID, &schedule.ConfigJSON,
+		&schedule.BaseVersion, &schedule.PublishAt, &schedule.State, &schedule.Version, &schedule.Error,
+		&schedule.CreatedAt, &schedule.CreatedBy, &schedule.UpdatedAt, &schedule.UpdatedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// CreateSchedule 登记定时发布，填充 ID、状态与时间
+func (p *PostgresDB) CreateSchedule(schedule *models.Schedule) error {
+	now := time.Now()
+	schedule.State = models.ScheduleScheduled
+	schedule.CreatedAt = now
+	schedule.UpdatedAt = now
+	schedule.UpdatedBy = schedule.CreatedBy
+	err := p.db.QueryRow(`
+		INSERT INTO yaf_schedules (scope, cluster_name, node_id, config_json, base_version, publish_at, state,
+			created_at, created_by, updated_at, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $8, $9)
+		RETURNING id
+	`, schedule.Scope, schedule.ClusterName, schedule.NodeID, schedule.ConfigJSON, schedule.BaseVersion,
+		schedule.PublishAt, schedule.State, now, schedule.CreatedBy).Scan(&schedule.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create schedule: %w", err)
+	}
+
+	p.logger.Info("config change scheduled",
+		zap.Int64("id", schedule.ID),
+		zap.String("scope", string(schedule.Scope)),
+		zap.String("cluster", schedule.ClusterName),
+		zap.String("node", schedule.NodeID),
+		zap.Time("publish_at", schedule.PublishAt),
+	)
+	return nil
+}
+
+// GetSchedule 获取定时发布，不存在时返回 nil
+func (p *PostgresDB) GetSchedule(id int64) (*models.Schedule, error) {
+	schedule, err := scanSchedule(p.db.QueryRow(`SELECT `+scheduleColumns+` FROM yaf_schedules WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// ListSchedules 列出定时发布：等待发布的按发布时间排在最前，其余按 ID 倒序
+func (p *PostgresDB) ListSchedules(filter models.ScheduleFilter) ([]*models.Schedule, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := p.db.Query(`
+		SELECT `+scheduleColumns+` FROM yaf_schedules
+		WHERE ($1 = '' OR state = $1)
+			AND ($2 = '' OR scope = $2)
+			AND ($3 = '' OR cluster_name = $3)
+			AND ($4 = '' OR node_id = $4)
+		ORDER BY state <> 'scheduled', CASE WHEN state = 'scheduled' THEN publish_at END, id DESC
+		LIMIT $5
+	`, filter.State, filter.Scope, filter.ClusterName, filter.NodeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []*models.Schedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// scheduledHistory 某一级配置尚未发布的定时变更，以配置历史记录的形式返回
+func (p *PostgresDB) scheduledHistory(scope models.ConfigScope, clusterName, nodeID string) ([]*models.ConfigRecord, error) {
+	schedules, err := p.ListSchedules(models.ScheduleFilter{
+		State:       models.ScheduleScheduled,
+		Scope:       scope,
+		ClusterName: clusterName,
+		NodeID:      nodeID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*models.ConfigRecord
+	for i := len(schedules) - 1; i >= 0; i-- {
+		schedule := schedules[i]
+		record := schedule.Record()
+		record.CreatedAt = schedule.CreatedAt
+		record.Scheduled = true
+		record.ScheduleID = schedule.ID
+		record.PublishAt = &schedule.PublishAt
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// RescheduleSchedule 修改等待发布的定时发布的发布时间，已不在等待状态时返回 ErrScheduleState
+func (p *PostgresDB) RescheduleSchedule(id int64, publishAt time.Time, updatedBy string) error {
+	result, err := p.db.Exec(`
+		UPDATE yaf_schedules SET publish_at = $2, updated_at = NOW(), updated_by = $3
+		WHERE id = $1 AND state = 'scheduled'
+	`, id, publishAt, updatedBy)
+	return checkScheduleUpdated(result, err, "reschedule")
+}
+
+// CancelSchedule 取消等待发布的定时发布，已不在等待状态时返回 ErrScheduleState
+func (p *PostgresDB) CancelSchedule(id int64, updatedBy string) error {
+	result, err := p.db.Exec(`
+		UPDATE yaf_schedules SET state = 'canceled', updated_at = NOW(), updated_by = $2
+		WHERE id = $1 AND state = 'scheduled'
+	`, id, updatedBy)
+	return checkScheduleUpdated(result, err, "cancel")
+}
+
+// checkScheduleUpdated 检查只对等待状态生效的更新是否命中
+func checkScheduleUpdated(result sql.Result, err error, action string) error {
+	if err != nil {
+		return fmt.Errorf("failed to %s schedule: %w", action, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrScheduleState
+	}
+	return nil
+}
+
+// ListDueSchedules 列出已到发布时间、仍在等待的定时发布
+func (p *PostgresDB) ListDueSchedules(now time.Time, limit int) ([]*models.Schedule, error) {
+	rows, err := p.db.Query(`
+		SELECT `+scheduleColumns+` FROM yaf_schedules
+		WHERE state = 'scheduled' AND publish_at <= $1
+		ORDER BY publish_at, id
+		LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []*models.Schedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// PublishSchedule 在一个事务中将定时发布保存为新版本（检查 base_version 与灰度发布，同 SaveConfig）
+// 并标记为已发布，返回保存的配置记录。定时发布已不在等待状态（例如已被其他实例发布或刚被取消）时
+// 返回 ErrScheduleState；版本冲突与 ErrCanaryActive 原样返回，由调用方标记为失败
+func (p *PostgresDB) PublishSchedule(id int64) (*models.ConfigRecord, error) {
+	tx, err := p.beginChanges()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	schedule, err := scanSchedule(tx.QueryRow(`
+		SELECT `+scheduleColumns+` FROM yaf_schedules
+		WHERE id = $1 AND state = 'scheduled'
+		FOR UPDATE
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrScheduleState
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock schedule: %w", err)
+	}
+
+	record := schedule.Record()
+	if err := insertVersion(tx, record, schedule.BaseVersion); err != nil {
+		return nil, err
+	}
+	if err := checkNoCanary(tx.Tx, record); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE yaf_schedules SET state = 'published', version = $2, updated_at = NOW()
+		WHERE id = $1
+	`, id, record.Version); err != nil {
+		return nil, fmt.Errorf("failed to mark schedule published: %w", err)
+	}
+	if err := p.commit(tx); err != nil {
+		return nil, fmt.Errorf("failed to commit schedule: %w", err)
+	}
+
+	p.logger.Info("scheduled config saved",
+		zap.Int64("id", id),
+		zap.String("scope", string(record.Scope)),
+		zap.String("cluster", record.ClusterName),
+		zap.String("node", record.NodeID),
+		zap.Int("version", record.Version),
+	)
+	return record, nil
+}
+
+// FailSchedule 将等待发布的定时发布标记为失败
+func (p *PostgresDB) FailSchedule(id int64, reason string) error {
+	_, err := p.db.Exec(`
+		UPDATE yaf_schedules SET state = 'failed', error = $2, updated_at = NOW()
+		WHERE id = $1 AND state = 'scheduled'
+	`, id, reason)
+	if err != nil {
+		return fmt.Errorf("failed to mark schedule failed: %w", err)
+	}
+	return nil
+}
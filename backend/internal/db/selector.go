@@ -0,0 +1,317 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrSelectorExists 同名标签选择层已存在
+	ErrSelectorExists = errors.New("selector already exists")
+	// ErrSelectorNotFound 标签选择层不存在
+	ErrSelectorNotFound = errors.New("selector not found")
+)
+
+const selectorColumns = `name, description, cluster_name, labels, priority, version, config_json, deleted, status, attempts,
+	last_error, next_attempt_at, synced_at, created_at, created_by, updated_at, updated_by`
+
+// scanSelector 扫描一行标签选择层
+func scanSelector(row interface{ Scan(...interface{}) error }) (*models.Selector, error) {
+	selector := &models.Selector{}
+	var labels []byte
+	var syncedAt sql.NullTime
+	err := row.Scan(
+		&selector.Name, &selector.Description, &selector.ClusterName, &labels, &selector.Priority, &selector.Version,
+		&selector.ConfigJSON, &selector.Deleted, &selector.Status, &selector.Attempts, &selector.LastError,
+		&selector.NextAttemptAt, &syncedAt, &selector.CreatedAt, &selector.CreatedBy, &selector.UpdatedAt, &selector.UpdatedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if selector.Labels, err = decodeLabels(labels); err != nil {
+		return nil, err
+	}
+	if syncedAt.Valid {
+		selector.SyncedAt = &syncedAt.Time
+	}
+	return selector, nil
+}
+
+// querySelectors 查询标签选择层，WHERE 子句由调用方拼接
+func (p *PostgresDB) querySelectors(where string, args ...interface{}) ([]*models.Selector, error) {
+	rows, err := p.db.Query(`SELECT `+selectorColumns+` FROM yaf_selectors `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query selectors: %w", err)
+	}
+	defer rows.Close()
+
+	selectors := []*models.Selector{}
+	for rows.Next() {
+		selector, err := scanSelector(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan selector: %w", err)
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// CreateSelector 创建标签选择层，填充版本、状态与时间；同名选择层已存在时返回 ErrSelectorExists。
+// 同名选择层曾被删除时在其版本号之后继续，保证 ZooKeeper 中的旧版本不会覆盖新版本
+func (p *PostgresDB) CreateSelector(selector *models.Selector) error {
+	tx, err := p.beginChanges()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRow(`
+		INSERT INTO yaf_selectors (name, description, cluster_name, labels, priority, version, config_json, status,
+			created_at, created_by, updated_at, updated_by)
+		VALUES ($1, $2, $3, $4, $5, 1, $6, $7, NOW(), $8, NOW(), $8)
+		ON CONFLICT (name) DO UPDATE SET
+			description = EXCLUDED.description, cluster_name = EXCLUDED.cluster_name, labels = EXCLUDED.labels,
+			priority = EXCLUDED.priority, version = yaf_selectors.version + 1, config_json = EXCLUDED.config_json,
+			deleted = FALSE, status = EXCLUDED.status, attempts = 0, last_error = '', next_attempt_at = NOW(),
+			synced_at = NULL, created_at = NOW(), created_by = EXCLUDED.created_by, updated_at = NOW(),
+			updated_by = EXCLUDED.updated_by
+		WHERE yaf_selectors.deleted
+		RETURNING `+selectorColumns,
+		selector.Name, selector.Description, selector.ClusterName, encodeLabels(selector.Labels), selector.Priority,
+		selector.ConfigJSON, models.SyncPending, selector.CreatedBy)
+	created, err := scanSelector(row)
+	if err == sql.ErrNoRows {
+		return ErrSelectorExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create selector: %w", err)
+	}
+	tx.record(selectorChange(created, ""))
+	if err := p.commit(tx); err != nil {
+		return fmt.Errorf("failed to commit selector: %w", err)
+	}
+	*selector = *created
+
+	p.logger.Info("selector created", zap.String("selector", selector.Name), zap.Int("version", selector.Version))
+	return nil
+}
+
+// ListSelectors 列出标签选择层，按合并顺序（优先级、名称）排序
+func (p *PostgresDB) ListSelectors() ([]*models.Selector, error) {
+	return p.querySelectors(`WHERE NOT deleted ORDER BY priority, name`)
+}
+
+// GetSelector 获取标签选择层，不存在或已删除时返回 nil
+func (p *PostgresDB) GetSelector(name string) (*models.Selector, error) {
+	selector, err := scanSelector(p.db.QueryRow(`
+		SELECT `+selectorColumns+` FROM yaf_selectors WHERE name = $1 AND NOT deleted
+	`, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get selector: %w", err)
+	}
+	return selector, nil
+}
+
+// SaveSelector 修改标签选择层（选择条件、优先级与覆盖配置整体替换），版本号递增并等待发布。
+// baseVersion 的含义同 SaveConfig，不一致时返回 *VersionConflictError；不存在时返回 ErrSelectorNotFound
+func (p *PostgresDB) SaveSelector(selector *models.Selector, baseVersion int) error {
+	return p.updateSelector(selector.Name, baseVersion, func(tx *changeTx) *sql.Row {
+		return tx.QueryRow(`
+			UPDATE yaf_selectors SET description = $2, cluster_name = $3, labels = $4, priority = $5,
+				config_json = $6, version = version + 1, status = $7, attempts = 0, last_error = '',
+				next_attempt_at = NOW(), updated_at = NOW(), updated_by = $8
+			WHERE name = $1
+			RETURNING `+selectorColumns,
+			selector.Name, selector.Description, selector.ClusterName, encodeLabels(selector.Labels), selector.Priority,
+			selector.ConfigJSON, models.SyncPending, selector.UpdatedBy)
+	}, selector)
+}
+
+// DeleteSelector 删除标签选择层：标记为已删除并递增版本号，由 publisher 删除 ZooKeeper 中的节点。
+// 返回删除标记，用于立即发布
+func (p *PostgresDB) DeleteSelector(name string, baseVersion int, deletedBy string) (*models.Selector, error) {
+	selector := &models.Selector{}
+	err := p.updateSelector(name, baseVersion, func(tx *changeTx) *sql.Row {
+		return tx.QueryRow(`
+			UPDATE yaf_selectors SET deleted = TRUE, version = version + 1, status = $2, attempts = 0,
+				last_error = '', next_attempt_at = NOW(), updated_at = NOW(), updated_by = $3
+			WHERE name = $1
+			RETURNING `+selectorColumns,
+			name, models.SyncPending, deletedBy)
+	}, selector)
+	if err != nil {
+		return nil, err
+	}
+	return selector, nil
+}
+
+// updateSelector 锁定未删除的标签选择层，检查基准版本后执行 update，并以返回的行填充 result
+func (p *PostgresDB) updateSelector(name string, baseVersion int, update func(tx *changeTx) *sql.Row, result *models.Selector) error {
+	tx, err := p.beginChanges()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current int
+	var previous string
+	err = tx.QueryRow(`
+		SELECT version, config_json FROM yaf_selectors WHERE name = $1 AND NOT deleted FOR UPDATE
+	`, name).Scan(&current, &previous)
+	if err == sql.ErrNoRows {
+		return ErrSelectorNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock selector: %w", err)
+	}
+	if baseVersion != AnyVersion && baseVersion != current {
+		return &VersionConflictError{Expected: baseVersion, Current: current}
+	}
+
+	updated, err := scanSelector(update(tx))
+	if err != nil {
+		return fmt.Errorf("failed to update selector: %w", err)
+	}
+	tx.record(selectorChange(updated, previous))
+	if err := p.commit(tx); err != nil {
+		return fmt.Errorf("failed to commit selector: %w", err)
+	}
+	*result = *updated
+
+	p.logger.Info("selector updated",
+		zap.String("selector", name),
+		zap.Int("version", updated.Version),
+		zap.Bool("deleted", updated.Deleted),
+	)
+	return nil
+}
+
+// selectorChange 标签选择层新版本对应的配置变更，previous 为修改前的覆盖配置
+func selectorChange(selector *models.Selector, previous string) *models.ConfigChange {
+	change := &models.ConfigChange{
+		Scope:       models.ScopeSelector,
+		ClusterName: selector.ClusterName,
+		Name:        selector.Name,
+		Version:     selector.Version,
+		Deleted:     selector.Deleted,
+		Author:      selector.UpdatedBy,
+		Before:      previous,
+	}
+	if !selector.Deleted {
+		change.After = selector.ConfigJSON
+	}
+	return change
+}
+
+// MatchingSelectors 获取与集群 clusterName 中带有 labels 的节点匹配的标签选择层，按合并顺序排序
+func (p *PostgresDB) MatchingSelectors(clusterName string, labels map[string]string) ([]*models.Selector, error) {
+	selectors, err := p.querySelectors(`WHERE NOT deleted AND (cluster_name = '' OR cluster_name = $1)`, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	matched := []*models.Selector{}
+	for _, selector := range selectors {
+		if selector.Rule().Matches(clusterName, labels) {
+			matched = append(matched, selector)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return models.SelectorLess(matched[i].Rule(), matched[j].Rule())
+	})
+	return matched, nil
+}
+
+// SelectorNodes 列出清单中与标签选择层匹配的节点
+func (p *PostgresDB) SelectorNodes(selector *models.Selector) ([]*models.Node, error) {
+	nodes, err := p.queryNodes(`WHERE ($1 = '' OR n.cluster_name = $1) AND n.labels @> $2 ORDER BY n.cluster_name, n.node_id`,
+		selector.ClusterName, encodeLabels(selector.Labels))
+	if err != nil {
+		return nil, err
+	}
+	matched := []*models.Node{}
+	for _, node := range nodes {
+		if selector.Rule().Matches(node.ClusterName, node.Labels) {
+			matched = append(matched, node)
+		}
+	}
+	return matched, nil
+}
+
+// GetDueSelectors 获取到达重试时间、尚未写入 ZooKeeper 的标签选择层（含删除标记）
+func (p *PostgresDB) GetDueSelectors(limit int) ([]*models.Selector, error) {
+	return p.querySelectors(`WHERE status <> $1 AND next_attempt_at <= NOW() ORDER BY updated_at LIMIT $2`,
+		models.SyncSynced, limit)
+}
+
+// MarkSelectorSynced 标记标签选择层的某个版本已写入 ZooKeeper，已被再次修改时不做修改
+func (p *PostgresDB) MarkSelectorSynced(selector *models.Selector) error {
+	_, err := p.db.Exec(`
+		UPDATE yaf_selectors SET status = $3, attempts = attempts + 1, last_error = '', synced_at = NOW()
+		WHERE name = $1 AND version = $2
+	`, selector.Name, selector.Version, models.SyncSynced)
+	if err != nil {
+		return fmt.Errorf("failed to mark selector synced: %w", err)
+	}
+	return nil
+}
+
+// MarkSelectorFailed 记录标签选择层写入失败的原因与下次重试时间，已被再次修改时不做修改
+func (p *PostgresDB) MarkSelectorFailed(selector *models.Selector, reason string, nextAttempt time.Time) error {
+	_, err := p.db.Exec(`
+		UPDATE yaf_selectors SET status = $3, attempts = attempts + 1, last_error = $4, next_attempt_at = $5
+		WHERE name = $1 AND version = $2
+	`, selector.Name, selector.Version, models.SyncFailed, reason, nextAttempt)
+	if err != nil {
+		return fmt.Errorf("failed to mark selector failed: %w", err)
+	}
+	return nil
+}
+
+// GetUnpublishedLabels 获取修改后尚未写入 ZooKeeper 的节点标签
+func (p *PostgresDB) GetUnpublishedLabels(limit int) ([]*models.NodeLabels, error) {
+	rows, err := p.db.Query(`
+		SELECT cluster_name, node_id, labels, labels_version FROM yaf_nodes
+		WHERE labels_published < labels_version
+		ORDER BY updated_at
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query node labels: %w", err)
+	}
+	defer rows.Close()
+
+	result := []*models.NodeLabels{}
+	for rows.Next() {
+		item := &models.NodeLabels{}
+		var labels []byte
+		if err := rows.Scan(&item.ClusterName, &item.NodeID, &labels, &item.Version); err != nil {
+			return nil, fmt.Errorf("failed to scan node labels: %w", err)
+		}
+		if item.Labels, err = decodeLabels(labels); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// MarkLabelsPublished 记录节点标签的某个版本已写入 ZooKeeper
+func (p *PostgresDB) MarkLabelsPublished(labels *models.NodeLabels) error {
+	_, err := p.db.Exec(`
+		UPDATE yaf_nodes SET labels_published = $3
+		WHERE cluster_name = $1 AND node_id = $2 AND labels_published < $3
+	`, labels.ClusterName, labels.NodeID, labels.Version)
+	if err != nil {
+		return fmt.Errorf("failed to mark node labels published: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/yf-web/backend/internal/models"
+)
+
+// CreateSession 为用户创建登录会话，返回明文 token（数据库中只保存其哈希）
+func (p *PostgresDB) CreateSession(username string, ttl time.Duration) (*models.Session, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	session := &models.Session{
+		Token:     token,
+		Username:  username,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	// 顺便清理已过期的会话
+	if _, err := p.db.Exec("DELETE FROM yaf_sessions WHERE expires_at < NOW()"); err != nil {
+		return nil, fmt.Errorf("failed to purge expired sessions: %w", err)
+	}
+
+	_, err := p.db.Exec(`
+		INSERT INTO yaf_sessions (token_hash, username, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, hashToken(token), session.Username, session.CreatedAt, session.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return session, nil
+}
+
+// GetSession 根据 token 获取未过期的会话，不存在或已过期时返回 nil
+func (p *PostgresDB) GetSession(token string) (*models.Session, error) {
+	session := &models.Session{Token: token}
+	err := p.db.QueryRow(`
+		SELECT username, created_at, expires_at FROM yaf_sessions
+		WHERE token_hash = $1 AND expires_at > NOW()
+	`, hashToken(token)).Scan(&session.Username, &session.CreatedAt, &session.ExpiresAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+// DeleteSession 删除会话（退出登录）
+func (p *PostgresDB) DeleteSession(token string) error {
+	_, err := p.db.Exec("DELETE FROM yaf_sessions WHERE token_hash = $1", hashToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// hashToken 计算 token 的 SHA-256 摘要
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeleteUserSessions 删除用户除 keepToken 以外的全部会话
+func (p *PostgresDB) DeleteUserSessions(username, keepToken string) error {
+	_, err := p.db.Exec(
+		"DELETE FROM yaf_sessions WHERE username = $1 AND token_hash <> $2",
+		username, hashToken(keepToken),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete user sessions: %w", err)
+	}
+	return nil
+}
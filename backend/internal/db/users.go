@@ -0,0 +1,330 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrUserDisabled 账号已禁用
+	ErrUserDisabled = errors.New("user is disabled")
+	// ErrUserLocked 账号因连续登录失败被锁定
+	ErrUserLocked = errors.New("user is locked")
+	// ErrUserExists 用户名已存在
+	ErrUserExists = errors.New("user already exists")
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// dummyPasswordHash 用户不存在时参与比较的哈希，使响应耗时与用户存在时一致
+const dummyPasswordHash = "$2a$10$rqhCWNi0dgDUesjI/5j5huCQFEPmgeZpygbbSPJGrOsuAe/Q4puCi"
+
+const userColumns = `id, username, role, disabled, must_change_password, failed_attempts,
+	locked_until, last_login_at, created_at`
+
+// scanUser 扫描一行用户记录
+func scanUser(row interface{ Scan(...interface{}) error }) (*models.User, error) {
+	user := &models.User{}
+	var lockedUntil, lastLoginAt sql.NullTime
+	if err := row.Scan(
+		&user.ID, &user.Username, &user.Role, &user.Disabled, &user.MustChangePassword,
+		&user.FailedAttempts, &lockedUntil, &lastLoginAt, &user.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
+	if lastLoginAt.Valid {
+		user.LastLoginAt = &lastLoginAt.Time
+	}
+	return user, nil
+}
+
+// Authenticate 校验用户名密码，维护失败计数、锁定状态与最近登录时间
+func (p *PostgresDB) Authenticate(username, password string, policy models.LockoutPolicy) (*models.User, error) {
+	var stored string
+	user, err := scanUser(p.db.QueryRow(
+		"SELECT "+userColumns+" FROM yaf_users WHERE username = $1", username,
+	))
+	if err == sql.ErrNoRows {
+		// 仍然做一次哈希比较，避免通过响应时间枚举用户名
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if user.Disabled {
+		return user, ErrUserDisabled
+	}
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		return user, ErrUserLocked
+	}
+
+	if err := p.db.QueryRow("SELECT password FROM yaf_users WHERE username = $1", username).Scan(&stored); err != nil {
+		return nil, fmt.Errorf("failed to query password: %w", err)
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(stored), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return user, p.recordLoginFailure(user, policy)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare password: %w", err)
+	}
+
+	now := time.Now()
+	if _, err := p.db.Exec(`
+		UPDATE yaf_users SET failed_attempts = 0, locked_until = NULL, last_login_at = $1
+		WHERE username = $2
+	`, now, username); err != nil {
+		return nil, fmt.Errorf("failed to record login: %w", err)
+	}
+	user.FailedAttempts = 0
+	user.LockedUntil = nil
+	user.LastLoginAt = &now
+
+	clusters, err := p.ListUserGrants(username)
+	if err != nil {
+		return nil, err
+	}
+	user.Clusters = clusters
+	return user, nil
+}
+
+// recordLoginFailure 记录一次登录失败，达到阈值时锁定账号。计数与锁定在同一条 UPDATE 中完成，
+// 并发的失败登录不会丢失计数
+func (p *PostgresDB) recordLoginFailure(user *models.User, policy models.LockoutPolicy) error {
+	lockedUntil := time.Now().Add(policy.Duration)
+	var locked bool
+	var until sql.NullTime
+	err := p.db.QueryRow(`
+		UPDATE yaf_users SET
+			failed_attempts = CASE WHEN $1 > 0 AND failed_attempts + 1 >= $1 THEN 0 ELSE failed_attempts + 1 END,
+			locked_until = CASE WHEN $1 > 0 AND failed_attempts + 1 >= $1 THEN $2 ELSE locked_until END
+		WHERE username = $3
+		RETURNING failed_attempts, locked_until, $1 > 0 AND locked_until = $2
+	`, policy.MaxAttempts, lockedUntil, user.Username).Scan(&user.FailedAttempts, &until, &locked)
+	if err != nil {
+		return fmt.Errorf("failed to record login failure: %w", err)
+	}
+	if !locked {
+		return ErrInvalidCredentials
+	}
+
+	user.LockedUntil = &until.Time
+	p.logger.Warn("user locked after repeated login failures",
+		zap.String("username", user.Username),
+		zap.Time("locked_until", until.Time),
+	)
+	return ErrUserLocked
+}
+
+// GetUser 获取用户及其集群授权，不存在时返回 nil
+func (p *PostgresDB) GetUser(username string) (*models.User, error) {
+	user, err := scanUser(p.db.QueryRow(
+		"SELECT "+userColumns+" FROM yaf_users WHERE username = $1", username,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	clusters, err := p.ListUserGrants(username)
+	if err != nil {
+		return nil, err
+	}
+	user.Clusters = clusters
+	return user, nil
+}
+
+// ListUsers 列出所有用户
+func (p *PostgresDB) ListUsers() ([]*models.User, error) {
+	rows, err := p.db.Query("SELECT " + userColumns + " FROM yaf_users ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	for _, user := range users {
+		if user.Clusters, err = p.ListUserGrants(user.Username); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+// CreateUser 创建用户，mustChangePassword 为 true 时首次登录须修改密码
+func (p *PostgresDB) CreateUser(username, password string, role models.Role, mustChangePassword bool) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.Exec(`
+		INSERT INTO yaf_users (username, password, role, must_change_password) VALUES ($1, $2, $3, $4)
+	`, username, hash, role, mustChangePassword)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrUserExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser 删除用户（会话与授权级联删除）
+func (p *PostgresDB) DeleteUser(username string) error {
+	result, err := p.db.Exec("DELETE FROM yaf_users WHERE username = $1", username)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// CheckPassword 校验用户当前密码
+func (p *PostgresDB) CheckPassword(username, password string) (bool, error) {
+	var stored string
+	err := p.db.QueryRow("SELECT password FROM yaf_users WHERE username = $1", username).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query user: %w", err)
+	}
+	return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil, nil
+}
+
+// SetPassword 设置用户密码并解除锁定；mustChange 控制下次登录是否须再次修改
+func (p *PostgresDB) SetPassword(username, password string, mustChange bool) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	result, err := p.db.Exec(`
+		UPDATE yaf_users
+		SET password = $1, must_change_password = $2, failed_attempts = 0, locked_until = NULL
+		WHERE username = $3
+	`, hash, mustChange, username)
+	if err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// SetUserDisabled 启用或禁用用户；禁用时撤销其全部会话，启用时解除锁定
+func (p *PostgresDB) SetUserDisabled(username string, disabled bool) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE yaf_users SET disabled = $1, failed_attempts = 0, locked_until = NULL WHERE username = $2
+	`, disabled, username)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	if disabled {
+		if _, err := tx.Exec("DELETE FROM yaf_sessions WHERE username = $1", username); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit user update: %w", err)
+	}
+	return nil
+}
+
+// SetUserRole 设置用户角色
+func (p *PostgresDB) SetUserRole(username string, role models.Role) error {
+	result, err := p.db.Exec("UPDATE yaf_users SET role = $1 WHERE username = $2", role, username)
+	if err != nil {
+		return fmt.Errorf("failed to set user role: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// ListUserGrants 列出用户被授权的集群
+func (p *PostgresDB) ListUserGrants(username string) ([]string, error) {
+	rows, err := p.db.Query(`
+		SELECT cluster_name FROM yaf_user_grants WHERE username = $1 ORDER BY cluster_name
+	`, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user grants: %w", err)
+	}
+	defer rows.Close()
+
+	clusters := []string{}
+	for rows.Next() {
+		var cluster string
+		if err := rows.Scan(&cluster); err != nil {
+			return nil, fmt.Errorf("failed to scan grant: %w", err)
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// SetUserGrants 替换用户的集群授权
+func (p *PostgresDB) SetUserGrants(username string, clusters []string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM yaf_user_grants WHERE username = $1", username); err != nil {
+		return fmt.Errorf("failed to clear user grants: %w", err)
+	}
+	for _, cluster := range clusters {
+		if _, err := tx.Exec(`
+			INSERT INTO yaf_user_grants (username, cluster_name) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, username, cluster); err != nil {
+			return fmt.Errorf("failed to grant cluster %s: %w", cluster, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit user grants: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// 变更类型
+const (
+	OpAdded     = "added"
+	OpRemoved   = "removed"
+	OpChanged   = "changed"
+	OpReordered = "reordered" // 数组元素相同但顺序不同
+)
+
+// Change 单个字段的变更
+type Change struct {
+	Path    string        `json:"path"`              // 字段路径，如 capture.idle_timeout
+	Op      string        `json:"op"`                // added / removed / changed / reordered
+	Old     interface{}   `json:"old,omitempty"`     // 变更前的值
+	New     interface{}   `json:"new,omitempty"`     // 变更后的值
+	Added   []interface{} `json:"added,omitempty"`   // 数组中新增的元素
+	Removed []interface{} `json:"removed,omitempty"` // 数组中移除的元素
+}
+
+// JSON 比较两个 JSON 文档，返回按路径排序的字段级变更；任一文档为空视为空对象
+func JSON(before, after []byte) ([]Change, error) {
+	a, err := decode(before)
+	if err != nil {
+		return nil, fmt.Errorf("invalid before json: %w", err)
+	}
+	b, err := decode(after)
+	if err != nil {
+		return nil, fmt.Errorf("invalid after json: %w", err)
+	}
+
+	changes := []Change{}
+	walk("", a, b, &changes)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// Values 比较两个可序列化为 JSON 的值
+func Values(before, after interface{}) ([]Change, error) {
+	a, err := json.Marshal(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(after)
+	if err != nil {
+		return nil, err
+	}
+	return JSON(a, b)
+}
+
+// decode 解析 JSON 文档
+func decode(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return map[string]interface{}{}, nil
+	}
+	return v, nil
+}
+
+// walk 递归比较对象，非对象值作为叶子整体比较
+func walk(path string, a, b interface{}, changes *[]Change) {
+	am, aIsMap := a.(map[string]interface{})
+	bm, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		for key, av := range am {
+			bv, ok := bm[key]
+			if !ok {
+				*changes = append(*changes, Change{Path: join(path, key), Op: OpRemoved, Old: av})
+				continue
+			}
+			walk(join(path, key), av, bv, changes)
+		}
+		for key, bv := range bm {
+			if _, ok := am[key]; !ok {
+				*changes = append(*changes, Change{Path: join(path, key), Op: OpAdded, New: bv})
+			}
+		}
+		return
+	}
+
+	if reflect.DeepEqual(a, b) || (isEmpty(a) && isEmpty(b)) {
+		return
+	}
+
+	// 数组：列出增删的元素，元素相同仅顺序变化时标记为 reordered
+	as, aIsSlice := a.([]interface{})
+	bs, bIsSlice := b.([]interface{})
+	if aIsSlice && bIsSlice {
+		added, removed := elementDiff(as, bs)
+		op := OpChanged
+		if len(added) == 0 && len(removed) == 0 {
+			op = OpReordered
+		}
+		*changes = append(*changes, Change{Path: path, Op: op, Old: a, New: b, Added: added, Removed: removed})
+		return
+	}
+
+	*changes = append(*changes, Change{Path: path, Op: OpChanged, Old: a, New: b})
+}
+
+// elementDiff 按多重集合语义计算数组增删的元素，保持各自原有顺序
+func elementDiff(a, b []interface{}) (added, removed []interface{}) {
+	count := make(map[string]int)
+	for _, v := range a {
+		count[elementKey(v)]++
+	}
+	for _, v := range b {
+		key := elementKey(v)
+		if count[key] > 0 {
+			count[key]--
+			continue
+		}
+		added = append(added, v)
+	}
+
+	count = make(map[string]int)
+	for _, v := range b {
+		count[elementKey(v)]++
+	}
+	for _, v := range a {
+		key := elementKey(v)
+		if count[key] > 0 {
+			count[key]--
+			continue
+		}
+		removed = append(removed, v)
+	}
+	return added, removed
+}
+
+// isEmpty 判断值是否为 null 或空数组（两者视为相同）
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.([]interface{})
+	return ok && len(s) == 0
+}
+
+// elementKey 数组元素的比较键
+func elementKey(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// join 拼接字段路径
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,159 @@
+// Package events 进程内的实时事件广播：配置保存、回滚与删除、ZooKeeper 连接状态、Agent 上线与离线，
+// 由 GET /api/v1/events 以 Server-Sent Events 推送给前端与自动化脚本
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// 事件类型
+const (
+	TypeConfigSaved      = models.EventConfigSaved      // 配置、配置档层或标签选择层保存了新版本
+	TypeConfigRolledBack = models.EventConfigRolledBack // 配置回滚到历史版本
+	TypeConfigDeleted    = models.EventConfigDeleted    // 配置或标签选择层被删除
+	TypeZKState          = "zk.state"                   // 后端与 ZooKeeper 的连接状态变化
+	TypeAgentOnline      = "agent.online"               // config-agent 上线
+	TypeAgentOffline     = "agent.offline"              // config-agent 离线
+)
+
+// Event 一条实时事件，ID 在进程内单调递增，用于断线重连时补发（Last-Event-ID）
+type Event struct {
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// ConfigChange config.saved / config.rolled_back / config.deleted 事件内容
+type ConfigChange struct {
+	Scope       models.ConfigScope `json:"scope"`
+	ClusterName string             `json:"cluster_name,omitempty"`
+	NodeID      string             `json:"node_id,omitempty"`
+	Name        string             `json:"name,omitempty"` // 标签选择层名称
+	Version     int                `json:"version"`
+	RollbackTo  int                `json:"rollback_to,omitempty"` // 回滚的目标历史版本
+	Author      string             `json:"author"`
+}
+
+// ZKState zk.state 事件内容
+type ZKState struct {
+	State     string `json:"state"`
+	Connected bool   `json:"connected"`
+}
+
+// Agent agent.online / agent.offline 事件内容
+type Agent struct {
+	ClusterName  string `json:"cluster_name"`
+	NodeID       string `json:"node_id"`
+	Hostname     string `json:"hostname,omitempty"`
+	AgentVersion string `json:"agent_version,omitempty"`
+}
+
+// Config 事件广播配置
+type Config struct {
+	Backlog int // 保留最近的事件数，用于断线重连时补发
+	Buffer  int // 每个订阅者的缓冲事件数，写满（客户端读取过慢）时断开该订阅者
+}
+
+// Subscription 一个事件流订阅者。C 被关闭表示订阅者读取过慢已被断开，客户端应携带 Last-Event-ID 重连
+type Subscription struct {
+	C  <-chan Event
+	ch chan Event
+}
+
+// Hub 事件广播：发布不会阻塞，只在本进程内广播，多个后端实例各自推送自己观察到的事件
+type Hub struct {
+	config  Config
+	logger  *zap.Logger
+	mu      sync.Mutex
+	lastID  uint64
+	backlog []Event // 最近的事件，按 ID 递增
+	subs    map[*Subscription]struct{}
+}
+
+// New 创建事件广播
+func New(cfg Config, logger *zap.Logger) *Hub {
+	if cfg.Backlog <= 0 {
+		cfg.Backlog = 256
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 64
+	}
+	return &Hub{
+		config: cfg,
+		logger: logger,
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish 广播事件
+func (h *Hub) Publish(eventType string, data interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event := Event{ID: h.lastID, Type: eventType, Time: time.Now(), Data: data}
+	h.backlog = append(h.backlog, event)
+	if len(h.backlog) > h.config.Backlog {
+		h.backlog = h.backlog[len(h.backlog)-h.config.Backlog:]
+	}
+
+	for sub := range h.subs {
+		select {
+		case sub.ch <- event:
+		default:
+			h.logger.Warn("event subscriber too slow, disconnecting", zap.Uint64("event_id", event.ID))
+			delete(h.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// PublishChange 广播配置变更。注册为数据库的变更监听器，覆盖所有写入配置的途径
+func (h *Hub) PublishChange(change *models.ConfigChange) {
+	h.Publish(change.EventType(), ConfigChange{
+		Scope:       change.Scope,
+		ClusterName: change.ClusterName,
+		NodeID:      change.NodeID,
+		Name:        change.Name,
+		Version:     change.Version,
+		RollbackTo:  change.RollbackTo,
+		Author:      change.Author,
+	})
+}
+
+// Subscribe 订阅之后发布的事件，同时返回缓存中 ID 大于 lastID 的事件供补发；lastID 为 0
+// 或大于当前最大 ID（例如后端重启前的 ID）时不补发
+func (h *Hub) Subscribe(lastID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan Event, h.config.Buffer)
+	sub := &Subscription{C: ch, ch: ch}
+	h.subs[sub] = struct{}{}
+
+	var missed []Event
+	if lastID > 0 && lastID <= h.lastID {
+		for _, event := range h.backlog {
+			if event.ID > lastID {
+				missed = append(missed, event)
+			}
+		}
+	}
+	return sub, missed
+}
+
+// Unsubscribe 取消订阅
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/yf-web/shared/yafconfig"
+)
+
+// AgentInfo Agent 在 ZooKeeper 在线临时节点中登记的运行信息
+type AgentInfo = yafconfig.AgentInfo
+
+// ApplyState Agent 最近一次应用配置的结果（合并的各级版本、生成文件的哈希、重启结果）
+type ApplyState = yafconfig.ApplyState
+
+// 配置应用结果
+const (
+	ApplyApplied = yafconfig.ApplyApplied
+	ApplyFailed  = yafconfig.ApplyFailed
+)
+
+// Agent 状态筛选
+const (
+	AgentOnline  = "online"
+	AgentOffline = "offline"
+)
+
+// Agent 后端观察到的 config-agent，离线后记录保留，last_seen 为最后一次观察到在线的时间
+type Agent struct {
+	ClusterName  string      `json:"cluster_name"`
+	NodeID       string      `json:"node_id"`
+	Hostname     string      `json:"hostname"`
+	AgentVersion string      `json:"agent_version"`
+	Interface    string      `json:"interface"`
+	StartedAt    time.Time   `json:"started_at"`
+	Online       bool        `json:"online"`
+	FirstSeen    time.Time   `json:"first_seen"`
+	LastSeen     time.Time   `json:"last_seen"`
+	Apply        *ApplyState `json:"apply"` // 最近一次应用配置的结果，尚未上报时为 null
+}
+
+// AgentStatus 节点清单记录上附带的 Agent 状态
+type AgentStatus struct {
+	Online       bool      `json:"online"`
+	Hostname     string    `json:"hostname"`
+	AgentVersion string    `json:"agent_version"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// AgentFilter Agent 查询条件
+type AgentFilter struct {
+	ClusterName string
+	Status      string // online / offline，空表示全部
+}
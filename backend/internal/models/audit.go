@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 审计结果
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEntry 审计日志记录
+type AuditEntry struct {
+	ID          int64           `json:"id"`
+	Actor       string          `json:"actor"`
+	SourceIP    string          `json:"source_ip"`
+	Method      string          `json:"method"`
+	Endpoint    string          `json:"endpoint"`
+	Scope       string          `json:"scope,omitempty"`
+	ClusterName string          `json:"cluster_name,omitempty"`
+	NodeID      string          `json:"node_id,omitempty"`
+	Status      int             `json:"status"`
+	Outcome     string          `json:"outcome"`
+	Message     string          `json:"message,omitempty"`
+	Before      json.RawMessage `json:"before,omitempty"`
+	After       json.RawMessage `json:"after,omitempty"`
+	Diff        json.RawMessage `json:"diff,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// AuditFilter 审计日志查询条件，零值字段不参与过滤
+type AuditFilter struct {
+	Actor       string
+	Scope       string
+	ClusterName string
+	NodeID      string
+	Outcome     string
+	Since       time.Time
+	Until       time.Time
+	Limit       int
+	Offset      int
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/yf-web/shared/yafconfig"
+)
+
+// 灰度发布状态
+const (
+	CanaryRunning     = "running"      // 已发布到灰度节点，等待确认
+	CanaryVerified    = "verified"     // 灰度节点均已成功应用，等待手动全量
+	CanaryPromoting   = "promoting"    // 正在解除其余节点的固定版本
+	CanaryPromoted    = "promoted"     // 已全量发布
+	CanaryRollingBack = "rolling_back" // 已保存回滚版本，等待发布后解除固定版本
+	CanaryRolledBack  = "rolled_back"  // 已回滚
+)
+
+// CanarySelector 灰度节点的选择条件：先按标签筛选（为空表示全部节点），再按比例抽取
+type CanarySelector struct {
+	Labels  map[string]string `json:"labels,omitempty"`
+	Percent int               `json:"percent,omitempty"` // 1-100，0 表示筛选出的全部节点
+}
+
+// Canary 灰度发布：新版本先只对灰度节点生效，其余节点通过 ZooKeeper 中的固定版本节点停留在基准版本，
+// 灰度节点确认成功后全量（解除固定），失败或超时则回滚
+type Canary struct {
+	ID              int64          `json:"id"`
+	Scope           ConfigScope    `json:"scope"` // global / cluster
+	ClusterName     string         `json:"cluster_name,omitempty"`
+	Version         int            `json:"version"`                    // 灰度的新版本
+	BaseVersion     int            `json:"base_version"`               // 其余节点固定的版本，0 表示此前没有配置
+	RollbackVersion int            `json:"rollback_version,omitempty"` // 回滚时保存的版本
+	Selector        CanarySelector `json:"selector"`
+	AutoPromote     bool           `json:"auto_promote"`
+	Timeout         int            `json:"timeout_seconds"` // 等待灰度节点确认的时间
+	State           string         `json:"state"`
+	Reason          string         `json:"reason,omitempty"`   // 回滚原因
+	DraftID         int64          `json:"draft_id,omitempty"` // 从审批通过的灰度草稿开始时为草稿 ID
+	CreatedAt       time.Time      `json:"created_at"`
+	CreatedBy       string         `json:"created_by"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	UpdatedBy       string         `json:"updated_by"`
+	Deadline        time.Time      `json:"deadline"`
+	CanaryCount     int            `json:"canary_count"`  // 灰度节点数
+	HoldoutCount    int            `json:"holdout_count"` // 固定在基准版本的节点数
+	Nodes           []*CanaryNode  `json:"nodes,omitempty"`
+}
+
+// Active 灰度是否尚未结束
+func (c *Canary) Active() bool {
+	return c.State != CanaryPromoted && c.State != CanaryRolledBack
+}
+
+// CanaryGuard 灰度发布期间写在该级配置旁的守护节点，使开始灰度后才出现的节点同样停留在基准版本
+type CanaryGuard = yafconfig.CanaryGuard
+
+// CanaryNodeKey 灰度节点在 CanaryGuard.Nodes 中的写法
+func CanaryNodeKey(cluster, nodeID string) string {
+	return yafconfig.CanaryNodeKey(cluster, nodeID)
+}
+
+// CanaryNode 参与灰度的节点
+type CanaryNode struct {
+	ClusterName string `json:"cluster_name"`
+	NodeID      string `json:"node_id"`
+	Canary      bool   `json:"canary"` // true 为灰度节点，false 为固定在基准版本的节点
+	Online      bool   `json:"online"`
+	State       string `json:"state"` // 灰度节点对新版本的应用状态（latest / pending / failed / unknown）
+	Error       string `json:"error,omitempty"`
+}
@@ -1,47 +1,28 @@
 package models
 
-import "time"
-
-// CaptureConfig 采集配置
-type CaptureConfig struct {
-	Interface      string `json:"interface"`       // 网卡名称，如 eth0
-	IPFIXPort      int    `json:"ipfix_port"`      // IPFIX 输出端口，如 18000
-	IdleTimeout    int    `json:"idle_timeout"`    // 空闲超时（秒）
-	ActiveTimeout  int    `json:"active_timeout"`  // 活跃超时（秒）
-	StatsInterval  int    `json:"stats_interval"`  // 统计输出间隔（秒）
-	EnableAppLabel bool   `json:"enable_applabel"` // 启用应用识别
-	EnableDPI      bool   `json:"enable_dpi"`      // 启用 DPI
-	MaxPayload     int    `json:"max_payload"`     // 最大载荷字节数
-}
-
-// FilterConfig 过滤配置
-type FilterConfig struct {
-	IPWhitelist []string `json:"ip_whitelist"`
-	IPBlacklist []string `json:"ip_blacklist"`
-	SrcPorts    []int    `json:"src_ports"`
-	DstPorts    []int    `json:"dst_ports"`
-	BPFFilter   string   `json:"bpf_filter"`
-}
-
-// OutputConfig 输出配置
-type OutputConfig struct {
-	Fields []string `json:"fields"`
-}
+import (
+	"time"
 
-// StatusReportConfig 状态上报配置
-type StatusReportConfig struct {
-	StatusReportURL         string `json:"status_report_url"`          // 状态上报 URL，例如 "http://example.com/api/uploadStatus"
-	StatusReportIntervalSec int    `json:"status_report_interval_sec"` // 状态上报间隔（秒），默认 60
-	UUID                    string `json:"uuid"`                       // 容器主机名，如果为空则从环境变量 HOSTNAME 获取
-}
+	"github.com/yf-web/shared/yafconfig"
+)
 
-// YafConfig YAF 完整配置
-type YafConfig struct {
-	Capture      CaptureConfig      `json:"capture"`
-	Filter       FilterConfig       `json:"filter"`
-	Output       OutputConfig       `json:"output"`
-	StatusReport StatusReportConfig `json:"status_report"`
-}
+// 配置模型与默认值与 config-agent 共享（见 shared/yafconfig），保证两端合并语义一致
+type (
+	// CaptureConfig 采集配置
+	CaptureConfig = yafconfig.CaptureConfig
+	// FilterConfig 过滤配置
+	FilterConfig = yafconfig.FilterConfig
+	// OutputConfig 输出配置
+	OutputConfig = yafconfig.OutputConfig
+	// StatusReportConfig 状态上报配置
+	StatusReportConfig = yafconfig.StatusReportConfig
+	// YafConfig YAF 完整配置
+	YafConfig = yafconfig.YafConfig
+	// Overlay 某一级的覆盖配置（缺省继承、给值覆盖、null 清除），数据库与 ZooKeeper 中均保存该格式
+	Overlay = yafconfig.Overlay
+	// DocumentMeta 发布到 ZooKeeper 的配置文档元信息
+	DocumentMeta = yafconfig.Meta
+)
 
 // ConfigScope 配置作用范围
 type ConfigScope string
@@ -62,6 +43,40 @@ type ConfigRecord struct {
 	ConfigJSON  string      `json:"config_json" db:"config_json"`
 	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
 	CreatedBy   string      `json:"created_by" db:"created_by"`
+	Deleted     bool        `json:"deleted,omitempty" db:"deleted"` // 删除标记版本
+	// 以下字段仅用于配置历史中尚未发布的定时变更（见 Schedule），此时 Version 为 0
+	Scheduled  bool       `json:"scheduled,omitempty"`
+	ScheduleID int64      `json:"schedule_id,omitempty"`
+	PublishAt  *time.Time `json:"publish_at,omitempty"`
+	// RollbackTo 保存回滚版本时为回滚的目标版本，只用于登记变更（见 ConfigChange），不写入数据库
+	RollbackTo int `json:"-"`
+}
+
+// ConfigChange 一次提交写入的配置变更：全局、集群、节点配置的新版本（含删除标记），
+// 重新合并的配置档层（Scope 为 profile），或标签选择层的新版本（Scope 为 selector）。
+// 数据库在事务提交后交给变更监听器，用于发送通知与推送实时事件
+type ConfigChange struct {
+	Scope       ConfigScope
+	ClusterName string // 集群、节点配置与配置档层所属集群，或标签选择层限定的集群
+	NodeID      string
+	Name        string // 标签选择层名称
+	Version     int
+	RollbackTo  int  // 回滚的目标版本
+	Deleted     bool // 删除标记版本，或已删除的标签选择层
+	Author      string
+	Before      string // 变更前的配置，此前没有配置或已删除时为空
+	After       string // 变更后的配置，删除时为空
+}
+
+// EventType 变更对应的通知与实时事件类型
+func (c *ConfigChange) EventType() string {
+	switch {
+	case c.Deleted:
+		return EventConfigDeleted
+	case c.RollbackTo > 0:
+		return EventConfigRolledBack
+	}
+	return EventConfigSaved
 }
 
 // SupportedFields YAF 支持的所有输出字段
@@ -102,40 +117,56 @@ var FieldLabels = map[string]string{
 
 // DefaultConfig 默认配置
 func DefaultConfig() *YafConfig {
-	return &YafConfig{
-		Capture: CaptureConfig{
-			Interface:      "eth0",
-			IPFIXPort:      18000,
-			IdleTimeout:    60,
-			ActiveTimeout:  60,
-			StatsInterval:  300,
-			EnableAppLabel: true,
-			EnableDPI:      false,
-			MaxPayload:     1024,
-		},
-		Filter: FilterConfig{
-			IPWhitelist: []string{},
-			IPBlacklist: []string{},
-			SrcPorts:    []int{},
-			DstPorts:    []int{},
-			BPFFilter:   "ip and not port 22",
-		},
-		Output: OutputConfig{
-			Fields: []string{
-				"flowStartMilliseconds",
-				"flowEndMilliseconds",
-				"sourceIPv4Address",
-				"destinationIPv4Address",
-				"sourceTransportPort",
-				"destinationTransportPort",
-				"protocolIdentifier",
-				"silkAppLabel",
-			},
-		},
-		StatusReport: StatusReportConfig{
-			StatusReportURL:         "",
-			StatusReportIntervalSec: 60,
-			UUID:                    "",
-		},
-	}
+	return yafconfig.DefaultConfig()
+}
+
+// OverlayFormat 当前覆盖配置的格式版本
+const OverlayFormat = yafconfig.OverlayFormat
+
+// ParseOverlay 解析覆盖配置
+func ParseOverlay(data []byte) (Overlay, error) {
+	return yafconfig.ParseOverlay(data)
+}
+
+// LegacyOverlay 将旧格式的完整配置转换为等价的覆盖配置
+func LegacyOverlay(cfg *YafConfig) Overlay {
+	return yafconfig.LegacyOverlay(cfg)
+}
+
+// EncodeDocument 生成发布到 ZooKeeper 的配置文档
+func EncodeDocument(o Overlay, meta DocumentMeta) ([]byte, error) {
+	return yafconfig.EncodeDocument(o, meta)
+}
+
+// DecodeDocument 解析 ZooKeeper 中的配置文档（兼容旧格式）
+func DecodeDocument(data []byte) (Overlay, error) {
+	return yafconfig.DecodeDocument(data)
+}
+
+// DecodeDocumentMeta 读取配置文档中的元信息
+func DecodeDocumentMeta(data []byte) DocumentMeta {
+	return yafconfig.DecodeMeta(data)
+}
+
+// ResolveConfig 从默认配置开始依次应用各级覆盖配置，与 config-agent 使用同一套合并逻辑
+func ResolveConfig(layers ...Overlay) *YafConfig {
+	return yafconfig.Resolve(layers...)
+}
+
+// DefaultLayer 字段来源为默认配置
+const DefaultLayer = yafconfig.DefaultLayer
+
+// ResolveConfigWithProvenance 合并各级配置，并返回每个字段（路径 → 层级下标）的来源
+func ResolveConfigWithProvenance(layers ...Overlay) (*YafConfig, map[string]int) {
+	return yafconfig.ResolveWithProvenance(layers...)
+}
+
+// HasPlaceholder 判断字段值中是否含有 ${node.id} 等占位符，占位符由 Agent 在生成配置文件时展开
+func HasPlaceholder(s string) bool {
+	return yafconfig.HasPlaceholder(s)
+}
+
+// CheckPlaceholders 检查配置字符串字段中占位符的写法与变量名
+func CheckPlaceholders(cfg *YafConfig) error {
+	return yafconfig.CheckPlaceholders(cfg)
 }
@@ -0,0 +1,97 @@
+package models
+
+import "time"
+
+// 草稿状态
+const (
+	DraftOpen      = "draft"     // 编辑中，尚未提交评审
+	DraftInReview  = "in_review" // 等待审批
+	DraftRejected  = "rejected"  // 被驳回，作者修改后重新提交
+	DraftApproved  = "approved"  // 灰度草稿审批通过，等待开始灰度发布
+	DraftPublished = "published" // 审批通过，已保存为新版本并发布
+	DraftClosed    = "closed"    // 已关闭
+)
+
+// 草稿时间线中的动作
+const (
+	DraftActionComment = "comment"
+	DraftActionUpdate  = "update"
+	DraftActionSubmit  = "submit"
+	DraftActionApprove = "approve"
+	DraftActionReject  = "reject"
+	DraftActionPublish = "publish"
+	DraftActionClose   = "close"
+)
+
+// Draft 尚未发布的配置变更：保存在数据库中但不产生配置版本，提交评审后由作者以外的用户审批，
+// 审批数达到 RequiredApprovals 时保存为新版本并发布到 ZooKeeper。灰度草稿审批通过后不直接发布，
+// 由 POST /api/v1/canaries（draft_id）以灰度发布上线，使需要审批的配置同样可以灰度
+type Draft struct {
+	ID                int64         `json:"id"`
+	Scope             ConfigScope   `json:"scope"`
+	ClusterName       string        `json:"cluster_name,omitempty"`
+	NodeID            string        `json:"node_id,omitempty"`
+	Title             string        `json:"title"`
+	Description       string        `json:"description,omitempty"`
+	ConfigJSON        string        `json:"config_json"`
+	Deleted           bool          `json:"deleted,omitempty"` // 删除该级配置（集群、节点）
+	Canary            bool          `json:"canary,omitempty"`  // 灰度草稿（全局、集群）
+	BaseVersion       int           `json:"base_version"`      // 草稿所基于的版本，-1 表示不检查
+	State             string        `json:"state"`
+	RequiredApprovals int           `json:"required_approvals"` // 提交评审时按评审策略确定
+	Approvals         []string      `json:"approvals"`          // 已审批的用户
+	Version           int           `json:"version,omitempty"`  // 发布后的配置版本
+	CreatedAt         time.Time     `json:"created_at"`
+	CreatedBy         string        `json:"created_by"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+	UpdatedBy         string        `json:"updated_by"`
+	Comments          []*DraftEvent `json:"comments,omitempty"`
+}
+
+// Editable 草稿当前能否由作者修改
+func (d *Draft) Editable() bool {
+	return d.State == DraftOpen || d.State == DraftInReview || d.State == DraftRejected
+}
+
+// Record 草稿发布时要保存的配置版本
+func (d *Draft) Record() *ConfigRecord {
+	return &ConfigRecord{
+		Scope:       d.Scope,
+		ClusterName: d.ClusterName,
+		NodeID:      d.NodeID,
+		ConfigJSON:  d.ConfigJSON,
+		CreatedBy:   d.CreatedBy,
+		Deleted:     d.Deleted,
+	}
+}
+
+// DraftEvent 草稿时间线中的一条记录：评论、提交、审批、驳回等
+type DraftEvent struct {
+	ID        int64     `json:"id"`
+	DraftID   int64     `json:"draft_id"`
+	Author    string    `json:"author"`
+	Action    string    `json:"action"`
+	Body      string    `json:"body,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DraftFilter 草稿列表的筛选条件，空值表示不筛选
+type DraftFilter struct {
+	State       string
+	Scope       ConfigScope
+	ClusterName string
+	NodeID      string
+	CreatedBy   string
+	Limit       int
+}
+
+// ReviewPolicy 各级配置发布前需要的审批数，0 表示可以直接保存发布
+type ReviewPolicy map[ConfigScope]int
+
+// Required 某一级配置需要的审批数
+func (p ReviewPolicy) Required(scope ConfigScope) int {
+	if n := p[scope]; n > 0 {
+		return n
+	}
+	return 0
+}
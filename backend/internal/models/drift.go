@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/yf-web/backend/internal/diff"
+)
+
+// 配置漂移类型
+const (
+	DriftMissing  = "missing"  // 数据库中有配置，ZooKeeper 中没有对应节点
+	DriftMismatch = "mismatch" // ZooKeeper 中的内容与数据库最新版本不一致
+	DriftExtra    = "extra"    // ZooKeeper 中有配置节点，数据库中没有对应配置（只报告，不删除）
+)
+
+// Drift 一处数据库与 ZooKeeper 不一致的配置
+type Drift struct {
+	Kind        string        `json:"kind"`
+	Scope       ConfigScope   `json:"scope"`
+	ClusterName string        `json:"cluster_name,omitempty"`
+	NodeID      string        `json:"node_id,omitempty"`
+	Path        string        `json:"path"`                 // ZooKeeper 路径
+	DBVersion   int           `json:"db_version,omitempty"` // 数据库最新版本
+	ZKVersion   int           `json:"zk_version,omitempty"` // ZooKeeper 文档 _meta.version，旧格式或被手工修改时可能为 0
+	Changes     []diff.Change `json:"changes,omitempty"`    // ZooKeeper → 数据库 的字段级差异
+	Repaired    bool          `json:"repaired,omitempty"`   // 已按数据库内容修复
+	Error       string        `json:"error,omitempty"`      // 检查或修复失败的原因
+}
+
+// DriftReport 一次漂移检查的结果
+type DriftReport struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Checked   int       `json:"checked"` // 检查的配置数
+	Drifts    []*Drift  `json:"drifts"`
+}
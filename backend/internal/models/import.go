@@ -0,0 +1,33 @@
+package models
+
+// ZooKeeper 导入结果
+const (
+	ImportImported = "imported" // 已创建 v1 记录
+	ImportPending  = "pending"  // 演练模式下将被导入
+	ImportExists   = "exists"   // 数据库中已有该级配置，跳过
+	ImportInvalid  = "invalid"  // 无法解析或未通过校验，跳过
+)
+
+// ImportCreatedBy 导入记录的创建人
+const ImportCreatedBy = "zk-import"
+
+// ImportItem 单个配置节点的导入结果
+type ImportItem struct {
+	Scope       ConfigScope `json:"scope"`
+	ClusterName string      `json:"cluster_name,omitempty"`
+	NodeID      string      `json:"node_id,omitempty"`
+	Path        string      `json:"path"`
+	Status      string      `json:"status"`
+	Version     int         `json:"version,omitempty"` // 导入后的版本，已存在时为数据库当前版本
+	Overlay     Overlay     `json:"overlay,omitempty"` // 将要或已经导入的覆盖配置
+	Error       string      `json:"error,omitempty"`
+}
+
+// ImportReport 一次导入的结果
+type ImportReport struct {
+	DryRun   bool          `json:"dry_run"`
+	Imported int           `json:"imported"` // 已导入（演练模式下为将导入）的数量
+	Skipped  int           `json:"skipped"`  // 已存在的数量
+	Invalid  int           `json:"invalid"`  // 校验失败的数量
+	Items    []*ImportItem `json:"items"`
+}
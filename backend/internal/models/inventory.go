@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// InventoryMeta 集群与节点的描述信息
+type InventoryMeta struct {
+	Description string            `json:"description"`
+	Owner       string            `json:"owner"`
+	Labels      map[string]string `json:"labels"` // 如 site=bj、rack=a03、nic=x710
+}
+
+// ConfigSummary 某一级配置的最新版本
+type ConfigSummary struct {
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by"`
+}
+
+// Cluster 集群清单记录
+type Cluster struct {
+	Name string `json:"name"`
+	InventoryMeta
+	CreatedAt       time.Time      `json:"created_at"`
+	CreatedBy       string         `json:"created_by"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	UpdatedBy       string         `json:"updated_by"`
+	Archived        bool           `json:"archived"`
+	ArchivedAt      *time.Time     `json:"archived_at,omitempty"`
+	ArchivedBy      string         `json:"archived_by,omitempty"`
+	Config          *ConfigSummary `json:"config"`           // 集群配置的最新版本，尚无配置时为 null
+	NodeCount       int            `json:"node_count"`       // 登记的节点数
+	ConfiguredNodes int            `json:"configured_nodes"` // 有节点配置的节点数
+}
+
+// Node 节点清单记录
+type Node struct {
+	ClusterName string `json:"cluster_name"`
+	NodeID      string `json:"node_id"`
+	InventoryMeta
+	CreatedAt time.Time      `json:"created_at"`
+	CreatedBy string         `json:"created_by"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	UpdatedBy string         `json:"updated_by"`
+	Config    *ConfigSummary `json:"config"` // 节点配置的最新版本，尚无配置时为 null
+	Agent     *AgentStatus   `json:"agent"`  // 节点上 Agent 的在线状态，从未观察到时为 null
+}
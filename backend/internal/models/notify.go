@@ -0,0 +1,157 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yf-web/backend/internal/diff"
+)
+
+// 通知事件类型
+const (
+	EventConfigSaved      = "config.saved"       // 配置、配置档层或标签选择层保存了新版本
+	EventConfigRolledBack = "config.rolled_back" // 配置回滚到历史版本
+	EventConfigDeleted    = "config.deleted"     // 配置或标签选择层被删除（写入删除标记）
+	EventPublishFailed    = "publish.failed"     // 写入 ZooKeeper 失败（后台仍会重试）
+	EventSettingsSaved    = "settings.saved"     // 系统设置被修改
+	EventTest             = "test"               // 手动发送的测试通知，只投递给指定的订阅
+)
+
+// NotifyEvents 可订阅的事件类型
+var NotifyEvents = []string{EventConfigSaved, EventConfigRolledBack, EventConfigDeleted, EventPublishFailed, EventSettingsSaved}
+
+// 通知渠道
+const (
+	ChannelWebhook = "webhook" // HTTP POST JSON，带 HMAC-SHA256 签名
+	ChannelEmail   = "email"   // SMTP 邮件
+)
+
+// 投递状态
+const (
+	DeliveryPending   = "pending"   // 等待投递，失败后按退避间隔重试
+	DeliveryDelivered = "delivered" // 已投递
+	DeliveryFailed    = "failed"    // 重试次数用尽，可手动重试
+)
+
+// Subscription 通知订阅：Events、Scope、ClusterName 为空时不按该项过滤。
+// 指定了 Scope 或 ClusterName 时，不带配置范围的事件（如系统设置）不会投递
+type Subscription struct {
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Events      []string    `json:"events"`
+	Scope       ConfigScope `json:"scope"`
+	ClusterName string      `json:"cluster_name"`
+	Channel     string      `json:"channel"` // webhook / email
+	Target      string      `json:"target"`  // webhook URL，或以逗号分隔的收件人地址
+	Secret      string      `json:"-"`       // webhook 签名密钥
+	HasSecret   bool        `json:"has_secret"`
+	Enabled     bool        `json:"enabled"`
+	CreatedAt   time.Time   `json:"created_at"`
+	CreatedBy   string      `json:"created_by"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	UpdatedBy   string      `json:"updated_by"`
+}
+
+// Matches 判断订阅是否接收某个事件
+func (s *Subscription) Matches(event *NotifyEvent) bool {
+	if !s.Enabled {
+		return false
+	}
+	if len(s.Events) > 0 {
+		found := false
+		for _, eventType := range s.Events {
+			if eventType == event.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if s.Scope != "" && s.Scope != event.Scope {
+		return false
+	}
+	if s.ClusterName != "" && s.ClusterName != event.ClusterName {
+		return false
+	}
+	return true
+}
+
+// NotifyEvent 通知事件，以 JSON 作为 webhook 的请求体
+type NotifyEvent struct {
+	Type            string        `json:"type"`
+	Scope           ConfigScope   `json:"scope,omitempty"`
+	ClusterName     string        `json:"cluster_name,omitempty"`
+	NodeID          string        `json:"node_id,omitempty"`
+	Name            string        `json:"name,omitempty"`             // 标签选择层名称
+	Version         int           `json:"version,omitempty"`          // 保存的新版本
+	PreviousVersion int           `json:"previous_version,omitempty"` // 保存前的最新版本
+	RollbackTo      int           `json:"rollback_to,omitempty"`      // 回滚的目标版本
+	Author          string        `json:"author"`
+	Diff            []diff.Change `json:"diff,omitempty"` // 相对保存前版本（或修改前设置）的字段级差异
+	Error           string        `json:"error,omitempty"`
+	Time            time.Time     `json:"time"`
+}
+
+// Summary 事件的一句话描述，用作邮件主题
+func (e *NotifyEvent) Summary() string {
+	switch e.Type {
+	case EventConfigSaved:
+		return fmt.Sprintf("%s已保存 v%d（%s）", e.target(), e.Version, e.Author)
+	case EventConfigRolledBack:
+		return fmt.Sprintf("%s已回滚到 v%d，新版本 v%d（%s）", e.target(), e.RollbackTo, e.Version, e.Author)
+	case EventConfigDeleted:
+		return fmt.Sprintf("%s已删除 v%d（%s）", e.target(), e.Version, e.Author)
+	case EventPublishFailed:
+		return fmt.Sprintf("%s v%d 写入 ZooKeeper 失败", e.target(), e.Version)
+	case EventSettingsSaved:
+		return fmt.Sprintf("系统设置已修改（%s）", e.Author)
+	case EventTest:
+		return fmt.Sprintf("测试通知（%s）", e.Author)
+	}
+	return e.Type
+}
+
+// target 事件涉及的配置
+func (e *NotifyEvent) target() string {
+	switch e.Scope {
+	case ScopeGlobal:
+		return "全局配置"
+	case ScopeCluster:
+		return fmt.Sprintf("集群 %s 配置", e.ClusterName)
+	case ScopeNode:
+		return fmt.Sprintf("节点 %s/%s 配置", e.ClusterName, e.NodeID)
+	case ScopeProfile:
+		if e.NodeID == "" {
+			return fmt.Sprintf("集群 %s 配置档层", e.ClusterName)
+		}
+		return fmt.Sprintf("节点 %s/%s 配置档层", e.ClusterName, e.NodeID)
+	case ScopeSelector:
+		return fmt.Sprintf("标签选择层 %s", e.Name)
+	}
+	return "配置"
+}
+
+// Delivery 一个事件对一个订阅的投递记录，Payload 为事件 JSON
+type Delivery struct {
+	ID               int64      `json:"id"`
+	SubscriptionID   int64      `json:"subscription_id"`
+	SubscriptionName string     `json:"subscription_name"`
+	Channel          string     `json:"channel"`
+	EventType        string     `json:"event_type"`
+	Payload          string     `json:"payload"`
+	Status           string     `json:"status"` // pending / delivered / failed
+	Attempts         int        `json:"attempts"`
+	LastError        string     `json:"error,omitempty"`
+	NextAttemptAt    time.Time  `json:"next_attempt_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	DeliveredAt      *time.Time `json:"delivered_at,omitempty"`
+}
+
+// DeliveryFilter 投递记录查询条件
+type DeliveryFilter struct {
+	SubscriptionID int64
+	Status         string
+	Limit          int
+}
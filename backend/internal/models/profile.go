@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/yf-web/shared/yafconfig"
+)
+
+// ScopeProfile 配置档层，只出现在生效配置的层级与字段来源中
+const ScopeProfile ConfigScope = "profile"
+
+// ProfileRef 配置档的某个版本
+type ProfileRef = yafconfig.ProfileRef
+
+// ComposeOverlays 将依次应用的多级覆盖配置合并为一个等价的覆盖配置
+func ComposeOverlays(layers ...Overlay) Overlay {
+	return yafconfig.Compose(layers...)
+}
+
+// Profile 可复用的命名配置档：一份覆盖配置（部分 YafConfig），有独立的版本历史。
+// 挂载到集群或节点后，在全局配置之后、集群配置之前按挂载顺序合并
+type Profile struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Version     int       `json:"version"`     // 最新版本
+	ConfigJSON  string    `json:"config_json"` // 最新版本的覆盖配置
+	Attachments int       `json:"attachments"` // 挂载该配置档的集群与节点数
+	CreatedAt   time.Time `json:"created_at"`
+	CreatedBy   string    `json:"created_by"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UpdatedBy   string    `json:"updated_by"`
+}
+
+// ProfileVersion 配置档的一个版本
+type ProfileVersion struct {
+	Name       string    `json:"name"`
+	Version    int       `json:"version"`
+	ConfigJSON string    `json:"config_json"`
+	CreatedAt  time.Time `json:"created_at"`
+	CreatedBy  string    `json:"created_by"`
+}
+
+// ProfileLayer 集群（NodeID 为空）或节点挂载的配置档按顺序合并而成的配置档层，由 publisher 写入 ZooKeeper。
+// 挂载的配置档或其内容每次变化都会重新合并，Version 随之递增；只保留最新内容
+type ProfileLayer struct {
+	ClusterName   string       `json:"cluster_name"`
+	NodeID        string       `json:"node_id,omitempty"`
+	Version       int          `json:"version"`
+	ConfigJSON    string       `json:"config_json"`
+	Profiles      []ProfileRef `json:"profiles"` // 合并的配置档及其版本
+	Status        string       `json:"status"`   // pending / synced / failed
+	Attempts      int          `json:"attempts"`
+	LastError     string       `json:"error,omitempty"`
+	NextAttemptAt time.Time    `json:"next_attempt_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	SyncedAt      *time.Time   `json:"synced_at,omitempty"`
+}
+
+// ProfileAttachment 挂载了某个配置档的集群（NodeID 为空）或节点
+type ProfileAttachment struct {
+	ClusterName string `json:"cluster_name"`
+	NodeID      string `json:"node_id,omitempty"`
+	Position    int    `json:"position"`          // 在挂载列表中的位置，从 0 开始
+	Published   int    `json:"published_version"` // 配置档层中合并的该配置档版本，尚未合并时为 0
+	SyncStatus  string `json:"sync_status"`       // 配置档层写入 ZooKeeper 的状态
+}
+
+// ProfileInheritance 节点 Agent 上报的、生效配置中继承的某个配置档版本
+type ProfileInheritance struct {
+	ClusterName string    `json:"cluster_name"`
+	NodeID      string    `json:"node_id"`
+	Version     int       `json:"version"`
+	Online      bool      `json:"online"`
+	AppliedAt   time.Time `json:"applied_at"`
+}
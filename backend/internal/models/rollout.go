@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// 节点的发布状态
+const (
+	RolloutLatest  = "latest"  // 已应用各级配置的最新版本
+	RolloutPending = "pending" // 尚未应用最新版本（包括 Agent 离线）
+	RolloutFailed  = "failed"  // 最近一次应用配置失败
+	RolloutUnknown = "unknown" // 尚未收到 Agent 上报的应用结果
+)
+
+// RolloutVersions 节点合并的各级配置版本，该级没有配置时为 0
+type RolloutVersions struct {
+	Global  int `json:"global"`
+	Cluster int `json:"cluster"`
+	Node    int `json:"node"`
+}
+
+// NodeRollout 单个节点的发布状态
+type NodeRollout struct {
+	NodeID     string           `json:"node_id"`
+	Registered bool             `json:"registered"` // 是否已登记到节点清单
+	Online     bool             `json:"online"`
+	State      string           `json:"state"`
+	Expected   RolloutVersions  `json:"expected"` // 数据库中各级的最新版本
+	Applied    *RolloutVersions `json:"applied"`  // Agent 上报的已应用版本，尚未上报时为 null
+	ConfigHash string           `json:"config_hash,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	AppliedAt  *time.Time       `json:"applied_at,omitempty"`
+}
+
+// Rollout 集群的发布进度：已登记的节点与观察到 Agent 的节点按状态计数
+type Rollout struct {
+	ClusterName string         `json:"cluster_name"`
+	Total       int            `json:"total"`
+	Latest      int            `json:"latest"`
+	Pending     int            `json:"pending"`
+	Failed      int            `json:"failed"`
+	Unknown     int            `json:"unknown"`
+	Nodes       []*NodeRollout `json:"nodes,omitempty"` // 集群列表中省略
+}
+
+// Add 计入一个节点
+func (r *Rollout) Add(node *NodeRollout) {
+	r.Total++
+	switch node.State {
+	case RolloutLatest:
+		r.Latest++
+	case RolloutPending:
+		r.Pending++
+	case RolloutFailed:
+		r.Failed++
+	default:
+		r.Unknown++
+	}
+}
+
+// SetApplied 记录 Agent 上报的应用结果并判定状态：应用失败为 failed，
+// 已应用的版本与期望版本一致为 latest，否则为 pending；apply 为 nil 时为 unknown
+func (n *NodeRollout) SetApplied(apply *ApplyState) {
+	if apply == nil {
+		n.State = RolloutUnknown
+		return
+	}
+	n.Applied = &RolloutVersions{Global: apply.GlobalVersion, Cluster: apply.ClusterVersion, Node: apply.NodeVersion}
+	n.ConfigHash = apply.ConfigHash
+	n.Error = apply.Error
+	appliedAt := apply.AppliedAt
+	n.AppliedAt = &appliedAt
+
+	switch {
+	case apply.Status == ApplyFailed:
+		n.State = RolloutFailed
+	case *n.Applied == n.Expected:
+		n.State = RolloutLatest
+	default:
+		n.State = RolloutPending
+	}
+}
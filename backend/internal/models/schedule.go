@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// 定时发布状态
+const (
+	ScheduleScheduled = "scheduled" // 等待发布
+	SchedulePublished = "published" // 已保存为新版本并发布
+	ScheduleFailed    = "failed"    // 到期时无法保存（配置已被修改、正在灰度发布等），不再重试
+	ScheduleCanceled  = "canceled"  // 已取消
+)
+
+// Schedule 定时发布：准备好的某一级配置，到 publish_at 时由后台任务保存为新版本并写入 ZooKeeper。
+// 保存时检查 base_version，期间配置被他人修改则发布失败，避免覆盖他人的变更
+type Schedule struct {
+	ID          int64       `json:"id"`
+	Scope       ConfigScope `json:"scope"`
+	ClusterName string      `json:"cluster_name,omitempty"`
+	NodeID      string      `json:"node_id,omitempty"`
+	ConfigJSON  string      `json:"config_json"`
+	BaseVersion int         `json:"base_version"` // 准备变更时所基于的版本，-1 表示不检查
+	PublishAt   time.Time   `json:"publish_at"`
+	State       string      `json:"state"`
+	Version     int         `json:"version,omitempty"` // 发布后的配置版本
+	Error       string      `json:"error,omitempty"`   // 发布失败的原因
+	CreatedAt   time.Time   `json:"created_at"`
+	CreatedBy   string      `json:"created_by"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	UpdatedBy   string      `json:"updated_by"`
+}
+
+// Record 定时发布要保存的配置版本
+func (s *Schedule) Record() *ConfigRecord {
+	return &ConfigRecord{
+		Scope:       s.Scope,
+		ClusterName: s.ClusterName,
+		NodeID:      s.NodeID,
+		ConfigJSON:  s.ConfigJSON,
+		CreatedBy:   s.CreatedBy,
+	}
+}
+
+// ScheduleFilter 定时发布列表的筛选条件，空值表示不筛选
+type ScheduleFilter struct {
+	State       string
+	Scope       ConfigScope
+	ClusterName string
+	NodeID      string
+	Limit       int
+}
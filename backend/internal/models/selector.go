@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/yf-web/shared/yafconfig"
+)
+
+// ScopeSelector 标签选择层，只出现在生效配置的层级与字段来源中
+const ScopeSelector ConfigScope = "selector"
+
+// SelectorRule 标签选择层的选择条件与优先级，写在发布到 ZooKeeper 的文档的 _meta 中
+type SelectorRule = yafconfig.Selector
+
+// SelectorLess 匹配的标签选择层的合并顺序：优先级从低到高，相同时按名称
+func SelectorLess(a, b *SelectorRule) bool {
+	return yafconfig.SelectorLess(a, b)
+}
+
+// Selector 标签选择层：带有全部 Labels 的节点（ClusterName 非空时仅限该集群）在集群配置之后、
+// 节点配置之前合并 ConfigJSON，多个匹配的选择层按 Priority 从低到高合并。每次修改 Version 递增
+type Selector struct {
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	ClusterName   string            `json:"cluster_name"` // 为空时对所有集群生效
+	Labels        map[string]string `json:"labels"`
+	Priority      int               `json:"priority"`
+	Version       int               `json:"version"`
+	ConfigJSON    string            `json:"config_json"`
+	Deleted       bool              `json:"-"`
+	Status        string            `json:"status"` // 写入 ZooKeeper 的状态：pending / synced / failed
+	Attempts      int               `json:"attempts"`
+	LastError     string            `json:"error,omitempty"`
+	NextAttemptAt time.Time         `json:"next_attempt_at"`
+	SyncedAt      *time.Time        `json:"synced_at,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	CreatedBy     string            `json:"created_by"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	UpdatedBy     string            `json:"updated_by"`
+}
+
+// Rule 选择层的选择条件
+func (s *Selector) Rule() *SelectorRule {
+	return &SelectorRule{Name: s.Name, Cluster: s.ClusterName, Labels: s.Labels, Priority: s.Priority}
+}
+
+// NodeLabels 节点清单中的标签及其修改版本，由 publisher 写入 ZooKeeper 供 Agent 匹配标签选择层
+type NodeLabels struct {
+	ClusterName string
+	NodeID      string
+	Labels      map[string]string
+	Version     int
+}
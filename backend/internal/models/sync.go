@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// ZooKeeper 同步状态
+const (
+	SyncPending = "pending" // 已保存，等待写入 ZooKeeper
+	SyncSynced  = "synced"  // 已写入 ZooKeeper
+	SyncFailed  = "failed"  // 写入失败，后台仍会按退避间隔重试
+)
+
+// OutboxEntry 待发布到 ZooKeeper 的配置版本，与配置记录在同一事务中写入
+type OutboxEntry struct {
+	ID            int64
+	ConfigID      int64
+	Scope         ConfigScope
+	ClusterName   string
+	NodeID        string
+	Version       int
+	ConfigJSON    string
+	Deleted       bool // 删除标记版本：从 ZooKeeper 删除对应节点
+	Status        string
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	SyncedAt      *time.Time
+}
+
+// SyncState 某一级配置最新版本的同步状态
+type SyncState struct {
+	Status    string     `json:"status"`               // pending / synced / failed
+	Version   int        `json:"version"`              // 对应的配置版本
+	Attempts  int        `json:"attempts"`             // 已尝试写入的次数
+	Error     string     `json:"error,omitempty"`      // 最近一次失败的原因
+	NextRetry *time.Time `json:"next_retry,omitempty"` // 失败后下次重试的时间
+	SyncedAt  *time.Time `json:"synced_at,omitempty"`  // 写入成功的时间
+}
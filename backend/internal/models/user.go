@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Session 登录会话
+type Session struct {
+	Token     string    `json:"token"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Role 用户角色
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // 只读
+	RoleOperator Role = "operator" // 可修改被授权集群的集群/节点配置
+	RoleAdmin    Role = "admin"    // 可修改全部配置与系统设置
+)
+
+// Valid 判断角色是否合法
+func (r Role) Valid() bool {
+	return r == RoleViewer || r == RoleOperator || r == RoleAdmin
+}
+
+// User 用户
+type User struct {
+	ID                 int64      `json:"id"`
+	Username           string     `json:"username"`
+	Role               Role       `json:"role"`
+	Clusters           []string   `json:"clusters"` // 被授权的集群（仅 operator 生效）
+	Disabled           bool       `json:"disabled"`
+	MustChangePassword bool       `json:"must_change_password"`
+	FailedAttempts     int        `json:"failed_attempts"`
+	LockedUntil        *time.Time `json:"locked_until,omitempty"`
+	LastLoginAt        *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// LockoutPolicy 登录失败锁定策略
+type LockoutPolicy struct {
+	MaxAttempts int           // 连续失败多少次后锁定，0 表示不锁定
+	Duration    time.Duration // 锁定时长
+}
+
+// CanEdit 判断用户能否修改指定范围的配置
+func (u *User) CanEdit(scope ConfigScope, clusterName string) bool {
+	switch u.Role {
+	case RoleAdmin:
+		return true
+	case RoleOperator:
+		if scope == ScopeGlobal {
+			return false
+		}
+		for _, c := range u.Clusters {
+			if c == clusterName {
+				return true
+			}
+		}
+	}
+	return false
+}
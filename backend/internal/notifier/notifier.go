@@ -0,0 +1,361 @@
+// Package notifier 将配置事件投递给通知订阅：HTTP webhook（HMAC-SHA256 签名）与 SMTP 邮件，失败按退避间隔重试
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/diff"
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// batchSize 每轮最多投递的记录数
+	batchSize = 50
+	// minBackoff 首次失败后的重试间隔，之后逐次翻倍
+	minBackoff = 10 * time.Second
+	// lease 领取的记录在此期间不会被其他实例重复投递，应大于一次投递的最长耗时
+	lease = 2 * time.Minute
+	// errorBodyLimit webhook 返回非 2xx 时记录的响应体字节数
+	errorBodyLimit = 512
+)
+
+// SignatureHeader webhook 请求体的签名：sha256=<hex(HMAC-SHA256(secret, body))>，未设置密钥时不发送
+const SignatureHeader = "X-Yaf-Signature"
+
+// SMTPConfig 邮件服务器配置，Host 为空时邮件渠道不可用
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // 为空时不认证
+	Password string
+	From     string
+}
+
+// Config 通知任务配置
+type Config struct {
+	Interval    time.Duration // 检查待投递记录的周期
+	MaxAttempts int           // 投递失败后最多尝试的次数，用尽后标记为失败
+	MaxBackoff  time.Duration // 失败重试间隔的上限
+	Timeout     time.Duration // webhook 请求超时
+	SMTP        SMTPConfig
+}
+
+// Notifier 登记配置事件并投递 yaf_deliveries 中的记录。事件发生时为每个匹配的订阅登记一条记录，
+// 由后台任务投递；登记失败只记录日志，不影响触发事件的操作
+type Notifier struct {
+	db     *db.PostgresDB
+	config Config
+	client *http.Client
+	logger *zap.Logger
+	kick   chan struct{}
+}
+
+// New 创建通知任务
+func New(database *db.PostgresDB, cfg Config, logger *zap.Logger) *Notifier {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.MaxBackoff < minBackoff {
+		cfg.MaxBackoff = 30 * time.Minute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.SMTP.Port == 0 {
+		cfg.SMTP.Port = 25
+	}
+	return &Notifier{
+		db:     database,
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: logger,
+		kick:   make(chan struct{}, 1),
+	}
+}
+
+// EmailEnabled 是否配置了邮件服务器
+func (n *Notifier) EmailEnabled() bool {
+	return n.config.SMTP.Host != ""
+}
+
+// Run 周期性投递到期的记录，直到 ctx 结束
+func (n *Notifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		n.deliverDue()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-n.kick:
+		}
+	}
+}
+
+// Kick 唤醒后台任务立即投递一轮
+func (n *Notifier) Kick() {
+	select {
+	case n.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Notify 为接收该事件的订阅登记投递记录，并唤醒后台任务
+func (n *Notifier) Notify(event *models.NotifyEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	count, err := n.db.EnqueueNotification(event)
+	if err != nil {
+		n.logger.Error("failed to enqueue notification", zap.String("event", event.Type), zap.Error(err))
+		return
+	}
+	if count > 0 {
+		n.Kick()
+	}
+}
+
+// NotifyChange 登记配置变更事件（保存、回滚或删除），diff 为相对变更前的字段级差异。
+// 注册为数据库的变更监听器，覆盖所有写入配置的途径（接口、草稿、定时发布、灰度、配置档与标签选择层）
+func (n *Notifier) NotifyChange(change *models.ConfigChange) {
+	event := &models.NotifyEvent{
+		Type:        change.EventType(),
+		Scope:       change.Scope,
+		ClusterName: change.ClusterName,
+		NodeID:      change.NodeID,
+		Name:        change.Name,
+		Version:     change.Version,
+		RollbackTo:  change.RollbackTo,
+		Author:      change.Author,
+	}
+	if change.Version > 1 {
+		event.PreviousVersion = change.Version - 1
+	}
+	if changes, err := diff.JSON([]byte(change.Before), []byte(change.After)); err == nil {
+		event.Diff = changes
+	}
+	n.Notify(event)
+}
+
+// Test 向指定的订阅发送一条测试通知，返回投递记录的 ID
+func (n *Notifier) Test(subscription *models.Subscription, author string) (int64, error) {
+	event := &models.NotifyEvent{Type: models.EventTest, Author: author, Time: time.Now()}
+	id, err := n.db.EnqueueDelivery(subscription.ID, event)
+	if err != nil {
+		return 0, err
+	}
+	n.Kick()
+	return id, nil
+}
+
+// deliverDue 投递所有到期的记录
+func (n *Notifier) deliverDue() {
+	deliveries, err := n.db.ClaimDueDeliveries(batchSize, lease)
+	if err != nil {
+		n.logger.Error("failed to claim due deliveries", zap.Error(err))
+		return
+	}
+
+	subscriptions := map[int64]*models.Subscription{}
+	for _, delivery := range deliveries {
+		subscription, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			subscription, err = n.db.GetSubscription(delivery.SubscriptionID)
+			if err != nil {
+				n.logger.Error("failed to get subscription", zap.Int64("subscription", delivery.SubscriptionID), zap.Error(err))
+				continue
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+		}
+		if subscription == nil {
+			// 订阅刚被删除，投递记录随之级联删除
+			continue
+		}
+		n.deliver(subscription, delivery)
+	}
+}
+
+// deliver 投递一条记录并登记结果
+func (n *Notifier) deliver(subscription *models.Subscription, delivery *models.Delivery) {
+	logger := n.logger.With(
+		zap.Int64("delivery", delivery.ID),
+		zap.Int64("subscription", subscription.ID),
+		zap.String("channel", subscription.Channel),
+		zap.String("event", delivery.EventType),
+	)
+
+	var err error
+	switch subscription.Channel {
+	case models.ChannelWebhook:
+		err = n.sendWebhook(subscription, delivery)
+	case models.ChannelEmail:
+		err = n.sendEmail(subscription, delivery)
+	default:
+		err = fmt.Errorf("unknown channel %q", subscription.Channel)
+	}
+
+	if err == nil {
+		if err := n.db.MarkDeliveryDelivered(delivery.ID); err != nil {
+			logger.Error("failed to mark delivery delivered", zap.Error(err))
+		}
+		logger.Info("notification delivered")
+		return
+	}
+
+	giveUp := delivery.Attempts+1 >= n.config.MaxAttempts
+	next := time.Now().Add(n.backoff(delivery.Attempts))
+	if giveUp {
+		logger.Error("notification delivery failed, giving up", zap.Int("attempts", delivery.Attempts+1), zap.Error(err))
+	} else {
+		logger.Warn("notification delivery failed", zap.Int("attempts", delivery.Attempts+1), zap.Time("next_attempt", next), zap.Error(err))
+	}
+	if err := n.db.MarkDeliveryFailed(delivery.ID, err.Error(), next, giveUp); err != nil {
+		logger.Error("failed to mark delivery failed", zap.Error(err))
+	}
+}
+
+// backoff 第 attempts+1 次失败后的重试间隔
+func (n *Notifier) backoff(attempts int) time.Duration {
+	delay := minBackoff
+	for i := 0; i < attempts && delay < n.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > n.config.MaxBackoff {
+		delay = n.config.MaxBackoff
+	}
+	return delay
+}
+
+// Sign 计算 webhook 请求体的签名
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhook 以 POST 发送事件 JSON，2xx 视为成功
+func (n *Notifier) sendWebhook(subscription *models.Subscription, delivery *models.Delivery) error {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, subscription.Target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "yaf-config-service")
+	req.Header.Set("X-Yaf-Event", delivery.EventType)
+	req.Header.Set("X-Yaf-Delivery", strconv.FormatInt(delivery.ID, 10))
+	if subscription.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(subscription.Secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, errorBodyLimit))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(snippet)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// sendEmail 以纯文本邮件发送事件摘要、字段级差异与完整的事件 JSON
+func (n *Notifier) sendEmail(subscription *models.Subscription, delivery *models.Delivery) error {
+	smtpConfig := n.config.SMTP
+	if smtpConfig.Host == "" {
+		return fmt.Errorf("smtp is not configured")
+	}
+	var event models.NotifyEvent
+	if err := json.Unmarshal([]byte(delivery.Payload), &event); err != nil {
+		return fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	var recipients []string
+	for _, address := range strings.Split(subscription.Target, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", smtpConfig.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[YAF 配置中心] "+event.Summary()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(emailBody(&event, delivery.Payload), "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if smtpConfig.Username != "" {
+		auth = smtp.PlainAuth("", smtpConfig.Username, smtpConfig.Password, smtpConfig.Host)
+	}
+	addr := net.JoinHostPort(smtpConfig.Host, strconv.Itoa(smtpConfig.Port))
+	if err := smtp.SendMail(addr, auth, smtpConfig.From, recipients, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// emailBody 邮件正文
+func emailBody(event *models.NotifyEvent, payload string) string {
+	var b strings.Builder
+	b.WriteString(event.Summary() + "\n\n")
+	fmt.Fprintf(&b, "时间：%s\n", event.Time.Local().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "操作人：%s\n", event.Author)
+	if event.Error != "" {
+		fmt.Fprintf(&b, "错误：%s\n", event.Error)
+	}
+	if len(event.Diff) > 0 {
+		b.WriteString("\n变更：\n")
+		for _, change := range event.Diff {
+			fmt.Fprintf(&b, "  %s %s", change.Op, change.Path)
+			switch {
+			case change.Added != nil || change.Removed != nil:
+				fmt.Fprintf(&b, " +%s -%s", compact(change.Added), compact(change.Removed))
+			case change.Old != nil || change.New != nil:
+				fmt.Fprintf(&b, ": %s → %s", compact(change.Old), compact(change.New))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	var indented bytes.Buffer
+	if json.Indent(&indented, []byte(payload), "", "  ") == nil {
+		b.WriteString("\n事件：\n" + indented.String() + "\n")
+	}
+	return b.String()
+}
+
+// compact 以紧凑 JSON 表示字段值
+func compact(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
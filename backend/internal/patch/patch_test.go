@@ -0,0 +1,165 @@
+package patch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testDoc = `{"capture":{"interface":"eth0","enable_dpi":true},"filter":{"src_ports":[53,123],"rules":[{"name":"a"}]},"a/b":{"c~d":1}}`
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+		err   string
+	}{
+		{
+			name:  "add nested field",
+			patch: `[{"op":"add","path":"/capture/max_payload","value":512}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"enable_dpi":true,"interface":"eth0","max_payload":512},"filter":{"rules":[{"name":"a"}],"src_ports":[53,123]}}`,
+		},
+		{
+			name:  "add replaces existing field",
+			patch: `[{"op":"add","path":"/capture/interface","value":"eth1"}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"enable_dpi":true,"interface":"eth1"},"filter":{"rules":[{"name":"a"}],"src_ports":[53,123]}}`,
+		},
+		{
+			name:  "add null value",
+			patch: `[{"op":"add","path":"/capture/interface","value":null}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"enable_dpi":true,"interface":null},"filter":{"rules":[{"name":"a"}],"src_ports":[53,123]}}`,
+		},
+		{
+			name:  "add inserts into array",
+			patch: `[{"op":"add","path":"/filter/src_ports/1","value":80}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"enable_dpi":true,"interface":"eth0"},"filter":{"rules":[{"name":"a"}],"src_ports":[53,80,123]}}`,
+		},
+		{
+			name:  "add appends to array",
+			patch: `[{"op":"add","path":"/filter/src_ports/-","value":80}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"enable_dpi":true,"interface":"eth0"},"filter":{"rules":[{"name":"a"}],"src_ports":[53,123,80]}}`,
+		},
+		{
+			name:  "add inside array element",
+			patch: `[{"op":"add","path":"/filter/rules/0/port","value":22}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"enable_dpi":true,"interface":"eth0"},"filter":{"rules":[{"name":"a","port":22}],"src_ports":[53,123]}}`,
+		},
+		{
+			name:  "add escaped pointer",
+			patch: `[{"op":"add","path":"/a~1b/c~0d","value":2}]`,
+			want:  `{"a/b":{"c~d":2},"capture":{"enable_dpi":true,"interface":"eth0"},"filter":{"rules":[{"name":"a"}],"src_ports":[53,123]}}`,
+		},
+		{
+			name:  "remove nested field",
+			patch: `[{"op":"remove","path":"/capture/enable_dpi"}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"interface":"eth0"},"filter":{"rules":[{"name":"a"}],"src_ports":[53,123]}}`,
+		},
+		{
+			name:  "remove array element",
+			patch: `[{"op":"remove","path":"/filter/src_ports/0"}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"enable_dpi":true,"interface":"eth0"},"filter":{"rules":[{"name":"a"}],"src_ports":[123]}}`,
+		},
+		{
+			name:  "remove inside array element",
+			patch: `[{"op":"remove","path":"/filter/rules/0/name"}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"enable_dpi":true,"interface":"eth0"},"filter":{"rules":[{}],"src_ports":[53,123]}}`,
+		},
+		{
+			name:  "replace nested field",
+			patch: `[{"op":"replace","path":"/capture/enable_dpi","value":false}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"enable_dpi":false,"interface":"eth0"},"filter":{"rules":[{"name":"a"}],"src_ports":[53,123]}}`,
+		},
+		{
+			name:  "replace array element",
+			patch: `[{"op":"replace","path":"/filter/src_ports/1","value":443}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"enable_dpi":true,"interface":"eth0"},"filter":{"rules":[{"name":"a"}],"src_ports":[53,443]}}`,
+		},
+		{
+			name:  "replace whole document",
+			patch: `[{"op":"replace","path":"","value":{"output":{}}}]`,
+			want:  `{"output":{}}`,
+		},
+		{
+			name:  "operations applied in order",
+			patch: `[{"op":"add","path":"/output","value":{}},{"op":"add","path":"/output/fields","value":["x"]},{"op":"replace","path":"/output/fields/0","value":"y"}]`,
+			want:  `{"a/b":{"c~d":1},"capture":{"enable_dpi":true,"interface":"eth0"},"filter":{"rules":[{"name":"a"}],"src_ports":[53,123]},"output":{"fields":["y"]}}`,
+		},
+		{name: "add missing parent", patch: `[{"op":"add","path":"/output/fields","value":[]}]`, err: "path 'output' not found"},
+		{name: "add array index out of range", patch: `[{"op":"add","path":"/filter/src_ports/3","value":1}]`, err: "array index 3 out of range"},
+		{name: "add missing value", patch: `[{"op":"add","path":"/capture/interface"}]`, err: "missing value"},
+		{name: "remove missing field", patch: `[{"op":"remove","path":"/capture/max_payload"}]`, err: "path 'max_payload' not found"},
+		{name: "remove whole document", patch: `[{"op":"remove","path":""}]`, err: "cannot remove the whole document"},
+		{name: "remove past array end", patch: `[{"op":"remove","path":"/filter/src_ports/2"}]`, err: "array index 2 out of range"},
+		{name: "replace missing field", patch: `[{"op":"replace","path":"/capture/max_payload","value":1}]`, err: "path 'max_payload' not found"},
+		{name: "pointer without slash", patch: `[{"op":"add","path":"capture","value":1}]`, err: "invalid json pointer 'capture'"},
+		{name: "negative index", patch: `[{"op":"remove","path":"/filter/src_ports/-1"}]`, err: "invalid array index '-1'"},
+		{name: "leading zero index", patch: `[{"op":"remove","path":"/filter/src_ports/01"}]`, err: "invalid array index '01'"},
+		{name: "non-numeric index", patch: `[{"op":"replace","path":"/filter/src_ports/x","value":1}]`, err: "invalid array index 'x'"},
+		{name: "append marker outside add", patch: `[{"op":"remove","path":"/filter/src_ports/-"}]`, err: "invalid array index '-'"},
+		{name: "path through scalar", patch: `[{"op":"add","path":"/capture/interface/name","value":1}]`, err: "path 'name' not found"},
+		{name: "failing operation reported", patch: `[{"op":"add","path":"/x","value":1},{"op":"remove","path":"/y"}]`, err: "operation 1 (remove /y)"},
+		{name: "unsupported op", patch: `[{"op":"merge","path":"/x"}]`, err: "unsupported op 'merge'"},
+		{name: "invalid patch", patch: `{"op":"add"}`, err: "invalid json patch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(testDoc), []byte(tt.patch))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("JSONPatch(%s) error = %v, want %q", tt.patch, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("JSONPatch(%s) unexpected error: %v", tt.patch, err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("JSONPatch(%s) = %s, want %s", tt.patch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONPatchTestOperation(t *testing.T) {
+	_, err := JSONPatch([]byte(testDoc), []byte(`[{"op":"test","path":"/capture/interface","value":"eth1"}]`))
+	if !errors.Is(err, ErrTestFailed) {
+		t.Fatalf("JSONPatch() error = %v, want %v", err, ErrTestFailed)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		err   string
+	}{
+		{name: "add nested field", doc: `{"a":{"b":1}}`, patch: `{"a":{"c":2}}`, want: `{"a":{"b":1,"c":2}}`},
+		{name: "replace nested field", doc: `{"a":{"b":1}}`, patch: `{"a":{"b":[1]}}`, want: `{"a":{"b":[1]}}`},
+		{name: "null removes nested field", doc: `{"a":{"b":1,"c":2}}`, patch: `{"a":{"b":null}}`, want: `{"a":{"c":2}}`},
+		{name: "creates missing object", doc: `{}`, patch: `{"a":{"b":{"c":1}}}`, want: `{"a":{"b":{"c":1}}}`},
+		{name: "empty document", doc: ``, patch: `{"a":1}`, want: `{"a":1}`},
+		{name: "non-object patch replaces", doc: `{"a":1}`, patch: `[1]`, want: `[1]`},
+		{name: "invalid document", doc: `{`, patch: `{}`, err: "invalid document"},
+		{name: "invalid patch", doc: `{}`, patch: `{`, err: "invalid merge patch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("MergePatch(%s, %s) error = %v, want %q", tt.doc, tt.patch, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MergePatch(%s, %s) unexpected error: %v", tt.doc, tt.patch, err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("MergePatch(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+			}
+		})
+	}
+}
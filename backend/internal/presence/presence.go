@@ -0,0 +1,195 @@
+// Package presence 根据 ZooKeeper 中的在线临时节点跟踪 config-agent 的在线状态，并记录到数据库
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/events"
+	"github.com/yf-web/backend/internal/models"
+	"github.com/yf-web/backend/internal/zk"
+	"go.uber.org/zap"
+)
+
+// Config 在线状态跟踪配置
+type Config struct {
+	Interval time.Duration // 没有变化时刷新 last_seen 的周期
+}
+
+// Tracker 监听 cluster/<cluster>/live 下的临时节点：子节点变化或节点内容变化（Agent 上报应用结果）时立即刷新，
+// 否则按周期刷新在线 Agent 的 last_seen。ZooKeeper 读取失败时不修改数据库中的状态
+type Tracker struct {
+	db      *db.PostgresDB
+	zk      *zk.Client
+	events  *events.Hub
+	config  Config
+	logger  *zap.Logger
+	watched map[string]bool              // 已注册 watch 且尚未触发的路径
+	online  map[string]*models.AgentInfo // 上一次观察到的在线 Agent，用于记录与广播上下线
+	fired   chan string                  // watch 触发的路径
+}
+
+// New 创建在线状态跟踪器
+func New(database *db.PostgresDB, zkClient *zk.Client, hub *events.Hub, cfg Config, logger *zap.Logger) *Tracker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	return &Tracker{
+		db:      database,
+		zk:      zkClient,
+		events:  hub,
+		config:  cfg,
+		logger:  logger,
+		watched: make(map[string]bool),
+		online:  make(map[string]*models.AgentInfo),
+		fired:   make(chan string, 64),
+	}
+}
+
+// Run 跟踪在线状态，直到 ctx 结束
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		t.refresh()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case path := <-t.fired:
+			delete(t.watched, path)
+		}
+		// 合并同时触发的多个 watch
+		for drained := false; !drained; {
+			select {
+			case path := <-t.fired:
+				delete(t.watched, path)
+			default:
+				drained = true
+			}
+		}
+	}
+}
+
+// refresh 读取所有集群的在线节点并写入数据库，同时为尚未监听的路径注册 watch
+func (t *Tracker) refresh() {
+	clusters, err := t.children(zk.ClusterPath)
+	if err != nil {
+		t.logger.Warn("failed to list clusters for agent presence", zap.Error(err))
+		return
+	}
+
+	agents := []*models.AgentInfo{}
+	for _, cluster := range clusters {
+		nodes, err := t.children(zk.GetLiveDir(cluster))
+		if err != nil {
+			t.logger.Warn("failed to list live agents", zap.String("cluster", cluster), zap.Error(err))
+			return
+		}
+		for _, node := range nodes {
+			agent, err := t.readAgent(cluster, node)
+			if err != nil {
+				t.logger.Warn("failed to read live agent", zap.String("cluster", cluster), zap.String("node", node), zap.Error(err))
+				return
+			}
+			if agent != nil {
+				agents = append(agents, agent)
+			}
+		}
+	}
+
+	if err := t.db.UpdateAgentPresence(agents, time.Now()); err != nil {
+		t.logger.Error("failed to update agent presence", zap.Error(err))
+		return
+	}
+	t.logTransitions(agents)
+}
+
+// readAgent 读取在线节点内容；节点已消失时返回 nil，内容无法解析时只保留集群与节点 ID
+func (t *Tracker) readAgent(cluster, node string) (*models.AgentInfo, error) {
+	data, err := t.data(zk.GetLivePath(cluster, node))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	agent := &models.AgentInfo{}
+	if err := json.Unmarshal(data, agent); err != nil {
+		t.logger.Warn("invalid live agent data", zap.String("cluster", cluster), zap.String("node", node), zap.Error(err))
+	}
+	agent.ClusterName = cluster
+	agent.NodeID = node
+	return agent, nil
+}
+
+// children 列出子节点。path 没有未触发的 watch 时同时注册 watch，触发后通知 Run 刷新；
+// 已有 watch 时只读取，避免重复注册的 watch 在 ZooKeeper 客户端中堆积
+func (t *Tracker) children(path string) ([]string, error) {
+	if t.watched[path] {
+		return t.zk.ListChildren(path)
+	}
+	children, ch, err := t.zk.WatchChildren(path)
+	if err != nil {
+		return nil, err
+	}
+	t.notify(path, ch)
+	return children, nil
+}
+
+// data 读取节点内容，注册 watch 的方式与 children 相同
+func (t *Tracker) data(path string) ([]byte, error) {
+	if t.watched[path] {
+		return t.zk.GetConfig(path)
+	}
+	data, ch, err := t.zk.WatchData(path)
+	if err != nil || ch == nil {
+		return nil, err
+	}
+	t.notify(path, ch)
+	return data, nil
+}
+
+// notify 记录 path 已注册 watch，watch 触发后通知 Run 刷新
+func (t *Tracker) notify(path string, ch <-chan zk.Event) {
+	t.watched[path] = true
+	go func() {
+		<-ch
+		t.fired <- path
+	}()
+}
+
+// logTransitions 记录并广播 Agent 上线与离线
+func (t *Tracker) logTransitions(agents []*models.AgentInfo) {
+	current := make(map[string]*models.AgentInfo, len(agents))
+	for _, agent := range agents {
+		key := agent.ClusterName + "/" + agent.NodeID
+		current[key] = agent
+		if t.online[key] == nil {
+			t.logger.Info("agent online",
+				zap.String("cluster", agent.ClusterName),
+				zap.String("node", agent.NodeID),
+				zap.String("hostname", agent.Hostname),
+				zap.String("version", agent.AgentVersion),
+			)
+			t.events.Publish(events.TypeAgentOnline, agentEvent(agent))
+		}
+	}
+	for key, agent := range t.online {
+		if current[key] == nil {
+			t.logger.Info("agent offline", zap.String("agent", key))
+			t.events.Publish(events.TypeAgentOffline, agentEvent(agent))
+		}
+	}
+	t.online = current
+}
+
+// agentEvent Agent 上下线事件内容
+func agentEvent(agent *models.AgentInfo) events.Agent {
+	return events.Agent{
+		ClusterName:  agent.ClusterName,
+		NodeID:       agent.NodeID,
+		Hostname:     agent.Hostname,
+		AgentVersion: agent.AgentVersion,
+	}
+}
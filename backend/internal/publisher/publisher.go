@@ -0,0 +1,443 @@
+// Package publisher 将数据库中登记的配置版本可靠地写入 ZooKeeper
+package publisher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"github.com/yf-web/backend/internal/notifier"
+	"github.com/yf-web/backend/internal/zk"
+	"go.uber.org/zap"
+)
+
+const (
+	// batchSize 每轮最多投递的条目数
+	batchSize = 100
+	// minBackoff 首次失败后的重试间隔，之后逐次翻倍
+	minBackoff = 2 * time.Second
+)
+
+// Config 发布任务配置
+type Config struct {
+	Interval   time.Duration // 检查待发布条目的周期
+	MaxBackoff time.Duration // 失败重试间隔的上限
+}
+
+// configStore 发布任务用到的 ZooKeeper 操作，由 *zk.Client 实现
+type configStore interface {
+	GetConfig(path string) ([]byte, error)
+	SetConfig(path string, data interface{}) error
+	CompareAndSet(path string, data []byte, shouldWrite func(current []byte) bool) (bool, error)
+	DeleteConfig(path string) error
+	DeleteRecursive(path string) error
+}
+
+// Publisher 投递 yaf_outbox 中的待发布条目：保存配置时登记的条目由保存请求立即尝试投递，
+// 失败的条目由后台任务按指数退避重试，直到写入成功。重新合并的配置档层（yaf_profile_layers）同样由它写入。
+// 条目首次写入失败（转为 failed）时登记 publish.failed 通知，之后的重试失败不再重复通知
+type Publisher struct {
+	db     *db.PostgresDB
+	zk     configStore
+	notify *notifier.Notifier
+	config Config
+	logger *zap.Logger
+	kick   chan struct{}
+	mu     sync.Mutex // 串行化投递，避免同一条目被并发写入
+}
+
+// New 创建发布任务
+func New(database *db.PostgresDB, zkClient *zk.Client, notify *notifier.Notifier, cfg Config, logger *zap.Logger) *Publisher {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.MaxBackoff < minBackoff {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	return &Publisher{
+		db:     database,
+		zk:     zkClient,
+		notify: notify,
+		config: cfg,
+		logger: logger,
+		kick:   make(chan struct{}, 1),
+	}
+}
+
+// Run 周期性投递到期的条目，直到 ctx 结束
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.publishDue()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.kick:
+		}
+	}
+}
+
+// Kick 唤醒后台任务立即检查一轮
+func (p *Publisher) Kick() {
+	select {
+	case p.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Publish 立即投递某一级配置尚未写入 ZooKeeper 的条目（忽略重试时间），返回最新的同步状态
+func (p *Publisher) Publish(scope models.ConfigScope, clusterName, nodeID string) (*models.SyncState, error) {
+	entries, err := p.db.GetPendingOutbox(scope, clusterName, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	p.deliverAll(entries)
+	return p.db.GetSyncState(scope, clusterName, nodeID)
+}
+
+// publishDue 投递到期的条目
+func (p *Publisher) publishDue() {
+	entries, err := p.db.GetDueOutbox(batchSize)
+	if err != nil {
+		p.logger.Error("failed to load outbox", zap.Error(err))
+		return
+	}
+	p.deliverAll(entries)
+
+	layers, err := p.db.GetDueProfileLayers(batchSize)
+	if err != nil {
+		p.logger.Error("failed to load profile layers", zap.Error(err))
+		return
+	}
+	p.deliverLayers(layers)
+
+	selectors, err := p.db.GetDueSelectors(batchSize)
+	if err != nil {
+		p.logger.Error("failed to load selectors", zap.Error(err))
+		return
+	}
+	p.deliverSelectors(selectors)
+
+	labels, err := p.db.GetUnpublishedLabels(batchSize)
+	if err != nil {
+		p.logger.Error("failed to load node labels", zap.Error(err))
+		return
+	}
+	p.deliverLabels(labels)
+	if len(entries) == batchSize || len(layers) == batchSize || len(selectors) == batchSize {
+		p.Kick()
+	}
+}
+
+// PublishLayer 立即写入一个刚合并的配置档层（忽略重试时间），返回最新的发布状态
+func (p *Publisher) PublishLayer(layer *models.ProfileLayer) (*models.ProfileLayer, error) {
+	p.deliverLayers([]*models.ProfileLayer{layer})
+	return p.db.GetProfileLayer(layer.ClusterName, layer.NodeID)
+}
+
+// deliverLayers 写入配置档层并记录结果；节点上已是同一或更新的版本时视为已完成
+func (p *Publisher) deliverLayers(layers []*models.ProfileLayer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, layer := range layers {
+		if err := p.deliverLayer(layer); err != nil {
+			next := time.Now().Add(p.backoff(layer.Attempts))
+			p.logger.Warn("failed to publish profile layer to zk",
+				zap.String("cluster", layer.ClusterName),
+				zap.String("node", layer.NodeID),
+				zap.Int("version", layer.Version),
+				zap.Int("attempts", layer.Attempts+1),
+				zap.Time("next_attempt", next),
+				zap.Error(err),
+			)
+			if err := p.db.MarkProfileLayerFailed(layer, err.Error(), next); err != nil {
+				p.logger.Error("failed to update profile layer", zap.Error(err))
+			}
+			if layer.Status != models.SyncFailed {
+				p.notifyFailed(&models.NotifyEvent{
+					Scope:       models.ScopeProfile,
+					ClusterName: layer.ClusterName,
+					NodeID:      layer.NodeID,
+					Version:     layer.Version,
+				}, err)
+			}
+			continue
+		}
+		if err := p.db.MarkProfileLayerSynced(layer); err != nil {
+			p.logger.Error("failed to update profile layer", zap.Error(err))
+		}
+	}
+}
+
+// deliverLayer 将配置档层写入 ZooKeeper，_meta 中附带合并的配置档及版本，Agent 应用后据此上报
+func (p *Publisher) deliverLayer(layer *models.ProfileLayer) error {
+	overlay, err := models.ParseOverlay([]byte(layer.ConfigJSON))
+	if err != nil {
+		return err
+	}
+	doc, err := models.EncodeDocument(overlay, models.DocumentMeta{Version: layer.Version, Profiles: layer.Profiles})
+	if err != nil {
+		return err
+	}
+	_, err = p.zk.CompareAndSet(ProfilesPath(layer.ClusterName, layer.NodeID), doc, func(current []byte) bool {
+		return models.DecodeDocumentMeta(current).Version < layer.Version
+	})
+	return err
+}
+
+// PublishSelector 立即写入刚修改或删除的标签选择层（忽略重试时间），返回最新的发布状态；
+// 已删除的选择层返回 nil
+func (p *Publisher) PublishSelector(selector *models.Selector) (*models.Selector, error) {
+	p.deliverSelectors([]*models.Selector{selector})
+	return p.db.GetSelector(selector.Name)
+}
+
+// deliverSelectors 写入或删除标签选择层并记录结果
+func (p *Publisher) deliverSelectors(selectors []*models.Selector) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, selector := range selectors {
+		if err := p.deliverSelector(selector); err != nil {
+			next := time.Now().Add(p.backoff(selector.Attempts))
+			p.logger.Warn("failed to publish selector to zk",
+				zap.String("selector", selector.Name),
+				zap.Int("version", selector.Version),
+				zap.Bool("deleted", selector.Deleted),
+				zap.Int("attempts", selector.Attempts+1),
+				zap.Time("next_attempt", next),
+				zap.Error(err),
+			)
+			if err := p.db.MarkSelectorFailed(selector, err.Error(), next); err != nil {
+				p.logger.Error("failed to update selector", zap.Error(err))
+			}
+			if selector.Status != models.SyncFailed {
+				p.notifyFailed(&models.NotifyEvent{
+					Scope:       models.ScopeSelector,
+					ClusterName: selector.ClusterName,
+					Name:        selector.Name,
+					Version:     selector.Version,
+				}, err)
+			}
+			continue
+		}
+		if err := p.db.MarkSelectorSynced(selector); err != nil {
+			p.logger.Error("failed to update selector", zap.Error(err))
+		}
+	}
+}
+
+// deliverSelector 将标签选择层写入 ZooKeeper，_meta 中附带选择条件与优先级，Agent 据此判断是否匹配；
+// 删除标记删除对应节点。节点上已是更新的版本时跳过
+func (p *Publisher) deliverSelector(selector *models.Selector) error {
+	path := zk.GetSelectorPath(selector.Name)
+	if selector.Deleted {
+		current, err := p.zk.GetConfig(path)
+		if err != nil {
+			return err
+		}
+		if current != nil && models.DecodeDocumentMeta(current).Version > selector.Version {
+			return nil
+		}
+		return p.zk.DeleteConfig(path)
+	}
+
+	overlay, err := models.ParseOverlay([]byte(selector.ConfigJSON))
+	if err != nil {
+		return err
+	}
+	doc, err := models.EncodeDocument(overlay, models.DocumentMeta{Version: selector.Version, Selector: selector.Rule()})
+	if err != nil {
+		return err
+	}
+	_, err = p.zk.CompareAndSet(path, doc, func(current []byte) bool {
+		return models.DecodeDocumentMeta(current).Version < selector.Version
+	})
+	return err
+}
+
+// deliverLabels 将节点清单中修改过的标签写入 ZooKeeper，供 Agent 匹配标签选择层。
+// 写入失败时停止本轮（通常是 ZooKeeper 不可用），下一轮重试
+func (p *Publisher) deliverLabels(labels []*models.NodeLabels) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, item := range labels {
+		if err := p.zk.SetConfig(zk.GetLabelsPath(item.ClusterName, item.NodeID), item.Labels); err != nil {
+			p.logger.Warn("failed to publish node labels to zk",
+				zap.String("cluster", item.ClusterName),
+				zap.String("node", item.NodeID),
+				zap.Error(err),
+			)
+			return
+		}
+		if err := p.db.MarkLabelsPublished(item); err != nil {
+			p.logger.Error("failed to update node labels", zap.Error(err))
+		}
+	}
+}
+
+// RemoveLabels 删除已从清单中删除的集群（nodeID 为空时删除整个集群）或节点在 ZooKeeper 中登记的标签
+func (p *Publisher) RemoveLabels(clusterName, nodeID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if nodeID == "" {
+		return p.zk.DeleteRecursive(zk.GetLabelsDir(clusterName))
+	}
+	return p.zk.DeleteConfig(zk.GetLabelsPath(clusterName, nodeID))
+}
+
+// deliverAll 按顺序投递条目并记录结果
+func (p *Publisher) deliverAll(entries []*models.OutboxEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, entry := range entries {
+		// 投递前重新读取最新版本：读取条目之后才提交的删除标记同样使旧条目作废
+		latest, err := p.db.LatestConfigVersion(entry.Scope, entry.ClusterName, entry.NodeID)
+		if err != nil {
+			p.logger.Error("failed to get latest config version", zap.Error(err))
+			continue
+		}
+		if err := p.deliver(entry, latest); err != nil {
+			next := time.Now().Add(p.backoff(entry.Attempts))
+			p.logger.Warn("failed to publish config to zk",
+				zap.String("scope", string(entry.Scope)),
+				zap.String("cluster", entry.ClusterName),
+				zap.String("node", entry.NodeID),
+				zap.Int("version", entry.Version),
+				zap.Int("attempts", entry.Attempts+1),
+				zap.Time("next_attempt", next),
+				zap.Error(err),
+			)
+			if err := p.db.MarkOutboxFailed(entry.ID, err.Error(), next); err != nil {
+				p.logger.Error("failed to update outbox", zap.Error(err))
+			}
+			if entry.Status != models.SyncFailed {
+				p.notifyFailed(&models.NotifyEvent{
+					Scope:       entry.Scope,
+					ClusterName: entry.ClusterName,
+					NodeID:      entry.NodeID,
+					Version:     entry.Version,
+				}, err)
+			}
+			continue
+		}
+		if err := p.db.MarkOutboxSynced(entry.ID); err != nil {
+			p.logger.Error("failed to update outbox", zap.Error(err))
+		}
+	}
+}
+
+// notifyFailed 登记 publish.failed 通知
+func (p *Publisher) notifyFailed(event *models.NotifyEvent, err error) {
+	event.Type = models.EventPublishFailed
+	event.Error = err.Error()
+	p.notify.Notify(event)
+}
+
+// deliver 将条目对应的配置写入 ZooKeeper；节点上已是同一或更新的版本时视为已完成，
+// 因此较早的条目在较新版本之后重试也不会覆盖新版本。latest 为数据库中该级配置的最新版本：
+// 条目已被更新的版本（包括删除标记）取代时直接视为已完成，否则删除后节点不存在，重试的旧条目会把它重新写回
+func (p *Publisher) deliver(entry *models.OutboxEntry, latest int) error {
+	if latest > entry.Version {
+		p.logger.Info("skipping superseded outbox entry",
+			zap.String("scope", string(entry.Scope)),
+			zap.String("cluster", entry.ClusterName),
+			zap.String("node", entry.NodeID),
+			zap.Int("version", entry.Version),
+			zap.Int("latest", latest),
+		)
+		return nil
+	}
+	if entry.Deleted {
+		return p.remove(entry)
+	}
+	overlay, err := models.ParseOverlay([]byte(entry.ConfigJSON))
+	if err != nil {
+		return err
+	}
+	doc, err := models.EncodeDocument(overlay, models.DocumentMeta{Version: entry.Version})
+	if err != nil {
+		return err
+	}
+	_, err = p.zk.CompareAndSet(ConfigPath(entry.Scope, entry.ClusterName, entry.NodeID), doc, func(current []byte) bool {
+		return models.DecodeDocumentMeta(current).Version < entry.Version
+	})
+	return err
+}
+
+// remove 删除标记：删除节点在 ZooKeeper 中的整个目录（集群则删除集群配置与各节点目录，
+// 保留 Agent 的在线临时节点），Agent 随之回退到上一级配置。节点上已是更新的版本（删除后又重新保存）时不删除
+func (p *Publisher) remove(entry *models.OutboxEntry) error {
+	path := ConfigPath(entry.Scope, entry.ClusterName, entry.NodeID)
+	current, err := p.zk.GetConfig(path)
+	if err != nil {
+		return err
+	}
+	if current != nil && models.DecodeDocumentMeta(current).Version > entry.Version {
+		return nil
+	}
+
+	switch entry.Scope {
+	case models.ScopeCluster:
+		if err := p.zk.DeleteRecursive(zk.GetNodesDir(entry.ClusterName)); err != nil {
+			return err
+		}
+		return p.zk.DeleteRecursive(path)
+	case models.ScopeNode:
+		return p.zk.DeleteRecursive(zk.GetNodeDir(entry.ClusterName, entry.NodeID))
+	}
+	return p.zk.DeleteConfig(path)
+}
+
+// backoff 第 attempts+1 次失败后的重试间隔
+func (p *Publisher) backoff(attempts int) time.Duration {
+	delay := minBackoff
+	for i := 0; i < attempts && delay < p.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.config.MaxBackoff {
+		delay = p.config.MaxBackoff
+	}
+	return delay
+}
+
+// ConfigPath 获取某一级配置在 ZooKeeper 中的路径
+func ConfigPath(scope models.ConfigScope, clusterName, nodeID string) string {
+	switch scope {
+	case models.ScopeGlobal:
+		return zk.GetGlobalConfigPath()
+	case models.ScopeCluster:
+		return zk.GetClusterConfigPath(clusterName)
+	case models.ScopeNode:
+		return zk.GetNodeConfigPath(clusterName, nodeID)
+	}
+	return ""
+}
+
+// ProfilesPath 获取集群（nodeID 为空）或节点的配置档层在 ZooKeeper 中的路径
+func ProfilesPath(clusterName, nodeID string) string {
+	if nodeID == "" {
+		return zk.GetClusterProfilesPath(clusterName)
+	}
+	return zk.GetNodeProfilesPath(clusterName, nodeID)
+}
+
+// ScopeOf ZooKeeper 配置节点对应的作用范围
+func ScopeOf(node zk.ConfigNode) models.ConfigScope {
+	switch {
+	case node.ClusterName == "":
+		return models.ScopeGlobal
+	case node.NodeID == "":
+		return models.ScopeCluster
+	}
+	return models.ScopeNode
+}
@@ -0,0 +1,157 @@
+package publisher
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yf-web/backend/internal/models"
+	"go.uber.org/zap"
+)
+
+// memStore 内存中的 ZooKeeper 节点，down 为 true 时模拟 ZooKeeper 不可用
+type memStore struct {
+	nodes map[string][]byte
+	down  bool
+}
+
+var errUnavailable = errors.New("zk: could not connect to a server")
+
+func (m *memStore) GetConfig(path string) ([]byte, error) {
+	if m.down {
+		return nil, errUnavailable
+	}
+	return m.nodes[path], nil
+}
+
+func (m *memStore) SetConfig(path string, data interface{}) error {
+	if m.down {
+		return errUnavailable
+	}
+	doc, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	m.nodes[path] = doc
+	return nil
+}
+
+func (m *memStore) CompareAndSet(path string, data []byte, shouldWrite func(current []byte) bool) (bool, error) {
+	if m.down {
+		return false, errUnavailable
+	}
+	if !shouldWrite(m.nodes[path]) {
+		return false, nil
+	}
+	m.nodes[path] = data
+	return true, nil
+}
+
+func (m *memStore) DeleteConfig(path string) error {
+	if m.down {
+		return errUnavailable
+	}
+	delete(m.nodes, path)
+	return nil
+}
+
+func (m *memStore) DeleteRecursive(path string) error {
+	if m.down {
+		return errUnavailable
+	}
+	for node := range m.nodes {
+		if node == path || strings.HasPrefix(node, path+"/") {
+			delete(m.nodes, node)
+		}
+	}
+	return nil
+}
+
+func nodeEntry(version int, config string, deleted bool) *models.OutboxEntry {
+	return &models.OutboxEntry{
+		Scope:       models.ScopeNode,
+		ClusterName: "bj-dc1",
+		NodeID:      "node-01",
+		Version:     version,
+		ConfigJSON:  config,
+		Deleted:     deleted,
+	}
+}
+
+func TestDeliverSkipsEntriesSupersededByDelete(t *testing.T) {
+	store := &memStore{nodes: map[string][]byte{}}
+	p := &Publisher{zk: store, logger: zap.NewNop()}
+	path := ConfigPath(models.ScopeNode, "bj-dc1", "node-01")
+
+	if err := p.deliver(nodeEntry(1, `{"capture":{"interface":"eth1"}}`, false), 1); err != nil {
+		t.Fatalf("deliver(v1) unexpected error: %v", err)
+	}
+
+	// v2 保存时 ZooKeeper 不可用，条目进入退避重试
+	store.down = true
+	failed := nodeEntry(2, `{"capture":{"interface":"eth2"}}`, false)
+	if err := p.deliver(failed, 2); err == nil {
+		t.Fatalf("deliver(v2) error = nil while zk is down")
+	}
+
+	// ZooKeeper 恢复后先投递 v3 删除标记，节点目录被删除
+	store.down = false
+	if err := p.deliver(nodeEntry(3, `{}`, true), 3); err != nil {
+		t.Fatalf("deliver(v3 tombstone) unexpected error: %v", err)
+	}
+	if _, ok := store.nodes[path]; ok {
+		t.Fatalf("node config still exists after tombstone")
+	}
+
+	// 之后重试的 v2 已被删除标记取代，不应重新写回
+	if err := p.deliver(failed, 3); err != nil {
+		t.Fatalf("deliver(v2 retry) unexpected error: %v", err)
+	}
+	if doc, ok := store.nodes[path]; ok {
+		t.Fatalf("superseded v2 recreated the deleted node config: %s", doc)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     int // ZooKeeper 中已有文档的版本，0 表示不存在
+		entry       *models.OutboxEntry
+		latest      int
+		wantVersion int // 投递后文档的版本，0 表示不存在
+	}{
+		{name: "first write", entry: nodeEntry(1, `{}`, false), latest: 1, wantVersion: 1},
+		{name: "newer version", current: 1, entry: nodeEntry(2, `{}`, false), latest: 2, wantVersion: 2},
+		{name: "older than zk", current: 3, entry: nodeEntry(2, `{}`, false), latest: 3, wantVersion: 3},
+		{name: "superseded by save", current: 1, entry: nodeEntry(2, `{}`, false), latest: 3, wantVersion: 1},
+		{name: "superseded on missing node", entry: nodeEntry(2, `{}`, false), latest: 3},
+		{name: "tombstone", current: 2, entry: nodeEntry(3, `{}`, true), latest: 3},
+		{name: "tombstone superseded by save", current: 4, entry: nodeEntry(3, `{}`, true), latest: 4, wantVersion: 4},
+	}
+	path := ConfigPath(models.ScopeNode, "bj-dc1", "node-01")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memStore{nodes: map[string][]byte{}}
+			if tt.current > 0 {
+				doc, err := models.EncodeDocument(models.Overlay{}, models.DocumentMeta{Version: tt.current})
+				if err != nil {
+					t.Fatalf("EncodeDocument() unexpected error: %v", err)
+				}
+				store.nodes[path] = doc
+			}
+			p := &Publisher{zk: store, logger: zap.NewNop()}
+
+			if err := p.deliver(tt.entry, tt.latest); err != nil {
+				t.Fatalf("deliver(v%d) unexpected error: %v", tt.entry.Version, err)
+			}
+			got := 0
+			if doc, ok := store.nodes[path]; ok {
+				got = models.DecodeDocumentMeta(doc).Version
+			}
+			if got != tt.wantVersion {
+				t.Fatalf("deliver(v%d) left version %d, want %d", tt.entry.Version, got, tt.wantVersion)
+			}
+		})
+	}
+}
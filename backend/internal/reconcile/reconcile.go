@@ -0,0 +1,262 @@
+// Package reconcile 检查 ZooKeeper 中的配置节点是否与数据库最新版本一致，并按数据库内容修复
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/diff"
+	"github.com/yf-web/backend/internal/models"
+	"github.com/yf-web/backend/internal/publisher"
+	"github.com/yf-web/backend/internal/zk"
+	"go.uber.org/zap"
+)
+
+// Config 漂移检查配置
+type Config struct {
+	AutoHeal bool          // 是否周期性自动修复
+	Interval time.Duration // 自动修复的周期
+}
+
+// Reconciler 对比数据库与 ZooKeeper 中的配置。修复时以数据库为准：
+// 缺失或内容不一致的节点写入数据库最新版本，数据库中没有的多余节点只报告、不删除
+type Reconciler struct {
+	db     *db.PostgresDB
+	zk     *zk.Client
+	config Config
+	logger *zap.Logger
+	mu     sync.Mutex // 串行化检查与修复
+}
+
+// New 创建漂移检查器
+func New(database *db.PostgresDB, zkClient *zk.Client, cfg Config, logger *zap.Logger) *Reconciler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	return &Reconciler{
+		db:     database,
+		zk:     zkClient,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// AutoHeal 是否开启了自动修复
+func (r *Reconciler) AutoHeal() bool {
+	return r.config.AutoHeal
+}
+
+// Run 开启自动修复时周期性检查并修复漂移，直到 ctx 结束
+func (r *Reconciler) Run(ctx context.Context) {
+	if !r.config.AutoHeal {
+		return
+	}
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := r.Repair()
+		if err != nil {
+			r.logger.Error("failed to reconcile zk configs", zap.Error(err))
+			continue
+		}
+		for _, drift := range report.Drifts {
+			r.logger.Warn("config drift detected",
+				zap.String("kind", drift.Kind),
+				zap.String("path", drift.Path),
+				zap.Int("db_version", drift.DBVersion),
+				zap.Int("zk_version", drift.ZKVersion),
+				zap.Bool("repaired", drift.Repaired),
+				zap.String("error", drift.Error),
+			)
+		}
+	}
+}
+
+// Check 对比数据库中每一级配置的最新版本与 ZooKeeper 中的节点，返回不一致之处
+func (r *Reconciler) Check() (*models.DriftReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.check()
+}
+
+// Repair 检查漂移并按数据库内容修复缺失与不一致的节点，返回带修复结果的报告
+func (r *Reconciler) Repair() (*models.DriftReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report, err := r.check()
+	if err != nil {
+		return nil, err
+	}
+	for _, drift := range report.Drifts {
+		if drift.Kind == models.DriftExtra {
+			continue
+		}
+		if err := r.repair(drift); err != nil {
+			drift.Error = err.Error()
+			continue
+		}
+		drift.Repaired = true
+		drift.Error = ""
+	}
+	return report, nil
+}
+
+// check 执行一次检查，调用方需持有 r.mu
+func (r *Reconciler) check() (*models.DriftReport, error) {
+	records, err := r.db.ListLatestConfigs()
+	if err != nil {
+		return nil, err
+	}
+	report := &models.DriftReport{CheckedAt: time.Now(), Drifts: []*models.Drift{}}
+
+	known := make(map[string]bool, len(records))
+	for _, record := range records {
+		path := publisher.ConfigPath(record.Scope, record.ClusterName, record.NodeID)
+		known[path] = true
+		report.Checked++
+
+		drift, err := r.compare(record, path)
+		if err != nil {
+			return nil, err
+		}
+		if drift != nil {
+			report.Drifts = append(report.Drifts, drift)
+		}
+	}
+
+	extras, err := r.extraNodes(known)
+	if err != nil {
+		return nil, err
+	}
+	report.Drifts = append(report.Drifts, extras...)
+	return report, nil
+}
+
+// compare 对比一条配置记录与 ZooKeeper 中的节点，一致时返回 nil
+func (r *Reconciler) compare(record *models.ConfigRecord, path string) (*models.Drift, error) {
+	drift := &models.Drift{
+		Scope:       record.Scope,
+		ClusterName: record.ClusterName,
+		NodeID:      record.NodeID,
+		Path:        path,
+		DBVersion:   record.Version,
+	}
+
+	data, err := r.zk.GetConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		drift.Kind = models.DriftMissing
+		return drift, nil
+	}
+	drift.ZKVersion = models.DecodeDocumentMeta(data).Version
+
+	expected, err := models.ParseOverlay([]byte(record.ConfigJSON))
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s v%d: %w", path, record.Version, err)
+	}
+	actual, err := models.DecodeDocument(data)
+	if err != nil {
+		drift.Kind = models.DriftMismatch
+		drift.Error = err.Error()
+		return drift, nil
+	}
+
+	// 覆盖配置序列化时按键排序，可以直接比较
+	want, _ := json.Marshal(expected)
+	got, _ := json.Marshal(actual)
+	if string(want) == string(got) {
+		return nil, nil
+	}
+	drift.Kind = models.DriftMismatch
+	if drift.Changes, err = diff.JSON(got, want); err != nil {
+		return nil, err
+	}
+	return drift, nil
+}
+
+// extraNodes 找出 ZooKeeper 中存在、数据库中没有对应配置的配置节点
+func (r *Reconciler) extraNodes(known map[string]bool) ([]*models.Drift, error) {
+	nodes, err := r.zk.ListConfigNodes()
+	if err != nil {
+		return nil, err
+	}
+	extras := []*models.Drift{}
+	for _, node := range nodes {
+		if known[node.Path] {
+			continue
+		}
+		data, err := r.zk.GetConfig(node.Path)
+		if err != nil {
+			return nil, err
+		}
+		extras = append(extras, &models.Drift{
+			Kind:        models.DriftExtra,
+			Scope:       publisher.ScopeOf(node),
+			ClusterName: node.ClusterName,
+			NodeID:      node.NodeID,
+			Path:        node.Path,
+			ZKVersion:   models.DecodeDocumentMeta(data).Version,
+		})
+	}
+	return extras, nil
+}
+
+// repair 将数据库最新版本写入 ZooKeeper。写入前再次确认该版本仍是最新的，
+// 避免与并发的保存竞争时用较早的版本覆盖刚发布的新版本
+func (r *Reconciler) repair(drift *models.Drift) error {
+	record, err := r.db.GetLatestConfig(drift.Scope, drift.ClusterName, drift.NodeID)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return fmt.Errorf("config no longer exists")
+	}
+	overlay, err := models.ParseOverlay([]byte(record.ConfigJSON))
+	if err != nil {
+		return err
+	}
+	doc, err := models.EncodeDocument(overlay, models.DocumentMeta{Version: record.Version})
+	if err != nil {
+		return err
+	}
+
+	var checkErr error
+	written, err := r.zk.CompareAndSet(drift.Path, doc, func(current []byte) bool {
+		latest, err := r.db.GetLatestConfig(drift.Scope, drift.ClusterName, drift.NodeID)
+		if err != nil {
+			checkErr = err
+			return false
+		}
+		return latest != nil && latest.Version == record.Version
+	})
+	if err != nil {
+		return err
+	}
+	if checkErr != nil {
+		return checkErr
+	}
+	if !written {
+		return fmt.Errorf("config changed during repair, check again")
+	}
+
+	r.logger.Info("repaired config drift",
+		zap.String("kind", drift.Kind),
+		zap.String("path", drift.Path),
+		zap.Int("version", record.Version),
+	)
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package scheduler 到发布时间时将定时发布保存为新版本并写入 ZooKeeper
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/yf-web/backend/internal/db"
+	"github.com/yf-web/backend/internal/models"
+	"github.com/yf-web/backend/internal/publisher"
+	"go.uber.org/zap"
+)
+
+// batchSize 每轮最多发布的定时变更数
+const batchSize = 50
+
+// Config 定时发布任务配置
+type Config struct {
+	Interval time.Duration // 检查到期定时发布的周期，决定发布时间的精度
+}
+
+// Scheduler 周期性发布到期的定时变更。定时发布保存在数据库中，后端重启后照常发布；
+// 多个后端实例同时运行时由 PublishSchedule 的行锁保证只发布一次
+type Scheduler struct {
+	db        *db.PostgresDB
+	publisher *publisher.Publisher
+	config    Config
+	logger    *zap.Logger
+}
+
+// New 创建定时发布任务
+func New(database *db.PostgresDB, pub *publisher.Publisher, cfg Config, logger *zap.Logger) *Scheduler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	return &Scheduler{
+		db:        database,
+		publisher: pub,
+		config:    cfg,
+		logger:    logger,
+	}
+}
+
+// Run 周期性发布到期的定时变更，直到 ctx 结束
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.publishDue()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDue 发布所有到期的定时变更
+func (s *Scheduler) publishDue() {
+	schedules, err := s.db.ListDueSchedules(time.Now(), batchSize)
+	if err != nil {
+		s.logger.Error("failed to list due schedules", zap.Error(err))
+		return
+	}
+	for _, schedule := range schedules {
+		s.publish(schedule)
+	}
+}
+
+// publish 保存并发布一个定时变更。配置已被修改或正在灰度发布时标记为失败；
+// 其他错误（如数据库暂时不可用）保留等待状态，下一轮重试
+func (s *Scheduler) publish(schedule *models.Schedule) {
+	logger := s.logger.With(
+		zap.Int64("schedule", schedule.ID),
+		zap.String("scope", string(schedule.Scope)),
+		zap.String("cluster", schedule.ClusterName),
+		zap.String("node", schedule.NodeID),
+	)
+
+	record, err := s.db.PublishSchedule(schedule.ID)
+	var conflict *db.VersionConflictError
+	switch {
+	case err == nil:
+	case err == db.ErrScheduleState:
+		return
+	case errors.As(err, &conflict), err == db.ErrCanaryActive:
+		logger.Warn("scheduled config change failed", zap.Error(err))
+		if err := s.db.FailSchedule(schedule.ID, err.Error()); err != nil {
+			logger.Error("failed to mark schedule failed", zap.Error(err))
+		}
+		return
+	default:
+		logger.Error("failed to publish scheduled config", zap.Error(err))
+		return
+	}
+
+	// 新版本已登记发布条目，写入 ZooKeeper 失败时由 publisher 重试
+	if _, err := s.publisher.Publish(record.Scope, record.ClusterName, record.NodeID); err != nil {
+		logger.Error("failed to publish config", zap.Error(err))
+		s.publisher.Kick()
+	}
+	logger.Info("scheduled config published", zap.Int("version", record.Version))
+}
@@ -3,6 +3,8 @@ package validator
 import (
 	"fmt"
 	"net"
+	"net/mail"
+	"net/url"
 	"strings"
 
 	"github.com/yf-web/backend/internal/models"
@@ -18,6 +20,9 @@ func NewConfigValidator() *ConfigValidator {
 
 // Validate 验证配置
 func (v *ConfigValidator) Validate(cfg *models.YafConfig) error {
+	if err := models.CheckPlaceholders(cfg); err != nil {
+		return fmt.Errorf("placeholder error: %w", err)
+	}
 	if err := v.validateCapture(&cfg.Capture); err != nil {
 		return fmt.Errorf("capture config error: %w", err)
 	}
@@ -30,6 +35,28 @@ func (v *ConfigValidator) Validate(cfg *models.YafConfig) error {
 	return nil
 }
 
+// ValidateOverlay 验证某一级的覆盖配置：只检查显式给出的字段，清除（null）总是允许，
+// 但输出字段列表不能被清空
+func (v *ConfigValidator) ValidateOverlay(overlay models.Overlay) error {
+	// 未给出的字段在零值配置上保持为零，零值都能通过范围检查
+	cfg := overlay.Apply(&models.YafConfig{})
+	if err := models.CheckPlaceholders(cfg); err != nil {
+		return fmt.Errorf("placeholder error: %w", err)
+	}
+	if err := v.validateCapture(&cfg.Capture); err != nil {
+		return fmt.Errorf("capture config error: %w", err)
+	}
+	if err := v.validateFilter(&cfg.Filter); err != nil {
+		return fmt.Errorf("filter config error: %w", err)
+	}
+	if overlay.Has("output.fields") {
+		if err := v.validateOutput(&cfg.Output); err != nil {
+			return fmt.Errorf("output config error: %w", err)
+		}
+	}
+	return nil
+}
+
 // validateCapture 验证采集配置
 func (v *ConfigValidator) validateCapture(cfg *models.CaptureConfig) error {
 	// 验证 IPFIX 端口
@@ -60,14 +87,20 @@ func (v *ConfigValidator) validateCapture(cfg *models.CaptureConfig) error {
 
 // validateFilter 验证过滤配置
 func (v *ConfigValidator) validateFilter(cfg *models.FilterConfig) error {
-	// 验证 IP 白名单
+	// 验证 IP 白名单，含占位符的项在 Agent 展开后才能确定，不检查格式
 	for _, cidr := range cfg.IPWhitelist {
+		if models.HasPlaceholder(cidr) {
+			continue
+		}
 		if err := v.validateCIDR(cidr); err != nil {
 			return fmt.Errorf("invalid ip_whitelist entry '%s': %w", cidr, err)
 		}
 	}
 	// 验证 IP 黑名单
 	for _, cidr := range cfg.IPBlacklist {
+		if models.HasPlaceholder(cidr) {
+			continue
+		}
 		if err := v.validateCIDR(cidr); err != nil {
 			return fmt.Errorf("invalid ip_blacklist entry '%s': %w", cidr, err)
 		}
@@ -166,3 +199,192 @@ func (v *ConfigValidator) ValidateNodeID(nodeID string) error {
 	return nil
 }
 
+// ValidateProfileName 验证配置档名称
+func (v *ConfigValidator) ValidateProfileName(name string) error {
+	if name == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if len(name) > 128 {
+		return fmt.Errorf("profile name too long (max 128 characters)")
+	}
+	// 只允许字母、数字、下划线、中划线、点
+	for _, c := range name {
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-' || c == '.') {
+			return fmt.Errorf("profile name contains invalid character: %c", c)
+		}
+	}
+	return nil
+}
+
+// ValidateSelectorName 验证标签选择层名称
+func (v *ConfigValidator) ValidateSelectorName(name string) error {
+	if name == "" {
+		return fmt.Errorf("selector name is required")
+	}
+	if len(name) > 128 {
+		return fmt.Errorf("selector name too long (max 128 characters)")
+	}
+	// 只允许字母、数字、下划线、中划线、点
+	for _, c := range name {
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-' || c == '.') {
+			return fmt.Errorf("selector name contains invalid character: %c", c)
+		}
+	}
+	return nil
+}
+
+// ValidateSelectorLabels 验证标签选择层的标签条件，至少需要一个标签
+func (v *ConfigValidator) ValidateSelectorLabels(labels map[string]string) error {
+	if len(labels) == 0 {
+		return fmt.Errorf("selector requires at least one label")
+	}
+	for key, value := range labels {
+		if err := v.validateLabelKey(key); err != nil {
+			return fmt.Errorf("invalid label '%s': %w", key, err)
+		}
+		if err := v.validateLabelValue(value); err != nil {
+			return fmt.Errorf("invalid label '%s': %w", key, err)
+		}
+	}
+	return nil
+}
+
+// ValidateSubscription 验证通知订阅：事件类型、配置范围与渠道的投递目标
+func (v *ConfigValidator) ValidateSubscription(sub *models.Subscription) error {
+	if strings.TrimSpace(sub.Name) == "" {
+		return fmt.Errorf("subscription name is required")
+	}
+	if len(sub.Name) > 128 {
+		return fmt.Errorf("subscription name too long (max 128 characters)")
+	}
+	for _, event := range sub.Events {
+		supported := false
+		for _, known := range models.NotifyEvents {
+			if event == known {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return fmt.Errorf("unsupported event type '%s'", event)
+		}
+	}
+	switch sub.Scope {
+	case "", models.ScopeGlobal, models.ScopeCluster, models.ScopeNode, models.ScopeProfile, models.ScopeSelector:
+	default:
+		return fmt.Errorf("invalid scope '%s'", sub.Scope)
+	}
+	if sub.ClusterName != "" {
+		if sub.Scope == models.ScopeGlobal {
+			return fmt.Errorf("cluster_name cannot be combined with global scope")
+		}
+		if err := v.ValidateClusterName(sub.ClusterName); err != nil {
+			return err
+		}
+	}
+
+	switch sub.Channel {
+	case models.ChannelWebhook:
+		u, err := url.Parse(sub.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook target must be an http or https URL")
+		}
+	case models.ChannelEmail:
+		count := 0
+		for _, address := range strings.Split(sub.Target, ",") {
+			if address = strings.TrimSpace(address); address == "" {
+				continue
+			}
+			if _, err := mail.ParseAddress(address); err != nil {
+				return fmt.Errorf("invalid email address '%s'", address)
+			}
+			count++
+		}
+		if count == 0 {
+			return fmt.Errorf("email target requires at least one address")
+		}
+	default:
+		return fmt.Errorf("channel must be webhook or email")
+	}
+	return nil
+}
+
+// ValidateInventoryMeta 验证集群与节点的描述信息
+func (v *ConfigValidator) ValidateInventoryMeta(meta *models.InventoryMeta) error {
+	if len(meta.Description) > 1024 {
+		return fmt.Errorf("description too long (max 1024 characters)")
+	}
+	if len(meta.Owner) > 128 {
+		return fmt.Errorf("owner too long (max 128 characters)")
+	}
+	for key, value := range meta.Labels {
+		if err := v.validateLabelKey(key); err != nil {
+			return fmt.Errorf("invalid label '%s': %w", key, err)
+		}
+		if err := v.validateLabelValue(value); err != nil {
+			return fmt.Errorf("invalid label '%s': %w", key, err)
+		}
+	}
+	return nil
+}
+
+// validateLabelKey 验证标签名：字母或数字开头，只允许字母、数字、下划线、中划线、点与斜线
+func (v *ConfigValidator) validateLabelKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("label key is required")
+	}
+	if len(key) > 63 {
+		return fmt.Errorf("label key too long (max 63 characters)")
+	}
+	for i, c := range key {
+		alnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if i == 0 && !alnum {
+			return fmt.Errorf("label key must start with a letter or digit")
+		}
+		if !alnum && c != '_' && c != '-' && c != '.' && c != '/' {
+			return fmt.Errorf("label key contains invalid character: %c", c)
+		}
+	}
+	return nil
+}
+
+// validateLabelValue 验证标签值：最长 128 个字符，不允许换行等控制字符（标签值可经占位符写入 yaf.init）
+func (v *ConfigValidator) validateLabelValue(value string) error {
+	if len(value) > 128 {
+		return fmt.Errorf("value too long (max 128 characters)")
+	}
+	for _, c := range value {
+		if c < 0x20 || c == 0x7f {
+			return fmt.Errorf("value contains control character %q", c)
+		}
+	}
+	return nil
+}
+
+// ValidateUsername 验证用户名
+func (v *ConfigValidator) ValidateUsername(username string) error {
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if len(username) > 64 {
+		return fmt.Errorf("username too long (max 64 characters)")
+	}
+	// 只允许字母、数字、下划线、中划线、点
+	for _, c := range username {
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-' || c == '.') {
+			return fmt.Errorf("username contains invalid character: %c", c)
+		}
+	}
+	return nil
+}
+
+// ValidatePassword 验证密码强度
+func (v *ConfigValidator) ValidatePassword(password string) error {
+	if len(password) < 8 {
+		return fmt.Errorf("password must be at least 8 characters")
+	}
+	if len(password) > 72 {
+		return fmt.Errorf("password too long (max 72 bytes)")
+	}
+	return nil
+}
@@ -16,14 +16,26 @@ const (
 	ConfigBasePath = "/xnta/yaf-config"
 	GlobalPath     = "/xnta/yaf-config/global/config"
 	ClusterPath    = "/xnta/yaf-config/cluster"
+
+	// casMaxAttempts CompareAndSet 遇到并发修改时的最大尝试次数
+	casMaxAttempts = 5
 )
 
+// Event watch 触发的事件
+type Event = zk.Event
+
+// StateListener 连接状态变化回调，connected 表示已建立会话
+type StateListener func(state string, connected bool)
+
 // Client ZooKeeper 客户端封装
 type Client struct {
 	conn    *zk.Conn
 	servers []string
 	logger  *zap.Logger
 	mu      sync.RWMutex
+
+	listenerMu sync.RWMutex // 与 mu 分开：Reconnect 持有 mu 关闭旧连接时，旧连接的事件仍需能被处理
+	listener   StateListener
 }
 
 // Reconnect 重新连接到新的 ZooKeeper 服务器
@@ -71,13 +83,29 @@ func NewClient(servers []string, logger *zap.Logger) (*Client, error) {
 	return client, nil
 }
 
-// watchConnection 监听连接状态
+// SetStateListener 设置连接状态变化回调
+func (c *Client) SetStateListener(listener StateListener) {
+	c.listenerMu.Lock()
+	defer c.listenerMu.Unlock()
+	c.listener = listener
+}
+
+// watchConnection 监听连接状态，会话状态变化时通知 StateListener
 func (c *Client) watchConnection(eventCh <-chan zk.Event) {
 	for event := range eventCh {
 		c.logger.Info("zk connection event",
 			zap.String("type", event.Type.String()),
 			zap.String("state", event.State.String()),
 		)
+		if event.Type != zk.EventSession {
+			continue
+		}
+		c.listenerMu.RLock()
+		listener := c.listener
+		c.listenerMu.RUnlock()
+		if listener != nil {
+			listener(event.State.String(), event.State == zk.StateConnected || event.State == zk.StateHasSession)
+		}
 	}
 }
 
@@ -94,7 +122,11 @@ func (c *Client) Close() {
 func (c *Client) EnsurePath(path string) error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	return c.ensurePathLocked(path)
+}
 
+// ensurePathLocked 逐级创建不存在的节点，调用方需持有读锁
+func (c *Client) ensurePathLocked(path string) error {
 	parts := strings.Split(path, "/")
 	currentPath := ""
 	for _, part := range parts {
@@ -128,7 +160,7 @@ func (c *Client) SetConfig(path string, data interface{}) error {
 
 	// 确保路径存在
 	parentPath := path[:strings.LastIndex(path, "/")]
-	if err := c.EnsurePath(parentPath); err != nil {
+	if err := c.ensurePathLocked(parentPath); err != nil {
 		return err
 	}
 
@@ -154,6 +186,52 @@ func (c *Client) SetConfig(path string, data interface{}) error {
 	return nil
 }
 
+// CompareAndSet 基于 znode 的 stat.Version 写入 data：先读取当前内容交给 shouldWrite 判断是否需要写入，
+// 写入时节点已被他人修改（ErrBadVersion / ErrNodeExists）则重新读取后重试。返回是否实际写入
+func (c *Client) CompareAndSet(path string, data []byte, shouldWrite func(current []byte) bool) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// 确保路径存在
+	parentPath := path[:strings.LastIndex(path, "/")]
+	if err := c.ensurePathLocked(parentPath); err != nil {
+		return false, err
+	}
+
+	for attempt := 0; attempt < casMaxAttempts; attempt++ {
+		current, stat, err := c.conn.Get(path)
+		switch {
+		case err == zk.ErrNoNode:
+			if !shouldWrite(nil) {
+				return false, nil
+			}
+			_, err = c.conn.Create(path, data, 0, zk.WorldACL(zk.PermAll))
+		case err != nil:
+			return false, fmt.Errorf("failed to get config: %w", err)
+		default:
+			if !shouldWrite(current) {
+				return false, nil
+			}
+			_, err = c.conn.Set(path, data, stat.Version)
+		}
+
+		if err == zk.ErrBadVersion || err == zk.ErrNodeExists {
+			c.logger.Info("config changed concurrently, retrying", zap.String("path", path))
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to set config: %w", err)
+		}
+
+		c.logger.Info("config updated in zookeeper",
+			zap.String("path", path),
+			zap.Int("size", len(data)),
+		)
+		return true, nil
+	}
+	return false, fmt.Errorf("failed to set config: too many concurrent modifications on %s", path)
+}
+
 // GetConfig 获取配置
 func (c *Client) GetConfig(path string) ([]byte, error) {
 	c.mu.RLock()
@@ -185,6 +263,33 @@ func (c *Client) DeleteConfig(path string) error {
 	return c.conn.Delete(path, stat.Version)
 }
 
+// DeleteRecursive 删除节点及其全部子节点，节点不存在时忽略
+func (c *Client) DeleteRecursive(path string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.deleteRecursive(path)
+}
+
+// deleteRecursive 先删除子节点再删除自身，调用方需持有读锁
+func (c *Client) deleteRecursive(path string) error {
+	children, _, err := c.conn.Children(path)
+	if err == zk.ErrNoNode {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list children of %s: %w", path, err)
+	}
+	for _, child := range children {
+		if err := c.deleteRecursive(path + "/" + child); err != nil {
+			return err
+		}
+	}
+	if err := c.conn.Delete(path, -1); err != nil && err != zk.ErrNoNode {
+		return fmt.Errorf("failed to delete %s: %w", path, err)
+	}
+	return nil
+}
+
 // GetGlobalConfigPath 获取全局配置路径
 func GetGlobalConfigPath() string {
 	return GlobalPath
@@ -195,6 +300,67 @@ func GetClusterConfigPath(clusterName string) string {
 	return fmt.Sprintf("%s/%s/config", ClusterPath, clusterName)
 }
 
+// GetNodesDir 获取集群下各节点配置的目录
+func GetNodesDir(clusterName string) string {
+	return fmt.Sprintf("%s/%s/nodes", ClusterPath, clusterName)
+}
+
+// GetLiveDir 获取集群下 Agent 在线临时节点的目录
+func GetLiveDir(clusterName string) string {
+	return fmt.Sprintf("%s/%s/live", ClusterPath, clusterName)
+}
+
+// GetLivePath 获取 Agent 在线临时节点的路径
+func GetLivePath(clusterName, nodeID string) string {
+	return fmt.Sprintf("%s/%s/live/%s", ClusterPath, clusterName, nodeID)
+}
+
+// GetPinPath 获取灰度发布期间节点固定某一级（global / cluster）配置版本的节点路径，
+// 存在时 Agent 以其内容代替该级的配置节点
+func GetPinPath(clusterName, nodeID, scope string) string {
+	return fmt.Sprintf("%s/%s/pins/%s/%s", ClusterPath, clusterName, nodeID, scope)
+}
+
+// GetCanaryGuardPath 获取灰度发布期间某一级（global / cluster）配置的守护节点路径：
+// 没有固定节点且不是灰度节点的 Agent 以其中的基准版本代替该级的配置节点
+func GetCanaryGuardPath(scope, clusterName string) string {
+	if scope == "global" {
+		return fmt.Sprintf("%s/global/canary", ConfigBasePath)
+	}
+	return fmt.Sprintf("%s/%s/canary", ClusterPath, clusterName)
+}
+
+// GetClusterProfilesPath 获取集群挂载的配置档合并而成的配置档层路径，
+// Agent 在全局配置之后、集群配置之前合并
+func GetClusterProfilesPath(clusterName string) string {
+	return fmt.Sprintf("%s/%s/profiles/cluster", ClusterPath, clusterName)
+}
+
+// GetNodeProfilesPath 获取节点挂载的配置档合并而成的配置档层路径，在集群的配置档层之后合并
+func GetNodeProfilesPath(clusterName, nodeID string) string {
+	return fmt.Sprintf("%s/%s/profiles/nodes/%s", ClusterPath, clusterName, nodeID)
+}
+
+// GetSelectorPath 获取标签选择层的路径，Agent 按 _meta 中的选择条件判断本节点是否匹配
+func GetSelectorPath(name string) string {
+	return fmt.Sprintf("%s/selectors/%s", ConfigBasePath, name)
+}
+
+// GetLabelsDir 获取集群下各节点登记标签的目录
+func GetLabelsDir(clusterName string) string {
+	return fmt.Sprintf("%s/%s/labels", ClusterPath, clusterName)
+}
+
+// GetLabelsPath 获取节点清单中的标签在配置树中的路径，Agent 用于匹配标签选择层
+func GetLabelsPath(clusterName, nodeID string) string {
+	return fmt.Sprintf("%s/%s/labels/%s", ClusterPath, clusterName, nodeID)
+}
+
+// GetNodeDir 获取节点在配置树中的目录
+func GetNodeDir(clusterName, nodeID string) string {
+	return fmt.Sprintf("%s/%s/nodes/%s", ClusterPath, clusterName, nodeID)
+}
+
 // GetNodeConfigPath 获取节点配置路径
 func GetNodeConfigPath(clusterName, nodeID string) string {
 	return fmt.Sprintf("%s/%s/nodes/%s/config", ClusterPath, clusterName, nodeID)
@@ -231,6 +397,106 @@ func (c *Client) ListNodes(clusterName string) ([]string, error) {
 	return children, nil
 }
 
+// ListChildren 列出 path 的子节点，path 不存在时返回空列表
+func (c *Client) ListChildren(path string) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	children, _, err := c.conn.Children(path)
+	if err == zk.ErrNoNode {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list children of %s: %w", path, err)
+	}
+	return children, nil
+}
+
+// WatchChildren 列出 path 的子节点并监听子节点变化；path 不存在时返回空列表并监听其创建
+func (c *Client) WatchChildren(path string) ([]string, <-chan zk.Event, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	children, _, ch, err := c.conn.ChildrenW(path)
+	if err == zk.ErrNoNode {
+		var exists bool
+		exists, _, ch, err = c.conn.ExistsW(path)
+		if err == nil && exists {
+			// 两次调用之间节点被创建，立即触发一次
+			created := make(chan zk.Event, 1)
+			created <- zk.Event{Type: zk.EventNodeCreated, Path: path}
+			return []string{}, created, nil
+		}
+		children = []string{}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to watch children of %s: %w", path, err)
+	}
+	return children, ch, nil
+}
+
+// WatchData 读取 path 的内容并监听其修改与删除；path 不存在时返回 nil
+func (c *Client) WatchData(path string) ([]byte, <-chan zk.Event, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data, _, ch, err := c.conn.GetW(path)
+	if err == zk.ErrNoNode {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	return data, ch, nil
+}
+
+// ConfigNode 配置树中的一个配置节点，ClusterName 为空表示全局配置，NodeID 为空表示集群配置
+type ConfigNode struct {
+	ClusterName string
+	NodeID      string
+	Path        string
+}
+
+// ListConfigNodes 遍历 ConfigBasePath 下的全局、集群与节点配置，只返回 config 节点存在的项
+func (c *Client) ListConfigNodes() ([]ConfigNode, error) {
+	nodes := []ConfigNode{}
+	add := func(cluster, node, path string) error {
+		c.mu.RLock()
+		exists, _, err := c.conn.Exists(path)
+		c.mu.RUnlock()
+		if err != nil {
+			return fmt.Errorf("failed to check path %s: %w", path, err)
+		}
+		if exists {
+			nodes = append(nodes, ConfigNode{ClusterName: cluster, NodeID: node, Path: path})
+		}
+		return nil
+	}
+
+	if err := add("", "", GetGlobalConfigPath()); err != nil {
+		return nil, err
+	}
+	clusters, err := c.ListClusters()
+	if err != nil {
+		return nil, err
+	}
+	for _, cluster := range clusters {
+		if err := add(cluster, "", GetClusterConfigPath(cluster)); err != nil {
+			return nil, err
+		}
+		nodeIDs, err := c.ListNodes(cluster)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodeIDs {
+			if err := add(cluster, node, GetNodeConfigPath(cluster, node)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nodes, nil
+}
+
 // IsConnected 检查 ZK 是否连接
 func (c *Client) IsConnected() bool {
 	c.mu.RLock()
@@ -258,4 +524,3 @@ func (c *Client) GetState() string {
 func (c *Client) GetServers() []string {
 	return c.servers
 }
-
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/signal"
 	"strings"
@@ -15,24 +16,33 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+// version Agent 版本，构建时通过 -ldflags "-X main.version=..." 注入
+var version = "dev"
+
 func main() {
 	// 初始化日志
 	logger := initLogger()
 	defer logger.Sync()
 
-	logger.Info("yaf-config-agent starting...")
+	logger.Info("yaf-config-agent starting...", zap.String("version", version))
+	startedAt := time.Now()
 
 	// 读取环境变量
 	zkServers := getEnv("ZK_SERVERS", "localhost:2181")
 	cluster := getEnv("YAF_CLUSTER", "default")
 	nodeID := getEnv("YAF_NODE_ID", "node-1")
 	configPath := getEnv("YAF_CONFIG_PATH", "/etc/yaf/yaf.init")
+	labels, err := config.ParseLabels(os.Getenv("YAF_NODE_LABELS"))
+	if err != nil {
+		logger.Fatal("invalid YAF_NODE_LABELS", zap.Error(err))
+	}
 
 	logger.Info("configuration",
 		zap.String("zk_servers", zkServers),
 		zap.String("cluster", cluster),
 		zap.String("node_id", nodeID),
 		zap.String("config_path", configPath),
+		zap.Any("labels", labels),
 	)
 
 	// 创建 supervisor 控制器
@@ -53,8 +63,8 @@ func main() {
 		logger.Fatal("failed to create template generator", zap.Error(err))
 	}
 
-	// 配置变更回调
-	onConfigChange := func(cfg *config.YafConfig) error {
+	// 配置变更回调：返回生成的配置文件的哈希；生成或重启失败时返回错误，作为应用结果上报
+	onConfigChange := func(cfg *config.YafConfig, labels map[string]string) (string, error) {
 		applyStartTime := time.Now()
 		logger.Info("[CONFIG_APPLY] 收到配置变更，开始应用新配置",
 			zap.Time("apply_time", applyStartTime),
@@ -69,15 +79,17 @@ func main() {
 
 		// 生成配置文件
 		generateStartTime := time.Now()
-		if err := generator.Generate(cfg); err != nil {
+		hash, err := generator.Generate(cfg, labels)
+		if err != nil {
 			logger.Error("[CONFIG_APPLY] 配置文件生成失败",
 				zap.Error(err),
 				zap.Duration("generate_duration", time.Since(generateStartTime)),
 			)
-			return err
+			return "", err
 		}
 		logger.Info("[CONFIG_APPLY] 配置文件生成成功",
 			zap.String("config_path", configPath),
+			zap.String("config_hash", hash),
 			zap.Duration("generate_duration", time.Since(generateStartTime)),
 		)
 
@@ -92,15 +104,15 @@ func main() {
 				zap.Duration("restart_duration", time.Since(restartStartTime)),
 				zap.Duration("total_duration", time.Since(applyStartTime)),
 			)
-			// 不返回错误，配置已写入
-		} else {
-			logger.Info("[CONFIG_APPLY] 配置应用完成",
-				zap.Duration("restart_duration", time.Since(restartStartTime)),
-				zap.Duration("total_duration", time.Since(applyStartTime)),
-			)
+			// 配置文件已写入，但新配置未生效
+			return hash, fmt.Errorf("restart failed: %w", err)
 		}
+		logger.Info("[CONFIG_APPLY] 配置应用完成",
+			zap.Duration("restart_duration", time.Since(restartStartTime)),
+			zap.Duration("total_duration", time.Since(applyStartTime)),
+		)
 
-		return nil
+		return hash, nil
 	}
 
 	// 创建配置监听器
@@ -110,6 +122,18 @@ func main() {
 		logger.Fatal("failed to create config watcher", zap.Error(err))
 	}
 
+	// 匹配标签选择层时使用的节点标签：后端登记的标签与环境变量中的标签合并
+	configWatcher.SetLabels(labels)
+
+	// 登记在线临时节点，后端据此展示 Agent 在线状态；环境变量中的标签供后端预览生效配置时匹配标签选择层
+	hostname, _ := os.Hostname()
+	configWatcher.RegisterLive(config.AgentInfo{
+		Hostname:     hostname,
+		AgentVersion: version,
+		StartedAt:    startedAt,
+		Labels:       labels,
+	})
+
 	// 启动监听
 	if err := configWatcher.Start(); err != nil {
 		logger.Fatal("failed to start config watcher", zap.Error(err))
@@ -4,7 +4,10 @@ go 1.21
 
 require (
 	github.com/go-zookeeper/zk v1.0.3
+	github.com/yf-web/shared v0.0.0
 	go.uber.org/zap v1.26.0
 )
 
 require go.uber.org/multierr v1.10.0 // indirect
+
+replace github.com/yf-web/shared => ../shared
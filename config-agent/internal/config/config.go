@@ -1,167 +1,92 @@
+// Package config 复用与后端共享的配置模型与合并逻辑（见 shared/yafconfig）
 package config
 
-// CaptureConfig 采集配置
-type CaptureConfig struct {
-	Interface      string `json:"interface"`        // 网卡名称，如 eth0
-	IPFIXPort      int    `json:"ipfix_port"`       // IPFIX 输出端口，如 18000
-	IdleTimeout    int    `json:"idle_timeout"`     // 空闲超时（秒）
-	ActiveTimeout  int    `json:"active_timeout"`   // 活跃超时（秒）
-	StatsInterval  int    `json:"stats_interval"`   // 统计输出间隔（秒）
-	EnableAppLabel bool   `json:"enable_applabel"`  // 启用应用识别
-	EnableDPI      bool   `json:"enable_dpi"`       // 启用 DPI
-	MaxPayload     int    `json:"max_payload"`      // 最大载荷字节数
-}
+import (
+	"fmt"
+	"strings"
 
-// FilterConfig 过滤配置
-type FilterConfig struct {
-	IPWhitelist []string `json:"ip_whitelist"`
-	IPBlacklist []string `json:"ip_blacklist"`
-	SrcPorts    []int    `json:"src_ports"`
-	DstPorts    []int    `json:"dst_ports"`
-	BPFFilter   string   `json:"bpf_filter"`
-}
+	"github.com/yf-web/shared/yafconfig"
+)
 
-// OutputConfig 输出配置
-type OutputConfig struct {
-	Fields []string `json:"fields"`
-}
+type (
+	// CaptureConfig 采集配置
+	CaptureConfig = yafconfig.CaptureConfig
+	// FilterConfig 过滤配置
+	FilterConfig = yafconfig.FilterConfig
+	// OutputConfig 输出配置
+	OutputConfig = yafconfig.OutputConfig
+	// StatusReportConfig 状态上报配置
+	StatusReportConfig = yafconfig.StatusReportConfig
+	// YafConfig YAF 完整配置
+	YafConfig = yafconfig.YafConfig
+)
 
-// StatusReportConfig 状态上报配置
-type StatusReportConfig struct {
-	StatusReportURL        string `json:"status_report_url"`         // 状态上报 URL，例如 "http://example.com/api/uploadStatus"
-	StatusReportIntervalSec int    `json:"status_report_interval_sec"` // 状态上报间隔（秒），默认 60
-	UUID                    string `json:"uuid"`                    // 容器主机名，如果为空则从环境变量 HOSTNAME 获取
-}
+// AgentInfo Agent 在 ZooKeeper 临时节点中登记的运行信息
+type AgentInfo = yafconfig.AgentInfo
 
-// YafConfig YAF 完整配置
-type YafConfig struct {
-	Capture      CaptureConfig      `json:"capture"`
-	Filter       FilterConfig       `json:"filter"`
-	Output       OutputConfig       `json:"output"`
-	StatusReport StatusReportConfig `json:"status_report"`
+// ApplyState Agent 最近一次应用配置的结果
+type ApplyState = yafconfig.ApplyState
+
+// ProfileRef 配置档的某个版本
+type ProfileRef = yafconfig.ProfileRef
+
+// Selector 标签选择层的选择条件与优先级
+type Selector = yafconfig.Selector
+
+// SelectorLess 匹配的标签选择层的合并顺序：优先级从低到高，相同时按名称
+func SelectorLess(a, b *Selector) bool {
+	return yafconfig.SelectorLess(a, b)
 }
 
-// MergeConfig 合并配置，后者覆盖前者
-func MergeConfig(base, overlay *YafConfig) *YafConfig {
-	if overlay == nil {
-		return base
-	}
-	if base == nil {
-		return overlay
-	}
+// CanaryGuard 灰度发布期间该级配置的守护节点：不是灰度节点且没有固定节点时使用其中的基准版本
+type CanaryGuard = yafconfig.CanaryGuard
 
-	merged := &YafConfig{}
+// Vars 展开配置中 ${node.id}、${cluster}、${env.YAF_VAR_NAME}、${label.KEY} 等占位符使用的节点变量
+type Vars = yafconfig.Vars
 
-	// Capture 配置合并
-	merged.Capture.Interface = base.Capture.Interface
-	if overlay.Capture.Interface != "" {
-		merged.Capture.Interface = overlay.Capture.Interface
-	}
-	merged.Capture.IPFIXPort = base.Capture.IPFIXPort
-	if overlay.Capture.IPFIXPort > 0 {
-		merged.Capture.IPFIXPort = overlay.Capture.IPFIXPort
-	}
-	merged.Capture.IdleTimeout = base.Capture.IdleTimeout
-	if overlay.Capture.IdleTimeout > 0 {
-		merged.Capture.IdleTimeout = overlay.Capture.IdleTimeout
-	}
-	merged.Capture.ActiveTimeout = base.Capture.ActiveTimeout
-	if overlay.Capture.ActiveTimeout > 0 {
-		merged.Capture.ActiveTimeout = overlay.Capture.ActiveTimeout
-	}
-	merged.Capture.StatsInterval = base.Capture.StatsInterval
-	if overlay.Capture.StatsInterval > 0 {
-		merged.Capture.StatsInterval = overlay.Capture.StatsInterval
-	}
-	merged.Capture.EnableAppLabel = base.Capture.EnableAppLabel || overlay.Capture.EnableAppLabel
-	merged.Capture.EnableDPI = base.Capture.EnableDPI || overlay.Capture.EnableDPI
-	merged.Capture.MaxPayload = base.Capture.MaxPayload
-	if overlay.Capture.MaxPayload > 0 {
-		merged.Capture.MaxPayload = overlay.Capture.MaxPayload
-	}
+// ParseLabels 解析 "site=sh,nic=x710" 形式的节点标签，空字符串返回空标签
+func ParseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", pair)
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
 
-	// Filter 配置合并（覆盖策略）
-	merged.Filter.IPWhitelist = base.Filter.IPWhitelist
-	if len(overlay.Filter.IPWhitelist) > 0 {
-		merged.Filter.IPWhitelist = overlay.Filter.IPWhitelist
-	}
-	merged.Filter.IPBlacklist = base.Filter.IPBlacklist
-	if len(overlay.Filter.IPBlacklist) > 0 {
-		merged.Filter.IPBlacklist = overlay.Filter.IPBlacklist
-	}
-	merged.Filter.SrcPorts = base.Filter.SrcPorts
-	if len(overlay.Filter.SrcPorts) > 0 {
-		merged.Filter.SrcPorts = overlay.Filter.SrcPorts
-	}
-	merged.Filter.DstPorts = base.Filter.DstPorts
-	if len(overlay.Filter.DstPorts) > 0 {
-		merged.Filter.DstPorts = overlay.Filter.DstPorts
-	}
-	merged.Filter.BPFFilter = base.Filter.BPFFilter
-	if overlay.Filter.BPFFilter != "" {
-		merged.Filter.BPFFilter = overlay.Filter.BPFFilter
-	}
+// 配置应用结果
+const (
+	ApplyApplied = yafconfig.ApplyApplied
+	ApplyFailed  = yafconfig.ApplyFailed
+)
 
-	// Output 配置合并（覆盖策略）
-	merged.Output.Fields = base.Output.Fields
-	if len(overlay.Output.Fields) > 0 {
-		merged.Output.Fields = overlay.Output.Fields
-	}
+// DecodeMeta 读取配置文档中的 _meta，旧格式文档返回零值
+func DecodeMeta(data []byte) yafconfig.Meta {
+	return yafconfig.DecodeMeta(data)
+}
 
-	// StatusReport 配置合并
-	merged.StatusReport.StatusReportURL = base.StatusReport.StatusReportURL
-	if overlay.StatusReport.StatusReportURL != "" {
-		merged.StatusReport.StatusReportURL = overlay.StatusReport.StatusReportURL
-	}
-	merged.StatusReport.StatusReportIntervalSec = base.StatusReport.StatusReportIntervalSec
-	if overlay.StatusReport.StatusReportIntervalSec > 0 {
-		merged.StatusReport.StatusReportIntervalSec = overlay.StatusReport.StatusReportIntervalSec
-	}
-	merged.StatusReport.UUID = base.StatusReport.UUID
-	if overlay.StatusReport.UUID != "" {
-		merged.StatusReport.UUID = overlay.StatusReport.UUID
-	}
+// Overlay 某一级的覆盖配置（缺省继承、给值覆盖、null 清除）
+type Overlay = yafconfig.Overlay
 
-	return merged
+// DecodeDocument 解析 ZooKeeper 中的配置文档，兼容旧格式的完整配置
+func DecodeDocument(data []byte) (Overlay, error) {
+	return yafconfig.DecodeDocument(data)
+}
+
+// Resolve 从默认配置开始依次应用各级覆盖配置
+func Resolve(layers ...Overlay) *YafConfig {
+	return yafconfig.Resolve(layers...)
 }
 
 // DefaultConfig 默认配置
 func DefaultConfig() *YafConfig {
-	return &YafConfig{
-		Capture: CaptureConfig{
-			Interface:      "eth0",
-			IPFIXPort:      18000,
-			IdleTimeout:    60,
-			ActiveTimeout:  60,
-			StatsInterval:  300,
-			EnableAppLabel: true,
-			EnableDPI:      false,
-			MaxPayload:     1024,
-		},
-		Filter: FilterConfig{
-			IPWhitelist: []string{},
-			IPBlacklist: []string{},
-			SrcPorts:    []int{},
-			DstPorts:    []int{},
-			BPFFilter:   "ip and not port 22",
-		},
-		Output: OutputConfig{
-			Fields: []string{
-				"flowStartMilliseconds",
-				"flowEndMilliseconds",
-				"sourceIPv4Address",
-				"destinationIPv4Address",
-				"sourceTransportPort",
-				"destinationTransportPort",
-				"protocolIdentifier",
-				"silkAppLabel",
-			},
-		},
-		StatusReport: StatusReportConfig{
-			StatusReportURL:        "",
-			StatusReportIntervalSec: 60,
-			UUID:                   "",
-		},
-	}
+	return yafconfig.DefaultConfig()
 }
-
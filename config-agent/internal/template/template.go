@@ -2,6 +2,8 @@ package template
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,16 +16,16 @@ import (
 	"go.uber.org/zap"
 )
 
-// YafInitTemplate yaf.init Lua 配置模板
+// YafInitTemplate yaf.init Lua 配置模板，字符串值经 lua 转义后放入双引号字面量
 const YafInitTemplate = `-- ========= YAF 配置 (自动生成，请勿手工修改) =========
 -- 生成时间: {{ .GeneratedAt }}
--- 集群: {{ .Cluster }}
--- 节点: {{ .NodeID }}
+-- 集群: {{ lua .Cluster }}
+-- 节点: {{ lua .NodeID }}
 
 -- 从网卡抓包
 input = {
   type = "pcap",
-  inf  = "{{ .Interface }}",
+  inf  = "{{ lua .Interface }}",
   export_interface = true,
 }
 
@@ -54,7 +56,7 @@ idle_timeout   = {{ .IdleTimeout }}
 active_timeout = {{ .ActiveTimeout }}
 
 -- BPF 过滤器
-filter = "{{ .BPFFilter }}"
+filter = "{{ lua .BPFFilter }}"
 
 -- 应用识别 / DPI
 applabel   = {{ .AppLabel }}
@@ -77,15 +79,15 @@ processor = {
   -- 输出字段列表
   output_fields = {
 {{- range $i, $field := .OutputFields }}
-    "{{ $field }}",
+    "{{ lua $field }}",
 {{- end }}
   },
   
   -- 状态上报配置
-  status_report_url = "{{ .StatusReportURL }}",
+  status_report_url = "{{ lua .StatusReportURL }}",
   status_report_interval_sec = {{ .StatusReportIntervalSec }},
 {{- if .UUID }}
-  uuid = "{{ .UUID }}",
+  uuid = "{{ lua .UUID }}",
 {{- end }}
 }
 `
@@ -125,7 +127,7 @@ func NewGenerator(
 	cluster, nodeID string,
 	logger *zap.Logger,
 ) (*Generator, error) {
-	tmpl, err := template.New("yaf.init").Parse(YafInitTemplate)
+	tmpl, err := template.New("yaf.init").Funcs(template.FuncMap{"lua": luaString}).Parse(YafInitTemplate)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -139,8 +141,15 @@ func NewGenerator(
 	}, nil
 }
 
-// Generate 生成配置文件
-func (g *Generator) Generate(cfg *config.YafConfig) error {
+// Generate 生成配置文件，返回写入内容的 SHA-256（十六进制）。
+// 字符串字段中的占位符先按本节点的集群、节点 ID、labels 与环境变量展开，有未定义的变量时不写入文件
+func (g *Generator) Generate(cfg *config.YafConfig, labels map[string]string) (string, error) {
+	vars := &config.Vars{Cluster: g.cluster, NodeID: g.nodeID, Labels: labels}
+	cfg, err := vars.ExpandConfig(cfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand placeholders: %w", err)
+	}
+
 	// 使用配置中的值，如果为空则使用硬编码的默认值
 	iface := cfg.Capture.Interface
 	if iface == "" {
@@ -219,24 +228,24 @@ func (g *Generator) Generate(cfg *config.YafConfig) error {
 	// 渲染模板
 	var buf bytes.Buffer
 	if err := g.tmpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("failed to render template: %w", err)
+		return "", fmt.Errorf("failed to render template: %w", err)
 	}
 
 	// 原子写入：先写临时文件，再重命名
 	tmpPath := g.configPath + ".tmp"
 	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
-		return fmt.Errorf("failed to write temp file: %w", err)
+		return "", fmt.Errorf("failed to write temp file: %w", err)
 	}
 
 	// 确保目录存在
 	dir := filepath.Dir(g.configPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create config dir: %w", err)
+		return "", fmt.Errorf("failed to create config dir: %w", err)
 	}
 
 	// 原子重命名
 	if err := os.Rename(tmpPath, g.configPath); err != nil {
-		return fmt.Errorf("failed to rename config file: %w", err)
+		return "", fmt.Errorf("failed to rename config file: %w", err)
 	}
 
 	g.logger.Info("[CONFIG_GENERATE] 配置文件生成成功",
@@ -247,7 +256,8 @@ func (g *Generator) Generate(cfg *config.YafConfig) error {
 		zap.String("bpf_filter", data.BPFFilter),
 		zap.Int("output_fields_count", len(outputFields)),
 	)
-	return nil
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
 }
 
 // buildBPFFilter 构建 BPF 过滤器
@@ -298,6 +308,33 @@ func (g *Generator) buildBPFFilter(cfg *config.YafConfig) string {
 	return strings.Join(parts, " and ")
 }
 
+// luaString 转义字符串，使其可以放入 Lua 的双引号字符串字面量：反斜杠、双引号与换行等控制字符都被转义，
+// 因此节点标签、环境变量等占位符的值不会破坏生成的文件，也不能注入 Lua 代码
+func luaString(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, "\\%03d", c)
+				continue
+			}
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
 func boolToLua(b bool) string {
 	if b {
 		return "true"
@@ -0,0 +1,61 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yf-web/config-agent/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestLuaString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "eth0", want: "eth0"},
+		{name: "quote", in: `a"b`, want: `a\"b`},
+		{name: "backslash", in: `a\b`, want: `a\\b`},
+		{name: "newline", in: "a\nb\r\tc", want: `a\nb\r\tc`},
+		{name: "control", in: "a\x00b\x7f", want: `a\000b\127`},
+		{name: "injection", in: `x", os.execute("id") --`, want: `x\", os.execute(\"id\") --`},
+		{name: "utf-8", in: "网卡", want: "网卡"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := luaString(tt.in); got != tt.want {
+				t.Fatalf("luaString(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateEscapesPlaceholderValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "yaf.init")
+	g, err := NewGenerator(path, "bj-dc1", "node-01", zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewGenerator() unexpected error: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Capture.Interface = "${label.nic}"
+	cfg.StatusReport.UUID = "${label.id}"
+	labels := map[string]string{"nic": `eth0" inf = "lo`, "id": "a\\b\nc"}
+	if _, err := g.Generate(cfg, labels); err != nil {
+		t.Fatalf("Generate() unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() unexpected error: %v", err)
+	}
+
+	out := string(data)
+	for _, want := range []string{`inf  = "eth0\" inf = \"lo",`, `uuid = "a\\b\nc",`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Generate() output missing %s:\n%s", want, out)
+		}
+	}
+}
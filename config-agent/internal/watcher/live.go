@@ -0,0 +1,143 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/yf-web/config-agent/internal/config"
+	"go.uber.org/zap"
+)
+
+// RegisterLive 设置在线节点中登记的 Agent 信息，并在 ZooKeeper 中创建临时节点。
+// 之后每次建立新会话都会重新创建，应在 Start 之前调用
+func (w *ConfigWatcher) RegisterLive(info config.AgentInfo) {
+	info.ClusterName = w.cluster
+	info.NodeID = w.nodeID
+
+	w.liveMu.Lock()
+	w.agentInfo = &info
+	w.liveMu.Unlock()
+
+	w.registerLive()
+}
+
+// reportApply 在在线节点中登记一次配置应用的结果及生效配置中的采集网卡，后端据此统计发布进度
+func (w *ConfigWatcher) reportApply(iface string, apply *config.ApplyState) {
+	w.liveMu.Lock()
+	if w.agentInfo == nil {
+		w.liveMu.Unlock()
+		return
+	}
+	w.agentInfo.Interface = iface
+	w.agentInfo.Apply = apply
+	w.liveMu.Unlock()
+
+	w.registerLive()
+}
+
+// reportVersions 生效配置未变化、但某一级发布了新版本（如内容相同的保存或回滚）时，
+// 只更新登记的版本号，沿用上一次应用的结果
+func (w *ConfigWatcher) reportVersions(versions *config.ApplyState) {
+	w.liveMu.Lock()
+	current := w.agentInfo
+	if current == nil || current.Apply == nil || current.Apply.Status != config.ApplyApplied ||
+		(current.Apply.GlobalVersion == versions.GlobalVersion &&
+			current.Apply.ClusterVersion == versions.ClusterVersion &&
+			current.Apply.NodeVersion == versions.NodeVersion &&
+			sameProfiles(current.Apply.Profiles, versions.Profiles)) {
+		w.liveMu.Unlock()
+		return
+	}
+	apply := *current.Apply
+	apply.GlobalVersion = versions.GlobalVersion
+	apply.ClusterVersion = versions.ClusterVersion
+	apply.NodeVersion = versions.NodeVersion
+	apply.Profiles = versions.Profiles
+	current.Apply = &apply
+	w.liveMu.Unlock()
+
+	w.registerLive()
+}
+
+// sameProfiles 判断两次加载继承的配置档版本是否相同
+func sameProfiles(a, b []config.ProfileRef) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// registerLive 创建或更新在线临时节点。上一个会话遗留的同名临时节点（Agent 快速重启时，
+// 旧会话尚未过期）会被删除后重新创建，使节点归属当前会话
+func (w *ConfigWatcher) registerLive() {
+	w.liveMu.Lock()
+	defer w.liveMu.Unlock()
+	if w.agentInfo == nil {
+		return
+	}
+
+	livePath := LivePath(w.cluster, w.nodeID)
+	data, err := json.Marshal(w.agentInfo)
+	if err != nil {
+		w.logger.Error("failed to marshal agent info", zap.Error(err))
+		return
+	}
+	if err := w.ensureParent(livePath); err != nil {
+		w.logger.Warn("failed to register live node", zap.String("path", livePath), zap.Error(err))
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		_, err = w.conn.Create(livePath, data, zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
+		if err != zk.ErrNodeExists || attempt == 3 {
+			break
+		}
+		var stat *zk.Stat
+		_, stat, err = w.conn.Get(livePath)
+		if err == zk.ErrNoNode {
+			continue
+		}
+		if err != nil {
+			break
+		}
+		if stat.EphemeralOwner == w.conn.SessionID() {
+			_, err = w.conn.Set(livePath, data, stat.Version)
+			break
+		}
+		if err = w.conn.Delete(livePath, stat.Version); err != nil && err != zk.ErrNoNode && err != zk.ErrBadVersion {
+			break
+		}
+	}
+	if err != nil {
+		w.logger.Warn("failed to register live node", zap.String("path", livePath), zap.Error(err))
+		return
+	}
+	w.logger.Info("registered live node",
+		zap.String("path", livePath),
+		zap.String("interface", w.agentInfo.Interface),
+	)
+}
+
+// ensureParent 逐级创建 p 的父节点（持久节点）
+func (w *ConfigWatcher) ensureParent(p string) error {
+	current := ""
+	for _, part := range strings.Split(path.Dir(p), "/") {
+		if part == "" {
+			continue
+		}
+		current += "/" + part
+		_, err := w.conn.Create(current, []byte{}, 0, zk.WorldACL(zk.PermAll))
+		if err != nil && err != zk.ErrNodeExists {
+			return fmt.Errorf("failed to create path %s: %w", current, err)
+		}
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package watcher
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
+	"sort"
 	"sync"
 	"time"
 
@@ -16,22 +18,61 @@ const (
 	ConfigBasePath = "/xnta/yaf-config"
 	GlobalPath     = "/xnta/yaf-config/global/config"
 	ClusterPath    = "/xnta/yaf-config/cluster"
+	SelectorsPath  = "/xnta/yaf-config/selectors"
 )
 
+// LivePath Agent 在线临时节点的路径
+func LivePath(cluster, nodeID string) string {
+	return fmt.Sprintf("%s/%s/live/%s", ClusterPath, cluster, nodeID)
+}
+
+// PinPath 灰度发布期间本节点固定某一级（global / cluster）配置版本的节点路径，存在时代替该级的配置节点
+func PinPath(cluster, nodeID, scope string) string {
+	return fmt.Sprintf("%s/%s/pins/%s/%s", ClusterPath, cluster, nodeID, scope)
+}
+
+// CanaryGuardPath 灰度发布期间某一级（global / cluster）配置的守护节点路径，本节点没有固定节点且不是灰度节点时
+// 以其中的基准版本代替该级的配置节点（灰度开始后才上线的节点）
+func CanaryGuardPath(cluster, scope string) string {
+	if scope == "global" {
+		return ConfigBasePath + "/global/canary"
+	}
+	return fmt.Sprintf("%s/%s/canary", ClusterPath, cluster)
+}
+
+// ClusterProfilesPath 集群挂载的配置档合并而成的配置档层，在全局配置之后、集群配置之前合并
+func ClusterProfilesPath(cluster string) string {
+	return fmt.Sprintf("%s/%s/profiles/cluster", ClusterPath, cluster)
+}
+
+// NodeProfilesPath 本节点挂载的配置档合并而成的配置档层，在集群的配置档层之后合并
+func NodeProfilesPath(cluster, nodeID string) string {
+	return fmt.Sprintf("%s/%s/profiles/nodes/%s", ClusterPath, cluster, nodeID)
+}
+
+// LabelsPath 后端登记的本节点标签（清单中的标签），与环境变量中的标签合并后用于匹配标签选择层
+func LabelsPath(cluster, nodeID string) string {
+	return fmt.Sprintf("%s/%s/labels/%s", ClusterPath, cluster, nodeID)
+}
+
 // ConfigWatcher ZK 配置监听器
 type ConfigWatcher struct {
-	conn        *zk.Conn
-	cluster     string
-	nodeID      string
-	logger      *zap.Logger
-	onChange    func(*config.YafConfig) error
-	stopCh      chan struct{}
-	mu          sync.RWMutex
-	lastConfig  *config.YafConfig
+	conn       *zk.Conn
+	cluster    string
+	nodeID     string
+	logger     *zap.Logger
+	onChange   func(*config.YafConfig, map[string]string) (string, error)
+	stopCh     chan struct{}
+	mu         sync.RWMutex
+	lastConfig *config.YafConfig
+	lastLabels map[string]string // 上次应用配置时的节点标签，标签变化时即使配置相同也重新生成（占位符可能引用标签）
+	liveMu     sync.Mutex
+	agentInfo  *config.AgentInfo
+	labels     map[string]string // 环境变量中的节点标签，覆盖后端登记的同名标签
 }
 
-// NewConfigWatcher 创建配置监听器
-func NewConfigWatcher(servers []string, cluster, nodeID string, logger *zap.Logger, onChange func(*config.YafConfig) error) (*ConfigWatcher, error) {
+// NewConfigWatcher 创建配置监听器。onChange 应用合并后的配置（及展开占位符使用的本节点标签），返回生成的配置文件的哈希
+func NewConfigWatcher(servers []string, cluster, nodeID string, logger *zap.Logger, onChange func(*config.YafConfig, map[string]string) (string, error)) (*ConfigWatcher, error) {
 	conn, eventCh, err := zk.Connect(servers, 10*time.Second)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to zookeeper: %w", err)
@@ -62,7 +103,8 @@ func (w *ConfigWatcher) handleConnectionEvents(eventCh <-chan zk.Event) {
 				zap.String("state", event.State.String()),
 			)
 			if event.State == zk.StateHasSession {
-				// 重新连接后重新加载配置
+				// 重新连接后重新登记在线节点（会话过期时临时节点已被删除）并重新加载配置
+				go w.registerLive()
 				go w.loadAndApplyConfig()
 			}
 		case <-w.stopCh:
@@ -71,6 +113,13 @@ func (w *ConfigWatcher) handleConnectionEvents(eventCh <-chan zk.Event) {
 	}
 }
 
+// SetLabels 设置本节点的标签（如来自环境变量），同名时覆盖后端登记的标签，应在 Start 之前调用
+func (w *ConfigWatcher) SetLabels(labels map[string]string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.labels = labels
+}
+
 // Start 启动监听
 func (w *ConfigWatcher) Start() error {
 	// 首次加载配置
@@ -107,26 +156,30 @@ func (w *ConfigWatcher) watchLoop() {
 		clusterPath := fmt.Sprintf("%s/%s/config", ClusterPath, w.cluster)
 		nodePath := fmt.Sprintf("%s/%s/nodes/%s/config", ClusterPath, w.cluster, w.nodeID)
 
-		// 创建 watch channels
-		var globalCh, clusterCh, nodeCh <-chan zk.Event
-
-		// Global config watch
-		_, _, globalCh, err := w.conn.GetW(globalPath)
-		if err != nil && err != zk.ErrNoNode {
-			w.logger.Warn("failed to watch global config", zap.Error(err))
-		}
-
-		// Cluster config watch
-		_, _, clusterCh, err = w.conn.GetW(clusterPath)
-		if err != nil && err != zk.ErrNoNode {
-			w.logger.Warn("failed to watch cluster config", zap.Error(err))
-		}
+		globalPinPath := PinPath(w.cluster, w.nodeID, "global")
+		clusterPinPath := PinPath(w.cluster, w.nodeID, "cluster")
+		globalGuardPath := CanaryGuardPath(w.cluster, "global")
+		clusterGuardPath := CanaryGuardPath(w.cluster, "cluster")
+		clusterProfilesPath := ClusterProfilesPath(w.cluster)
+		nodeProfilesPath := NodeProfilesPath(w.cluster, w.nodeID)
+		labelsPath := LabelsPath(w.cluster, w.nodeID)
 
-		// Node config watch
-		_, _, nodeCh, err = w.conn.GetW(nodePath)
-		if err != nil && err != zk.ErrNoNode {
-			w.logger.Warn("failed to watch node config", zap.Error(err))
-		}
+		// 创建 watch channels
+		var globalCh, clusterCh, nodeCh, globalPinCh, clusterPinCh, globalGuardCh, clusterGuardCh <-chan zk.Event
+		var clusterProfilesCh, nodeProfilesCh, labelsCh <-chan zk.Event
+
+		// 节点不存在时监听其创建，已存在时监听修改与删除
+		globalCh = w.watch(globalPath, "global")
+		clusterCh = w.watch(clusterPath, "cluster")
+		nodeCh = w.watch(nodePath, "node")
+		globalPinCh = w.watch(globalPinPath, "global pin")
+		clusterPinCh = w.watch(clusterPinPath, "cluster pin")
+		globalGuardCh = w.watch(globalGuardPath, "global canary")
+		clusterGuardCh = w.watch(clusterGuardPath, "cluster canary")
+		clusterProfilesCh = w.watch(clusterProfilesPath, "cluster profiles")
+		nodeProfilesCh = w.watch(nodeProfilesPath, "node profiles")
+		labelsCh = w.watch(labelsPath, "labels")
+		selectorsCh, stopSelectors := w.watchSelectors()
 
 		// 等待任意一个配置变化
 		select {
@@ -156,10 +209,60 @@ func (w *ConfigWatcher) watchLoop() {
 				zap.String("node_id", w.nodeID),
 				zap.String("path", nodePath),
 			)
+		case event := <-globalPinCh:
+			w.logger.Info("[CONFIG_CHANGE] 检测到全局配置的灰度固定版本变更",
+				zap.String("event_type", event.Type.String()),
+				zap.String("source", "global pin"),
+				zap.String("path", globalPinPath),
+			)
+		case event := <-clusterPinCh:
+			w.logger.Info("[CONFIG_CHANGE] 检测到集群配置的灰度固定版本变更",
+				zap.String("event_type", event.Type.String()),
+				zap.String("source", "cluster pin"),
+				zap.String("path", clusterPinPath),
+			)
+		case event := <-globalGuardCh:
+			w.logger.Info("[CONFIG_CHANGE] 检测到全局配置的灰度发布变更",
+				zap.String("event_type", event.Type.String()),
+				zap.String("source", "global canary"),
+				zap.String("path", globalGuardPath),
+			)
+		case event := <-clusterGuardCh:
+			w.logger.Info("[CONFIG_CHANGE] 检测到集群配置的灰度发布变更",
+				zap.String("event_type", event.Type.String()),
+				zap.String("source", "cluster canary"),
+				zap.String("path", clusterGuardPath),
+			)
+		case event := <-clusterProfilesCh:
+			w.logger.Info("[CONFIG_CHANGE] 检测到集群配置档变更",
+				zap.String("event_type", event.Type.String()),
+				zap.String("source", "cluster profiles"),
+				zap.String("path", clusterProfilesPath),
+			)
+		case event := <-nodeProfilesCh:
+			w.logger.Info("[CONFIG_CHANGE] 检测到节点配置档变更",
+				zap.String("event_type", event.Type.String()),
+				zap.String("source", "node profiles"),
+				zap.String("path", nodeProfilesPath),
+			)
+		case event := <-labelsCh:
+			w.logger.Info("[CONFIG_CHANGE] 检测到节点标签变更",
+				zap.String("event_type", event.Type.String()),
+				zap.String("source", "labels"),
+				zap.String("path", labelsPath),
+			)
+		case event := <-selectorsCh:
+			w.logger.Info("[CONFIG_CHANGE] 检测到标签选择层变更",
+				zap.String("event_type", event.Type.String()),
+				zap.String("source", "selectors"),
+				zap.String("path", event.Path),
+			)
 		case <-time.After(30 * time.Second):
 			// 定期刷新 watch（防止 session 过期）
+			stopSelectors()
 			continue
 		}
+		stopSelectors()
 
 		// 重新加载配置
 		if err := w.loadAndApplyConfig(); err != nil {
@@ -179,22 +282,51 @@ func (w *ConfigWatcher) loadAndApplyConfig() error {
 		zap.String("node_id", w.nodeID),
 	)
 
-	// 加载各级配置
-	globalCfg := w.loadConfig(GlobalPath)
+	// 加载各级配置；某一级读取失败时保留当前配置，避免误回退到上级配置。
+	// 全局与集群配置在灰度发布期间可能被固定在基准版本
+	apply := &config.ApplyState{}
+	globalCfg, err := w.loadPinned("global", GlobalPath, &apply.GlobalVersion)
+	if err != nil {
+		return err
+	}
+	// 配置档层位于全局与集群配置之间：先集群挂载的，再本节点挂载的
+	clusterProfiles, err := w.loadProfiles(ClusterProfilesPath(w.cluster), &apply.Profiles)
+	if err != nil {
+		return err
+	}
+	nodeProfiles, err := w.loadProfiles(NodeProfilesPath(w.cluster, w.nodeID), &apply.Profiles)
+	if err != nil {
+		return err
+	}
 	clusterPath := fmt.Sprintf("%s/%s/config", ClusterPath, w.cluster)
-	clusterCfg := w.loadConfig(clusterPath)
+	clusterCfg, err := w.loadPinned("cluster", clusterPath, &apply.ClusterVersion)
+	if err != nil {
+		return err
+	}
+	// 标签选择层位于集群与节点配置之间：按本节点的标签筛选，按优先级与名称依次合并
+	labels, err := w.loadLabels()
+	if err != nil {
+		return err
+	}
+	selectorCfgs, err := w.loadSelectors(labels)
+	if err != nil {
+		return err
+	}
 	nodePath := fmt.Sprintf("%s/%s/nodes/%s/config", ClusterPath, w.cluster, w.nodeID)
-	nodeCfg := w.loadConfig(nodePath)
+	nodeCfg, err := w.loadConfig(nodePath, &apply.NodeVersion)
+	if err != nil {
+		return err
+	}
 
-	// 合并配置：global → cluster → node
-	merged := config.DefaultConfig()
-	merged = config.MergeConfig(merged, globalCfg)
-	merged = config.MergeConfig(merged, clusterCfg)
-	merged = config.MergeConfig(merged, nodeCfg)
+	// 合并配置：default → global → profiles → cluster → selectors → node（与后端预览使用同一套合并逻辑）
+	layers := append([]config.Overlay{globalCfg, clusterProfiles, nodeProfiles, clusterCfg}, selectorCfgs...)
+	merged := config.Resolve(append(layers, nodeCfg)...)
 
 	w.logger.Info("[CONFIG_LOAD] 配置加载完成",
 		zap.Bool("has_global", globalCfg != nil),
+		zap.Int("profiles", len(apply.Profiles)),
 		zap.Bool("has_cluster", clusterCfg != nil),
+		zap.Int("selectors", len(selectorCfgs)),
 		zap.Bool("has_node", nodeCfg != nil),
 		zap.String("interface", merged.Capture.Interface),
 		zap.Int("ipfix_port", merged.Capture.IPFIXPort),
@@ -204,10 +336,11 @@ func (w *ConfigWatcher) loadAndApplyConfig() error {
 	)
 
 	// 检查配置是否变化
-	if w.configEqual(w.lastConfig, merged) {
+	if w.configEqual(w.lastConfig, merged) && maps.Equal(w.lastLabels, labels) {
 		w.logger.Info("[CONFIG_LOAD] 配置未变化，跳过应用",
 			zap.Duration("check_duration", time.Since(startTime)),
 		)
+		w.reportVersions(apply)
 		return nil
 	}
 
@@ -216,15 +349,24 @@ func (w *ConfigWatcher) loadAndApplyConfig() error {
 	)
 
 	w.lastConfig = merged
+	w.lastLabels = labels
 
 	// 调用回调应用配置
 	applyStartTime := time.Now()
 	if w.onChange != nil {
-		if err := w.onChange(merged); err != nil {
+		hash, err := w.onChange(merged, labels)
+		apply.ConfigHash = hash
+		apply.AppliedAt = time.Now()
+		if err != nil {
 			w.logger.Error("[CONFIG_APPLY] 配置应用失败",
 				zap.Error(err),
 				zap.Duration("apply_duration", time.Since(applyStartTime)),
 			)
+			// 下一次配置变化或重连时即使内容相同也重新应用
+			w.lastConfig = nil
+			apply.Status = config.ApplyFailed
+			apply.Error = err.Error()
+			w.reportApply(merged.Capture.Interface, apply)
 			return fmt.Errorf("failed to apply config: %w", err)
 		}
 		w.logger.Info("[CONFIG_APPLY] 配置应用成功",
@@ -232,27 +374,217 @@ func (w *ConfigWatcher) loadAndApplyConfig() error {
 			zap.Duration("total_duration", time.Since(startTime)),
 		)
 	}
+	apply.Status = config.ApplyApplied
+	w.reportApply(merged.Capture.Interface, apply)
 
 	return nil
 }
 
-// loadConfig 从 ZK 加载配置
-func (w *ConfigWatcher) loadConfig(path string) *config.YafConfig {
-	data, _, err := w.conn.Get(path)
+// loadConfig 从 ZK 加载某一级的覆盖配置，并将文档中的配置版本写入 version。
+// 节点不存在（未配置或已被删除）时返回 nil，表示完全继承上级；读取失败时返回错误
+func (w *ConfigWatcher) loadConfig(path string, version *int) (config.Overlay, error) {
+	data, err := w.loadDocument(path)
+	if data == nil || err != nil {
+		return nil, err
+	}
+
+	*version = config.DecodeMeta(data).Version
+	return w.decode(path, data), nil
+}
+
+// loadProfiles 从 ZK 加载配置档层，并将其中按顺序合并的配置档及版本追加到 profiles。
+// 节点不存在（未挂载配置档）时返回 nil
+func (w *ConfigWatcher) loadProfiles(path string, profiles *[]config.ProfileRef) (config.Overlay, error) {
+	data, err := w.loadDocument(path)
+	if data == nil || err != nil {
+		return nil, err
+	}
+
+	*profiles = append(*profiles, config.DecodeMeta(data).Profiles...)
+	return w.decode(path, data), nil
+}
+
+// loadLabels 读取后端登记的本节点标签，并以环境变量中的标签覆盖同名标签。
+// 未登记时只使用环境变量中的标签；登记内容无法解析时忽略
+func (w *ConfigWatcher) loadLabels() (map[string]string, error) {
+	labels := map[string]string{}
+	data, err := w.loadDocument(LabelsPath(w.cluster, w.nodeID))
 	if err != nil {
-		if err != zk.ErrNoNode {
-			w.logger.Warn("failed to get config", zap.String("path", path), zap.Error(err))
+		return nil, err
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &labels); err != nil {
+			w.logger.Warn("failed to parse registered labels", zap.Error(err))
+			labels = map[string]string{}
 		}
-		return nil
 	}
+	for key, value := range w.labels {
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// loadSelectors 加载与本节点匹配的标签选择层，按优先级与名称排序后返回各层的覆盖配置
+func (w *ConfigWatcher) loadSelectors(labels map[string]string) ([]config.Overlay, error) {
+	names, _, err := w.conn.Children(SelectorsPath)
+	if err == zk.ErrNoNode {
+		return nil, nil
+	}
+	if err != nil {
+		w.logger.Warn("failed to list selectors", zap.Error(err))
+		return nil, fmt.Errorf("failed to list selectors: %w", err)
+	}
+
+	type matched struct {
+		selector *config.Selector
+		overlay  config.Overlay
+	}
+	var layers []matched
+	for _, name := range names {
+		path := SelectorsPath + "/" + name
+		data, err := w.loadDocument(path)
+		if data == nil || err != nil {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		selector := config.DecodeMeta(data).Selector
+		if selector == nil || !selector.Matches(w.cluster, labels) {
+			continue
+		}
+		if overlay := w.decode(path, data); overlay != nil {
+			layers = append(layers, matched{selector: selector, overlay: overlay})
+		}
+	}
+	sort.Slice(layers, func(i, j int) bool {
+		return config.SelectorLess(layers[i].selector, layers[j].selector)
+	})
+
+	overlays := make([]config.Overlay, 0, len(layers))
+	matchedNames := make([]string, 0, len(layers))
+	for _, layer := range layers {
+		overlays = append(overlays, layer.overlay)
+		matchedNames = append(matchedNames, layer.selector.Name)
+	}
+	if len(matchedNames) > 0 {
+		w.logger.Info("[CONFIG_LOAD] 匹配的标签选择层", zap.Strings("selectors", matchedNames))
+	}
+	return overlays, nil
+}
+
+// loadDocument 读取配置文档，节点不存在时返回 nil
+func (w *ConfigWatcher) loadDocument(path string) ([]byte, error) {
+	data, _, err := w.conn.Get(path)
+	if err == zk.ErrNoNode {
+		return nil, nil
+	}
+	if err != nil {
+		w.logger.Warn("failed to get config", zap.String("path", path), zap.Error(err))
+		return nil, fmt.Errorf("failed to get config %s: %w", path, err)
+	}
+	return data, nil
+}
 
-	var cfg config.YafConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
+// decode 解析配置文档，无法解析时忽略该级配置
+func (w *ConfigWatcher) decode(path string, data []byte) config.Overlay {
+	overlay, err := config.DecodeDocument(data)
+	if err != nil {
 		w.logger.Warn("failed to parse config", zap.String("path", path), zap.Error(err))
 		return nil
 	}
+	return overlay
+}
+
+// loadPinned 加载 scope 级配置：本节点的固定节点存在时从固定节点加载；否则该级正在灰度发布且本节点不是灰度节点时
+// 使用守护节点中的基准版本；其余情况从该级的配置节点 path 加载
+func (w *ConfigWatcher) loadPinned(scope, path string, version *int) (config.Overlay, error) {
+	pinPath := PinPath(w.cluster, w.nodeID, scope)
+	exists, _, err := w.conn.Exists(pinPath)
+	if err != nil {
+		w.logger.Warn("failed to check pinned config", zap.String("path", pinPath), zap.Error(err))
+		return nil, fmt.Errorf("failed to check pinned config %s: %w", pinPath, err)
+	}
+	if exists {
+		w.logger.Info("[CONFIG_LOAD] 使用灰度固定版本", zap.String("path", pinPath))
+		return w.loadConfig(pinPath, version)
+	}
 
-	return &cfg
+	guardPath := CanaryGuardPath(w.cluster, scope)
+	data, err := w.loadDocument(guardPath)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		var guard config.CanaryGuard
+		if err := json.Unmarshal(data, &guard); err != nil {
+			w.logger.Warn("failed to parse canary guard", zap.String("path", guardPath), zap.Error(err))
+		} else if !guard.Includes(w.cluster, w.nodeID) {
+			w.logger.Info("[CONFIG_LOAD] 灰度发布中，本节点不是灰度节点，使用基准版本", zap.String("path", guardPath))
+			*version = config.DecodeMeta(guard.Stable).Version
+			return w.decode(guardPath, guard.Stable), nil
+		}
+	}
+	return w.loadConfig(path, version)
+}
+
+// watch 监听某一级配置节点：存在时用 GetW 监听修改与删除，不存在时用 ExistsW 监听创建
+func (w *ConfigWatcher) watch(path, source string) <-chan zk.Event {
+	_, _, ch, err := w.conn.GetW(path)
+	if err == zk.ErrNoNode {
+		var exists bool
+		exists, _, ch, err = w.conn.ExistsW(path)
+		if err == nil && exists {
+			// 两次调用之间节点被创建，直接触发一次重新加载
+			created := make(chan zk.Event, 1)
+			created <- zk.Event{Type: zk.EventNodeCreated, Path: path}
+			return created
+		}
+	}
+	if err != nil {
+		w.logger.Warn("failed to watch config", zap.String("source", source), zap.Error(err))
+		return nil
+	}
+	return ch
+}
+
+// watchSelectors 监听标签选择层目录的增删与每个选择层的修改，任一变化时从返回的 channel 收到事件；
+// 每轮监听结束时调用 stop 释放转发的 goroutine
+func (w *ConfigWatcher) watchSelectors() (<-chan zk.Event, func()) {
+	merged := make(chan zk.Event, 1)
+	done := make(chan struct{})
+	stop := func() { close(done) }
+	forward := func(ch <-chan zk.Event) {
+		if ch == nil {
+			return
+		}
+		go func() {
+			select {
+			case event := <-ch:
+				select {
+				case merged <- event:
+				default:
+				}
+			case <-done:
+			}
+		}()
+	}
+
+	names, _, ch, err := w.conn.ChildrenW(SelectorsPath)
+	if err == zk.ErrNoNode {
+		// 目录尚不存在时监听其创建
+		forward(w.watch(SelectorsPath, "selectors"))
+		return merged, stop
+	}
+	if err != nil {
+		w.logger.Warn("failed to watch selectors", zap.Error(err))
+		return merged, stop
+	}
+	forward(ch)
+	for _, name := range names {
+		forward(w.watch(SelectorsPath+"/"+name, "selector "+name))
+	}
+	return merged, stop
 }
 
 // configEqual 比较两个配置是否相等
@@ -268,4 +600,3 @@ func (w *ConfigWatcher) configEqual(a, b *config.YafConfig) bool {
 	bJSON, _ := json.Marshal(b)
 	return string(aJSON) == string(bJSON)
 }
-
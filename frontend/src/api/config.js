@@ -8,6 +8,23 @@ const api = axios.create({
   }
 })
 
+// 请求拦截器：携带登录 token
+api.interceptors.request.use(config => {
+  const token = localStorage.getItem('yaf_token') || sessionStorage.getItem('yaf_token')
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`
+  }
+  return config
+})
+
+// 清除本地登录状态
+export const clearAuth = () => {
+  localStorage.removeItem('yaf_token')
+  localStorage.removeItem('yaf_user')
+  sessionStorage.removeItem('yaf_token')
+  sessionStorage.removeItem('yaf_user')
+}
+
 // 响应拦截器
 api.interceptors.response.use(
   response => {
@@ -24,6 +41,14 @@ api.interceptors.response.use(
     if (!error.response) {
       return Promise.reject(new Error('无法连接到后端服务，请确保后端已启动'))
     }
+    // 未登录或登录过期，跳转登录页
+    if (error.response.status === 401 && !error.config.url.endsWith('/auth/login')) {
+      clearAuth()
+      window.location.href = '/login'
+    }
+    if (error.response.data && error.response.data.message) {
+      return Promise.reject(new Error(error.response.data.message))
+    }
     return Promise.reject(error)
   }
 )
@@ -31,6 +56,9 @@ api.interceptors.response.use(
 // 用户登录
 export const login = (username, password) => 
   api.post('/auth/login', { username, password })
+export const logout = () => api.post('/auth/logout')
+export const changePassword = (oldPassword, newPassword) =>
+  api.post('/auth/password', { old_password: oldPassword, new_password: newPassword })
 
 // 系统设置
 export const getSettings = () => api.get('/settings')
@@ -39,6 +67,81 @@ export const saveSettings = (settings) => api.post('/settings', settings)
 // 系统状态
 export const getSystemStatus = () => api.get('/status')
 
+// config-agent 在线状态，params: { cluster, status: 'online' | 'offline' }
+export const listAgents = (params) => api.get('/agents', { params })
+
+// 发布进度：各集群的节点是否已应用最新的配置版本
+export const listRollouts = () => api.get('/rollout')
+export const getClusterRollout = (cluster) => api.get(`/clusters/${cluster}/rollout`)
+
+// 数据库与 ZooKeeper 的配置漂移（仅管理员）
+export const getDrift = () => api.get('/sync/drift')
+export const repairDrift = () => api.post('/sync/drift/repair')
+export const importFromZK = (dryRun) => api.post('/sync/import', null, { params: { dry_run: dryRun } })
+
+// 灰度发布：新版本先只对选出的灰度节点生效，确认后全量或回滚
+// payload: { scope, cluster_name, config, base_version, selector: { labels, percent }, auto_promote, timeout_seconds }
+export const listCanaries = (params) => api.get('/canaries', { params })
+export const getCanary = (id) => api.get(`/canaries/${id}`)
+export const createCanary = (payload) => api.post('/canaries', payload)
+export const promoteCanary = (id) => api.post(`/canaries/${id}/promote`)
+export const rollbackCanary = (id, reason) => api.post(`/canaries/${id}/rollback`, { reason })
+
+// 草稿与评审：审批数达到该级配置的要求后才保存为新版本并发布
+// payload: { scope, cluster_name, node_id, title, description, config, deleted, base_version, submit }
+export const getReviewPolicy = () => api.get('/review/policy')
+export const listDrafts = (params) => api.get('/drafts', { params })
+export const getDraft = (id) => api.get(`/drafts/${id}`)
+export const createDraft = (payload) => api.post('/drafts', payload)
+export const updateDraft = (id, payload) => api.put(`/drafts/${id}`, payload)
+export const closeDraft = (id) => api.delete(`/drafts/${id}`)
+export const submitDraft = (id) => api.post(`/drafts/${id}/submit`)
+export const approveDraft = (id, comment) => api.post(`/drafts/${id}/approve`, { comment })
+export const rejectDraft = (id, comment) => api.post(`/drafts/${id}/reject`, { comment })
+export const commentDraft = (id, comment) => api.post(`/drafts/${id}/comments`, { comment })
+
+// 定时发布：到 publish_at 时保存为新版本并发布
+// payload: { scope, cluster_name, node_id, config, base_version, publish_at }
+export const listSchedules = (params) => api.get('/schedules', { params })
+export const createSchedule = (payload) => api.post('/schedules', payload)
+export const rescheduleSchedule = (id, publishAt) => api.put(`/schedules/${id}`, { publish_at: publishAt })
+export const cancelSchedule = (id) => api.delete(`/schedules/${id}`)
+
+// 配置档：可复用的命名覆盖配置，挂载到集群或节点后在全局配置与集群配置之间按顺序合并
+// payload: { name, description, config, base_version }
+export const listProfiles = () => api.get('/profiles')
+export const getProfile = (name) => api.get(`/profiles/${name}`)
+export const createProfile = (payload) => api.post('/profiles', payload)
+export const saveProfile = (name, payload) => api.put(`/profiles/${name}`, payload)
+export const deleteProfile = (name) => api.delete(`/profiles/${name}`)
+export const getProfileHistory = (name, limit = 20) =>
+  api.get(`/profiles/${name}/history`, { params: { limit } })
+// 集群（node 为空）或节点挂载的配置档，profiles 为按合并顺序排列的名称
+const profilesPath = (cluster, node) =>
+  node ? `/clusters/${cluster}/nodes/${node}/profiles` : `/clusters/${cluster}/profiles`
+export const getAttachedProfiles = (cluster, node) => api.get(profilesPath(cluster, node))
+export const setAttachedProfiles = (cluster, node, profiles) => api.put(profilesPath(cluster, node), { profiles })
+
+// 标签选择层：带有全部 labels 的节点在集群配置之后、节点配置之前按 priority 从低到高合并
+// payload: { name, description, cluster_name, labels, priority, config, base_version }
+export const listSelectors = () => api.get('/selectors')
+export const getSelector = (name) => api.get(`/selectors/${name}`)
+export const createSelector = (payload) => api.post('/selectors', payload)
+export const saveSelector = (name, payload) => api.put(`/selectors/${name}`, payload)
+export const deleteSelector = (name, baseVersion) => api.delete(`/selectors/${name}`, ifMatch(baseVersion))
+
+// 通知订阅（仅管理员）：按事件类型与配置范围过滤，通过 webhook 或邮件投递
+// payload: { name, events, scope, cluster_name, channel, target, secret, enabled }
+export const listSubscriptions = () => api.get('/notifications/subscriptions')
+export const createSubscription = (payload) => api.post('/notifications/subscriptions', payload)
+export const updateSubscription = (id, payload) => api.put(`/notifications/subscriptions/${id}`, payload)
+export const deleteSubscription = (id) => api.delete(`/notifications/subscriptions/${id}`)
+export const testSubscription = (id) => api.post(`/notifications/subscriptions/${id}/test`)
+
+// 通知投递记录，params: { subscription_id, status, limit }
+export const listDeliveries = (params) => api.get('/notifications/deliveries', { params })
+export const retryDelivery = (id) => api.post(`/notifications/deliveries/${id}/retry`)
+
 // 获取支持的字段列表
 export const getSupportedFields = () => api.get('/fields')
 
@@ -47,34 +150,107 @@ export const getDefaultConfig = () => api.get('/config/default')
 
 // 全局配置
 export const getGlobalConfig = () => api.get('/config/global')
-export const saveGlobalConfig = (config, createdBy) => 
-  api.post('/config/global', { config, created_by: createdBy })
+export const saveGlobalConfig = (config, baseVersion) => 
+  api.post('/config/global', { config, base_version: baseVersion })
 export const getGlobalConfigHistory = (limit = 20) => 
   api.get('/config/global/history', { params: { limit } })
 
+// 计算 before → after 的 JSON Merge Patch，只包含发生变化的字段
+export const buildMergePatch = (before, after) => {
+  const isObject = (v) => v !== null && typeof v === 'object' && !Array.isArray(v)
+  const patch = {}
+  Object.keys(after || {}).forEach(key => {
+    const oldValue = before ? before[key] : undefined
+    const newValue = after[key]
+    if (isObject(oldValue) && isObject(newValue)) {
+      const sub = buildMergePatch(oldValue, newValue)
+      if (Object.keys(sub).length > 0) patch[key] = sub
+    } else if (JSON.stringify(oldValue) !== JSON.stringify(newValue)) {
+      patch[key] = newValue
+    }
+  })
+  return patch
+}
+
+// 将 JSON Merge Patch 应用到 target 上，返回新对象（null 表示删除字段）
+export const applyMergePatch = (target, patch) => {
+  const isObject = (v) => v !== null && typeof v === 'object' && !Array.isArray(v)
+  const result = isObject(target) ? JSON.parse(JSON.stringify(target)) : {}
+  Object.keys(patch || {}).forEach(key => {
+    const value = patch[key]
+    if (value === null) {
+      delete result[key]
+    } else if (isObject(value)) {
+      result[key] = applyMergePatch(result[key], value)
+    } else {
+      result[key] = value
+    }
+  })
+  return result
+}
+
+// 给出 baseVersion 时通过 If-Match 声明所基于的版本，期间被他人修改会返回 409
+const ifMatch = (baseVersion) =>
+  baseVersion !== undefined ? { headers: { 'If-Match': `"${baseVersion}"` } } : {}
+
+// 合并补丁请求头
+const mergePatchOptions = (baseVersion) => {
+  const options = ifMatch(baseVersion)
+  options.headers = { ...options.headers, 'Content-Type': 'application/merge-patch+json' }
+  return options
+}
+
 // 集群配置
+// 集群清单：描述、负责人、标签与配置概况
 export const listClusters = () => api.get('/clusters')
+export const createCluster = (cluster) => api.post('/clusters', cluster)
+export const getCluster = (cluster) => api.get(`/clusters/${cluster}`)
+export const updateCluster = (cluster, meta) => api.put(`/clusters/${cluster}`, meta)
+// 删除集群清单记录，集群仍有配置时需先 deleteClusterConfig
+export const deleteCluster = (cluster) => api.delete(`/clusters/${cluster}`)
 export const getClusterConfig = (cluster) => api.get(`/config/cluster/${cluster}`)
-export const saveClusterConfig = (cluster, config, createdBy) =>
-  api.post(`/config/cluster/${cluster}`, { config, created_by: createdBy })
+export const saveClusterConfig = (cluster, config) =>
+  api.post(`/config/cluster/${cluster}`, { config })
+export const patchClusterConfig = (cluster, patch, baseVersion) =>
+  api.patch(`/config/cluster/${cluster}`, patch, mergePatchOptions(baseVersion))
+// 删除集群（集群及其下所有节点的配置），baseVersion 为集群配置的当前版本
+export const deleteClusterConfig = (cluster, baseVersion) =>
+  api.delete(`/config/cluster/${cluster}`, ifMatch(baseVersion))
+export const listArchivedClusters = () => api.get('/clusters/archived')
+export const archiveCluster = (cluster) => api.post(`/clusters/${cluster}/archive`)
+export const unarchiveCluster = (cluster) => api.delete(`/clusters/${cluster}/archive`)
 export const getClusterConfigHistory = (cluster, limit = 20) =>
   api.get(`/config/cluster/${cluster}/history`, { params: { limit } })
 
 // 节点配置
+// 节点清单
 export const listNodes = (cluster) => api.get(`/clusters/${cluster}/nodes`)
+export const createNode = (cluster, node) => api.post(`/clusters/${cluster}/nodes`, node)
+export const getNode = (cluster, node) => api.get(`/clusters/${cluster}/nodes/${node}`)
+export const updateNode = (cluster, node, meta) => api.put(`/clusters/${cluster}/nodes/${node}`, meta)
+// 删除节点清单记录，节点仍有配置时需先 deleteNodeConfig
+export const deleteNode = (cluster, node) => api.delete(`/clusters/${cluster}/nodes/${node}`)
 export const getNodeConfig = (cluster, node) => api.get(`/config/cluster/${cluster}/node/${node}`)
-export const saveNodeConfig = (cluster, node, config, createdBy) =>
-  api.post(`/config/cluster/${cluster}/node/${node}`, { config, created_by: createdBy })
+export const saveNodeConfig = (cluster, node, config) =>
+  api.post(`/config/cluster/${cluster}/node/${node}`, { config })
+export const patchNodeConfig = (cluster, node, patch, baseVersion) =>
+  api.patch(`/config/cluster/${cluster}/node/${node}`, patch, mergePatchOptions(baseVersion))
+// 删除节点覆盖配置，节点回退到集群配置
+export const deleteNodeConfig = (cluster, node, baseVersion) =>
+  api.delete(`/config/cluster/${cluster}/node/${node}`, ifMatch(baseVersion))
 export const getNodeConfigHistory = (cluster, node, limit = 20) =>
   api.get(`/config/cluster/${cluster}/node/${node}/history`, { params: { limit } })
 
+// 获取节点生效配置及各字段来源
+export const getEffectiveNodeConfig = (cluster, node) =>
+  api.get(`/config/cluster/${cluster}/node/${node}/effective`)
+
 // 配置回滚
-export const rollbackConfig = (scope, clusterName, nodeId, version, createdBy) =>
+export const rollbackConfig = (scope, clusterName, nodeId, version) =>
   api.post('/config/rollback', {
     scope,
     cluster_name: clusterName,
     node_id: nodeId,
-    version,
-    created_by: createdBy
+    version
   })
 
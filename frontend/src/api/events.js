@@ -0,0 +1,105 @@
+import { clearAuth } from './config'
+
+// 实时事件流 GET /api/v1/events（Server-Sent Events）。
+// EventSource 不能携带 Authorization 请求头，这里用 fetch 读取事件流，断线后携带 Last-Event-ID 重连。
+// 除服务端事件外，连接建立与断开时分别派发 stream.open / stream.closed
+
+const listeners = new Map() // 事件类型 -> 处理函数集合，'*' 接收所有事件
+let controller = null
+let lastEventId = ''
+let retryDelay = 3000
+let retryTimer = null
+
+// 监听某类事件，返回取消监听的函数
+export const onServerEvent = (type, handler) => {
+  if (!listeners.has(type)) {
+    listeners.set(type, new Set())
+  }
+  listeners.get(type).add(handler)
+  return () => listeners.get(type).delete(handler)
+}
+
+const dispatch = (event) => {
+  for (const type of [event.type, '*']) {
+    for (const handler of listeners.get(type) || []) {
+      try {
+        handler(event)
+      } catch (error) {
+        console.error('Event handler failed:', error)
+      }
+    }
+  }
+}
+
+// 解析一条 SSE 消息（以空行分隔的若干行）
+const handleMessage = (block) => {
+  let data = ''
+  for (const line of block.split('\n')) {
+    if (line.startsWith(':')) continue // 心跳
+    const idx = line.indexOf(':')
+    const field = idx < 0 ? line : line.slice(0, idx)
+    const value = idx < 0 ? '' : line.slice(idx + 1).replace(/^ /, '')
+    if (field === 'id') lastEventId = value
+    else if (field === 'data') data += value
+    else if (field === 'retry' && /^\d+$/.test(value)) retryDelay = Number(value)
+  }
+  if (data) {
+    dispatch(JSON.parse(data))
+  }
+}
+
+const run = async (signal) => {
+  try {
+    const token = localStorage.getItem('yaf_token') || sessionStorage.getItem('yaf_token')
+    const headers = { Accept: 'text/event-stream', Authorization: `Bearer ${token}` }
+    if (lastEventId) {
+      headers['Last-Event-ID'] = lastEventId
+    }
+    const res = await fetch('/api/v1/events', { headers, signal })
+    if (res.status === 401) {
+      clearAuth()
+      window.location.href = '/login'
+      return
+    }
+    if (!res.ok) {
+      throw new Error(`HTTP ${res.status}`)
+    }
+
+    dispatch({ type: 'stream.open' })
+    const reader = res.body.pipeThrough(new TextDecoderStream()).getReader()
+    let buffer = ''
+    for (;;) {
+      const { value, done } = await reader.read()
+      if (done) break
+      buffer += value
+      let idx
+      while ((idx = buffer.indexOf('\n\n')) >= 0) {
+        handleMessage(buffer.slice(0, idx))
+        buffer = buffer.slice(idx + 2)
+      }
+    }
+  } catch (error) {
+    if (signal.aborted) return
+    console.error('Event stream failed:', error)
+  }
+  if (signal.aborted) return
+
+  dispatch({ type: 'stream.closed' })
+  retryTimer = setTimeout(() => run(signal), retryDelay)
+}
+
+// 建立事件流连接，已连接时不重复建立
+export const connectEvents = () => {
+  if (controller) return
+  controller = new AbortController()
+  run(controller.signal)
+}
+
+// 断开事件流连接（退出登录时）
+export const disconnectEvents = () => {
+  if (!controller) return
+  controller.abort()
+  controller = null
+  clearTimeout(retryTimer)
+  lastEventId = ''
+}
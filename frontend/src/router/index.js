@@ -36,12 +36,42 @@ const routes = [
     component: () => import('../views/NodeConfig.vue'),
     meta: { title: '节点配置' }
   },
+  {
+    path: '/profiles',
+    name: 'Profiles',
+    component: () => import('../views/Profiles.vue'),
+    meta: { title: '配置档' }
+  },
+  {
+    path: '/selectors',
+    name: 'Selectors',
+    component: () => import('../views/Selectors.vue'),
+    meta: { title: '标签选择层' }
+  },
+  {
+    path: '/canaries',
+    name: 'Canaries',
+    component: () => import('../views/Canaries.vue'),
+    meta: { title: '灰度发布' }
+  },
+  {
+    path: '/drafts',
+    name: 'Drafts',
+    component: () => import('../views/Drafts.vue'),
+    meta: { title: '配置评审' }
+  },
   {
     path: '/history',
     name: 'History',
     component: () => import('../views/ConfigHistory.vue'),
     meta: { title: '配置历史' }
   },
+  {
+    path: '/notifications',
+    name: 'Notifications',
+    component: () => import('../views/Notifications.vue'),
+    meta: { title: '通知' }
+  },
   {
     path: '/settings',
     name: 'Settings',
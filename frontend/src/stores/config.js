@@ -1,11 +1,13 @@
 import { defineStore } from 'pinia'
 import { ref } from 'vue'
-import { getSupportedFields, getDefaultConfig } from '../api/config'
+import { getSupportedFields, getDefaultConfig, getReviewPolicy } from '../api/config'
 
 export const useConfigStore = defineStore('config', () => {
   const supportedFields = ref([])
   const defaultConfig = ref(null)
   const loading = ref(false)
+  // 各级配置发布前需要的审批数，大于 0 时只能提交草稿评审
+  const reviewPolicy = ref(null)
 
   const fetchSupportedFields = async () => {
     if (supportedFields.value.length > 0) return
@@ -27,6 +29,18 @@ export const useConfigStore = defineStore('config', () => {
     }
   }
 
+  const fetchReviewPolicy = async () => {
+    if (reviewPolicy.value) return reviewPolicy.value
+    try {
+      const res = await getReviewPolicy()
+      reviewPolicy.value = res.data
+    } catch (error) {
+      console.error('Failed to fetch review policy:', error)
+      return {}
+    }
+    return reviewPolicy.value
+  }
+
   const init = async () => {
     loading.value = true
     await Promise.all([fetchSupportedFields(), fetchDefaultConfig()])
@@ -37,9 +51,11 @@ export const useConfigStore = defineStore('config', () => {
     supportedFields,
     defaultConfig,
     loading,
+    reviewPolicy,
     init,
     fetchSupportedFields,
-    fetchDefaultConfig
+    fetchDefaultConfig,
+    fetchReviewPolicy
   }
 })
 
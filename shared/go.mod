@@ -0,0 +1,3 @@
+module github.com/yf-web/shared
+
+go 1.21
@@ -0,0 +1,34 @@
+package yafconfig
+
+import "time"
+
+// 配置应用结果
+const (
+	ApplyApplied = "applied" // 已生成 yaf.init 并重启进程
+	ApplyFailed  = "failed"  // 生成配置文件或重启进程失败
+)
+
+// AgentInfo config-agent 在 ZooKeeper 临时节点 cluster/<cluster>/live/<node> 中登记的运行信息。
+// 临时节点随 Agent 的会话断开自动消失，后端据此判断 Agent 是否在线
+type AgentInfo struct {
+	ClusterName  string            `json:"cluster_name"`
+	NodeID       string            `json:"node_id"`
+	Hostname     string            `json:"hostname"`
+	AgentVersion string            `json:"agent_version"`
+	StartedAt    time.Time         `json:"started_at"`
+	Interface    string            `json:"interface"`        // 当前生效配置中的采集网卡
+	Labels       map[string]string `json:"labels,omitempty"` // 环境变量 YAF_NODE_LABELS 中的标签，匹配标签选择层时覆盖清单中的同名标签
+	Apply        *ApplyState       `json:"apply,omitempty"`
+}
+
+// ApplyState Agent 最近一次应用配置的结果。各级版本取自配置文档的 _meta，该级没有配置时为 0
+type ApplyState struct {
+	GlobalVersion  int          `json:"global_version"`
+	ClusterVersion int          `json:"cluster_version"`
+	NodeVersion    int          `json:"node_version"`
+	Profiles       []ProfileRef `json:"profiles,omitempty"` // 继承的配置档版本，集群挂载的在前、节点挂载的在后
+	ConfigHash     string       `json:"config_hash"`        // 生成的 yaf.init 的 SHA-256，生成失败时为空
+	Status         string       `json:"status"`             // applied / failed
+	Error          string       `json:"error,omitempty"`
+	AppliedAt      time.Time    `json:"applied_at"`
+}
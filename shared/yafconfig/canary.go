@@ -0,0 +1,27 @@
+package yafconfig
+
+import "encoding/json"
+
+// CanaryGuard 灰度发布期间写在该级配置旁的守护节点（global/canary、cluster/<cluster>/canary）。
+// 开始灰度时已知的非灰度节点另有各自的固定节点；守护节点覆盖之后才上线或登记的节点：
+// 没有固定节点且不在 Nodes 中的节点使用 Stable（基准版本的配置文档），不会直接应用灰度中的新版本
+type CanaryGuard struct {
+	Nodes  []string        `json:"nodes"`  // 灰度节点，格式见 CanaryNodeKey
+	Stable json.RawMessage `json:"stable"` // 基准版本的配置文档
+}
+
+// CanaryNodeKey 灰度节点在 CanaryGuard.Nodes 中的写法
+func CanaryNodeKey(cluster, nodeID string) string {
+	return cluster + "/" + nodeID
+}
+
+// Includes 节点是否为灰度节点
+func (g *CanaryGuard) Includes(cluster, nodeID string) bool {
+	key := CanaryNodeKey(cluster, nodeID)
+	for _, node := range g.Nodes {
+		if node == key {
+			return true
+		}
+	}
+	return false
+}
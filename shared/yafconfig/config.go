@@ -0,0 +1,136 @@
+// Package yafconfig 定义后端与 config-agent 共用的 YAF 配置模型、默认值、合并逻辑与 Agent 在线信息
+package yafconfig
+
+// CaptureConfig 采集配置
+type CaptureConfig struct {
+	Interface      string `json:"interface"`       // 网卡名称，如 eth0
+	IPFIXPort      int    `json:"ipfix_port"`      // IPFIX 输出端口，如 18000
+	IdleTimeout    int    `json:"idle_timeout"`    // 空闲超时（秒）
+	ActiveTimeout  int    `json:"active_timeout"`  // 活跃超时（秒）
+	StatsInterval  int    `json:"stats_interval"`  // 统计输出间隔（秒）
+	EnableAppLabel bool   `json:"enable_applabel"` // 启用应用识别
+	EnableDPI      bool   `json:"enable_dpi"`      // 启用 DPI
+	MaxPayload     int    `json:"max_payload"`     // 最大载荷字节数
+}
+
+// FilterConfig 过滤配置
+type FilterConfig struct {
+	IPWhitelist []string `json:"ip_whitelist"`
+	IPBlacklist []string `json:"ip_blacklist"`
+	SrcPorts    []int    `json:"src_ports"`
+	DstPorts    []int    `json:"dst_ports"`
+	BPFFilter   string   `json:"bpf_filter"`
+}
+
+// OutputConfig 输出配置
+type OutputConfig struct {
+	Fields []string `json:"fields"`
+}
+
+// StatusReportConfig 状态上报配置
+type StatusReportConfig struct {
+	StatusReportURL         string `json:"status_report_url"`          // 状态上报 URL，例如 "http://example.com/api/uploadStatus"
+	StatusReportIntervalSec int    `json:"status_report_interval_sec"` // 状态上报间隔（秒），默认 60
+	UUID                    string `json:"uuid"`                       // 容器主机名，如果为空则从环境变量 HOSTNAME 获取
+}
+
+// YafConfig YAF 完整配置
+type YafConfig struct {
+	Capture      CaptureConfig      `json:"capture"`
+	Filter       FilterConfig       `json:"filter"`
+	Output       OutputConfig       `json:"output"`
+	StatusReport StatusReportConfig `json:"status_report"`
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() *YafConfig {
+	return &YafConfig{
+		Capture: CaptureConfig{
+			Interface:      "eth0",
+			IPFIXPort:      18000,
+			IdleTimeout:    60,
+			ActiveTimeout:  60,
+			StatsInterval:  300,
+			EnableAppLabel: true,
+			EnableDPI:      false,
+			MaxPayload:     1024,
+		},
+		Filter: FilterConfig{
+			IPWhitelist: []string{},
+			IPBlacklist: []string{},
+			SrcPorts:    []int{},
+			DstPorts:    []int{},
+			BPFFilter:   "ip and not port 22",
+		},
+		Output: OutputConfig{
+			Fields: []string{
+				"flowStartMilliseconds",
+				"flowEndMilliseconds",
+				"sourceIPv4Address",
+				"destinationIPv4Address",
+				"sourceTransportPort",
+				"destinationTransportPort",
+				"protocolIdentifier",
+				"silkAppLabel",
+			},
+		},
+		StatusReport: StatusReportConfig{
+			StatusReportURL:         "",
+			StatusReportIntervalSec: 60,
+			UUID:                    "",
+		},
+	}
+}
+
+// DefaultLayer Provenance 中表示字段取默认值
+const DefaultLayer = -1
+
+// FieldPaths 所有可合并字段的路径（与 JSON 字段名一致）
+var FieldPaths = []string{
+	"capture.interface",
+	"capture.ipfix_port",
+	"capture.idle_timeout",
+	"capture.active_timeout",
+	"capture.stats_interval",
+	"capture.enable_applabel",
+	"capture.enable_dpi",
+	"capture.max_payload",
+	"filter.ip_whitelist",
+	"filter.ip_blacklist",
+	"filter.src_ports",
+	"filter.dst_ports",
+	"filter.bpf_filter",
+	"output.fields",
+	"status_report.status_report_url",
+	"status_report.status_report_interval_sec",
+	"status_report.uuid",
+}
+
+// Provenance 字段来源：字段路径 → 提供该值的层级在 Resolve 参数中的下标，DefaultLayer 表示默认值
+type Provenance map[string]int
+
+// Resolve 从默认配置开始依次应用各级覆盖配置（global → cluster → node），nil 表示该级没有配置
+func Resolve(layers ...Overlay) *YafConfig {
+	merged, _ := ResolveWithProvenance(layers...)
+	return merged
+}
+
+// ResolveWithProvenance 与 Resolve 相同，同时返回每个字段的来源层级（显式清除也算作提供了值）
+func ResolveWithProvenance(layers ...Overlay) (*YafConfig, Provenance) {
+	prov := make(Provenance, len(FieldPaths))
+	for _, path := range FieldPaths {
+		prov[path] = DefaultLayer
+	}
+
+	merged := DefaultConfig()
+	for i, layer := range layers {
+		if layer == nil {
+			continue
+		}
+		merged = layer.Apply(merged)
+		for _, path := range layer.Paths() {
+			prov[path] = i
+		}
+	}
+	return merged, prov
+}
@@ -0,0 +1,357 @@
+package yafconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// MetaKey 发布到 ZooKeeper 的配置文档中保存元信息的字段
+	MetaKey = "_meta"
+	// OverlayFormat 覆盖文档的格式版本；没有 _meta 的文档为旧格式（零值表示未设置，布尔值取“或”）
+	OverlayFormat = 2
+)
+
+// Meta 配置文档元信息
+type Meta struct {
+	Format   int          `json:"format"`
+	Version  int          `json:"version,omitempty"`  // 该文档对应的配置版本，用于避免旧版本覆盖新版本
+	Profiles []ProfileRef `json:"profiles,omitempty"` // 配置档层：按顺序合并的配置档及其版本
+	Selector *Selector    `json:"selector,omitempty"` // 标签选择层：选择条件与优先级
+}
+
+// ProfileRef 配置档的某个版本
+type ProfileRef struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+// Overlay 某一级的覆盖配置，采用 JSON Merge Patch（RFC 7396）语义：
+// 字段缺省表示继承上级；给出值表示覆盖（false、0、""、[] 同样生效）；
+// null 表示清除继承的值，恢复为零值（布尔为 false、数值为 0、字符串为空、列表为空）
+type Overlay map[string]interface{}
+
+// ParseOverlay 解析覆盖配置，拒绝未知字段与类型不符的值
+func ParseOverlay(data []byte) (Overlay, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return Overlay{}, nil
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("config must be a JSON object: %w", err)
+	}
+	for section, value := range doc {
+		if value == nil {
+			if !knownSection(section) {
+				return nil, fmt.Errorf("unknown config section '%s'", section)
+			}
+			continue
+		}
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("config section '%s' must be an object or null", section)
+		}
+		for field := range fields {
+			if !knownField(section + "." + field) {
+				return nil, fmt.Errorf("unknown config field '%s.%s'", section, field)
+			}
+		}
+		// 空分组不覆盖任何字段，去掉以保持文档精简
+		if len(fields) == 0 {
+			delete(doc, section)
+		}
+	}
+
+	// 借助结构体解码检查字段类型，null 在这里会被忽略
+	var typed YafConfig
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("invalid config value: %w", err)
+	}
+	return Overlay(doc), nil
+}
+
+// LegacyOverlay 将旧格式的完整配置转换为等价的覆盖配置：只保留非零字段，布尔值只保留 true
+func LegacyOverlay(cfg *YafConfig) Overlay {
+	if cfg == nil {
+		return Overlay{}
+	}
+
+	capture := map[string]interface{}{}
+	if cfg.Capture.Interface != "" {
+		capture["interface"] = cfg.Capture.Interface
+	}
+	if cfg.Capture.IPFIXPort > 0 {
+		capture["ipfix_port"] = cfg.Capture.IPFIXPort
+	}
+	if cfg.Capture.IdleTimeout > 0 {
+		capture["idle_timeout"] = cfg.Capture.IdleTimeout
+	}
+	if cfg.Capture.ActiveTimeout > 0 {
+		capture["active_timeout"] = cfg.Capture.ActiveTimeout
+	}
+	if cfg.Capture.StatsInterval > 0 {
+		capture["stats_interval"] = cfg.Capture.StatsInterval
+	}
+	if cfg.Capture.EnableAppLabel {
+		capture["enable_applabel"] = true
+	}
+	if cfg.Capture.EnableDPI {
+		capture["enable_dpi"] = true
+	}
+	if cfg.Capture.MaxPayload > 0 {
+		capture["max_payload"] = cfg.Capture.MaxPayload
+	}
+
+	filter := map[string]interface{}{}
+	if len(cfg.Filter.IPWhitelist) > 0 {
+		filter["ip_whitelist"] = cfg.Filter.IPWhitelist
+	}
+	if len(cfg.Filter.IPBlacklist) > 0 {
+		filter["ip_blacklist"] = cfg.Filter.IPBlacklist
+	}
+	if len(cfg.Filter.SrcPorts) > 0 {
+		filter["src_ports"] = cfg.Filter.SrcPorts
+	}
+	if len(cfg.Filter.DstPorts) > 0 {
+		filter["dst_ports"] = cfg.Filter.DstPorts
+	}
+	if cfg.Filter.BPFFilter != "" {
+		filter["bpf_filter"] = cfg.Filter.BPFFilter
+	}
+
+	output := map[string]interface{}{}
+	if len(cfg.Output.Fields) > 0 {
+		output["fields"] = cfg.Output.Fields
+	}
+
+	statusReport := map[string]interface{}{}
+	if cfg.StatusReport.StatusReportURL != "" {
+		statusReport["status_report_url"] = cfg.StatusReport.StatusReportURL
+	}
+	if cfg.StatusReport.StatusReportIntervalSec > 0 {
+		statusReport["status_report_interval_sec"] = cfg.StatusReport.StatusReportIntervalSec
+	}
+	if cfg.StatusReport.UUID != "" {
+		statusReport["uuid"] = cfg.StatusReport.UUID
+	}
+
+	overlay := Overlay{}
+	for section, fields := range map[string]map[string]interface{}{
+		"capture":       capture,
+		"filter":        filter,
+		"output":        output,
+		"status_report": statusReport,
+	} {
+		if len(fields) > 0 {
+			overlay[section] = fields
+		}
+	}
+
+	// 统一为解码后的表示（数字为 float64、列表为 []interface{}），便于比较与序列化
+	data, _ := json.Marshal(overlay)
+	normalized := Overlay{}
+	json.Unmarshal(data, &normalized)
+	return normalized
+}
+
+// Has 判断覆盖配置是否涉及某个字段（显式设置或清除），path 形如 capture.enable_dpi
+func (o Overlay) Has(path string) bool {
+	section, field, _ := strings.Cut(path, ".")
+	value, ok := o[section]
+	if !ok {
+		return false
+	}
+	if value == nil {
+		return true
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = fields[field]
+	return ok
+}
+
+// Paths 覆盖配置涉及的字段路径，按 FieldPaths 的顺序返回
+func (o Overlay) Paths() []string {
+	paths := []string{}
+	for _, path := range FieldPaths {
+		if o.Has(path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// WithSections 返回补全了空分组的副本，便于用 JSON Pointer 直接增改分组内的字段；
+// 被整体清除（null）的分组保持不变
+func (o Overlay) WithSections() Overlay {
+	out := make(Overlay, len(o))
+	for key, value := range o {
+		out[key] = value
+	}
+	for _, path := range FieldPaths {
+		section, _, _ := strings.Cut(path, ".")
+		if _, ok := out[section]; !ok {
+			out[section] = map[string]interface{}{}
+		}
+	}
+	return out
+}
+
+// Apply 将覆盖配置应用到 base 上，返回新的配置，base 不会被修改
+func (o Overlay) Apply(base *YafConfig) *YafConfig {
+	if base == nil {
+		base = &YafConfig{}
+	}
+
+	data, _ := json.Marshal(base)
+	var doc map[string]interface{}
+	json.Unmarshal(data, &doc)
+	mergePatch(doc, o)
+
+	data, _ = json.Marshal(doc)
+	merged := &YafConfig{}
+	json.Unmarshal(data, merged)
+
+	// 被清除的列表统一为空列表
+	if merged.Filter.IPWhitelist == nil {
+		merged.Filter.IPWhitelist = []string{}
+	}
+	if merged.Filter.IPBlacklist == nil {
+		merged.Filter.IPBlacklist = []string{}
+	}
+	if merged.Filter.SrcPorts == nil {
+		merged.Filter.SrcPorts = []int{}
+	}
+	if merged.Filter.DstPorts == nil {
+		merged.Filter.DstPorts = []int{}
+	}
+	if merged.Output.Fields == nil {
+		merged.Output.Fields = []string{}
+	}
+	return merged
+}
+
+// Compose 将依次应用的多级覆盖配置合并为一个等价的覆盖配置，
+// 即 Compose(a, b).Apply(cfg) 与 b.Apply(a.Apply(cfg)) 的结果相同
+func Compose(layers ...Overlay) Overlay {
+	out := Overlay{}
+	for _, layer := range layers {
+		for section, value := range layer {
+			fields, ok := value.(map[string]interface{})
+			if !ok {
+				out[section] = nil
+				continue
+			}
+
+			merged := map[string]interface{}{}
+			switch current := out[section].(type) {
+			case map[string]interface{}:
+				for field, v := range current {
+					merged[field] = v
+				}
+			case nil:
+				// 此前整体清除的分组：之后未给出的字段仍然是清除
+				if _, cleared := out[section]; cleared {
+					for _, path := range FieldPaths {
+						if s, field, _ := strings.Cut(path, "."); s == section {
+							merged[field] = nil
+						}
+					}
+				}
+			}
+			for field, v := range fields {
+				merged[field] = v
+			}
+			out[section] = merged
+		}
+	}
+	return out
+}
+
+// EncodeDocument 生成发布到 ZooKeeper 的配置文档（覆盖配置附带 _meta 元信息，Format 总是当前格式）
+func EncodeDocument(o Overlay, meta Meta) ([]byte, error) {
+	doc := make(map[string]interface{}, len(o)+1)
+	for key, value := range o {
+		doc[key] = value
+	}
+	meta.Format = OverlayFormat
+	doc[MetaKey] = meta
+	return json.Marshal(doc)
+}
+
+// DecodeMeta 读取配置文档中的 _meta，旧格式文档或无法解析时返回零值
+func DecodeMeta(data []byte) Meta {
+	var probe struct {
+		Meta Meta `json:"_meta"`
+	}
+	json.Unmarshal(data, &probe)
+	return probe.Meta
+}
+
+// DecodeDocument 解析 ZooKeeper 中的配置文档，旧格式的完整配置按原有语义转换为覆盖配置
+func DecodeDocument(data []byte) (Overlay, error) {
+	var probe struct {
+		Meta *Meta `json:"_meta"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("config must be a JSON object: %w", err)
+	}
+
+	if probe.Meta == nil || probe.Meta.Format < OverlayFormat {
+		var cfg YafConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid legacy config: %w", err)
+		}
+		return LegacyOverlay(&cfg), nil
+	}
+
+	var doc map[string]interface{}
+	json.Unmarshal(data, &doc)
+	delete(doc, MetaKey)
+	stripped, _ := json.Marshal(doc)
+	return ParseOverlay(stripped)
+}
+
+// mergePatch 按 RFC 7396 将 patch 合并到 target
+func mergePatch(target, patch map[string]interface{}) {
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		if sub, ok := value.(map[string]interface{}); ok {
+			child, ok := target[key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+			}
+			mergePatch(child, sub)
+			target[key] = child
+			continue
+		}
+		target[key] = value
+	}
+}
+
+// knownSection 判断是否为已知的配置分组
+func knownSection(section string) bool {
+	for _, path := range FieldPaths {
+		if strings.HasPrefix(path, section+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// knownField 判断是否为已知的配置字段
+func knownField(path string) bool {
+	for _, p := range FieldPaths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
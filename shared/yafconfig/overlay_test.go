@@ -0,0 +1,345 @@
+package yafconfig
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, data string) Overlay {
+	t.Helper()
+	o, err := ParseOverlay([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseOverlay(%s) unexpected error: %v", data, err)
+	}
+	return o
+}
+
+// toJSON 以规范形式（键有序）输出，便于比较覆盖配置
+func toJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal(%v) unexpected error: %v", v, err)
+	}
+	return string(data)
+}
+
+func TestParseOverlay(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		err  string
+	}{
+		{name: "empty", in: "", want: `{}`},
+		{name: "null", in: "null", want: `{}`},
+		{name: "field", in: `{"capture":{"enable_dpi":false}}`, want: `{"capture":{"enable_dpi":false}}`},
+		{name: "null field", in: `{"capture":{"interface":null}}`, want: `{"capture":{"interface":null}}`},
+		{name: "null section", in: `{"filter":null}`, want: `{"filter":null}`},
+		{name: "empty section dropped", in: `{"capture":{},"output":{"fields":[]}}`, want: `{"output":{"fields":[]}}`},
+		{
+			name: "nested sections",
+			in:   `{"capture":{"ipfix_port":18001},"filter":{"src_ports":[53],"bpf_filter":"ip"}}`,
+			want: `{"capture":{"ipfix_port":18001},"filter":{"bpf_filter":"ip","src_ports":[53]}}`,
+		},
+		{name: "not an object", in: `[1]`, err: "config must be a JSON object"},
+		{name: "unknown section", in: `{"bogus":{"x":1}}`, err: "unknown config field 'bogus.x'"},
+		{name: "unknown null section", in: `{"bogus":null}`, err: "unknown config section 'bogus'"},
+		{name: "unknown field", in: `{"capture":{"bogus":1}}`, err: "unknown config field 'capture.bogus'"},
+		{name: "section not an object", in: `{"capture":1}`, err: "config section 'capture' must be an object or null"},
+		{name: "wrong type", in: `{"capture":{"ipfix_port":"x"}}`, err: "invalid config value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOverlay([]byte(tt.in))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ParseOverlay(%s) error = %v, want %q", tt.in, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseOverlay(%s) unexpected error: %v", tt.in, err)
+			}
+			if s := toJSON(t, got); s != tt.want {
+				t.Fatalf("ParseOverlay(%s) = %s, want %s", tt.in, s, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		overlay string
+		check   func(cfg *YafConfig) bool
+	}{
+		{
+			name:    "empty keeps base",
+			overlay: `{}`,
+			check:   func(cfg *YafConfig) bool { return reflect.DeepEqual(cfg, DefaultConfig()) },
+		},
+		{
+			name:    "override keeps siblings",
+			overlay: `{"capture":{"interface":"eth1"}}`,
+			check: func(cfg *YafConfig) bool {
+				return cfg.Capture.Interface == "eth1" && cfg.Capture.IPFIXPort == 18000 && cfg.Capture.EnableAppLabel
+			},
+		},
+		{
+			name:    "false overrides true",
+			overlay: `{"capture":{"enable_applabel":false}}`,
+			check:   func(cfg *YafConfig) bool { return !cfg.Capture.EnableAppLabel },
+		},
+		{
+			name:    "null deletes field",
+			overlay: `{"capture":{"max_payload":null},"filter":{"bpf_filter":null}}`,
+			check: func(cfg *YafConfig) bool {
+				return cfg.Capture.MaxPayload == 0 && cfg.Filter.BPFFilter == "" && cfg.Capture.IPFIXPort == 18000
+			},
+		},
+		{
+			name:    "null deletes list",
+			overlay: `{"output":{"fields":null}}`,
+			check:   func(cfg *YafConfig) bool { return cfg.Output.Fields != nil && len(cfg.Output.Fields) == 0 },
+		},
+		{
+			name:    "null deletes section",
+			overlay: `{"capture":null}`,
+			check: func(cfg *YafConfig) bool {
+				return reflect.DeepEqual(cfg.Capture, CaptureConfig{}) && cfg.Filter.BPFFilter == "ip and not port 22"
+			},
+		},
+		{
+			name:    "nested sections",
+			overlay: `{"filter":{"src_ports":[53,123]},"status_report":{"uuid":"abc"}}`,
+			check: func(cfg *YafConfig) bool {
+				return reflect.DeepEqual(cfg.Filter.SrcPorts, []int{53, 123}) && cfg.StatusReport.UUID == "abc" &&
+					cfg.StatusReport.StatusReportIntervalSec == 60
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := DefaultConfig()
+			got := mustParse(t, tt.overlay).Apply(base)
+			if !tt.check(got) {
+				t.Fatalf("Apply(%s) = %s", tt.overlay, toJSON(t, got))
+			}
+			if !reflect.DeepEqual(base, DefaultConfig()) {
+				t.Fatalf("Apply(%s) modified base", tt.overlay)
+			}
+		})
+	}
+}
+
+func TestCompose(t *testing.T) {
+	tests := []struct {
+		name   string
+		layers []string
+		want   string
+	}{
+		{name: "no layers", want: `{}`},
+		{
+			name:   "later wins",
+			layers: []string{`{"capture":{"interface":"eth1","ipfix_port":18001}}`, `{"capture":{"interface":"eth2"}}`},
+			want:   `{"capture":{"interface":"eth2","ipfix_port":18001}}`,
+		},
+		{
+			name:   "null field then value",
+			layers: []string{`{"capture":{"interface":null}}`, `{"filter":{"bpf_filter":"ip"}}`},
+			want:   `{"capture":{"interface":null},"filter":{"bpf_filter":"ip"}}`,
+		},
+		{
+			name:   "section cleared",
+			layers: []string{`{"output":{"fields":["a"]}}`, `{"output":null}`},
+			want:   `{"output":null}`,
+		},
+		{
+			name:   "cleared section keeps other fields cleared",
+			layers: []string{`{"status_report":null}`, `{"status_report":{"uuid":"abc"}}`},
+			want:   `{"status_report":{"status_report_interval_sec":null,"status_report_url":null,"uuid":"abc"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var layers []Overlay
+			for _, layer := range tt.layers {
+				layers = append(layers, mustParse(t, layer))
+			}
+			got := Compose(layers...)
+			if s := toJSON(t, got); s != tt.want {
+				t.Fatalf("Compose(%v) = %s, want %s", tt.layers, s, tt.want)
+			}
+
+			// Compose(a, b).Apply(cfg) 与依次应用的结果相同
+			stepwise := DefaultConfig()
+			for _, layer := range layers {
+				stepwise = layer.Apply(stepwise)
+			}
+			if composed := got.Apply(DefaultConfig()); !reflect.DeepEqual(composed, stepwise) {
+				t.Fatalf("Compose(%v).Apply = %s, want %s", tt.layers, toJSON(t, composed), toJSON(t, stepwise))
+			}
+		})
+	}
+}
+
+func TestLegacyOverlay(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *YafConfig
+		want string
+	}{
+		{name: "nil", want: `{}`},
+		{name: "zero", cfg: &YafConfig{}, want: `{}`},
+		{
+			name: "only non-zero fields",
+			cfg: &YafConfig{
+				Capture: CaptureConfig{Interface: "eth1", EnableAppLabel: true, EnableDPI: false},
+				Filter:  FilterConfig{SrcPorts: []int{53}, IPWhitelist: []string{}},
+			},
+			want: `{"capture":{"enable_applabel":true,"interface":"eth1"},"filter":{"src_ports":[53]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LegacyOverlay(tt.cfg)
+			if s := toJSON(t, got); s != tt.want {
+				t.Fatalf("LegacyOverlay() = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeDocument(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		err  string
+	}{
+		{
+			name: "legacy full config",
+			in:   `{"capture":{"interface":"eth1","ipfix_port":18000,"enable_dpi":false},"filter":{"ip_whitelist":[]}}`,
+			want: `{"capture":{"interface":"eth1","ipfix_port":18000}}`,
+		},
+		{
+			name: "old format meta",
+			in:   `{"_meta":{"format":1},"capture":{"enable_dpi":false,"max_payload":512}}`,
+			want: `{"capture":{"max_payload":512}}`,
+		},
+		{
+			name: "overlay keeps false and null",
+			in:   `{"_meta":{"format":2,"version":3},"capture":{"enable_dpi":false},"filter":null}`,
+			want: `{"capture":{"enable_dpi":false},"filter":null}`,
+		},
+		{name: "not an object", in: `"x"`, err: "config must be a JSON object"},
+		{name: "invalid legacy value", in: `{"capture":{"ipfix_port":"x"}}`, err: "invalid legacy config"},
+		{name: "unknown field", in: `{"_meta":{"format":2},"capture":{"bogus":1}}`, err: "unknown config field 'capture.bogus'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeDocument([]byte(tt.in))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("DecodeDocument(%s) error = %v, want %q", tt.in, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeDocument(%s) unexpected error: %v", tt.in, err)
+			}
+			if s := toJSON(t, got); s != tt.want {
+				t.Fatalf("DecodeDocument(%s) = %s, want %s", tt.in, s, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeDocumentRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		overlay string
+		meta    Meta
+	}{
+		{name: "empty", overlay: `{}`, meta: Meta{}},
+		{
+			name:    "version and profiles",
+			overlay: `{"capture":{"enable_dpi":false,"interface":null},"output":null}`,
+			meta:    Meta{Format: 1, Version: 7, Profiles: []ProfileRef{{Name: "dpi", Version: 2}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := mustParse(t, tt.overlay)
+			data, err := EncodeDocument(o, tt.meta)
+			if err != nil {
+				t.Fatalf("EncodeDocument() unexpected error: %v", err)
+			}
+
+			want := tt.meta
+			want.Format = OverlayFormat
+			if meta := DecodeMeta(data); !reflect.DeepEqual(meta, want) {
+				t.Fatalf("DecodeMeta(%s) = %+v, want %+v", data, meta, want)
+			}
+			got, err := DecodeDocument(data)
+			if err != nil {
+				t.Fatalf("DecodeDocument(%s) unexpected error: %v", data, err)
+			}
+			if s := toJSON(t, got); s != toJSON(t, o) {
+				t.Fatalf("DecodeDocument(%s) = %s, want %s", data, s, toJSON(t, o))
+			}
+		})
+	}
+}
+
+func TestResolveWithProvenance(t *testing.T) {
+	tests := []struct {
+		name   string
+		layers []string
+		want   map[string]int // 只检查列出的字段
+	}{
+		{
+			name: "defaults",
+			want: map[string]int{"capture.interface": DefaultLayer, "output.fields": DefaultLayer},
+		},
+		{
+			name:   "later layer wins",
+			layers: []string{`{"capture":{"interface":"eth1","ipfix_port":18001}}`, `{"capture":{"interface":"eth2"}}`},
+			want:   map[string]int{"capture.interface": 1, "capture.ipfix_port": 0, "capture.idle_timeout": DefaultLayer},
+		},
+		{
+			name:   "null field counts as set",
+			layers: []string{`{}`, `{"filter":{"bpf_filter":null}}`},
+			want:   map[string]int{"filter.bpf_filter": 1, "filter.src_ports": DefaultLayer},
+		},
+		{
+			name:   "null section covers all fields",
+			layers: []string{`{"status_report":{"uuid":"abc"}}`, `{"status_report":null}`},
+			want: map[string]int{
+				"status_report.uuid":                       1,
+				"status_report.status_report_url":          1,
+				"status_report.status_report_interval_sec": 1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var layers []Overlay
+			for _, layer := range tt.layers {
+				layers = append(layers, mustParse(t, layer))
+			}
+			_, prov := ResolveWithProvenance(layers...)
+			if len(prov) != len(FieldPaths) {
+				t.Fatalf("ResolveWithProvenance() has %d paths, want %d", len(prov), len(FieldPaths))
+			}
+			for path, want := range tt.want {
+				if got := prov[path]; got != want {
+					t.Errorf("ResolveWithProvenance() provenance[%s] = %d, want %d", path, got, want)
+				}
+			}
+		})
+	}
+}
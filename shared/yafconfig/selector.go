@@ -0,0 +1,31 @@
+package yafconfig
+
+// Selector 标签选择层的选择条件：匹配的节点在集群配置之后、节点配置之前按优先级合并该层的覆盖配置。
+// 发布到 ZooKeeper 时写在文档的 _meta 中，Agent 据此判断本节点是否匹配
+type Selector struct {
+	Name     string            `json:"name"`
+	Cluster  string            `json:"cluster,omitempty"` // 为空时对所有集群生效
+	Labels   map[string]string `json:"labels"`            // 节点须带有全部这些标签且值相等
+	Priority int               `json:"priority"`          // 数值大的后合并，覆盖数值小的
+}
+
+// Matches 判断集群 cluster 中带有 labels 的节点是否匹配；没有任何标签条件的选择层不匹配任何节点
+func (s *Selector) Matches(cluster string, labels map[string]string) bool {
+	if len(s.Labels) == 0 || (s.Cluster != "" && s.Cluster != cluster) {
+		return false
+	}
+	for key, value := range s.Labels {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectorLess 匹配的选择层的合并顺序：优先级从低到高，相同时按名称，保证每个节点的合并结果确定
+func SelectorLess(a, b *Selector) bool {
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	return a.Name < b.Name
+}
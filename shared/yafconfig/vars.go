@@ -0,0 +1,176 @@
+package yafconfig
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 字符串字段中可以使用占位符，由 Agent 在生成配置文件时替换为本节点的值：
+//
+//	${node.id}      节点 ID
+//	${cluster}      集群名称
+//	${env.NAME}     Agent 的环境变量 NAME，只能读取以 YAF_VAR_ 开头的变量
+//	${label.KEY}    节点标签 KEY（节点清单中的标签与 YAF_NODE_LABELS）
+//
+// ${name:-default} 在变量未定义时使用 default；没有默认值的未定义变量使配置应用失败。
+// $${ 表示字面的 ${，不展开
+
+// EnvPrefix ${env.NAME} 可以读取的环境变量前缀，避免配置读出 Agent 的其他环境变量（如凭据）
+const EnvPrefix = "YAF_VAR_"
+
+var envNamePattern = regexp.MustCompile(`^` + EnvPrefix + `[A-Za-z0-9_]+$`)
+
+// Vars 展开占位符使用的节点变量
+type Vars struct {
+	Cluster string
+	NodeID  string
+	Labels  map[string]string
+	// LookupEnv 读取环境变量，为 nil 时使用 os.LookupEnv
+	LookupEnv func(string) (string, bool)
+}
+
+// HasPlaceholder 判断字符串中是否含有占位符
+func HasPlaceholder(s string) bool {
+	return strings.Contains(s, "${")
+}
+
+// Expand 展开字符串中的占位符，变量名不合法（见 CheckPlaceholders）时返回错误
+func (v *Vars) Expand(s string) (string, error) {
+	return scanPlaceholders(s, func(name, def string, hasDef bool) (string, error) {
+		if err := checkVariable(name); err != nil {
+			return "", err
+		}
+		value, ok := v.lookup(name)
+		if ok {
+			return value, nil
+		}
+		if hasDef {
+			return def, nil
+		}
+		return "", fmt.Errorf("undefined variable ${%s}", name)
+	})
+}
+
+// ExpandConfig 返回展开了所有字符串字段中占位符的副本，cfg 不会被修改
+func (v *Vars) ExpandConfig(cfg *YafConfig) (*YafConfig, error) {
+	out := *cfg
+	out.Filter.IPWhitelist = append([]string(nil), cfg.Filter.IPWhitelist...)
+	out.Filter.IPBlacklist = append([]string(nil), cfg.Filter.IPBlacklist...)
+	for _, field := range stringFields(&out) {
+		expanded, err := v.Expand(*field.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.path, err)
+		}
+		*field.value = expanded
+	}
+	return &out, nil
+}
+
+// lookup 读取变量的值，变量未定义时返回 false；name 应已通过 checkVariable 检查
+func (v *Vars) lookup(name string) (string, bool) {
+	switch {
+	case name == "node.id":
+		return v.NodeID, true
+	case name == "cluster":
+		return v.Cluster, true
+	case strings.HasPrefix(name, "env."):
+		lookupEnv := v.LookupEnv
+		if lookupEnv == nil {
+			lookupEnv = os.LookupEnv
+		}
+		return lookupEnv(strings.TrimPrefix(name, "env."))
+	case strings.HasPrefix(name, "label."):
+		value, ok := v.Labels[strings.TrimPrefix(name, "label.")]
+		return value, ok
+	}
+	return "", false
+}
+
+// CheckPlaceholders 检查配置所有字符串字段中占位符的写法与变量名（环境变量须以 EnvPrefix 开头），不检查变量是否有定义
+func CheckPlaceholders(cfg *YafConfig) error {
+	for _, field := range stringFields(cfg) {
+		_, err := scanPlaceholders(*field.value, func(name, def string, hasDef bool) (string, error) {
+			return "", checkVariable(name)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", field.path, err)
+		}
+	}
+	return nil
+}
+
+// checkVariable 检查占位符中的变量名
+func checkVariable(name string) error {
+	switch {
+	case name == "node.id", name == "cluster":
+		return nil
+	case strings.HasPrefix(name, "env."):
+		if envNamePattern.MatchString(strings.TrimPrefix(name, "env.")) {
+			return nil
+		}
+		return fmt.Errorf("invalid environment variable name in ${%s}, only %s* variables are allowed", name, EnvPrefix)
+	case strings.HasPrefix(name, "label."):
+		if strings.TrimPrefix(name, "label.") != "" {
+			return nil
+		}
+		return fmt.Errorf("missing label name in ${%s}", name)
+	}
+	return fmt.Errorf("unknown variable ${%s}, expected node.id, cluster, env.%sNAME or label.KEY", name, EnvPrefix)
+}
+
+// scanPlaceholders 依次将 s 中的占位符替换为 replace 的返回值
+func scanPlaceholders(s string, replace func(name, def string, hasDef bool) (string, error)) (string, error) {
+	var b strings.Builder
+	rest := s
+	for {
+		start := strings.Index(rest, "${")
+		if start < 0 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		if start > 0 && rest[start-1] == '$' {
+			b.WriteString(rest[:start-1])
+			b.WriteString("${")
+			rest = rest[start+2:]
+			continue
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder in %s", strconv.Quote(s))
+		}
+		name, def, hasDef := strings.Cut(rest[start+2:start+end], ":-")
+		value, err := replace(strings.TrimSpace(name), def, hasDef)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(rest[:start])
+		b.WriteString(value)
+		rest = rest[start+end+1:]
+	}
+}
+
+// stringField 配置中的一个字符串字段（或字符串列表中的一项）
+type stringField struct {
+	path  string
+	value *string
+}
+
+// stringFields 配置中可以使用占位符的字符串字段。output.fields 只能是受支持的字段名，不展开占位符
+func stringFields(cfg *YafConfig) []stringField {
+	fields := []stringField{
+		{"capture.interface", &cfg.Capture.Interface},
+		{"filter.bpf_filter", &cfg.Filter.BPFFilter},
+		{"status_report.status_report_url", &cfg.StatusReport.StatusReportURL},
+		{"status_report.uuid", &cfg.StatusReport.UUID},
+	}
+	for i := range cfg.Filter.IPWhitelist {
+		fields = append(fields, stringField{fmt.Sprintf("filter.ip_whitelist[%d]", i), &cfg.Filter.IPWhitelist[i]})
+	}
+	for i := range cfg.Filter.IPBlacklist {
+		fields = append(fields, stringField{fmt.Sprintf("filter.ip_blacklist[%d]", i), &cfg.Filter.IPBlacklist[i]})
+	}
+	return fields
+}
@@ -0,0 +1,127 @@
+package yafconfig
+
+import (
+	"strings"
+	"testing"
+)
+
+func testVars() *Vars {
+	env := map[string]string{"YAF_VAR_IF": "eth1", "HOME": "/root"}
+	return &Vars{
+		Cluster: "bj-dc1",
+		NodeID:  "node-01",
+		Labels:  map[string]string{"nic": "x710", "empty": ""},
+		LookupEnv: func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		},
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		err  string
+	}{
+		{name: "no placeholder", in: "eth0", want: "eth0"},
+		{name: "node and cluster", in: "${cluster}-${node.id}", want: "bj-dc1-node-01"},
+		{name: "spaces around name", in: "${ node.id }", want: "node-01"},
+		{name: "label", in: "${label.nic}", want: "x710"},
+		{name: "empty label is defined", in: "${label.empty:-x}", want: ""},
+		{name: "env", in: "${env.YAF_VAR_IF}", want: "eth1"},
+		{name: "default unused", in: "${env.YAF_VAR_IF:-eth0}", want: "eth1"},
+		{name: "default used", in: "${env.YAF_VAR_MISSING:-eth0}", want: "eth0"},
+		{name: "empty default", in: "[${label.missing:-}]", want: "[]"},
+		{name: "default containing colon", in: "${label.missing:-a:b}", want: "a:b"},
+		{name: "escaped", in: "$${node.id}", want: "${node.id}"},
+		{name: "escaped then expanded", in: "$${x} ${node.id}", want: "${x} node-01"},
+		{name: "lone dollar", in: "a$b", want: "a$b"},
+		{name: "undefined without default", in: "${label.missing}", err: "undefined variable ${label.missing}"},
+		{name: "unterminated", in: "eth${node.id", err: "unterminated placeholder"},
+		{name: "env outside prefix", in: "${env.HOME}", err: "only YAF_VAR_* variables are allowed"},
+		{name: "env outside prefix with default", in: "${env.HOME:-x}", err: "only YAF_VAR_* variables are allowed"},
+		{name: "unknown namespace", in: "${secret.token:-x}", err: "unknown variable ${secret.token}"},
+	}
+	vars := testVars()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := vars.Expand(tt.in)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expand(%q) error = %v, want %q", tt.in, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expand(%q) unexpected error: %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPlaceholders(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{name: "plain", value: "eth0"},
+		{name: "known variables", value: "${cluster}/${node.id}/${label.nic}/${env.YAF_VAR_IF:-eth0}"},
+		{name: "undefined is not checked", value: "${label.anything}"},
+		{name: "escaped unknown", value: "$${whatever}"},
+		{name: "unterminated", value: "${node.id", err: "unterminated placeholder"},
+		{name: "unknown namespace", value: "${host.name}", err: "unknown variable ${host.name}"},
+		{name: "bare env", value: "${env}", err: "unknown variable ${env}"},
+		{name: "env outside prefix", value: "${env.PATH}", err: "only YAF_VAR_* variables are allowed"},
+		{name: "env prefix only", value: "${env.YAF_VAR_}", err: "invalid environment variable name"},
+		{name: "env invalid characters", value: "${env.YAF_VAR_A-B}", err: "invalid environment variable name"},
+		{name: "missing label name", value: "${label.}", err: "missing label name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Capture.Interface = tt.value
+			err := CheckPlaceholders(cfg)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("CheckPlaceholders(%q) unexpected error: %v", tt.value, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("CheckPlaceholders(%q) error = %v, want %q", tt.value, err, tt.err)
+			}
+			if !strings.HasPrefix(err.Error(), "capture.interface: ") {
+				t.Errorf("error %q does not name the field", err)
+			}
+		})
+	}
+}
+
+func TestExpandConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Capture.Interface = "${env.YAF_VAR_IF}"
+	cfg.Filter.IPWhitelist = []string{"10.0.0.0/8", "${label.missing:-192.168.0.0/16}"}
+	cfg.StatusReport.UUID = "${cluster}-${node.id}"
+
+	out, err := testVars().ExpandConfig(cfg)
+	if err != nil {
+		t.Fatalf("ExpandConfig unexpected error: %v", err)
+	}
+	if out.Capture.Interface != "eth1" || out.StatusReport.UUID != "bj-dc1-node-01" || out.Filter.IPWhitelist[1] != "192.168.0.0/16" {
+		t.Errorf("ExpandConfig = %+v", out)
+	}
+	if cfg.Capture.Interface != "${env.YAF_VAR_IF}" || cfg.Filter.IPWhitelist[1] != "${label.missing:-192.168.0.0/16}" {
+		t.Errorf("ExpandConfig modified its input: %+v", cfg)
+	}
+
+	cfg.Filter.IPBlacklist = []string{"${label.missing}"}
+	if _, err := testVars().ExpandConfig(cfg); err == nil || !strings.HasPrefix(err.Error(), "filter.ip_blacklist[0]: ") {
+		t.Errorf("ExpandConfig error = %v, want it to name filter.ip_blacklist[0]", err)
+	}
+}